@@ -0,0 +1,168 @@
+package main
+
+// Harts execute one instruction at a time in program order, so the
+// ordering required by aq and rl bits always holds and they are ignored.
+
+// lr loads a value and reserves its address
+func (cpu *Cpu) lr(inst InstWord, size uint8) {
+	addr := cpu.truncAddr(cpu.readReg(inst.rs1()))
+	if addr%uint64(size/8) != 0 {
+		cpu.raise(LOAD_ADDRESS_MISALIGNED, addr)
+		return
+	}
+	paddr, ok := cpu.translate(addr, ACCESS_LOAD)
+	if !ok {
+		return
+	}
+	data, ok := cpu.readPhys(paddr, size, ACCESS_LOAD)
+	if !ok {
+		cpu.raise(LOAD_ACCESS_FAULT, addr)
+		return
+	}
+	cpu.bus.reserve(cpu.hartid, paddr)
+	cpu.writeReg(inst.rd(), uint64(signExtend(int64(data), uint(size))))
+}
+
+// sc stores a value only if the reservation is still held,
+// rd is 0 on success and 1 on failure
+func (cpu *Cpu) sc(inst InstWord, size uint8) {
+	addr := cpu.truncAddr(cpu.readReg(inst.rs1()))
+	paddr, ok := cpu.amoAddress(addr, size)
+	if !ok {
+		return
+	}
+	if !cpu.bus.release(cpu.hartid, paddr) {
+		cpu.writeReg(inst.rd(), 1)
+		return
+	}
+	if !cpu.writePhys(paddr, cpu.readReg(inst.rs2()), size, ACCESS_STORE) {
+		cpu.raise(STORE_AMO_ACCESS_FAULT, addr)
+		return
+	}
+	cpu.writeReg(inst.rd(), 0)
+}
+
+// amoAddress translates the address of sc or amo, which require
+// natural alignment and both read and write permissions
+func (cpu *Cpu) amoAddress(addr uint64, size uint8) (uint64, bool) {
+	if addr%uint64(size/8) != 0 {
+		cpu.raise(STORE_AMO_ADDRESS_MISALIGNED, addr)
+		return 0, false
+	}
+	paddr, ok := cpu.translate(addr, ACCESS_STORE)
+	if !ok {
+		return 0, false
+	}
+	priv := cpu.effectivePrivilege(ACCESS_STORE)
+	if !cpu.pmp.check(paddr, size, ACCESS_LOAD, priv) || !cpu.pmp.check(paddr, size, ACCESS_STORE, priv) {
+		cpu.raise(STORE_AMO_ACCESS_FAULT, addr)
+		return 0, false
+	}
+	return paddr, true
+}
+
+// amo atomically replaces memory value a with op(a, rs2) and returns a in rd.
+// Word operands are sign-extended before op is applied.
+func (cpu *Cpu) amo(inst InstWord, size uint8, op func(a, b uint64) uint64) {
+	addr := cpu.truncAddr(cpu.readReg(inst.rs1()))
+	paddr, ok := cpu.amoAddress(addr, size)
+	if !ok {
+		return
+	}
+	data, ok := cpu.readPhys(paddr, size, ACCESS_LOAD)
+	if !ok {
+		cpu.raise(STORE_AMO_ACCESS_FAULT, addr)
+		return
+	}
+	a := uint64(signExtend(int64(data), uint(size)))
+	b := uint64(signExtend(int64(cpu.readReg(inst.rs2())), uint(size)))
+	if !cpu.writePhys(paddr, op(a, b), size, ACCESS_STORE) {
+		cpu.raise(STORE_AMO_ACCESS_FAULT, addr)
+		return
+	}
+	cpu.writeReg(inst.rd(), a)
+}
+
+func amoSwap(a, b uint64) uint64 { return b }
+func amoAdd(a, b uint64) uint64  { return a + b }
+func amoXor(a, b uint64) uint64  { return a ^ b }
+func amoAnd(a, b uint64) uint64  { return a & b }
+func amoOr(a, b uint64) uint64   { return a | b }
+
+func amoMin(a, b uint64) uint64 {
+	if int64(a) < int64(b) {
+		return a
+	}
+	return b
+}
+
+func amoMax(a, b uint64) uint64 {
+	if int64(a) > int64(b) {
+		return a
+	}
+	return b
+}
+
+// знакорасширенные слова сравниваются как беззнаковые так же, как uint32
+func amoMinu(a, b uint64) uint64 { return min(a, b) }
+func amoMaxu(a, b uint64) uint64 { return max(a, b) }
+
+func (cpu *Cpu) lrW(inst InstWord)      { cpu.lr(inst, WORD) }
+func (cpu *Cpu) scW(inst InstWord)      { cpu.sc(inst, WORD) }
+func (cpu *Cpu) amoswapW(inst InstWord) { cpu.amo(inst, WORD, amoSwap) }
+func (cpu *Cpu) amoaddW(inst InstWord)  { cpu.amo(inst, WORD, amoAdd) }
+func (cpu *Cpu) amoxorW(inst InstWord)  { cpu.amo(inst, WORD, amoXor) }
+func (cpu *Cpu) amoandW(inst InstWord)  { cpu.amo(inst, WORD, amoAnd) }
+func (cpu *Cpu) amoorW(inst InstWord)   { cpu.amo(inst, WORD, amoOr) }
+func (cpu *Cpu) amominW(inst InstWord)  { cpu.amo(inst, WORD, amoMin) }
+func (cpu *Cpu) amomaxW(inst InstWord)  { cpu.amo(inst, WORD, amoMax) }
+func (cpu *Cpu) amominuW(inst InstWord) { cpu.amo(inst, WORD, amoMinu) }
+func (cpu *Cpu) amomaxuW(inst InstWord) { cpu.amo(inst, WORD, amoMaxu) }
+
+func (cpu *Cpu) lrD(inst InstWord)      { cpu.lr(inst, DOUBLEWORD) }
+func (cpu *Cpu) scD(inst InstWord)      { cpu.sc(inst, DOUBLEWORD) }
+func (cpu *Cpu) amoswapD(inst InstWord) { cpu.amo(inst, DOUBLEWORD, amoSwap) }
+func (cpu *Cpu) amoaddD(inst InstWord)  { cpu.amo(inst, DOUBLEWORD, amoAdd) }
+func (cpu *Cpu) amoxorD(inst InstWord)  { cpu.amo(inst, DOUBLEWORD, amoXor) }
+func (cpu *Cpu) amoandD(inst InstWord)  { cpu.amo(inst, DOUBLEWORD, amoAnd) }
+func (cpu *Cpu) amoorD(inst InstWord)   { cpu.amo(inst, DOUBLEWORD, amoOr) }
+func (cpu *Cpu) amominD(inst InstWord)  { cpu.amo(inst, DOUBLEWORD, amoMin) }
+func (cpu *Cpu) amomaxD(inst InstWord)  { cpu.amo(inst, DOUBLEWORD, amoMax) }
+func (cpu *Cpu) amominuD(inst InstWord) { cpu.amo(inst, DOUBLEWORD, amoMinu) }
+func (cpu *Cpu) amomaxuD(inst InstWord) { cpu.amo(inst, DOUBLEWORD, amoMaxu) }
+
+// WRS_STO_TIMEOUT is the number of steps wrs.sto may stall the hart
+const WRS_STO_TIMEOUT uint64 = 64
+
+// wrs stalls the hart while it holds a reservation, until another hart
+// stores to the reservation set, an interrupt becomes pending or,
+// for wrs.sto, the timeout expires
+func (cpu *Cpu) wrs(inst InstWord, timeout uint64) {
+	// при TW=1 ограниченное время ожидания wrs.nto равно нулю
+	if timeout == 0 && !cpu.waitAllowed(inst, false) {
+		return
+	}
+	if cpu.bus.reserved(cpu.hartid) {
+		cpu.waiting = true
+		cpu.wrsWaiting = true
+		cpu.wrsTimeout = timeout
+	}
+}
+
+func (cpu *Cpu) wrsNto(inst InstWord) { cpu.wrs(inst, 0) }
+func (cpu *Cpu) wrsSto(inst InstWord) { cpu.wrs(inst, WRS_STO_TIMEOUT) }
+
+// wrsWoken checks the wake-up conditions of wrs besides interrupts
+func (cpu *Cpu) wrsWoken() bool {
+	if !cpu.wrsWaiting {
+		return false
+	}
+	if !cpu.bus.reserved(cpu.hartid) {
+		return true
+	}
+	if cpu.wrsTimeout != 0 {
+		cpu.wrsTimeout--
+		return cpu.wrsTimeout == 0
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package main
+
+import "testing"
+
+// amoInst encodes an AMO instruction, funct3 selects the width
+func amoInst(funct5, rs2, rs1, funct3, rd uint32) uint32 {
+	return funct5<<27 | rs2<<20 | rs1<<15 | funct3<<12 | rd<<7 | 0x2f
+}
+
+func TestAmo(t *testing.T) {
+	const addr = DRAM_BASE + 0x1000
+	tests := []struct {
+		name   string
+		funct5 uint32
+		funct3 uint32
+		mem    uint64
+		rs2    uint64
+		rd     uint64
+		result uint64
+	}{
+		{"amoswap.w", 0x01, 2, 0x11111111, 0x80000000, 0x11111111, 0x80000000},
+		{"amoadd.w", 0x00, 2, 0xffffffff, 2, 0xffffffffffffffff, 1},
+		{"amoadd.d", 0x00, 3, 0xffffffff, 1, 0xffffffff, 0x100000000},
+		{"amoxor.d", 0x04, 3, 0xff00, 0x0ff0, 0xff00, 0xf0f0},
+		{"amoand.w", 0x0c, 2, 0xf0f0f0f0, 0xff00ff00, 0xfffffffff0f0f0f0, 0xf000f000},
+		{"amoor.d", 0x08, 3, 0x1, 0x8000000000000000, 0x1, 0x8000000000000001},
+		{"amomin.w", 0x10, 2, 0x80000000, 1, 0xffffffff80000000, 0x80000000},
+		{"amomax.d", 0x14, 3, 0xffffffffffffffff, 1, 0xffffffffffffffff, 1},
+		{"amominu.w", 0x18, 2, 0x80000000, 1, 0xffffffff80000000, 1},
+		{"amomaxu.d", 0x1c, 3, 0xffffffffffffffff, 1, 0xffffffffffffffff, 0xffffffffffffffff},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		cpu.reset()
+		cpu.bus.Write(addr, test.mem, DOUBLEWORD)
+		cpu.writeReg(1, addr)
+		cpu.writeReg(2, test.rs2)
+		// aq и rl не влияют на результат
+		cpu.ExecuteInst(amoInst(test.funct5, 2, 1, test.funct3, 3) | 3<<25)
+		if got := cpu.readReg(3); got != test.rd {
+			t.Fatalf("%s: rd=%#x, want %#x", test.name, got, test.rd)
+		}
+		size := WORD
+		if test.funct3 == 3 {
+			size = DOUBLEWORD
+		}
+		if got := memRead(cpu, addr, size); got != test.result {
+			t.Fatalf("%s: memory=%#x, want %#x", test.name, got, test.result)
+		}
+	}
+}
+
+func TestLrSc(t *testing.T) {
+	const addr = DRAM_BASE + 0x1000
+	lrW := amoInst(0x02, 0, 1, 2, 3)
+	scW := amoInst(0x03, 2, 1, 2, 4)
+
+	bus := NewBus()
+	bus.Map("dram", DRAM_BASE, MEMORY_SIZE, InitDram(MEMORY_SIZE))
+	hart0, hart1 := NewHart(bus, 0), NewHart(bus, 1)
+	for _, cpu := range []*Cpu{hart0, hart1} {
+		cpu.writeReg(1, addr)
+		cpu.writeReg(2, 42)
+	}
+
+	sc := func(name string, want uint64) {
+		t.Helper()
+		hart0.ExecuteInst(scW)
+		if got := hart0.readReg(4); got != want {
+			t.Fatalf("%s: sc.w rd=%d, want %d", name, got, want)
+		}
+	}
+
+	sc("no reservation", 1)
+
+	hart0.ExecuteInst(lrW)
+	sc("reserved", 0)
+	if got := memRead(hart0, addr, WORD); got != 42 {
+		t.Fatalf("sc.w stored %d, want 42", got)
+	}
+	sc("reservation consumed", 1)
+
+	hart0.ExecuteInst(lrW)
+	hart0.ExecuteInst(0x0020a223) // sw x2, 4(x1): собственная запись не снимает резервирование
+	sc("own store", 0)
+
+	hart0.ExecuteInst(lrW)
+	hart1.ExecuteInst(0x0020a223) // sw x2, 4(x1) с другого харта
+	sc("other hart store", 1)
+
+	hart0.ExecuteInst(lrW)
+	hart1.writeReg(5, addr+RESERVATION_GRANULE)
+	hart1.ExecuteInst(0x0022a023) // sw x2, 0(x5) в другой блок
+	sc("store outside reservation set", 0)
+
+	hart0.ExecuteInst(lrW)
+	hart1.ExecuteInst(amoInst(0x00, 2, 1, 2, 0)) // amoadd.w
+	sc("other hart amo", 1)
+
+	hart0.ExecuteInst(lrW)
+	hart0.writeReg(1, addr+8)
+	sc("different address", 1)
+}
+
+func TestAmoMisaligned(t *testing.T) {
+	tests := []struct {
+		name  string
+		inst  uint32
+		cause ExceptionCause
+	}{
+		{"lr.d", amoInst(0x02, 0, 1, 3, 3), LOAD_ADDRESS_MISALIGNED},
+		{"sc.w", amoInst(0x03, 2, 1, 2, 3), STORE_AMO_ADDRESS_MISALIGNED},
+		{"amoadd.w", amoInst(0x00, 2, 1, 2, 3), STORE_AMO_ADDRESS_MISALIGNED},
+		{"amoswap.d", amoInst(0x01, 2, 1, 3, 3), STORE_AMO_ADDRESS_MISALIGNED},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		cpu.reset()
+		cpu.csr[MTVEC] = 0x80001000
+		cpu.writeReg(1, DRAM_BASE+0x1002)
+		cpu.ExecuteInst(test.inst)
+		if cpu.csr[MCAUSE] != uint64(test.cause) || cpu.csr[MTVAL] != DRAM_BASE+0x1002 {
+			t.Fatalf("%s: mcause=%d mtval=%#x, want mcause=%d mtval=%#x",
+				test.name, cpu.csr[MCAUSE], cpu.csr[MTVAL], test.cause, DRAM_BASE+0x1002)
+		}
+		if cpu.readReg(3) != 0 {
+			t.Fatalf("%s: rd written on fault", test.name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+// rInst encodes an R-type instruction with rs1=x1, rs2=x2, rd=x3
+func rInst(funct7, funct3, opcode uint32) uint32 {
+	return funct7<<25 | 2<<20 | 1<<15 | funct3<<12 | 3<<7 | opcode
+}
+
+// unaryInst encodes an I-type instruction with rs1=x1, rd=x3 and
+// the operation selected by the immediate field
+func unaryInst(imm, funct3, opcode uint32) uint32 {
+	return imm<<20 | 1<<15 | funct3<<12 | 3<<7 | opcode
+}
+
+func TestBitmanip(t *testing.T) {
+	tests := []struct {
+		name string
+		inst uint32
+		rs1  uint64
+		rs2  uint64
+		rd   uint64
+	}{
+		// Zba
+		{"sh1add", rInst(0x10, 2, 0x33), 3, 10, 16},
+		{"sh2add", rInst(0x10, 4, 0x33), 3, 10, 22},
+		{"sh3add", rInst(0x10, 6, 0x33), 3, 10, 34},
+		{"add.uw", rInst(0x04, 0, 0x3b), 0xffffffff80000000, 1, 0x80000001},
+		{"sh1add.uw", rInst(0x10, 2, 0x3b), 0xffffffff80000000, 1, 0x100000001},
+		{"sh3add.uw", rInst(0x10, 6, 0x3b), 0x1_00000002, 1, 17},
+		{"slli.uw", unaryInst(0x080|36, 1, 0x1b), 0xffffffff00000001, 0, 0x1000000000},
+		// Zbb
+		{"andn", rInst(0x20, 7, 0x33), 0xff, 0x0f, 0xf0},
+		{"orn", rInst(0x20, 6, 0x33), 0, 0xffffffffffffff00, 0xff},
+		{"xnor", rInst(0x20, 4, 0x33), 0xf0, 0xff, 0xfffffffffffffff0},
+		{"clz", unaryInst(0x600, 1, 0x13), 0x00ff000000000000, 0, 8},
+		{"clz zero", unaryInst(0x600, 1, 0x13), 0, 0, 64},
+		{"clzw", unaryInst(0x600, 1, 0x1b), 0xffffffff00001000, 0, 19},
+		{"ctz", unaryInst(0x601, 1, 0x13), 0x8000000000000000, 0, 63},
+		{"ctzw", unaryInst(0x601, 1, 0x1b), 0xffffffff00000000, 0, 32},
+		{"cpop", unaryInst(0x602, 1, 0x13), 0xf0f0, 0, 8},
+		{"cpopw", unaryInst(0x602, 1, 0x1b), 0xffffffff00000007, 0, 3},
+		{"max", rInst(0x05, 6, 0x33), 0xffffffffffffffff, 1, 1},
+		{"maxu", rInst(0x05, 7, 0x33), 0xffffffffffffffff, 1, 0xffffffffffffffff},
+		{"min", rInst(0x05, 4, 0x33), 0xffffffffffffffff, 1, 0xffffffffffffffff},
+		{"minu", rInst(0x05, 5, 0x33), 0xffffffffffffffff, 1, 1},
+		{"sext.b", unaryInst(0x604, 1, 0x13), 0x80, 0, 0xffffffffffffff80},
+		{"sext.h", unaryInst(0x605, 1, 0x13), 0x1234, 0, 0x1234},
+		{"zext.h", rInst(0x04, 4, 0x3b) &^ (0x1f << 20), 0xffffffffffff8000, 0, 0x8000},
+		{"rol", rInst(0x30, 1, 0x33), 0x8000000000000001, 68, 0x18},
+		{"ror", rInst(0x30, 5, 0x33), 0x1, 1, 0x8000000000000000},
+		{"rori", unaryInst(0x600|8, 5, 0x13), 0xff, 0, 0xff00000000000000},
+		{"rolw", rInst(0x30, 1, 0x3b), 0x80000001, 1, 3},
+		{"rorw", rInst(0x30, 5, 0x3b), 0x2, 2, 0xffffffff80000000},
+		{"roriw", unaryInst(0x600|4, 5, 0x1b), 0x10, 0, 1},
+		{"orc.b", unaryInst(0x287, 5, 0x13), 0x0100200000000301, 0, 0xff00ff000000ffff},
+		{"rev8", unaryInst(0x6b8, 5, 0x13), 0x0102030405060708, 0, 0x0807060504030201},
+		// Zbc
+		{"clmul", rInst(0x05, 1, 0x33), 0x3, 0x3, 0x5},
+		{"clmulh", rInst(0x05, 3, 0x33), 0x8000000000000000, 0x6, 0x3},
+		{"clmulr", rInst(0x05, 2, 0x33), 0x8000000000000000, 0x6, 0x6},
+		// Zbs
+		{"bclr", rInst(0x24, 1, 0x33), 0xff, 67, 0xf7},
+		{"bclri", unaryInst(0x480|63, 1, 0x13), 0xffffffffffffffff, 0, 0x7fffffffffffffff},
+		{"bext", rInst(0x24, 5, 0x33), 0x100, 8, 1},
+		{"bexti", unaryInst(0x480|40, 5, 0x13), 0x10000000000, 0, 1},
+		{"binv", rInst(0x34, 1, 0x33), 0x1, 0, 0},
+		{"binvi", unaryInst(0x680|33, 1, 0x13), 0, 0, 0x200000000},
+		{"bset", rInst(0x14, 1, 0x33), 0, 63, 0x8000000000000000},
+		{"bseti", unaryInst(0x280|5, 1, 0x13), 0, 0, 0x20},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		cpu.writeReg(1, test.rs1)
+		cpu.writeReg(2, test.rs2)
+		cpu.ExecuteInst(test.inst)
+		if cpu.exception != nil {
+			t.Fatalf("%s: unexpected %v", test.name, cpu.exception)
+		}
+		if got := cpu.readReg(3); got != test.rd {
+			t.Fatalf("%s: rd=%#x, want %#x", test.name, got, test.rd)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnmapped = errors.New("Address is not mapped to any device")
+var ErrAccessSize = errors.New("Unsupported access size")
+var ErrReadOnly = errors.New("Device is read-only")
+
+// Device is a memory-mapped region of the physical address space.
+// addr is an offset from the region base, size is 8, 16, 32 or 64 bits.
+type Device interface {
+	Read(addr uint64, size uint8) (uint64, error)
+	Write(addr uint64, value uint64, size uint8) error
+}
+
+type Region struct {
+	name   string
+	base   uint64
+	size   uint64
+	device Device
+}
+
+func (r *Region) contains(addr uint64, n uint64) bool {
+	return addr >= r.base && addr-r.base+n <= r.size
+}
+
+// Bus routes physical addresses to the registered regions
+type Bus struct {
+	regions      []Region
+	last         int               // регион последнего обращения
+	reservations map[uint64]uint64 // адреса LR по номеру харта
+}
+
+func NewBus() *Bus {
+	return &Bus{reservations: map[uint64]uint64{}}
+}
+
+// Map registers device at [base, base+size)
+func (b *Bus) Map(name string, base, size uint64, device Device) error {
+	if size == 0 || base+size < base {
+		return fmt.Errorf("Region %s: wrong size %#x at %#x", name, size, base)
+	}
+	for _, r := range b.regions {
+		if base < r.base+r.size && r.base < base+size {
+			return fmt.Errorf("Region %s [%#x, %#x) overlaps %s", name, base, base+size, r.name)
+		}
+	}
+	b.regions = append(b.regions, Region{name: name, base: base, size: size, device: device})
+	return nil
+}
+
+// find returns region that holds all n bytes starting at addr
+func (b *Bus) find(addr uint64, n uint64) *Region {
+	if b.last < len(b.regions) && b.regions[b.last].contains(addr, n) {
+		return &b.regions[b.last]
+	}
+	for i := range b.regions {
+		if b.regions[i].contains(addr, n) {
+			b.last = i
+			return &b.regions[i]
+		}
+	}
+	return nil
+}
+
+func (b *Bus) Read(addr uint64, size uint8) (uint64, error) {
+	r := b.find(addr, uint64(size/8))
+	if r == nil {
+		return 0, ErrUnmapped
+	}
+	return r.device.Read(addr-r.base, size)
+}
+
+func (b *Bus) Write(addr uint64, value uint64, size uint8) error {
+	r := b.find(addr, uint64(size/8))
+	if r == nil {
+		return ErrUnmapped
+	}
+	return r.device.Write(addr-r.base, value, size)
+}
+
+// RESERVATION_GRANULE is the size of the aligned block an LR reserves,
+// a store anywhere in it by another hart breaks the reservation
+const RESERVATION_GRANULE uint64 = 64
+
+func (b *Bus) reserve(hart uint64, addr uint64) {
+	b.reservations[hart] = addr
+}
+
+// reserved reports whether hart holds a reservation
+func (b *Bus) reserved(hart uint64) bool {
+	_, ok := b.reservations[hart]
+	return ok
+}
+
+// release drops the reservation of hart, true is returned if it was held on addr
+func (b *Bus) release(hart uint64, addr uint64) bool {
+	reserved, ok := b.reservations[hart]
+	delete(b.reservations, hart)
+	return ok && reserved == addr
+}
+
+// invalidate breaks reservations of other harts overlapping a store
+func (b *Bus) invalidate(hart uint64, addr uint64, size uint8) {
+	first := addr &^ (RESERVATION_GRANULE - 1)
+	last := (addr + uint64(size/8) - 1) &^ (RESERVATION_GRANULE - 1)
+	for h, reserved := range b.reservations {
+		if g := reserved &^ (RESERVATION_GRANULE - 1); h != hart && g >= first && g <= last {
+			delete(b.reservations, h)
+		}
+	}
+}
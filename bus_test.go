@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// memRead reads guest physical memory bypassing the CPU
+func memRead(cpu *Cpu, addr uint64, size uint8) uint64 {
+	data, err := cpu.bus.Read(addr, size)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// scratchDevice remembers the last access
+type scratchDevice struct {
+	addr  uint64
+	value uint64
+	size  uint8
+}
+
+func (d *scratchDevice) Read(addr uint64, size uint8) (uint64, error) {
+	d.addr, d.size = addr, size
+	return d.value, nil
+}
+
+func (d *scratchDevice) Write(addr uint64, value uint64, size uint8) error {
+	d.addr, d.value, d.size = addr, value, size
+	return nil
+}
+
+func TestBusMap(t *testing.T) {
+	bus := NewBus()
+	if err := bus.Map("dev", 0x1000, 0x100, &scratchDevice{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Map("overlap", 0x10f0, 0x100, &scratchDevice{}); err == nil {
+		t.Fatalf("overlapping regions must be rejected")
+	}
+	if err := bus.Map("rom", 0x2000, 0x10, NewRom(make([]byte, 0x10))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr uint64
+		size uint8
+		err  error
+	}{
+		{0x1000, DOUBLEWORD, nil},
+		{0x10f8, DOUBLEWORD, nil},
+		{0x10fc, DOUBLEWORD, ErrUnmapped}, // выходит за границу региона
+		{0xfff, BYTE, ErrUnmapped},
+		{0x2008, DOUBLEWORD, ErrReadOnly},
+	}
+	for _, test := range tests {
+		if err := bus.Write(test.addr, 0x42, test.size); !errors.Is(err, test.err) {
+			t.Fatalf("write %#x: got %v, want %v", test.addr, err, test.err)
+		}
+	}
+	if data, err := bus.Read(0x1010, WORD); err != nil || data != 0x42 {
+		t.Fatalf("read: got %#x, %v", data, err)
+	}
+}
+
+func TestBusAccessFaults(t *testing.T) {
+	cpu := NewCPU()
+	dev := &scratchDevice{}
+	if err := cpu.bus.Map("dev", 0x10000000, 0x100, dev); err != nil {
+		t.Fatal(err)
+	}
+	cpu.csr[MTVEC] = 0x80000100
+
+	cpu.writeReg(1, 0x10000000)
+	cpu.writeReg(2, 0x1234)
+	cpu.ExecuteInst(0x0020a223) // sw x2, 4(x1)
+	if dev.addr != 4 || dev.value != 0x1234 || dev.size != WORD {
+		t.Fatalf("device got addr=%#x value=%#x size=%d", dev.addr, dev.value, dev.size)
+	}
+
+	cpu.writeReg(1, 0x20000000)
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if cpu.csr[MCAUSE] != uint64(LOAD_ACCESS_FAULT) || cpu.csr[MTVAL] != 0x20000000 {
+		t.Fatalf("mcause=%d mtval=%#x, want load access fault", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+	cpu.writeReg(1, DRAM_BASE+MEMORY_SIZE)
+	cpu.ExecuteInst(0x0020b023) // sd x2, 0(x1)
+	if cpu.csr[MCAUSE] != uint64(STORE_AMO_ACCESS_FAULT) || cpu.csr[MTVAL] != DRAM_BASE+MEMORY_SIZE {
+		t.Fatalf("mcause=%d mtval=%#x, want store access fault", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+
+	cpu.pc = 0x20000000
+	cpu.Step()
+	if cpu.csr[MCAUSE] != uint64(INSTRUCTION_ACCESS_FAULT) || cpu.pc != 0x80000100 {
+		t.Fatalf("mcause=%d pc=%#x, want instruction access fault", cpu.csr[MCAUSE], cpu.pc)
+	}
+}
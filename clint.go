@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	CLINT_BASE uint64 = 0x2000000
+	CLINT_SIZE uint64 = 0x10000
+	// частота mtime, тиков в секунду
+	CLINT_FREQ uint64 = 10000000
+
+	CLINT_MSIP     uint64 = 0x0
+	CLINT_MTIMECMP uint64 = 0x4000
+	CLINT_MTIME    uint64 = 0xbff8
+)
+
+// Clint is the core-local interruptor: per-hart software interrupt
+// (msip) and timer compare (mtimecmp) registers plus the shared mtime
+type Clint struct {
+	mu       sync.Mutex
+	msip     []uint32
+	mtimecmp []uint64
+	offset   uint64        // mtime = clock() + offset
+	clock    func() uint64 // источник времени в тиках mtime
+}
+
+func NewClint(harts int) *Clint {
+	start := time.Now()
+	c := &Clint{
+		msip:     make([]uint32, harts),
+		mtimecmp: make([]uint64, harts),
+		clock: func() uint64 {
+			return uint64(time.Since(start)) * CLINT_FREQ / uint64(time.Second)
+		},
+	}
+	for i := range c.mtimecmp {
+		c.mtimecmp[i] = ^uint64(0)
+	}
+	return c
+}
+
+func (c *Clint) Mtime() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock() + c.offset
+}
+
+// Pending returns MSIP/MTIP bits that CLINT drives into mip of the hart
+func (c *Clint) Pending(hart uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hart >= uint64(len(c.msip)) {
+		return 0
+	}
+	var mip uint64
+	if c.msip[hart]&1 != 0 {
+		mip |= MIP_MSIP
+	}
+	if c.clock()+c.offset >= c.mtimecmp[hart] {
+		mip |= MIP_MTIP
+	}
+	return mip
+}
+
+// readPart returns size bits of 64-bit reg located at byte offset
+func readPart(reg uint64, offset uint64, size uint8) uint64 {
+	data := reg >> (8 * offset)
+	if size < DOUBLEWORD {
+		data &= (uint64(1) << size) - 1
+	}
+	return data
+}
+
+// writePart replaces size bits of 64-bit reg at byte offset
+func writePart(reg uint64, offset uint64, value uint64, size uint8) uint64 {
+	mask := ^uint64(0)
+	if size < DOUBLEWORD {
+		mask = (uint64(1) << size) - 1
+	}
+	shift := 8 * offset
+	return reg&^(mask<<shift) | (value&mask)<<shift
+}
+
+func (c *Clint) Read(addr uint64, size uint8) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	harts := uint64(len(c.msip))
+	switch {
+	case addr >= CLINT_MSIP && addr < CLINT_MSIP+4*harts:
+		return readPart(uint64(c.msip[addr/4]), addr%4, size), nil
+	case addr >= CLINT_MTIMECMP && addr < CLINT_MTIMECMP+8*harts:
+		off := addr - CLINT_MTIMECMP
+		return readPart(c.mtimecmp[off/8], off%8, size), nil
+	case addr >= CLINT_MTIME && addr < CLINT_MTIME+8:
+		return readPart(c.clock()+c.offset, addr-CLINT_MTIME, size), nil
+	}
+	return 0, nil
+}
+
+func (c *Clint) Write(addr uint64, value uint64, size uint8) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	harts := uint64(len(c.msip))
+	switch {
+	case addr >= CLINT_MSIP && addr < CLINT_MSIP+4*harts:
+		// реализован только младший бит
+		if addr%4 == 0 {
+			c.msip[addr/4] = uint32(value) & 1
+		}
+	case addr >= CLINT_MTIMECMP && addr < CLINT_MTIMECMP+8*harts:
+		off := addr - CLINT_MTIMECMP
+		c.mtimecmp[off/8] = writePart(c.mtimecmp[off/8], off%8, value, size)
+	case addr >= CLINT_MTIME && addr < CLINT_MTIME+8:
+		now := c.clock()
+		c.offset = writePart(now+c.offset, addr-CLINT_MTIME, value, size) - now
+	}
+	return nil
+}
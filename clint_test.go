@@ -0,0 +1,136 @@
+package main
+
+import "testing"
+
+func newTestClint(cpu *Cpu, now *uint64) *Clint {
+	clint := NewClint(1)
+	clint.clock = func() uint64 { return *now }
+	cpu.bus.Map("clint", CLINT_BASE, CLINT_SIZE, clint)
+	cpu.ConnectInterrupts(clint)
+	return clint
+}
+
+func TestClintTimerInterrupt(t *testing.T) {
+	var now uint64
+	cpu := NewCPU()
+	clint := newTestClint(cpu, &now)
+	cpu.csr[MTVEC] = 0x80001001 // векторный режим
+	cpu.csr[MIE] = MIP_MTIP
+	cpu.csr[MSTATUS] = MSTATUS_MIE
+
+	clint.Write(CLINT_MTIMECMP, 100, DOUBLEWORD)
+	now = 99
+	cpu.ExecuteProgram([]uint32{0x00000013}) // nop
+	if cpu.pc != DRAM_BASE+4 {
+		t.Fatalf("timer must not fire before mtimecmp, pc=%#x", cpu.pc)
+	}
+	now = 100
+	cpu.pc = DRAM_BASE
+	cpu.Step()
+	if cpu.csr[MCAUSE] != CAUSE_INTERRUPT|MACHINE_TIMER_INTERRUPT || cpu.pc != 0x8000101c || cpu.csr[MEPC] != DRAM_BASE {
+		t.Fatalf("mcause=%#x pc=%#x mepc=%#x", cpu.csr[MCAUSE], cpu.pc, cpu.csr[MEPC])
+	}
+	if cpu.csr[MSTATUS]&MSTATUS_MIE != 0 {
+		t.Fatalf("MIE must be cleared by the trap")
+	}
+
+	// mtime пишется гостем
+	clint.Write(CLINT_MTIME, 5, WORD)
+	cpu.updateInterrupts()
+	if mtime, _ := clint.Read(CLINT_MTIME, DOUBLEWORD); mtime != 5 || cpu.readCSR(MIP)&MIP_MTIP != 0 {
+		t.Fatalf("mtime=%d mip=%#x", mtime, cpu.readCSR(MIP))
+	}
+}
+
+func TestInterruptPriority(t *testing.T) {
+	var now uint64 = 1000
+	cpu := NewCPU()
+	clint := newTestClint(cpu, &now)
+	clint.Write(CLINT_MTIMECMP, 0, DOUBLEWORD)
+	clint.Write(CLINT_MSIP, 1, WORD)
+	cpu.csr[MTVEC] = 0x80001000
+	cpu.csr[STVEC] = 0x80002000
+	cpu.writeCSR(MIDELEG, MIP_STIP)
+	cpu.csr[MIE] = MIP_MTIP | MIP_MSIP | MIP_STIP
+	cpu.writeCSR(MIP, MIP_STIP|MIP_MTIP) // MTIP только для чтения
+	cpu.updateInterrupts()
+	if cpu.csr[MIP] != MIP_STIP {
+		t.Fatalf("mip=%#x, only STIP is writable", cpu.csr[MIP])
+	}
+
+	// в M-режиме при MIE=0 прерывания не принимаются
+	if _, ok := cpu.pendingInterrupt(); ok {
+		t.Fatalf("interrupts are disabled in M-mode with MIE=0")
+	}
+	cpu.privilege = SUPERVISOR_MODE
+	if cause, _ := cpu.pendingInterrupt(); cause != MACHINE_SOFTWARE_INTERRUPT {
+		t.Fatalf("cause=%d, MSI has priority over MTI", cause)
+	}
+	clint.Write(CLINT_MSIP, 0, WORD)
+	clint.Write(CLINT_MTIMECMP, ^uint64(0), DOUBLEWORD)
+	cpu.updateInterrupts()
+	if _, ok := cpu.pendingInterrupt(); ok {
+		t.Fatalf("delegated interrupt needs SIE in S-mode")
+	}
+	cpu.privilege = USER_MODE
+	cpu.Step()
+	if cpu.privilege != SUPERVISOR_MODE || cpu.pc != 0x80002000 || cpu.readCSR(SCAUSE) != CAUSE_INTERRUPT|SUPERVISOR_TIMER_INTERRUPT {
+		t.Fatalf("delegated timer: privilege=%d pc=%#x scause=%#x", cpu.privilege, cpu.pc, cpu.readCSR(SCAUSE))
+	}
+}
+
+func TestWfiWakeUp(t *testing.T) {
+	var now uint64
+	cpu := NewCPU()
+	clint := newTestClint(cpu, &now)
+	clint.Write(CLINT_MTIMECMP, 10, DOUBLEWORD)
+	cpu.csr[MIE] = MIP_MTIP
+	cpu.bus.Write(DRAM_BASE+4, 0x00000013, WORD) // nop
+
+	cpu.ExecuteInst(0x10500073) // wfi
+	cpu.Step()
+	if !cpu.waiting {
+		t.Fatalf("hart must wait for timer")
+	}
+	now = 10
+	cpu.Step()
+	// MIE=0: прерывание не принимается, но хат просыпается
+	if cpu.waiting || cpu.pc != DRAM_BASE+8 {
+		t.Fatalf("hart must resume after wfi, waiting=%v pc=%#x", cpu.waiting, cpu.pc)
+	}
+}
+
+func TestMipSetClearKeepsDeviceLines(t *testing.T) {
+	cpu := NewCPU()
+	cpu.irqLines = MIP_SEIP | MIP_MTIP
+	cpu.ExecuteInst(0x344161f3) // csrrsi x3, mip, 2
+	if got := cpu.readReg(3); got != MIP_SEIP|MIP_MTIP {
+		t.Fatalf("csrrsi read mip=%#x, want device lines", got)
+	}
+	if cpu.csr[MIP] != MIP_SSIP {
+		t.Fatalf("mip=%#x, device lines must not be written back", cpu.csr[MIP])
+	}
+	cpu.irqLines = 0
+	if got := cpu.readCSR(MIP); got != MIP_SSIP {
+		t.Fatalf("mip=%#x after SEIP is deasserted", got)
+	}
+
+	// программный SEIP сохраняется при снятой линии
+	cpu.writeCSR(MIP, MIP_SEIP)
+	cpu.irqLines = MIP_SEIP
+	cpu.writeReg(1, MIP_SSIP)
+	cpu.ExecuteInst(0x3440b073) // csrrc x0, mip, x1
+	cpu.irqLines = 0
+	if got := cpu.readCSR(MIP); got != MIP_SEIP {
+		t.Fatalf("mip=%#x, software SEIP must be kept", got)
+	}
+
+	cpu.writeCSR(MIP, 0)
+	cpu.writeCSR(MIDELEG, MIP_SSIP|MIP_SEIP)
+	cpu.irqLines = MIP_SEIP
+	cpu.ExecuteInst(0x144161f3) // csrrsi x3, sip, 2
+	cpu.irqLines = 0
+	if cpu.readReg(3) != MIP_SEIP || cpu.readCSR(SIP) != MIP_SSIP {
+		t.Fatalf("x3=%#x sip=%#x", cpu.readReg(3), cpu.readCSR(SIP))
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+// Encoders of 32-bit instruction formats used to expand RVC instructions
+
+func encR(opcode, rd, funct3, rs1, rs2, funct7 uint32) uint32 {
+	return funct7<<25 | rs2<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+func encI(opcode, rd, funct3, rs1 uint32, imm int32) uint32 {
+	return uint32(imm)<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+func encS(opcode, funct3, rs1, rs2 uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>5&0x7f)<<25 | rs2<<20 | rs1<<15 | funct3<<12 | (u&0x1f)<<7 | opcode
+}
+
+func encB(funct3, rs1, rs2 uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>12&1)<<31 | (u>>5&0x3f)<<25 | rs2<<20 | rs1<<15 | funct3<<12 |
+		(u>>1&0xf)<<8 | (u>>11&1)<<7 | 0x63
+}
+
+func encU(opcode, rd uint32, imm int32) uint32 {
+	return uint32(imm)&0xfffff000 | rd<<7 | opcode
+}
+
+func encJ(rd uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>20&1)<<31 | (u>>1&0x3ff)<<21 | (u>>11&1)<<20 | (u>>12&0xff)<<12 | rd<<7 | 0x6f
+}
+
+const (
+	OP_LOAD     uint32 = 0x03
+	OP_LOAD_FP  uint32 = 0x07
+	OP_IMM      uint32 = 0x13
+	OP_IMM_32   uint32 = 0x1b
+	OP_STORE    uint32 = 0x23
+	OP_STORE_FP uint32 = 0x27
+	OP          uint32 = 0x33
+	OP_LUI      uint32 = 0x37
+	OP_32       uint32 = 0x3b
+	OP_JALR     uint32 = 0x67
+)
+
+// cbits gathers instruction bits: each pair is (position in c, position in result)
+func cbits(c uint32, pairs ...uint) uint32 {
+	var v uint32
+	for i := 0; i < len(pairs); i += 2 {
+		v |= (c >> pairs[i] & 1) << pairs[i+1]
+	}
+	return v
+}
+
+// cfield extracts len bits of c starting at start
+func cfield(c uint32, start, len uint) uint32 {
+	return c >> start & (1<<len - 1)
+}
+
+// sext sign-extends a value whose sign bit is bit
+func sext(v uint32, bit uint) int32 {
+	return int32(v<<(31-bit)) >> (31 - bit)
+}
+
+// expandCompressed converts a 16-bit RVC instruction to its 32-bit equivalent
+// for RV32 or RV64. false is returned for illegal and reserved encodings.
+func expandCompressed(c uint32, xlen uint64) (uint32, bool) {
+	rd := cfield(c, 7, 5)
+	rs2 := cfield(c, 2, 5)
+	rdp := cfield(c, 2, 3) + 8  // rd'
+	rs1p := cfield(c, 7, 3) + 8 // rs1'
+	funct3 := cfield(c, 13, 3)
+	// 6-битный знаковый imm[5|4:0] инструкций CI
+	imm6 := sext(cbits(c, 12, 5)|cfield(c, 2, 5), 5)
+	shamt := int32(cbits(c, 12, 5) | cfield(c, 2, 5))
+	// в RV32 shamt[5] = 1 зарезервирован
+	shamtOK := xlen == 64 || shamt < 32
+	// c.ld/c.sd/c.ldsp/c.sdsp и c.addiw в RV32 заменяются
+	// на c.flw/c.fsw/c.flwsp/c.fswsp и c.jal
+	rv32 := xlen == 32
+
+	switch c & 3 {
+	case 0:
+		switch funct3 {
+		case 0: // c.addi4spn
+			imm := cbits(c, 5, 3, 6, 2, 7, 6, 8, 7, 9, 8, 10, 9, 11, 4, 12, 5)
+			if imm == 0 {
+				return 0, false
+			}
+			return encI(OP_IMM, rdp, 0, 2, int32(imm)), true
+		case 1: // c.fld
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 5, 6, 6, 7)
+			return encI(OP_LOAD_FP, rdp, 3, rs1p, int32(imm)), true
+		case 2: // c.lw
+			imm := cbits(c, 6, 2, 10, 3, 11, 4, 12, 5, 5, 6)
+			return encI(OP_LOAD, rdp, 2, rs1p, int32(imm)), true
+		case 3:
+			if rv32 { // c.flw
+				imm := cbits(c, 6, 2, 10, 3, 11, 4, 12, 5, 5, 6)
+				return encI(OP_LOAD_FP, rdp, 2, rs1p, int32(imm)), true
+			}
+			// c.ld
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 5, 6, 6, 7)
+			return encI(OP_LOAD, rdp, 3, rs1p, int32(imm)), true
+		case 5: // c.fsd
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 5, 6, 6, 7)
+			return encS(OP_STORE_FP, 3, rs1p, rdp, int32(imm)), true
+		case 6: // c.sw
+			imm := cbits(c, 6, 2, 10, 3, 11, 4, 12, 5, 5, 6)
+			return encS(OP_STORE, 2, rs1p, rdp, int32(imm)), true
+		case 7:
+			if rv32 { // c.fsw
+				imm := cbits(c, 6, 2, 10, 3, 11, 4, 12, 5, 5, 6)
+				return encS(OP_STORE_FP, 2, rs1p, rdp, int32(imm)), true
+			}
+			// c.sd
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 5, 6, 6, 7)
+			return encS(OP_STORE, 3, rs1p, rdp, int32(imm)), true
+		}
+	case 1:
+		switch funct3 {
+		case 0: // c.addi, c.nop
+			return encI(OP_IMM, rd, 0, rd, imm6), true
+		case 1:
+			if rv32 { // c.jal
+				imm := sext(cbits(c, 3, 1, 4, 2, 5, 3, 11, 4, 2, 5, 7, 6, 6, 7, 9, 8, 10, 9, 8, 10, 12, 11), 11)
+				return encJ(1, imm), true
+			}
+			// c.addiw
+			if rd == 0 {
+				return 0, false
+			}
+			return encI(OP_IMM_32, rd, 0, rd, imm6), true
+		case 2: // c.li
+			return encI(OP_IMM, rd, 0, 0, imm6), true
+		case 3:
+			if rd == 2 { // c.addi16sp
+				imm := sext(cbits(c, 6, 4, 2, 5, 5, 6, 3, 7, 4, 8, 12, 9), 9)
+				if imm == 0 {
+					return 0, false
+				}
+				return encI(OP_IMM, 2, 0, 2, imm), true
+			}
+			// c.lui
+			if imm6 == 0 {
+				return 0, false
+			}
+			return encU(OP_LUI, rd, imm6<<12), true
+		case 4:
+			rd := rs1p
+			switch cfield(c, 10, 2) {
+			case 0: // c.srli
+				return encI(OP_IMM, rd, 5, rd, shamt), shamtOK
+			case 1: // c.srai
+				return encI(OP_IMM, rd, 5, rd, shamt|0x400), shamtOK
+			case 2: // c.andi
+				return encI(OP_IMM, rd, 7, rd, imm6), true
+			}
+			rs2 := rdp
+			switch cbits(c, 12, 2) | cfield(c, 5, 2) {
+			case 0: // c.sub
+				return encR(OP, rd, 0, rd, rs2, 0x20), true
+			case 1: // c.xor
+				return encR(OP, rd, 4, rd, rs2, 0), true
+			case 2: // c.or
+				return encR(OP, rd, 6, rd, rs2, 0), true
+			case 3: // c.and
+				return encR(OP, rd, 7, rd, rs2, 0), true
+			case 4: // c.subw
+				return encR(OP_32, rd, 0, rd, rs2, 0x20), true
+			case 5: // c.addw
+				return encR(OP_32, rd, 0, rd, rs2, 0), true
+			}
+		case 5: // c.j
+			imm := sext(cbits(c, 3, 1, 4, 2, 5, 3, 11, 4, 2, 5, 7, 6, 6, 7, 9, 8, 10, 9, 8, 10, 12, 11), 11)
+			return encJ(0, imm), true
+		case 6, 7: // c.beqz, c.bnez
+			imm := sext(cbits(c, 3, 1, 4, 2, 10, 3, 11, 4, 2, 5, 5, 6, 6, 7, 12, 8), 8)
+			return encB(funct3-6, rs1p, 0, imm), true
+		}
+	case 2:
+		switch funct3 {
+		case 0: // c.slli
+			return encI(OP_IMM, rd, 1, rd, shamt), shamtOK
+		case 1: // c.fldsp
+			imm := cbits(c, 5, 3, 6, 4, 12, 5, 2, 6, 3, 7, 4, 8)
+			return encI(OP_LOAD_FP, rd, 3, 2, int32(imm)), true
+		case 2: // c.lwsp
+			if rd == 0 {
+				return 0, false
+			}
+			imm := cbits(c, 4, 2, 5, 3, 6, 4, 12, 5, 2, 6, 3, 7)
+			return encI(OP_LOAD, rd, 2, 2, int32(imm)), true
+		case 3:
+			if rv32 { // c.flwsp
+				imm := cbits(c, 4, 2, 5, 3, 6, 4, 12, 5, 2, 6, 3, 7)
+				return encI(OP_LOAD_FP, rd, 2, 2, int32(imm)), true
+			}
+			// c.ldsp
+			if rd == 0 {
+				return 0, false
+			}
+			imm := cbits(c, 5, 3, 6, 4, 12, 5, 2, 6, 3, 7, 4, 8)
+			return encI(OP_LOAD, rd, 3, 2, int32(imm)), true
+		case 4:
+			switch {
+			case c>>12&1 == 0 && rs2 == 0: // c.jr
+				if rd == 0 {
+					return 0, false
+				}
+				return encI(OP_JALR, 0, 0, rd, 0), true
+			case c>>12&1 == 0: // c.mv
+				return encR(OP, rd, 0, 0, rs2, 0), true
+			case rd == 0 && rs2 == 0: // c.ebreak
+				return 0x00100073, true
+			case rs2 == 0: // c.jalr
+				return encI(OP_JALR, 1, 0, rd, 0), true
+			default: // c.add
+				return encR(OP, rd, 0, rd, rs2, 0), true
+			}
+		case 5: // c.fsdsp
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 7, 6, 8, 7, 9, 8)
+			return encS(OP_STORE_FP, 3, 2, rs2, int32(imm)), true
+		case 6: // c.swsp
+			imm := cbits(c, 9, 2, 10, 3, 11, 4, 12, 5, 7, 6, 8, 7)
+			return encS(OP_STORE, 2, 2, rs2, int32(imm)), true
+		case 7:
+			if rv32 { // c.fswsp
+				imm := cbits(c, 9, 2, 10, 3, 11, 4, 12, 5, 7, 6, 8, 7)
+				return encS(OP_STORE_FP, 2, 2, rs2, int32(imm)), true
+			}
+			// c.sdsp
+			imm := cbits(c, 10, 3, 11, 4, 12, 5, 7, 6, 8, 7, 9, 8)
+			return encS(OP_STORE, 3, 2, rs2, int32(imm)), true
+		}
+	}
+	return 0, false
+}
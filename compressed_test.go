@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestExpandCompressed(t *testing.T) {
+	tests := []struct {
+		name string
+		c    uint32
+		inst uint32
+	}{
+		{"c.addi4spn a0, sp, 16", 0x0808, 0x01010513},
+		{"c.li a0, 0", 0x4501, 0x00000513},
+		{"c.li a5, -1", 0x57fd, 0xfff00793},
+		{"c.mv a0, a1", 0x852e, 0x00b00533},
+		{"c.add a0, a1", 0x952e, 0x00b50533},
+		{"c.jr ra", 0x8082, 0x00008067},
+		{"c.ld a5, 8(a0)", 0x651c, 0x00853783},
+		{"c.sdsp ra, 8(sp)", 0xe406, 0x00113423},
+		{"c.ldsp ra, 8(sp)", 0x60a2, 0x00813083},
+		{"c.swsp a0, 4(sp)", 0xc22a, 0x00a12223},
+		{"c.addi16sp sp, -16", 0x1141, 0xff010113},
+		{"c.addi sp, 16", 0x0141, 0x01010113},
+		{"c.addiw a0, 1", 0x2505, 0x0015051b},
+		{"c.lui a0, 1", 0x6505, 0x00001537},
+		{"c.j 0", 0xa001, 0x0000006f},
+		{"c.beqz a0, 8", 0xc501, 0x00050463},
+		{"c.slli a0, 32", 0x1502, 0x02051513},
+		{"c.srai a0, 1", 0x8505, 0x40155513},
+		{"c.subw a0, a1", 0x9d0d, 0x40b5053b},
+		{"c.fsd fa0, 0(a1)", 0xa188, 0x00a5b027},
+		{"c.ebreak", 0x9002, 0x00100073},
+	}
+	for _, test := range tests {
+		got, ok := expandCompressed(test.c, XLEN)
+		if !ok || got != test.inst {
+			t.Fatalf("%s (%#04x): got %#08x, want %#08x", test.name, test.c, got, test.inst)
+		}
+	}
+
+	illegal := []uint32{
+		0x0000, // нулевая инструкция
+		0x2001, // c.addiw x0
+		0x4002, // c.lwsp x0
+		0x8002, // c.jr x0
+		0x8000, // зарезервировано в квадранте 0
+		0x6081, // c.lui с нулевым imm
+		0x6101, // c.addi16sp с нулевым imm
+	}
+	for _, c := range illegal {
+		if inst, ok := expandCompressed(c, XLEN); ok {
+			t.Fatalf("%#04x: expanded to %#08x, want illegal", c, inst)
+		}
+	}
+}
+
+func TestCompressedProgram(t *testing.T) {
+	cpu := NewCPU()
+	cpu.ExecuteCode([]byte{
+		0x15, 0x45, // c.li a0, 5
+		0x93, 0x05, 0x15, 0x00, // addi a1, a0, 1
+		0x17, 0x06, 0x00, 0x00, // auipc a2, 0
+		0x29, 0x06, // c.addi a2, 10
+		0x02, 0x96, // c.jalr a2
+		0x01, 0x45, // c.li a0, 0 (пропускается)
+		0x86, 0x86, // c.mv a3, ra
+	})
+	if err := cpu.regsMustEq(map[uint]uint64{10: 5, 11: 6, 13: DRAM_BASE + 14}); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.pc != DRAM_BASE+18 {
+		t.Fatalf("pc=%#x, want %#x", cpu.pc, DRAM_BASE+18)
+	}
+}
+
+func TestCompressedDisabled(t *testing.T) {
+	cpu := NewCPU()
+	cpu.csr[MTVEC] = 0x80001000
+
+	// выключить C нельзя, если следующая инструкция не выровнена на 4 байта
+	cpu.pc = DRAM_BASE + 2
+	cpu.writeReg(1, misaExt('C'))
+	cpu.ExecuteInst(0x3010b073) // csrrc x0, misa, x1
+	if cpu.readCSR(MISA)&misaExt('C') == 0 {
+		t.Fatalf("misa.C cleared with misaligned next instruction")
+	}
+
+	cpu.pc = DRAM_BASE
+	cpu.ExecuteInst(0x3010b073)
+	if cpu.readCSR(MISA)&misaExt('C') != 0 {
+		t.Fatalf("misa.C not cleared")
+	}
+
+	cpu.writeReg(5, DRAM_BASE+0x102)
+	cpu.ExecuteInst(0x000280e7) // jalr ra, 0(x5)
+	if cpu.csr[MCAUSE] != uint64(INSTRUCTION_ADDRESS_MISALIGNED) || cpu.csr[MTVAL] != DRAM_BASE+0x102 {
+		t.Fatalf("jalr: mcause=%d mtval=%#x, want misaligned fetch at %#x",
+			cpu.csr[MCAUSE], cpu.csr[MTVAL], DRAM_BASE+0x102)
+	}
+	if cpu.readReg(1) != misaExt('C') {
+		t.Fatalf("jalr wrote rd on misaligned target")
+	}
+
+	cpu.ExecuteInst(0x4501) // c.li a0, 0
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) || cpu.csr[MTVAL] != 0x4501 {
+		t.Fatalf("c.li without C: mcause=%d mtval=%#x, want illegal instruction", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+
+	cpu.csr[MEPC] = DRAM_BASE + 0x102
+	if got := cpu.readCSR(MEPC); got != DRAM_BASE+0x100 {
+		t.Fatalf("mepc=%#x, want bit 1 masked", got)
+	}
+}
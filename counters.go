@@ -0,0 +1,98 @@
+package main
+
+// Bits of mcounteren, scounteren and mcountinhibit
+const (
+	COUNTER_CY uint64 = 1 << 0
+	COUNTER_TM uint64 = 1 << 1
+	COUNTER_IR uint64 = 1 << 2
+	// mhpmcounter3..31
+	COUNTER_HPM uint64 = 0xfffffff8
+
+	COUNTEREN_MASK     uint64 = COUNTER_CY | COUNTER_TM | COUNTER_IR | COUNTER_HPM
+	COUNTINHIBIT_MASK  uint64 = COUNTER_CY | COUNTER_IR | COUNTER_HPM
+	FIRST_HPM_COUNTER  uint64 = 3
+	HPM_COUNTERS_LIMIT uint64 = 32
+)
+
+// Emulator events selectable in mhpmevent
+const (
+	HPM_EVENT_NONE         uint64 = 0
+	HPM_EVENT_LOAD         uint64 = 1 // обращения на чтение к памяти
+	HPM_EVENT_STORE        uint64 = 2 // обращения на запись к памяти
+	HPM_EVENT_BRANCH       uint64 = 3 // условные переходы
+	HPM_EVENT_BRANCH_TAKEN uint64 = 4
+	HPM_EVENT_EXCEPTION    uint64 = 5
+	HPM_EVENT_INTERRUPT    uint64 = 6
+	HPM_EVENT_ITLB_MISS    uint64 = 7
+	HPM_EVENT_DTLB_MISS    uint64 = 8
+	HPM_EVENT_COUNT        uint64 = 9
+)
+
+// TimeSource provides the value of mtime for the time CSR
+type TimeSource interface {
+	Mtime() uint64
+}
+
+// ConnectTimer makes the time CSR read src. Without a timer reads of
+// time raise illegal instruction so that M-mode software can emulate it.
+func (cpu *Cpu) ConnectTimer(src TimeSource) {
+	cpu.timer = src
+}
+
+// counterAccessible applies mcounteren and scounteren to the user
+// counter idx (0 for cycle, 1 for time, ...)
+func (cpu *Cpu) counterAccessible(idx uint64) bool {
+	if idx == 1 && cpu.timer == nil {
+		return false
+	}
+	bit := uint64(1) << idx
+	switch {
+	case cpu.privilege == MACHINE_MODE:
+		return true
+	case cpu.privilege == SUPERVISOR_MODE || cpu.virt:
+		// в V-режиме hcounteren и scounteren проверяет csrVirtualFault
+		return cpu.csr[MCOUNTEREN]&bit != 0
+	default:
+		return cpu.csr[MCOUNTEREN]&cpu.csr[SCOUNTEREN]&bit != 0
+	}
+}
+
+// tick advances mcycle and, for retired instructions, minstret.
+// Counters written by the instruction itself keep the written value.
+func (cpu *Cpu) tick(retired bool) {
+	inhibit := cpu.csr[MCOUNTINHIBIT] | cpu.countersWritten
+	cpu.countersWritten = 0
+	if inhibit&COUNTER_CY == 0 {
+		cpu.csr[MCYCLE]++
+	}
+	if retired && inhibit&COUNTER_IR == 0 {
+		cpu.csr[MINSTRET]++
+	}
+}
+
+// countEvent increments hpm counters programmed to count event
+func (cpu *Cpu) countEvent(event uint64) {
+	if cpu.hpmEvents&(1<<event) == 0 {
+		return
+	}
+	for i := FIRST_HPM_COUNTER; i < HPM_COUNTERS_LIMIT; i++ {
+		if cpu.csr[MHPMEVENT3+i-FIRST_HPM_COUNTER] == event && cpu.csr[MCOUNTINHIBIT]>>i&1 == 0 {
+			cpu.csr[MCYCLE+i]++
+		}
+	}
+}
+
+// writeHpmEvent selects the event of an hpm counter, unknown events
+// turn the counter off
+func (cpu *Cpu) writeHpmEvent(csr, event uint64) {
+	if event >= HPM_EVENT_COUNT {
+		event = HPM_EVENT_NONE
+	}
+	cpu.csr[csr] = event
+	cpu.hpmEvents = 0
+	for i := MHPMEVENT3; i <= MHPMEVENT31; i++ {
+		if cpu.csr[i] != HPM_EVENT_NONE {
+			cpu.hpmEvents |= 1 << cpu.csr[i]
+		}
+	}
+}
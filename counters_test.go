@@ -0,0 +1,195 @@
+package main
+
+import "testing"
+
+// csrInst encodes a csr instruction with rs1=x1, rd=x3
+func csrInst(csr uint64, funct3 uint32) uint32 {
+	return uint32(csr)<<20 | 1<<15 | funct3<<12 | 3<<7 | 0x73
+}
+
+// csrRead executes csrrs x3, csr, x0 and reports whether it trapped
+func csrRead(cpu *Cpu, csr uint64) (uint64, bool) {
+	cpu.ExecuteInst(uint32(csr)<<20 | 2<<12 | 3<<7 | 0x73)
+	return cpu.readReg(3), cpu.exception == nil
+}
+
+func TestZicond(t *testing.T) {
+	tests := []struct {
+		name     string
+		inst     uint32
+		rs1, rs2 uint64
+		want     uint64
+	}{
+		{"czero.eqz zero", rInst(0x07, 5, 0x33), 42, 0, 0},
+		{"czero.eqz nonzero", rInst(0x07, 5, 0x33), 42, 7, 42},
+		{"czero.nez zero", rInst(0x07, 7, 0x33), 42, 0, 42},
+		{"czero.nez nonzero", rInst(0x07, 7, 0x33), 42, 7, 0},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		cpu.writeReg(1, tt.rs1)
+		cpu.writeReg(2, tt.rs2)
+		cpu.ExecuteInst(tt.inst)
+		if got := cpu.readReg(3); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestZicntr(t *testing.T) {
+	cpu := NewCPU()
+	cpu.ExecuteProgram([]uint32{0x00000013, 0x00000013, 0x00000013}) // nop x3
+	if got, ok := csrRead(cpu, INSTRET); !ok || got != 3 {
+		t.Fatalf("instret=%d ok=%v, want 3", got, ok)
+	}
+	if got, ok := csrRead(cpu, CYCLE); !ok || got != 4 {
+		t.Fatalf("cycle=%d ok=%v, want 4", got, ok)
+	}
+
+	// записанное инструкцией значение не увеличивается на ней же
+	cpu.writeReg(1, 100)
+	cpu.ExecuteInst(csrInst(MINSTRET, 1))
+	if got, _ := csrRead(cpu, INSTRET); got != 100 {
+		t.Fatalf("instret after write=%d, want 100", got)
+	}
+	if got, _ := csrRead(cpu, INSTRET); got != 101 {
+		t.Fatalf("instret=%d, want 101", got)
+	}
+
+	cpu.writeReg(1, COUNTER_IR)
+	cpu.ExecuteInst(csrInst(MCOUNTINHIBIT, 1))
+	cpu.ExecuteInst(0x00000013)
+	if got, _ := csrRead(cpu, INSTRET); got != 102 {
+		t.Fatalf("inhibited instret=%d, want 102", got)
+	}
+
+	if _, ok := csrRead(cpu, TIME); ok || cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("time without timer must be illegal, mcause=%#x", cpu.csr[MCAUSE])
+	}
+	now := uint64(12345)
+	cpu.ConnectTimer(newTestClint(cpu, &now))
+	if got, ok := csrRead(cpu, TIME); !ok || got != now {
+		t.Fatalf("time=%d ok=%v, want %d", got, ok, now)
+	}
+
+	cpu.ExecuteInst(csrInst(CYCLE, 1))
+	if cpu.exception == nil {
+		t.Fatalf("writing cycle must be illegal")
+	}
+}
+
+func TestCounterEnable(t *testing.T) {
+	cpu := NewCPU()
+	cpu.privilege = SUPERVISOR_MODE
+	if _, ok := csrRead(cpu, CYCLE); ok {
+		t.Fatalf("cycle must be illegal in S-mode with mcounteren=0")
+	}
+	cpu.csr[MCOUNTEREN] = COUNTER_CY
+	cpu.privilege = SUPERVISOR_MODE // ловушка перевела харт в M-режим
+	if _, ok := csrRead(cpu, CYCLE); !ok {
+		t.Fatalf("cycle must be accessible in S-mode with mcounteren.CY")
+	}
+
+	cpu.privilege = USER_MODE
+	if _, ok := csrRead(cpu, CYCLE); ok {
+		t.Fatalf("cycle must be illegal in U-mode with scounteren=0")
+	}
+	cpu.csr[SCOUNTEREN] = COUNTER_CY | COUNTER_IR
+	cpu.privilege = USER_MODE
+	if _, ok := csrRead(cpu, CYCLE); !ok {
+		t.Fatalf("cycle must be accessible in U-mode")
+	}
+	if _, ok := csrRead(cpu, INSTRET); ok {
+		t.Fatalf("instret must be illegal without mcounteren.IR")
+	}
+}
+
+func TestZihpm(t *testing.T) {
+	cpu := NewCPU()
+	cpu.writeReg(1, HPM_EVENT_LOAD)
+	cpu.ExecuteInst(csrInst(MHPMEVENT3, 1))
+	cpu.writeReg(1, HPM_EVENT_BRANCH_TAKEN)
+	cpu.ExecuteInst(csrInst(MHPMEVENT3+1, 1))
+	cpu.writeReg(1, HPM_EVENT_COUNT)
+	cpu.ExecuteInst(csrInst(MHPMEVENT3+2, 1))
+	if got, _ := csrRead(cpu, MHPMEVENT3+2); got != HPM_EVENT_NONE {
+		t.Fatalf("unknown event must read as none, got %d", got)
+	}
+
+	cpu.writeReg(1, DRAM_BASE)
+	cpu.ExecuteInst(0x0000b103) // ld x2, 0(x1)
+	cpu.ExecuteInst(0x0000b103)
+	cpu.ExecuteInst(0x00000463) // beq x0, x0, 8
+	cpu.ExecuteInst(0x00101463) // bne x0, x1, 8
+	cpu.ExecuteInst(0x00001463) // bne x0, x0, 8
+	if got, _ := csrRead(cpu, HPMCOUNTER3); got != 2 {
+		t.Fatalf("loads=%d, want 2", got)
+	}
+	if got, _ := csrRead(cpu, HPMCOUNTER3+1); got != 2 {
+		t.Fatalf("taken branches=%d, want 2", got)
+	}
+}
+
+func TestZawrs(t *testing.T) {
+	const addr = DRAM_BASE + 0x1000
+	const wrsNto, wrsSto = 0x00d00073, 0x01d00073
+	lrW := amoInst(0x02, 0, 1, 2, 3)
+
+	bus := NewBus()
+	bus.Map("dram", DRAM_BASE, MEMORY_SIZE, InitDram(MEMORY_SIZE))
+	hart0, hart1 := NewHart(bus, 0), NewHart(bus, 1)
+	for _, cpu := range []*Cpu{hart0, hart1} {
+		cpu.writeReg(1, addr)
+		cpu.writeReg(2, 42)
+		cpu.pc = DRAM_BASE
+	}
+
+	// без резервирования wrs не останавливает харт
+	hart0.ExecuteInst(wrsNto)
+	if hart0.waiting {
+		t.Fatalf("wrs.nto without reservation must not stall")
+	}
+
+	hart0.ExecuteInst(lrW)
+	hart0.ExecuteInst(wrsNto)
+	pc := hart0.pc
+	for range 3 {
+		hart0.Step()
+	}
+	if hart0.pc != pc {
+		t.Fatalf("wrs.nto must stall while the reservation is held, pc=%#x", hart0.pc)
+	}
+	hart1.ExecuteInst(0x0020a023) // sw x2, 0(x1) с другого харта
+	hart0.Step()
+	if hart0.waiting || hart0.pc == pc {
+		t.Fatalf("store from another hart must wake wrs.nto")
+	}
+
+	hart0.ExecuteInst(lrW)
+	hart0.ExecuteInst(wrsSto)
+	steps := 0
+	for hart0.waiting {
+		hart0.Step()
+		steps++
+	}
+	if steps != int(WRS_STO_TIMEOUT) {
+		t.Fatalf("wrs.sto woke after %d steps, want %d", steps, WRS_STO_TIMEOUT)
+	}
+
+	allowAllPMP(hart0)
+	hart0.privilege = SUPERVISOR_MODE
+	hart0.csr[MSTATUS] |= MSTATUS_TW
+	hart0.ExecuteInst(lrW)
+	hart0.ExecuteInst(wrsNto)
+	if hart0.exception == nil || hart0.exception.cause != ILLEGAL_INSTRUCTION {
+		t.Fatalf("wrs.nto with TW=1 must be illegal below M-mode")
+	}
+}
+
+func TestPause(t *testing.T) {
+	cpu := NewCPU()
+	cpu.ExecuteProgram([]uint32{0x0100000f}) // pause
+	if cpu.exception != nil || cpu.pc != DRAM_BASE+4 {
+		t.Fatalf("pause must retire as a hint, pc=%#x", cpu.pc)
+	}
+}
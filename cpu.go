@@ -22,34 +22,94 @@ const (
 
 type Cpu struct {
 	pc         uint64
+	hartid     uint64
 	privilege  PrivMode
+	ilen       uint64 // длина текущей инструкции: 2 для сжатых, иначе 4
+	rawInst    uint32 // текущая инструкция в исходной кодировке
 	xregisters [32]uint64
-	fregisters [32]float64 // F/D расширения
+	fregisters [32]uint64 // F/D расширения, одинарная точность упакована в NaN
+	vregisters []byte     // V расширение: 32 регистра по VLEN бит подряд
+	vcfg       VectorConfig
 	csr        [4096]uint64
-	xlen       uint64 // разрядность регистров общего назначения
-	flen       uint64 // разрядность float-регистров
-	memory     Dram   // доступ к памяти
+	xlen       uint64     // разрядность регистров в текущем режиме
+	flen       uint64     // разрядность float-регистров
+	bus        *Bus       // доступ к памяти и устройствам
+	exception  *Exception // исключение, возникшее при выполнении текущей инструкции
+	waiting    bool       // хат простаивает после WFI или WRS
+	wrsWaiting bool       // ожидание WRS до потери резервирования
+	wrsTimeout uint64     // оставшиеся шаги WRS.STO
+	itlb       *TLB       // кэш трансляций для выборки инструкций
+	dtlb       *TLB       // кэш трансляций для загрузок и сохранений
+	pmp        PMP        // защита физической памяти
+	irqSources []InterruptSource
+	irqLines   uint64        // биты mip, управляемые устройствами
+	entropy    EntropySource // источник для CSR seed
+	timer      TimeSource    // источник для CSR time
+	hpmEvents  uint64        // события, выбранные в mhpmevent
+	// mcycle/minstret, записанные текущей инструкцией
+	countersWritten uint64
+	// старшие 64 бита f-регистров при FLEN=128
+	fregistersHi [32]uint64
+	// режим виртуализации: V=1 в VS- и VU-режимах
+	virt bool
+	// выполняется hlv/hsv, для hlvx вместо права чтения нужно исполнение
+	hlsv, hlvx bool
+	// MXLEN: разрядность M-режима, задаётся ConfigureXLEN
+	mxlen uint64
+	// включённые расширения, задаются ConfigureISA
+	exts Extension
 }
 
 func NewCPU() *Cpu {
+	bus := NewBus()
+	bus.Map("dram", DRAM_BASE, MEMORY_SIZE, InitDram(MEMORY_SIZE))
+	return NewHart(bus, 0)
+}
+
+// NewHart creates a hart with the given mhartid attached to a shared bus
+func NewHart(bus *Bus, hartid uint64) *Cpu {
 	cpu := Cpu{}
-	cpu.pc = DRAM_BASE
-	cpu.privilege = USER_MODE
-	cpu.xlen = XLEN
+	cpu.hartid = hartid
+	cpu.bus = bus
+	cpu.ConfigureTLB(DEFAULT_TLB_ENTRIES, DEFAULT_TLB_WAYS)
+	cpu.ConfigureVector(VectorConfig{VLEN: DEFAULT_VLEN, ELEN: DEFAULT_ELEN})
+	cpu.pmp = NewPMP(PMP_DEFAULT_ENTRIES)
+	cpu.entropy = systemEntropy{}
 	cpu.flen = FLEN
-	cpu.xregisters[0] = 0 // x0
-	cpu.memory = InitDram(MEMORY_SIZE)
+	cpu.mxlen = XLEN
+	cpu.exts = DEFAULT_EXTENSIONS
+	cpu.reset()
 	return &cpu
 }
 
 func (cpu *Cpu) reset() {
 	cpu.pc = DRAM_BASE
-	cpu.privilege = USER_MODE
-	cpu.xlen = XLEN
-	cpu.flen = FLEN
+	cpu.privilege = MACHINE_MODE
+	cpu.virt = false
 	for i := range cpu.xregisters {
 		cpu.xregisters[i] = 0
 	}
+	for i := range cpu.fregisters {
+		cpu.fregisters[i] = 0
+		cpu.fregistersHi[i] = 0
+	}
+	for i := range cpu.csr {
+		cpu.csr[i] = 0
+	}
+	cpu.csr[MHARTID] = cpu.hartid
+	cpu.csr[MISA] = cpu.exts.misa()
+	cpu.csr[HSTATUS] = HSTATUS_VSXL_64
+	cpu.resetXLEN()
+	cpu.resetVector()
+	cpu.bus.release(cpu.hartid, 0)
+	cpu.exception = nil
+	cpu.waiting = false
+	cpu.wrsWaiting = false
+	cpu.irqLines = 0
+	cpu.hpmEvents = 0
+	cpu.itlb.flush(0, false, 0, false)
+	cpu.dtlb.flush(0, false, 0, false)
+	cpu.pmp = NewPMP(cpu.pmp.entries)
 }
 
 func (cpu *Cpu) regsMustEq(xregs map[uint]uint64) error {
@@ -61,29 +121,131 @@ func (cpu *Cpu) regsMustEq(xregs map[uint]uint64) error {
 	return nil
 }
 
+// ExecuteProgram places prog at pc and runs it until pc leaves the program.
+// A word may hold two compressed instructions, the lower one first.
 func (cpu *Cpu) ExecuteProgram(prog []uint32) {
-	for pc := cpu.pc - DRAM_BASE; int(pc/4) < len(prog); pc = cpu.pc - DRAM_BASE {
-		cpu.ExecuteInst(prog[pc/4])
+	code := make([]byte, 0, len(prog)*4)
+	for _, inst := range prog {
+		code = append(code, byte(inst), byte(inst>>8), byte(inst>>16), byte(inst>>24))
+	}
+	cpu.ExecuteCode(code)
+}
+
+// ExecuteCode places machine code at pc and runs it until pc leaves it
+func (cpu *Cpu) ExecuteCode(code []byte) {
+	start := cpu.pc
+	for i, b := range code {
+		cpu.bus.Write(start+uint64(i), uint64(b), BYTE)
+	}
+	end := start + uint64(len(code))
+	for cpu.pc >= start && cpu.pc < end {
+		cpu.Step()
+	}
+}
+
+// Step fetches the instruction at pc through the MMU and executes it
+func (cpu *Cpu) Step() {
+	cpu.updateInterrupts()
+	if cpu.idle() {
+		cpu.tick(false)
+		return
+	}
+	if cause, ok := cpu.pendingInterrupt(); ok {
+		cpu.tick(false)
+		cpu.takeTrap(cause, 0, true)
+		return
+	}
+	cpu.exception = nil
+	cpu.updateXLEN()
+	inst, ok := cpu.fetch()
+	if !ok {
+		cpu.tick(false)
+		cpu.takeTrap(uint64(cpu.exception.cause), cpu.exception.tval, false)
+		return
+	}
+	cpu.ExecuteInst(inst)
+}
+
+// idle reports whether the hart is still stalled by WFI or WRS
+func (cpu *Cpu) idle() bool {
+	if cpu.waiting && cpu.readCSR(MIP)&cpu.csr[MIE] == 0 && !cpu.wrsWoken() {
+		return true
 	}
+	cpu.waiting = false
+	cpu.wrsWaiting = false
+	return false
 }
 
 func (cpu *Cpu) ExecuteInst(inst uint32) {
-	legal_inst := false
-	for _, i := range INSTRUCTIONS {
-		if (inst & i.mask) == i.match {
-			legal_inst = true
-			i.execute(cpu, inst)
-			cpu.pc += 4
-			break
+	if cpu.idle() {
+		return
+	}
+	cpu.exception = nil
+	cpu.updateXLEN()
+	cpu.rawInst = inst
+	cpu.ilen = 4
+	legal_inst := true
+	if inst&3 != 3 {
+		// сжатая инструкция выполняется как её 32-битный эквивалент
+		cpu.ilen = 2
+		inst &= 0xffff
+		if cpu.csr[MISA]&misaExt('C') != 0 {
+			inst, legal_inst = expandCompressed(inst, cpu.xlen)
+		} else {
+			legal_inst = false
+		}
+	}
+	if legal_inst {
+		legal_inst = false
+		for _, i := range INSTRUCTIONS {
+			// инструкции другой разрядности и выключенных расширений пропускаются
+			if (inst&i.mask) == i.match && (i.xlen == 0 || i.xlen == cpu.xlen) && i.enabled(cpu.exts) {
+				legal_inst = true
+				i.execute(cpu, inst)
+				break
+			}
 		}
 	}
 	if !legal_inst {
-		IllegalInst(inst)
+		cpu.IllegalInst(inst)
+	}
+	if e := cpu.exception; e != nil {
+		cpu.tick(false)
+		cpu.takeTrap(uint64(e.cause), e.tval, false)
+		return
 	}
+	cpu.tick(true)
+	cpu.pc += cpu.ilen
 }
 
+// setPC makes target the next instruction, ExecuteInst advances pc afterwards
+func (cpu *Cpu) setPC(target uint64) {
+	cpu.pc = target - cpu.ilen
+}
+
+// jump transfers control to target, raising misaligned exception if
+// target is not aligned to 4 bytes, or 2 bytes with C extension
+func (cpu *Cpu) jump(target uint64) bool {
+	align := uint64(3)
+	if cpu.csr[MISA]&misaExt('C') != 0 {
+		align = 1
+	}
+	target = cpu.truncAddr(target)
+	if target&align != 0 {
+		cpu.raise(INSTRUCTION_ADDRESS_MISALIGNED, target)
+		return false
+	}
+	cpu.setPC(target)
+	return true
+}
+
+// writeReg stores val in x register, in RV32 the result is
+// sign-extended from bit 31
 func (cpu *Cpu) writeReg(reg uint64, val uint64) {
 	if reg != 0 {
+		if cpu.xlen == 32 {
+			val = uint64(int32(val))
+		}
 		cpu.xregisters[reg] = val
 	}
 }
@@ -92,17 +254,13 @@ func (cpu *Cpu) readReg(reg uint64) uint64 {
 	if reg == 0 {
 		return 0
 	}
+	if cpu.xlen == 32 {
+		// после смены XLEN старшие биты регистра могут быть любыми
+		return uint64(int32(cpu.xregisters[reg]))
+	}
 	return cpu.xregisters[reg]
 }
 
-func (cpu *Cpu) readCSR(csr uint64) uint64 {
-	return cpu.csr[csr]
-}
-
-func (cpu *Cpu) writeCSR(csr uint64, data uint64) {
-	cpu.csr[csr] = data
-}
-
 func (cpu *Cpu) dumpRegN(regs ...uint64) {
 	for _, r := range regs {
 		fmt.Printf("[x%d: %d]\n", r, cpu.readReg(r))
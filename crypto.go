@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+)
+
+// seed CSR fields
+const (
+	SEED_OPST_SHIFT uint64 = 30
+	SEED_ENTROPY    uint64 = 0xffff
+)
+
+// Operational states of the entropy source reported in seed.OPST
+const (
+	SEED_OPST_BIST uint64 = 0 // самопроверка
+	SEED_OPST_WAIT uint64 = 1 // энтропия ещё не накоплена
+	SEED_OPST_ES16 uint64 = 2 // в seed 16 бит энтропии
+	SEED_OPST_DEAD uint64 = 3 // неустранимая ошибка
+)
+
+// mseccfg fields
+const (
+	MSECCFG_USEED uint64 = 1 << 8
+	MSECCFG_SSEED uint64 = 1 << 9
+)
+
+// EntropySource feeds the seed CSR of Zkr. Every read of seed polls
+// the source once.
+type EntropySource interface {
+	// Poll returns the operational state and, in SEED_OPST_ES16,
+	// 16 bits of entropy
+	Poll() (opst uint64, entropy uint16)
+}
+
+// systemEntropy is the default source backed by the host RNG
+type systemEntropy struct{}
+
+func (systemEntropy) Poll() (uint64, uint16) {
+	var buf [2]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return SEED_OPST_DEAD, 0
+	}
+	return SEED_OPST_ES16, binary.LittleEndian.Uint16(buf[:])
+}
+
+// SetEntropySource replaces the source behind the seed CSR
+func (cpu *Cpu) SetEntropySource(src EntropySource) {
+	cpu.entropy = src
+}
+
+// seedAccessible checks mseccfg permissions for seed below M-mode
+func (cpu *Cpu) seedAccessible() bool {
+	switch cpu.privilege {
+	case MACHINE_MODE:
+		return true
+	case SUPERVISOR_MODE:
+		return cpu.csr[MSECCFG]&MSECCFG_SSEED != 0
+	default:
+		return cpu.csr[MSECCFG]&MSECCFG_USEED != 0
+	}
+}
+
+func (cpu *Cpu) readSeed() uint64 {
+	opst, entropy := cpu.entropy.Poll()
+	if opst != SEED_OPST_ES16 {
+		// биты энтропии передаются только в состоянии ES16
+		entropy = 0
+	}
+	return opst<<SEED_OPST_SHIFT | uint64(entropy)&SEED_ENTROPY
+}
+
+// gfMul multiplies in GF(2^8) modulo the AES polynomial x^8+x^4+x^3+x+1
+func gfMul(a, b byte) byte {
+	var res byte
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			res ^= a
+		}
+		a = a<<1 ^ 0x1b*(a>>7)
+	}
+	return res
+}
+
+// AES_SBOX and AES_INV_SBOX are built from the multiplicative inverse
+// in GF(2^8) followed by the affine transform of FIPS-197
+var AES_SBOX, AES_INV_SBOX = func() (fwd, inv [256]byte) {
+	for x := 0; x < 256; x++ {
+		// x^254 = x^-1, для нуля получается ноль
+		b, p := byte(1), byte(x)
+		for e := 254; e != 0; e >>= 1 {
+			if e&1 != 0 {
+				b = gfMul(b, p)
+			}
+			p = gfMul(p, p)
+		}
+		s := b ^ bits.RotateLeft8(b, 1) ^ bits.RotateLeft8(b, 2) ^
+			bits.RotateLeft8(b, 3) ^ bits.RotateLeft8(b, 4) ^ 0x63
+		fwd[x], inv[s] = s, byte(x)
+	}
+	return fwd, inv
+}()
+
+// AES round constants, aes64ks1i with rnum=0xa uses none
+var AES_RCON = [11]uint32{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x00}
+
+var SM4_SBOX = [256]byte{
+	0xd6, 0x90, 0xe9, 0xfe, 0xcc, 0xe1, 0x3d, 0xb7, 0x16, 0xb6, 0x14, 0xc2, 0x28, 0xfb, 0x2c, 0x05,
+	0x2b, 0x67, 0x9a, 0x76, 0x2a, 0xbe, 0x04, 0xc3, 0xaa, 0x44, 0x13, 0x26, 0x49, 0x86, 0x06, 0x99,
+	0x9c, 0x42, 0x50, 0xf4, 0x91, 0xef, 0x98, 0x7a, 0x33, 0x54, 0x0b, 0x43, 0xed, 0xcf, 0xac, 0x62,
+	0xe4, 0xb3, 0x1c, 0xa9, 0xc9, 0x08, 0xe8, 0x95, 0x80, 0xdf, 0x94, 0xfa, 0x75, 0x8f, 0x3f, 0xa6,
+	0x47, 0x07, 0xa7, 0xfc, 0xf3, 0x73, 0x17, 0xba, 0x83, 0x59, 0x3c, 0x19, 0xe6, 0x85, 0x4f, 0xa8,
+	0x68, 0x6b, 0x81, 0xb2, 0x71, 0x64, 0xda, 0x8b, 0xf8, 0xeb, 0x0f, 0x4b, 0x70, 0x56, 0x9d, 0x35,
+	0x1e, 0x24, 0x0e, 0x5e, 0x63, 0x58, 0xd1, 0xa2, 0x25, 0x22, 0x7c, 0x3b, 0x01, 0x21, 0x78, 0x87,
+	0xd4, 0x00, 0x46, 0x57, 0x9f, 0xd3, 0x27, 0x52, 0x4c, 0x36, 0x02, 0xe7, 0xa0, 0xc4, 0xc8, 0x9e,
+	0xea, 0xbf, 0x8a, 0xd2, 0x40, 0xc7, 0x38, 0xb5, 0xa3, 0xf7, 0xf2, 0xce, 0xf9, 0x61, 0x15, 0xa1,
+	0xe0, 0xae, 0x5d, 0xa4, 0x9b, 0x34, 0x1a, 0x55, 0xad, 0x93, 0x32, 0x30, 0xf5, 0x8c, 0xb1, 0xe3,
+	0x1d, 0xf6, 0xe2, 0x2e, 0x82, 0x66, 0xca, 0x60, 0xc0, 0x29, 0x23, 0xab, 0x0d, 0x53, 0x4e, 0x6f,
+	0xd5, 0xdb, 0x37, 0x45, 0xde, 0xfd, 0x8e, 0x2f, 0x03, 0xff, 0x6a, 0x72, 0x6d, 0x6c, 0x5b, 0x51,
+	0x8d, 0x1b, 0xaf, 0x92, 0xbb, 0xdd, 0xbc, 0x7f, 0x11, 0xd9, 0x5c, 0x41, 0x1f, 0x10, 0x5a, 0xd8,
+	0x0a, 0xc1, 0x31, 0x88, 0xa5, 0xcd, 0x7b, 0xbd, 0x2d, 0x74, 0xd0, 0x12, 0xb8, 0xe5, 0xb4, 0xb0,
+	0x89, 0x69, 0x97, 0x4a, 0x0c, 0x96, 0x77, 0x7e, 0x65, 0xb9, 0xf1, 0x09, 0xc5, 0x6e, 0xc6, 0x84,
+	0x18, 0xf0, 0x7d, 0xec, 0x3a, 0xdc, 0x4d, 0x20, 0x79, 0xee, 0x5f, 0x3e, 0xd7, 0xcb, 0x39, 0x48,
+}
+
+// aesShiftRows returns columns 0 and 1 of the state after (inverse)
+// ShiftRows, rs1 holds columns 0-1 and rs2 columns 2-3
+func aesShiftRows(rs1, rs2 uint64, inv bool) uint64 {
+	var state [16]byte
+	binary.LittleEndian.PutUint64(state[:8], rs1)
+	binary.LittleEndian.PutUint64(state[8:], rs2)
+	var res uint64
+	for i := 0; i < 8; i++ {
+		row, col := i%4, i/4
+		src := (col + row) % 4
+		if inv {
+			src = (col - row + 4) % 4
+		}
+		res |= uint64(state[4*src+row]) << (8 * i)
+	}
+	return res
+}
+
+func aesSubBytes(val uint64, sbox *[256]byte) uint64 {
+	var res uint64
+	for i := 0; i < 64; i += 8 {
+		res |= uint64(sbox[byte(val>>i)]) << i
+	}
+	return res
+}
+
+// aesMixColumns applies (inverse) MixColumns to both 32-bit columns
+func aesMixColumns(val uint64, inv bool) uint64 {
+	coef := [4]byte{2, 3, 1, 1}
+	if inv {
+		coef = [4]byte{14, 11, 13, 9}
+	}
+	var res uint64
+	for c := 0; c < 64; c += 32 {
+		for r := 0; r < 4; r++ {
+			var out byte
+			for j := 0; j < 4; j++ {
+				out ^= gfMul(coef[(j-r+4)%4], byte(val>>(c+8*j)))
+			}
+			res |= uint64(out) << (c + 8*r)
+		}
+	}
+	return res
+}
+
+func (cpu *Cpu) aes64es(inst InstWord) {
+	sr := aesShiftRows(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()), false)
+	cpu.writeReg(inst.rd(), aesSubBytes(sr, &AES_SBOX))
+}
+
+func (cpu *Cpu) aes64esm(inst InstWord) {
+	sr := aesShiftRows(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()), false)
+	cpu.writeReg(inst.rd(), aesMixColumns(aesSubBytes(sr, &AES_SBOX), false))
+}
+
+func (cpu *Cpu) aes64ds(inst InstWord) {
+	sr := aesShiftRows(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()), true)
+	cpu.writeReg(inst.rd(), aesSubBytes(sr, &AES_INV_SBOX))
+}
+
+func (cpu *Cpu) aes64dsm(inst InstWord) {
+	sr := aesShiftRows(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()), true)
+	cpu.writeReg(inst.rd(), aesMixColumns(aesSubBytes(sr, &AES_INV_SBOX), true))
+}
+
+func (cpu *Cpu) aes64im(inst InstWord) {
+	cpu.writeReg(inst.rd(), aesMixColumns(cpu.readReg(inst.rs1()), true))
+}
+
+func (cpu *Cpu) aes64ks1i(inst InstWord) {
+	rnum := inst.x(20, 4)
+	if rnum > 0xa {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	word := uint32(cpu.readReg(inst.rs1()) >> 32)
+	if rnum != 0xa {
+		word = bits.RotateLeft32(word, -8)
+	}
+	word = uint32(aesSubBytes(uint64(word), &AES_SBOX)) ^ AES_RCON[rnum]
+	cpu.writeReg(inst.rd(), uint64(word)<<32|uint64(word))
+}
+
+func (cpu *Cpu) aes64ks2(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	w0 := uint32(rs1>>32) ^ uint32(rs2)
+	w1 := w0 ^ uint32(rs2>>32)
+	cpu.writeReg(inst.rd(), uint64(w1)<<32|uint64(w0))
+}
+
+func (cpu *Cpu) sha256sig0(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(bits.RotateLeft32(x, -7)^bits.RotateLeft32(x, -18)^x>>3)))
+}
+
+func (cpu *Cpu) sha256sig1(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(bits.RotateLeft32(x, -17)^bits.RotateLeft32(x, -19)^x>>10)))
+}
+
+func (cpu *Cpu) sha256sum0(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(bits.RotateLeft32(x, -2)^bits.RotateLeft32(x, -13)^bits.RotateLeft32(x, -22))))
+}
+
+func (cpu *Cpu) sha256sum1(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(bits.RotateLeft32(x, -6)^bits.RotateLeft32(x, -11)^bits.RotateLeft32(x, -25))))
+}
+
+func (cpu *Cpu) sha512sig0(inst InstWord) {
+	x := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(x, -1)^bits.RotateLeft64(x, -8)^x>>7)
+}
+
+func (cpu *Cpu) sha512sig1(inst InstWord) {
+	x := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(x, -19)^bits.RotateLeft64(x, -61)^x>>6)
+}
+
+func (cpu *Cpu) sha512sum0(inst InstWord) {
+	x := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(x, -28)^bits.RotateLeft64(x, -34)^bits.RotateLeft64(x, -39))
+}
+
+func (cpu *Cpu) sha512sum1(inst InstWord) {
+	x := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(x, -14)^bits.RotateLeft64(x, -18)^bits.RotateLeft64(x, -41))
+}
+
+func (cpu *Cpu) sm3p0(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(x^bits.RotateLeft32(x, 9)^bits.RotateLeft32(x, 17))))
+}
+
+func (cpu *Cpu) sm3p1(inst InstWord) {
+	x := uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(x^bits.RotateLeft32(x, 15)^bits.RotateLeft32(x, 23))))
+}
+
+// sm4Round passes byte bs of rs2 through the SM4 S-box and the linear
+// transform, the result stays in the byte position and is xored with rs1
+func (cpu *Cpu) sm4Round(inst InstWord, linear func(x uint32) uint32) {
+	bs := int(inst.x(30, 2)) * 8
+	x := uint32(SM4_SBOX[byte(cpu.readReg(inst.rs2())>>bs)])
+	res := bits.RotateLeft32(linear(x), bs) ^ uint32(cpu.readReg(inst.rs1()))
+	cpu.writeReg(inst.rd(), uint64(int32(res)))
+}
+
+func (cpu *Cpu) sm4ed(inst InstWord) {
+	cpu.sm4Round(inst, func(x uint32) uint32 {
+		return x ^ bits.RotateLeft32(x, 2) ^ bits.RotateLeft32(x, 10) ^
+			bits.RotateLeft32(x, 18) ^ bits.RotateLeft32(x, 24)
+	})
+}
+
+func (cpu *Cpu) sm4ks(inst InstWord) {
+	cpu.sm4Round(inst, func(x uint32) uint32 {
+		return x ^ bits.RotateLeft32(x, 13) ^ bits.RotateLeft32(x, 23)
+	})
+}
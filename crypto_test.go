@@ -0,0 +1,294 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/bits"
+	"testing"
+)
+
+// cryptoExec runs a single instruction with rs1=x1, rs2=x2 and returns x3
+func cryptoExec(t *testing.T, cpu *Cpu, inst uint32, rs1, rs2 uint64) uint64 {
+	t.Helper()
+	cpu.writeReg(1, rs1)
+	cpu.writeReg(2, rs2)
+	cpu.ExecuteInst(inst)
+	if cpu.exception != nil {
+		t.Fatalf("%#x: unexpected %v", inst, cpu.exception)
+	}
+	return cpu.readReg(3)
+}
+
+func TestZbkbZbkx(t *testing.T) {
+	tests := []struct {
+		name string
+		inst uint32
+		rs1  uint64
+		rs2  uint64
+		rd   uint64
+	}{
+		{"pack", rInst(0x04, 4, 0x33), 0x1111111122222222, 0x3333333344444444, 0x4444444422222222},
+		{"packh", rInst(0x04, 7, 0x33), 0x1234, 0x5678, 0x7834},
+		{"packw", rInst(0x04, 4, 0x3b), 0xabcd1234, 0x8765, 0xffffffff87651234},
+		{"brev8", unaryInst(0x687, 5, 0x13), 0x0102040810204080, 0, 0x8040201008040201},
+		{"xperm4", rInst(0x14, 2, 0x33), 0x0123456789abcdef, 0x1, 0xfffffffffffffffe},
+		{"xperm8", rInst(0x14, 4, 0x33), 0x1716151413121110, 0x07ff, 0x1010101010101700},
+		{"sha512sig0", unaryInst(0x106, 1, 0x13), 0x0123456789abcdef, 0, 0x6f92c77c6c4f1aa1},
+		{"sha512sig1", unaryInst(0x107, 1, 0x13), 0x0123456789abcdef, 0, 0x70a3460dbbd4317a},
+		{"sha512sum0", unaryInst(0x104, 1, 0x13), 0x0123456789abcdef, 0, 0xb7c57a100c7ec1ab},
+		{"sha512sum1", unaryInst(0x105, 1, 0x13), 0x0123456789abcdef, 0, 0x7703112333475567},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		if got := cryptoExec(t, cpu, test.inst, test.rs1, test.rs2); got != test.rd {
+			t.Fatalf("%s: rd=%#x, want %#x", test.name, got, test.rd)
+		}
+	}
+}
+
+// TestAES runs the AES-128 example of FIPS-197 appendix C.1
+// through the RV64 AES instructions
+func TestAES(t *testing.T) {
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	pt, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	ct, _ := hex.DecodeString("69c4e0d86a7b0430d8cdb78070b4c55a")
+	cpu := NewCPU()
+	exec := func(inst uint32, rs1, rs2 uint64) uint64 { return cryptoExec(t, cpu, inst, rs1, rs2) }
+	var (
+		es   = rInst(0x19, 0, 0x33)
+		esm  = rInst(0x1b, 0, 0x33)
+		ds   = rInst(0x1d, 0, 0x33)
+		dsm  = rInst(0x1f, 0, 0x33)
+		ks2  = rInst(0x3f, 0, 0x33)
+		im   = unaryInst(0x300, 1, 0x13)
+		ks1i = func(rnum uint32) uint32 { return unaryInst(0x310|rnum, 1, 0x13) }
+	)
+
+	rk := make([]uint64, 22)
+	rk[0] = binary.LittleEndian.Uint64(key[:8])
+	rk[1] = binary.LittleEndian.Uint64(key[8:])
+	for i := 0; i < 10; i++ {
+		tmp := exec(ks1i(uint32(i)), rk[2*i+1], 0)
+		rk[2*i+2] = exec(ks2, tmp, rk[2*i])
+		rk[2*i+3] = exec(ks2, rk[2*i+2], rk[2*i+1])
+	}
+
+	s0 := binary.LittleEndian.Uint64(pt[:8]) ^ rk[0]
+	s1 := binary.LittleEndian.Uint64(pt[8:]) ^ rk[1]
+	for r := 1; r < 10; r++ {
+		s0, s1 = exec(esm, s0, s1)^rk[2*r], exec(esm, s1, s0)^rk[2*r+1]
+	}
+	s0, s1 = exec(es, s0, s1)^rk[20], exec(es, s1, s0)^rk[21]
+	if s0 != binary.LittleEndian.Uint64(ct[:8]) || s1 != binary.LittleEndian.Uint64(ct[8:]) {
+		t.Fatalf("encrypt: got %016x%016x", s0, s1)
+	}
+
+	// эквивалентный обратный шифр с ключами раундов после InvMixColumns
+	s0, s1 = s0^rk[20], s1^rk[21]
+	for r := 9; r > 0; r-- {
+		k0, k1 := exec(im, rk[2*r], 0), exec(im, rk[2*r+1], 0)
+		s0, s1 = exec(dsm, s0, s1)^k0, exec(dsm, s1, s0)^k1
+	}
+	s0, s1 = exec(ds, s0, s1)^rk[0], exec(ds, s1, s0)^rk[1]
+	if s0 != binary.LittleEndian.Uint64(pt[:8]) || s1 != binary.LittleEndian.Uint64(pt[8:]) {
+		t.Fatalf("decrypt: got %016x%016x", s0, s1)
+	}
+
+	// rnum больше 0xa зарезервирован
+	cpu.ExecuteInst(ks1i(0xb))
+	if cpu.exception == nil || cpu.exception.cause != ILLEGAL_INSTRUCTION {
+		t.Fatalf("aes64ks1i rnum=0xb: %v", cpu.exception)
+	}
+}
+
+// TestSM4 runs the example of GB/T 32907-2016 through sm4ks and sm4ed
+func TestSM4(t *testing.T) {
+	block, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	want, _ := hex.DecodeString("681edf34d206965e86b3e94f536e4246")
+	fk := [4]uint32{0xa3b1bac6, 0x56aa3350, 0x677d9197, 0xb27022dc}
+	cpu := NewCPU()
+	// round applies T to the word x, byte bs of x is selected by bits 31:30
+	round := func(funct5 uint32, acc, x uint32) uint32 {
+		for bs := uint32(0); bs < 4; bs++ {
+			acc = uint32(cryptoExec(t, cpu, rInst(bs<<5|funct5, 0, 0x33), uint64(acc), uint64(x)))
+		}
+		return acc
+	}
+
+	var k [36]uint32
+	for i := range 4 {
+		k[i] = binary.BigEndian.Uint32(block[4*i:]) ^ fk[i]
+	}
+	var rk [32]uint32
+	for i := range 32 {
+		var ck uint32
+		for j := range 4 {
+			ck = ck<<8 | uint32((4*i+j)*7)&0xff
+		}
+		k[i+4] = round(0x1a, k[i], k[i+1]^k[i+2]^k[i+3]^ck)
+		rk[i] = k[i+4]
+	}
+
+	var x [36]uint32
+	for i := range 4 {
+		x[i] = binary.BigEndian.Uint32(block[4*i:])
+	}
+	for i := range 32 {
+		x[i+4] = round(0x18, x[i], x[i+1]^x[i+2]^x[i+3]^rk[i])
+	}
+	got := make([]byte, 16)
+	for i := range 4 {
+		binary.BigEndian.PutUint32(got[4*i:], x[35-i])
+	}
+	if hex.EncodeToString(got) != hex.EncodeToString(want) {
+		t.Fatalf("encrypt: got %x, want %x", got, want)
+	}
+}
+
+// TestSHA256 hashes "abc" with the sigma and sum instructions
+func TestSHA256(t *testing.T) {
+	cpu := NewCPU()
+	op := func(imm uint32, x uint32) uint32 {
+		res := cryptoExec(t, cpu, unaryInst(imm, 1, 0x13), uint64(x), 0)
+		if res != uint64(int64(int32(res))) {
+			t.Fatalf("sha256 %#x: result %#x is not sign-extended", imm, res)
+		}
+		return uint32(res)
+	}
+	// константы: дробные части кубических корней первых 64 простых чисел
+	var k [64]uint32
+	for n, i := 2, 0; i < 64; n++ {
+		prime := true
+		for d := 2; d*d <= n; d++ {
+			prime = prime && n%d != 0
+		}
+		if prime {
+			c := math.Cbrt(float64(n))
+			k[i] = uint32((c - math.Floor(c)) * (1 << 32))
+			i++
+		}
+	}
+	h := [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+
+	var msg [64]byte
+	copy(msg[:], "abc")
+	msg[3] = 0x80
+	msg[63] = 3 * 8
+	var w [64]uint32
+	for i := range 16 {
+		w[i] = binary.BigEndian.Uint32(msg[4*i:])
+	}
+	for i := 16; i < 64; i++ {
+		w[i] = op(0x103, w[i-2]) + w[i-7] + op(0x102, w[i-15]) + w[i-16]
+	}
+	a, b, c, d, e, f, g, hh := h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7]
+	for i := range 64 {
+		t1 := hh + op(0x101, e) + (e&f ^ ^e&g) + k[i] + w[i]
+		t2 := op(0x100, a) + (a&b ^ a&c ^ b&c)
+		a, b, c, d, e, f, g, hh = t1+t2, a, b, c, d+t1, e, f, g
+	}
+	got := make([]byte, 32)
+	for i, v := range []uint32{a, b, c, d, e, f, g, hh} {
+		binary.BigEndian.PutUint32(got[4*i:], h[i]+v)
+	}
+	if want := sha256.Sum256([]byte("abc")); string(got) != string(want[:]) {
+		t.Fatalf("sha256(abc) = %x, want %x", got, want)
+	}
+}
+
+// TestSM3 hashes "abc" with sm3p0 and sm3p1, the digest is the example
+// of GB/T 32905-2016
+func TestSM3(t *testing.T) {
+	cpu := NewCPU()
+	p0 := func(x uint32) uint32 { return uint32(cryptoExec(t, cpu, unaryInst(0x108, 1, 0x13), uint64(x), 0)) }
+	p1 := func(x uint32) uint32 { return uint32(cryptoExec(t, cpu, unaryInst(0x109, 1, 0x13), uint64(x), 0)) }
+
+	var msg [64]byte
+	copy(msg[:], "abc")
+	msg[3] = 0x80
+	msg[63] = 3 * 8
+	var w [68]uint32
+	var w1 [64]uint32
+	for i := range 16 {
+		w[i] = binary.BigEndian.Uint32(msg[4*i:])
+	}
+	for i := 16; i < 68; i++ {
+		w[i] = p1(w[i-16]^w[i-9]^bits.RotateLeft32(w[i-3], 15)) ^ bits.RotateLeft32(w[i-13], 7) ^ w[i-6]
+	}
+	for i := range 64 {
+		w1[i] = w[i] ^ w[i+4]
+	}
+	v := [8]uint32{0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600, 0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+	a, b, c, d, e, f, g, h := v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]
+	for j := range 64 {
+		tj, ff, gg := uint32(0x79cc4519), a^b^c, e^f^g
+		if j >= 16 {
+			tj, ff, gg = 0x7a879d8a, a&b|a&c|b&c, e&f|^e&g
+		}
+		ss1 := bits.RotateLeft32(bits.RotateLeft32(a, 12)+e+bits.RotateLeft32(tj, j), 7)
+		ss2 := ss1 ^ bits.RotateLeft32(a, 12)
+		tt1 := ff + d + ss2 + w1[j]
+		tt2 := gg + h + ss1 + w[j]
+		a, b, c, d, e, f, g, h = tt1, a, bits.RotateLeft32(b, 9), c, p0(tt2), e, bits.RotateLeft32(f, 19), g
+	}
+	got := make([]byte, 32)
+	for i, x := range []uint32{a, b, c, d, e, f, g, h} {
+		binary.BigEndian.PutUint32(got[4*i:], v[i]^x)
+	}
+	if want := "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"; hex.EncodeToString(got) != want {
+		t.Fatalf("sm3(abc) = %x, want %s", got, want)
+	}
+}
+
+// testEntropy replays fixed seed states
+type testEntropy struct {
+	opst    []uint64
+	entropy uint16
+}
+
+func (e *testEntropy) Poll() (uint64, uint16) {
+	opst := e.opst[0]
+	e.opst = e.opst[1:]
+	return opst, e.entropy
+}
+
+func TestSeed(t *testing.T) {
+	csrInst := func(funct3, rs1 uint32) uint32 {
+		return uint32(SEED)<<20 | rs1<<15 | funct3<<12 | 3<<7 | 0x73
+	}
+	cpu := NewCPU()
+	cpu.SetEntropySource(&testEntropy{opst: []uint64{SEED_OPST_ES16, SEED_OPST_WAIT, SEED_OPST_ES16}, entropy: 0xbeef})
+
+	// csrrw x3, seed, x0
+	cpu.ExecuteInst(csrInst(1, 0))
+	if got := cpu.readReg(3); got != SEED_OPST_ES16<<SEED_OPST_SHIFT|0xbeef {
+		t.Fatalf("seed ES16: %#x", got)
+	}
+	cpu.ExecuteInst(csrInst(1, 0))
+	if got := cpu.readReg(3); got != SEED_OPST_WAIT<<SEED_OPST_SHIFT {
+		t.Fatalf("seed WAIT: %#x", got)
+	}
+
+	// csrrs x3, seed, x0 только читает
+	cpu.ExecuteInst(csrInst(2, 0))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("read-only seed access: mcause=%d", cpu.csr[MCAUSE])
+	}
+
+	// из U-режима доступ разрешает mseccfg.USEED
+	cpu.csr[MCAUSE] = 0
+	cpu.privilege = USER_MODE
+	cpu.ExecuteInst(csrInst(1, 0))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("seed from U-mode: mcause=%d", cpu.csr[MCAUSE])
+	}
+	cpu.privilege = USER_MODE
+	cpu.writeCSR(MSECCFG, MSECCFG_USEED)
+	cpu.csr[MCAUSE] = 0
+	cpu.ExecuteInst(csrInst(1, 0))
+	if cpu.csr[MCAUSE] != 0 || cpu.readReg(3) != SEED_OPST_ES16<<SEED_OPST_SHIFT|0xbeef {
+		t.Fatalf("seed with USEED: mcause=%d seed=%#x", cpu.csr[MCAUSE], cpu.readReg(3))
+	}
+}
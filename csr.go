@@ -0,0 +1,584 @@
+package main
+
+// CSR addresses
+const (
+	FFLAGS uint64 = 0x001
+	FRM    uint64 = 0x002
+	FCSR   uint64 = 0x003
+	VSTART uint64 = 0x008
+	VXSAT  uint64 = 0x009
+	VXRM   uint64 = 0x00a
+	VCSR   uint64 = 0x00f
+	SEED   uint64 = 0x015
+
+	SSTATUS    uint64 = 0x100
+	SIE        uint64 = 0x104
+	STVEC      uint64 = 0x105
+	SCOUNTEREN uint64 = 0x106
+	SSCRATCH   uint64 = 0x140
+	SEPC       uint64 = 0x141
+	SCAUSE     uint64 = 0x142
+	STVAL      uint64 = 0x143
+	SIP        uint64 = 0x144
+	SATP       uint64 = 0x180
+
+	VSSTATUS  uint64 = 0x200
+	VSIE      uint64 = 0x204
+	VSTVEC    uint64 = 0x205
+	VSSCRATCH uint64 = 0x240
+	VSEPC     uint64 = 0x241
+	VSCAUSE   uint64 = 0x242
+	VSTVAL    uint64 = 0x243
+	VSIP      uint64 = 0x244
+	VSATP     uint64 = 0x280
+
+	HSTATUS    uint64 = 0x600
+	HEDELEG    uint64 = 0x602
+	HIDELEG    uint64 = 0x603
+	HIE        uint64 = 0x604
+	HTIMEDELTA uint64 = 0x605
+	HCOUNTEREN uint64 = 0x606
+	HGEIE      uint64 = 0x607
+	HTVAL      uint64 = 0x643
+	HIP        uint64 = 0x644
+	HVIP       uint64 = 0x645
+	HTINST     uint64 = 0x64a
+	HGATP      uint64 = 0x680
+	HGEIP      uint64 = 0xe12
+
+	MSTATUS       uint64 = 0x300
+	MISA          uint64 = 0x301
+	MEDELEG       uint64 = 0x302
+	MIDELEG       uint64 = 0x303
+	MIE           uint64 = 0x304
+	MTVEC         uint64 = 0x305
+	MCOUNTEREN    uint64 = 0x306
+	MCOUNTINHIBIT uint64 = 0x320
+	MHPMEVENT3    uint64 = 0x323
+	MHPMEVENT31   uint64 = 0x33f
+	MSCRATCH      uint64 = 0x340
+	MEPC          uint64 = 0x341
+	MCAUSE        uint64 = 0x342
+	MTVAL         uint64 = 0x343
+	MIP           uint64 = 0x344
+	MTINST        uint64 = 0x34a
+	MTVAL2        uint64 = 0x34b
+	MSECCFG       uint64 = 0x747
+	MVENDORID     uint64 = 0xf11
+	MARCHID       uint64 = 0xf12
+	MIMPID        uint64 = 0xf13
+	MHARTID       uint64 = 0xf14
+	MCONFIGPTR    uint64 = 0xf15
+
+	MCYCLE        uint64 = 0xb00
+	MINSTRET      uint64 = 0xb02
+	MHPMCOUNTER3  uint64 = 0xb03
+	MHPMCOUNTER31 uint64 = 0xb1f
+
+	CYCLE        uint64 = 0xc00
+	TIME         uint64 = 0xc01
+	INSTRET      uint64 = 0xc02
+	HPMCOUNTER3  uint64 = 0xc03
+	HPMCOUNTER31 uint64 = 0xc1f
+
+	VL    uint64 = 0xc20
+	VTYPE uint64 = 0xc21
+	VLENB uint64 = 0xc22
+
+	// старшие половины 64-битных CSR, только RV32
+	MSTATUSH       uint64 = 0x310
+	MCYCLEH        uint64 = 0xb80
+	MINSTRETH      uint64 = 0xb82
+	MHPMCOUNTER3H  uint64 = 0xb83
+	MHPMCOUNTER31H uint64 = 0xb9f
+	CYCLEH         uint64 = 0xc80
+	TIMEH          uint64 = 0xc81
+	INSTRETH       uint64 = 0xc82
+	HPMCOUNTER3H   uint64 = 0xc83
+	HPMCOUNTER31H  uint64 = 0xc9f
+)
+
+// mstatus fields
+const (
+	MSTATUS_SIE       uint64 = 1 << 1
+	MSTATUS_MIE       uint64 = 1 << 3
+	MSTATUS_SPIE      uint64 = 1 << 5
+	MSTATUS_VS_SHIFT  uint64 = 9
+	MSTATUS_VS        uint64 = 3 << MSTATUS_VS_SHIFT
+	MSTATUS_MPIE      uint64 = 1 << 7
+	MSTATUS_SPP_SHIFT uint64 = 8
+	MSTATUS_SPP       uint64 = 1 << MSTATUS_SPP_SHIFT
+	MSTATUS_MPP_SHIFT uint64 = 11
+	MSTATUS_MPP       uint64 = 3 << MSTATUS_MPP_SHIFT
+	MSTATUS_FS_SHIFT  uint64 = 13
+	MSTATUS_FS        uint64 = 3 << MSTATUS_FS_SHIFT
+	MSTATUS_XS        uint64 = 3 << 15
+	MSTATUS_MPRV      uint64 = 1 << 17
+	MSTATUS_SUM       uint64 = 1 << 18
+	MSTATUS_MXR       uint64 = 1 << 19
+	MSTATUS_TVM       uint64 = 1 << 20
+	MSTATUS_TW        uint64 = 1 << 21
+	MSTATUS_TSR       uint64 = 1 << 22
+	MSTATUS_UXL_SHIFT uint64 = 32
+	MSTATUS_UXL       uint64 = 3 << MSTATUS_UXL_SHIFT
+	MSTATUS_SXL_SHIFT uint64 = 34
+	MSTATUS_SXL       uint64 = 3 << MSTATUS_SXL_SHIFT
+	MSTATUS_GVA       uint64 = 1 << 38
+	MSTATUS_MPV       uint64 = 1 << 39
+	MSTATUS_SD        uint64 = 1 << 63
+)
+
+// Fields of mstatus writable by software, SXL and UXL are further
+// legalized by legalizeXL
+const MSTATUS_WRITABLE uint64 = MSTATUS_SIE | MSTATUS_MIE | MSTATUS_SPIE | MSTATUS_MPIE |
+	MSTATUS_SPP | MSTATUS_VS | MSTATUS_MPP | MSTATUS_FS | MSTATUS_MPRV | MSTATUS_SUM |
+	MSTATUS_MXR | MSTATUS_TVM | MSTATUS_TW | MSTATUS_TSR | MSTATUS_UXL | MSTATUS_SXL |
+	MSTATUS_GVA | MSTATUS_MPV
+
+// mstatus fields that exist only with S-mode, U-mode and the hypervisor extension
+const (
+	MSTATUS_S_FIELDS uint64 = MSTATUS_SIE | MSTATUS_SPIE | MSTATUS_SPP | MSTATUS_SUM |
+		MSTATUS_MXR | MSTATUS_TVM | MSTATUS_TSR | MSTATUS_SXL
+	MSTATUS_U_FIELDS uint64 = MSTATUS_MPRV | MSTATUS_TW | MSTATUS_UXL
+	MSTATUS_H_FIELDS uint64 = MSTATUS_GVA | MSTATUS_MPV
+)
+
+// sstatus is a restricted view of mstatus
+const SSTATUS_MASK uint64 = MSTATUS_SIE | MSTATUS_SPIE | MSTATUS_SPP |
+	MSTATUS_VS | MSTATUS_FS | MSTATUS_XS | MSTATUS_SUM | MSTATUS_MXR | MSTATUS_UXL | MSTATUS_SD
+
+// misa fields
+const (
+	MISA_MXL_SHIFT uint64 = 62
+	MISA_MXL_64    uint64 = 2 << MISA_MXL_SHIFT
+)
+
+// misaExt returns the misa bit of a single-letter extension
+func misaExt(ext byte) uint64 {
+	return 1 << (ext - 'A')
+}
+
+// mip/mie bits
+const (
+	MIP_SSIP  uint64 = 1 << 1
+	MIP_VSSIP uint64 = 1 << 2
+	MIP_MSIP  uint64 = 1 << 3
+	MIP_STIP  uint64 = 1 << 5
+	MIP_VSTIP uint64 = 1 << 6
+	MIP_MTIP  uint64 = 1 << 7
+	MIP_SEIP  uint64 = 1 << 9
+	MIP_VSEIP uint64 = 1 << 10
+	MIP_MEIP  uint64 = 1 << 11
+	MIP_SGEIP uint64 = 1 << 12
+
+	// прерывания VS-уровня
+	MIP_VS uint64 = MIP_VSSIP | MIP_VSTIP | MIP_VSEIP
+)
+
+const (
+	// MSIP, MTIP and MEIP are driven by CLINT and PLIC
+	MIP_WRITABLE uint64 = MIP_SSIP | MIP_STIP | MIP_SEIP
+	// interrupts that can be enabled in mie, VS-level ones only with H
+	MIE_WRITABLE uint64 = MIP_SSIP | MIP_MSIP | MIP_STIP | MIP_MTIP | MIP_SEIP | MIP_MEIP
+	// only supervisor interrupts can be delegated
+	MIDELEG_MASK uint64 = MIP_SSIP | MIP_STIP | MIP_SEIP
+	// VS-level and guest external interrupts are always delegated to HS-mode
+	MIDELEG_HYPERVISOR uint64 = MIP_VS | MIP_SGEIP
+	// ecall from M-mode is never delegated
+	MEDELEG_MASK uint64 = (0xffff | 0xf<<INSTRUCTION_GUEST_PAGE_FAULT) &^ (1 << ECALL_FROM_MMODE)
+)
+
+// mstatus.FS/VS/XS states
+const (
+	EXT_STATUS_OFF     uint64 = 0
+	EXT_STATUS_INITIAL uint64 = 1
+	EXT_STATUS_CLEAN   uint64 = 2
+	EXT_STATUS_DIRTY   uint64 = 3
+)
+
+// fcsr fields
+const (
+	FCSR_FFLAGS    uint64 = 0x1f
+	FCSR_FRM_SHIFT uint64 = 5
+	FCSR_FRM       uint64 = 7 << FCSR_FRM_SHIFT
+	FCSR_MASK      uint64 = FCSR_FFLAGS | FCSR_FRM
+)
+
+const (
+	CAUSE_INTERRUPT uint64 = 1 << 63
+
+	TVEC_DIRECT   uint64 = 0
+	TVEC_VECTORED uint64 = 1
+)
+
+// Lowest privilege level of a CSR encoded in address bits 9:8
+const (
+	CSR_LEVEL_USER       uint64 = 0
+	CSR_LEVEL_SUPERVISOR uint64 = 1
+	CSR_LEVEL_HYPERVISOR uint64 = 2 // CSR гипервизора и VS-копии
+	CSR_LEVEL_MACHINE    uint64 = 3
+)
+
+func csrLevel(csr uint64) uint64 {
+	return (csr >> 8) & 3
+}
+
+// csrReadOnly reports whether address bits 11:10 mark csr read-only
+func csrReadOnly(csr uint64) bool {
+	return (csr>>10)&3 == 3
+}
+
+// hasExt reports whether the single-letter extension is enabled in misa
+func (cpu *Cpu) hasExt(ext byte) bool {
+	return cpu.csr[MISA]&misaExt(ext) != 0
+}
+
+// csrImplemented reports whether csr exists on the hart. CSRs of
+// disabled extensions and modes absent from misa are not implemented.
+func (cpu *Cpu) csrImplemented(csr uint64) bool {
+	switch csr {
+	case FFLAGS, FRM, FCSR:
+		return cpu.hasExt('F')
+	case VSTART, VXSAT, VXRM, VCSR, VL, VTYPE, VLENB:
+		return cpu.hasExt('V')
+	case SSTATUS, SIE, STVEC, SCOUNTEREN, SSCRATCH, SEPC, SCAUSE, STVAL, SIP, SATP,
+		MEDELEG, MIDELEG:
+		return cpu.hasExt('S')
+	case VSSTATUS, VSIE, VSTVEC, VSSCRATCH, VSEPC, VSCAUSE, VSTVAL, VSIP, VSATP,
+		HSTATUS, HEDELEG, HIDELEG, HIE, HTIMEDELTA, HCOUNTEREN, HGEIE, HTVAL, HIP,
+		HVIP, HTINST, HGATP, HGEIP, MTINST, MTVAL2:
+		return cpu.hasExt('H')
+	case MCOUNTEREN:
+		return cpu.hasExt('U')
+	case SEED:
+		return cpu.exts&EXT_ZKR != 0
+	case CYCLE, TIME, INSTRET:
+		return cpu.exts&EXT_ZICNTR != 0
+	case MSECCFG, MSTATUS, MISA, MIE, MTVEC, MCOUNTINHIBIT, MSCRATCH, MEPC,
+		MCAUSE, MTVAL, MIP, MVENDORID, MARCHID, MIMPID, MHARTID, MCONFIGPTR,
+		MCYCLE, MINSTRET:
+		return true
+	}
+	switch {
+	case csr >= HPMCOUNTER3 && csr <= HPMCOUNTER31:
+		return cpu.exts&EXT_ZIHPM != 0
+	case csr >= MHPMEVENT3 && csr <= MHPMEVENT31,
+		csr >= MHPMCOUNTER3 && csr <= MHPMCOUNTER31,
+		csr >= PMPADDR0 && csr <= PMPADDR63:
+		return true
+	case csr >= PMPCFG0 && csr <= PMPCFG15:
+		// нечётные pmpcfg существуют только в RV32
+		return csr&1 == 0 || cpu.xlen == 32
+	}
+	return false
+}
+
+// csrAccessible reports whether the current instruction may access csr,
+// write is set when the instruction also writes it. Unimplemented CSRs,
+// writes to read-only ones and CSRs of a higher privilege level than
+// the current one are not accessible.
+func (cpu *Cpu) csrAccessible(csr uint64, write bool) bool {
+	if base, ok := highHalfOf(csr); ok {
+		// старшие половины доступны только в RV32
+		if cpu.xlen != 32 {
+			return false
+		}
+		csr = base
+	}
+	if !cpu.csrImplemented(csr) || (write && csrReadOnly(csr)) {
+		return false
+	}
+	// в VS/VU-режиме права проверяются как для HS-режима,
+	// остальное решает csrVirtualFault
+	priv, level := uint64(cpu.privilege), csrLevel(csr)
+	if cpu.virt {
+		priv = uint64(SUPERVISOR_MODE)
+	}
+	if level == CSR_LEVEL_HYPERVISOR {
+		level = CSR_LEVEL_SUPERVISOR
+	}
+	if priv < level {
+		return false
+	}
+	switch csr {
+	case FFLAGS, FRM, FCSR:
+		// при mstatus.FS = Off состояние FPU недоступно
+		return cpu.fpEnabled()
+	case VSTART, VXSAT, VXRM, VCSR, VL, VTYPE, VLENB:
+		return cpu.vsEnabled()
+	case SEED:
+		// чтение seed без записи недопустимо
+		return write && cpu.seedAccessible()
+	case SATP, HGATP:
+		// mstatus.TVM перехватывает трансляцию в HS-режиме,
+		// на vsatp действует hstatus.VTVM
+		return cpu.virt || cpu.privilege != SUPERVISOR_MODE || cpu.csr[MSTATUS]&MSTATUS_TVM == 0
+	}
+	if csr >= CYCLE && csr <= HPMCOUNTER31 {
+		return cpu.counterAccessible(csr - CYCLE)
+	}
+	return true
+}
+
+// csrCheck raises illegal instruction when the csr instruction is not
+// permitted, or virtual instruction when it is permitted only with V=0
+func (cpu *Cpu) csrCheck(inst InstWord, write bool) bool {
+	if !cpu.csrAccessible(inst.csr(), write) {
+		cpu.IllegalInst(uint32(inst))
+		return false
+	}
+	if cpu.virt && cpu.csrVirtualFault(inst.csr()) {
+		cpu.VirtualInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
+// statusSD sets the summary dirty bit of mstatus/sstatus
+func statusSD(status uint64) uint64 {
+	if status&MSTATUS_FS == MSTATUS_FS || status&MSTATUS_VS == MSTATUS_VS ||
+		status&MSTATUS_XS == MSTATUS_XS {
+		return status | MSTATUS_SD
+	}
+	return status &^ MSTATUS_SD
+}
+
+func (cpu *Cpu) readCSR(csr uint64) uint64 {
+	csr = cpu.virtualAlias(csr)
+	switch {
+	case csr == FFLAGS:
+		return cpu.csr[FCSR] & FCSR_FFLAGS
+	case csr == FRM:
+		return (cpu.csr[FCSR] & FCSR_FRM) >> FCSR_FRM_SHIFT
+	case csr == VXSAT:
+		return cpu.csr[VCSR] & VCSR_VXSAT
+	case csr == VXRM:
+		return (cpu.csr[VCSR] & VCSR_VXRM) >> VCSR_VXRM_SHIFT
+	case csr == VLENB:
+		return cpu.vlenb()
+	case csr == SEED:
+		return cpu.readSeed()
+	case csr == TIME:
+		if cpu.virt {
+			return cpu.timer.Mtime() + cpu.csr[HTIMEDELTA]
+		}
+		return cpu.timer.Mtime()
+	case csr >= CYCLE && csr <= HPMCOUNTER31:
+		// пользовательские счётчики отображают машинные
+		return cpu.csr[csr-CYCLE+MCYCLE]
+	case csr == MSTATUS:
+		return statusSD(cpu.csr[MSTATUS])
+	case csr == SSTATUS:
+		return statusSD(cpu.csr[MSTATUS]) & SSTATUS_MASK
+	case csr == SIE:
+		return cpu.csr[MIE] & cpu.csr[MIDELEG]
+	case csr == SIP:
+		return (cpu.csr[MIP] | cpu.irqLines) & cpu.csr[MIDELEG]
+	case csr == MIP:
+		return cpu.csr[MIP] | cpu.irqLines | cpu.csr[HVIP]&MIP_VS
+	case csr == MIDELEG:
+		return cpu.csr[MIDELEG] | MIDELEG_HYPERVISOR
+	case csr == VSSTATUS:
+		return statusSD(cpu.csr[VSSTATUS]) & SSTATUS_MASK
+	case csr == VSIE:
+		// VS-прерывания видны гостю как соответствующие S-прерывания
+		return (cpu.csr[MIE] & cpu.csr[HIDELEG]) >> 1
+	case csr == VSIP:
+		return (cpu.readCSR(MIP) & cpu.csr[HIDELEG]) >> 1
+	case csr == HIE:
+		return cpu.csr[MIE] & MIDELEG_HYPERVISOR
+	case csr == HIP:
+		return cpu.readCSR(MIP) & MIDELEG_HYPERVISOR
+	case csr == MEPC || csr == SEPC || csr == VSEPC:
+		// без C-расширения бит 1 маскируется при чтении
+		if cpu.csr[MISA]&misaExt('C') == 0 {
+			return cpu.csr[csr] &^ 2
+		}
+		return cpu.csr[csr]
+	case csr >= PMPCFG0 && csr <= PMPCFG15:
+		return cpu.pmp.readCfg(csr - PMPCFG0)
+	case csr >= PMPADDR0 && csr <= PMPADDR63:
+		return cpu.pmp.readAddr(csr - PMPADDR0)
+	default:
+		return cpu.csr[csr]
+	}
+}
+
+// csrModifyBase returns the value csrrs and csrrc set or clear bits in.
+// mip and sip read the interrupt lines of devices, but only the bits
+// stored in mip are written back.
+func (cpu *Cpu) csrModifyBase(csr uint64, data uint64) uint64 {
+	switch csr {
+	case MIP:
+		return data&^cpu.irqLines | cpu.csr[MIP]&cpu.irqLines
+	case SIP:
+		return data&^cpu.irqLines | cpu.csr[MIP]&cpu.irqLines&cpu.csr[MIDELEG]
+	}
+	return data
+}
+
+func (cpu *Cpu) writeCSR(csr uint64, data uint64) {
+	csr = cpu.virtualAlias(csr)
+	switch {
+	case csr == FFLAGS:
+		cpu.csr[FCSR] = (cpu.csr[FCSR] &^ FCSR_FFLAGS) | (data & FCSR_FFLAGS)
+		cpu.markFSDirty()
+	case csr == FRM:
+		cpu.csr[FCSR] = (cpu.csr[FCSR] &^ FCSR_FRM) | ((data << FCSR_FRM_SHIFT) & FCSR_FRM)
+		cpu.markFSDirty()
+	case csr == FCSR:
+		cpu.csr[FCSR] = data & FCSR_MASK
+		cpu.markFSDirty()
+	case csr == VSTART:
+		// хранится столько бит, сколько нужно для индекса элемента
+		cpu.csr[csr] = data & (cpu.vcfg.VLEN - 1)
+		cpu.markVSDirty()
+	case csr == VXSAT:
+		cpu.csr[VCSR] = (cpu.csr[VCSR] &^ VCSR_VXSAT) | (data & VCSR_VXSAT)
+		cpu.markVSDirty()
+	case csr == VXRM:
+		cpu.csr[VCSR] = (cpu.csr[VCSR] &^ VCSR_VXRM) | ((data << VCSR_VXRM_SHIFT) & VCSR_VXRM)
+		cpu.markVSDirty()
+	case csr == VCSR:
+		cpu.csr[csr] = data & (VCSR_VXSAT | VCSR_VXRM)
+		cpu.markVSDirty()
+	case csr == VL || csr == VTYPE || csr == VLENB:
+		// изменяются только инструкциями vset{i}vl{i}
+	case csr == SEED:
+		// записанное значение игнорируется
+	case csr == MSECCFG:
+		cpu.csr[csr] = data & (MSECCFG_USEED | MSECCFG_SSEED)
+	case csr == MCOUNTEREN || csr == SCOUNTEREN || csr == HCOUNTEREN:
+		cpu.csr[csr] = data & COUNTEREN_MASK
+	case csr == MCOUNTINHIBIT:
+		cpu.csr[csr] = data & COUNTINHIBIT_MASK
+	case csr == MCYCLE || csr == MINSTRET || (csr >= MHPMCOUNTER3 && csr <= MHPMCOUNTER31):
+		cpu.csr[csr] = data
+		cpu.countersWritten |= 1 << (csr - MCYCLE)
+	case csr >= MHPMEVENT3 && csr <= MHPMEVENT31:
+		cpu.writeHpmEvent(csr, data)
+	case csr == MSTATUS:
+		cpu.writeStatus(data)
+	case csr == SSTATUS:
+		cpu.writeStatus((cpu.csr[MSTATUS] &^ SSTATUS_MASK) | (data & SSTATUS_MASK))
+	case csr == MIE:
+		mask := MIE_WRITABLE
+		if cpu.hasExt('H') {
+			mask |= MIDELEG_HYPERVISOR
+		}
+		cpu.csr[csr] = data & mask
+	case csr == MTVEC || csr == STVEC || csr == VSTVEC:
+		// режимы 2 и 3 зарезервированы, запись с ними сохраняет прежний
+		if data&3 > TVEC_VECTORED {
+			data = data&^3 | cpu.csr[csr]&3
+		}
+		cpu.csr[csr] = data
+	case csr == SIE:
+		mask := cpu.csr[MIDELEG]
+		cpu.csr[MIE] = (cpu.csr[MIE] &^ mask) | (data & mask)
+	case csr == SIP:
+		// из S-режима можно изменить только SSIP
+		mask := cpu.csr[MIDELEG] & MIP_SSIP
+		cpu.csr[MIP] = (cpu.csr[MIP] &^ mask) | (data & mask)
+	case csr == MIP:
+		cpu.csr[csr] = (cpu.csr[csr] &^ MIP_WRITABLE) | (data & MIP_WRITABLE)
+		// mip.VSSIP отображает hvip.VSSIP
+		cpu.csr[HVIP] = (cpu.csr[HVIP] &^ MIP_VSSIP) | (data & MIP_VSSIP)
+	case csr == VSSTATUS:
+		cpu.csr[csr] = data & SSTATUS_MASK &^ (MSTATUS_SD | MSTATUS_XS)
+	case csr == VSIE:
+		mask := cpu.csr[HIDELEG]
+		cpu.csr[MIE] = (cpu.csr[MIE] &^ mask) | ((data << 1) & mask)
+	case csr == VSIP:
+		mask := cpu.csr[HIDELEG] & MIP_VSSIP
+		cpu.csr[HVIP] = (cpu.csr[HVIP] &^ mask) | ((data << 1) & mask)
+	case csr == HSTATUS:
+		cpu.csr[csr] = (cpu.csr[csr] &^ HSTATUS_WRITABLE) | (data & HSTATUS_WRITABLE)
+	case csr == HEDELEG:
+		cpu.csr[csr] = data & HEDELEG_MASK
+	case csr == HIDELEG:
+		cpu.csr[csr] = data & MIP_VS
+	case csr == HIE:
+		cpu.csr[MIE] = (cpu.csr[MIE] &^ MIDELEG_HYPERVISOR) | (data & MIDELEG_HYPERVISOR)
+	case csr == HIP:
+		// изменяем только VSSIP, остальные биты отражают hvip и устройства
+		cpu.csr[HVIP] = (cpu.csr[HVIP] &^ MIP_VSSIP) | (data & MIP_VSSIP)
+	case csr == HVIP:
+		cpu.csr[csr] = data & MIP_VS
+	case csr == HGEIE || csr == HGEIP:
+		// внешние прерывания гостей не реализованы: GEILEN = 0
+	case csr == HGATP:
+		// поддерживаются Sv39x4 и Sv48x4, корневая таблица выровнена на 16 КиБ
+		switch data >> SATP_MODE_SHIFT {
+		case SATP_MODE_BARE, SATP_MODE_SV39, SATP_MODE_SV48:
+			cpu.csr[csr] = data & (HGATP_MODE | HGATP_VMID | SATP_PPN&^3)
+		}
+	case csr == MISA:
+		// изменяемо только расширение C, если оно включено в конфигурации;
+		// его нельзя выключить, если следующая инструкция не выровнена на 4 байта
+		c := misaExt('C')
+		if cpu.exts&EXT_C == 0 || (data&c == 0 && (cpu.pc+cpu.ilen)&3 != 0) {
+			return
+		}
+		cpu.csr[csr] = (cpu.csr[csr] &^ c) | (data & c)
+	case csr == MEPC || csr == SEPC || csr == VSEPC:
+		cpu.csr[csr] = data &^ 1
+	case csr == MEDELEG:
+		cpu.csr[csr] = data & MEDELEG_MASK
+	case csr == MIDELEG:
+		cpu.csr[csr] = data & MIDELEG_MASK
+	case csr == SATP || csr == VSATP:
+		// запись с неподдерживаемым режимом игнорируется,
+		// Sv32 записывается только при SXLEN=32, см. writeCSRView
+		if mode := data >> SATP_MODE_SHIFT; mode == SATP_MODE_BARE || (mode != SATP_MODE_SV32 && pagingLevels(data) != 0) {
+			cpu.csr[csr] = data
+		}
+	case csr >= PMPCFG0 && csr <= PMPCFG15:
+		cpu.pmp.writeCfg(csr-PMPCFG0, data)
+	case csr >= PMPADDR0 && csr <= PMPADDR63:
+		cpu.pmp.writeAddr(csr-PMPADDR0, data)
+	default:
+		cpu.csr[csr] = data
+	}
+}
+
+// privSupported reports whether mode can be held in mstatus.MPP
+func (cpu *Cpu) privSupported(mode PrivMode) bool {
+	switch mode {
+	case MACHINE_MODE:
+		return true
+	case SUPERVISOR_MODE:
+		return cpu.hasExt('S')
+	case USER_MODE:
+		return cpu.hasExt('U')
+	}
+	return false
+}
+
+// writeStatus writes mstatus keeping its WARL fields legal: fields of
+// absent modes and extensions are zero, XS and SD are read-only and
+// MPP keeps its value when written with an unsupported mode
+func (cpu *Cpu) writeStatus(data uint64) {
+	old := cpu.csr[MSTATUS]
+	status := old&^MSTATUS_WRITABLE | data&MSTATUS_WRITABLE
+	if !cpu.privSupported(PrivMode((status & MSTATUS_MPP) >> MSTATUS_MPP_SHIFT)) {
+		status = status&^MSTATUS_MPP | old&MSTATUS_MPP
+	}
+	var absent uint64
+	if !cpu.hasExt('S') {
+		absent |= MSTATUS_S_FIELDS
+	}
+	if !cpu.hasExt('U') {
+		absent |= MSTATUS_U_FIELDS
+	}
+	if !cpu.hasExt('H') {
+		absent |= MSTATUS_H_FIELDS
+	}
+	if !cpu.hasExt('F') {
+		absent |= MSTATUS_FS
+	}
+	if !cpu.hasExt('V') {
+		absent |= MSTATUS_VS
+	}
+	cpu.csr[MSTATUS] = cpu.legalizeXL(old, status) &^ absent
+}
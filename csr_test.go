@@ -0,0 +1,119 @@
+package main
+
+import "testing"
+
+func TestCSRPermissions(t *testing.T) {
+	csrrs := func(csr uint64) uint32 { return uint32(csr)<<20 | 2<<12 | 3<<7 | 0x73 }
+	tests := []struct {
+		name   string
+		priv   PrivMode
+		virt   bool
+		inst   uint32
+		status uint64
+		want   ExceptionCause // 0 - без исключения
+	}{
+		{"mstatus from S", SUPERVISOR_MODE, false, csrrs(MSTATUS), 0, ILLEGAL_INSTRUCTION},
+		{"mscratch from M", MACHINE_MODE, false, csrInst(MSCRATCH, 1), 0, 0},
+		{"sstatus from S", SUPERVISOR_MODE, false, csrrs(SSTATUS), 0, 0},
+		{"sstatus from U", USER_MODE, false, csrrs(SSTATUS), 0, ILLEGAL_INSTRUCTION},
+		{"sstatus from VU", USER_MODE, true, csrrs(SSTATUS), 0, VIRTUAL_INSTRUCTION},
+		{"hstatus from U", USER_MODE, false, csrrs(HSTATUS), 0, ILLEGAL_INSTRUCTION},
+		{"hstatus from VS", SUPERVISOR_MODE, true, csrrs(HSTATUS), 0, VIRTUAL_INSTRUCTION},
+		{"mhartid read", MACHINE_MODE, false, csrrs(MHARTID), 0, 0},
+		{"mhartid write", MACHINE_MODE, false, csrInst(MHARTID, 1), 0, ILLEGAL_INSTRUCTION},
+		{"mhartid set bits", MACHINE_MODE, false, csrInst(MHARTID, 2), 0, ILLEGAL_INSTRUCTION},
+		{"mvendorid read", MACHINE_MODE, false, csrrs(MVENDORID), 0, 0},
+		{"vl write", MACHINE_MODE, false, csrInst(VL, 1), MSTATUS_VS, ILLEGAL_INSTRUCTION},
+		{"unimplemented", MACHINE_MODE, false, csrrs(0x7c0), 0, ILLEGAL_INSTRUCTION},
+		{"unimplemented in VS", SUPERVISOR_MODE, true, csrrs(0x5c0), 0, ILLEGAL_INSTRUCTION},
+		{"pmpcfg1 on RV64", MACHINE_MODE, false, csrrs(PMPCFG0 + 1), 0, ILLEGAL_INSTRUCTION},
+		{"satp with TVM", SUPERVISOR_MODE, false, csrrs(SATP), MSTATUS_TVM, ILLEGAL_INSTRUCTION},
+		{"vsatp with TVM", SUPERVISOR_MODE, true, csrrs(SATP), MSTATUS_TVM, 0},
+		{"hgatp with TVM", SUPERVISOR_MODE, false, csrrs(HGATP), MSTATUS_TVM, ILLEGAL_INSTRUCTION},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		cpu.privilege, cpu.virt = tt.priv, tt.virt
+		cpu.csr[MSTATUS] |= tt.status
+		cpu.ExecuteInst(tt.inst)
+		switch {
+		case tt.want == 0 && cpu.exception != nil:
+			t.Errorf("%s: unexpected %v", tt.name, cpu.exception)
+		case tt.want != 0 && (cpu.exception == nil || cpu.exception.cause != tt.want):
+			t.Errorf("%s: exception=%v, want cause %d", tt.name, cpu.exception, tt.want)
+		}
+	}
+}
+
+func TestCSRWithoutExtensions(t *testing.T) {
+	cpu := NewCPU()
+	cpu.csr[MISA] &^= misaExt('F') | misaExt('D') | misaExt('S') | misaExt('H')
+	for _, csr := range []uint64{FCSR, FFLAGS, SSTATUS, SATP, MEDELEG, HSTATUS, MTINST} {
+		if _, ok := csrRead(cpu, csr); ok {
+			t.Errorf("csr %#x is accessible without its extension", csr)
+		}
+	}
+	cpu.writeCSR(MSTATUS, MSTATUS_FS|MSTATUS_SIE|MSTATUS_TVM|MSTATUS_MPV|MSTATUS_MIE)
+	if got := cpu.csr[MSTATUS] & MSTATUS_WRITABLE; got != MSTATUS_MIE|XL_64<<MSTATUS_UXL_SHIFT {
+		t.Errorf("mstatus=%#x, want only MIE and UXL", got)
+	}
+	cpu.writeCSR(MSTATUS, uint64(SUPERVISOR_MODE)<<MSTATUS_MPP_SHIFT)
+	if mpp := cpu.csr[MSTATUS] & MSTATUS_MPP; mpp != 0 {
+		t.Errorf("MPP=%#x without S-mode", mpp)
+	}
+}
+
+func TestCSRWarl(t *testing.T) {
+	cpu := NewCPU()
+
+	cpu.writeCSR(MSTATUS, MSTATUS_MPP|MSTATUS_XS|MSTATUS_SD|1<<4)
+	if got := cpu.csr[MSTATUS] &^ (MSTATUS_UXL | MSTATUS_SXL); got != MSTATUS_MPP {
+		t.Errorf("mstatus=%#x, want MPP=M only", got)
+	}
+	// MPP=2 зарезервирован
+	cpu.writeCSR(MSTATUS, 2<<MSTATUS_MPP_SHIFT)
+	if mpp := cpu.csr[MSTATUS] & MSTATUS_MPP; mpp != MSTATUS_MPP {
+		t.Errorf("reserved MPP written: %#x", mpp)
+	}
+
+	cpu.writeCSR(MTVEC, 0x80001001)
+	cpu.writeCSR(MTVEC, 0x80002002)
+	if got := cpu.csr[MTVEC]; got != 0x80002001 {
+		t.Errorf("mtvec=%#x, want 0x80002001", got)
+	}
+
+	cpu.writeCSR(MIE, ^uint64(0))
+	if want := MIE_WRITABLE | MIDELEG_HYPERVISOR; cpu.csr[MIE] != want {
+		t.Errorf("mie=%#x, want %#x", cpu.csr[MIE], want)
+	}
+
+	cpu.writeCSR(VSSTATUS, ^uint64(0))
+	if got := cpu.csr[VSSTATUS] & (MSTATUS_XS | MSTATUS_SD); got != 0 {
+		t.Errorf("vsstatus read-only fields written: %#x", got)
+	}
+}
+
+func TestCSRAliases(t *testing.T) {
+	cpu := NewCPU()
+	cpu.csr[MSTATUS] |= MSTATUS_FS
+
+	// sstatus пишет в mstatus, не затрагивая машинные поля
+	cpu.writeCSR(MSTATUS, cpu.csr[MSTATUS]|MSTATUS_MIE)
+	cpu.writeReg(1, MSTATUS_SIE)
+	cpu.ExecuteInst(csrInst(SSTATUS, 2))
+	if cpu.csr[MSTATUS]&(MSTATUS_SIE|MSTATUS_MIE) != MSTATUS_SIE|MSTATUS_MIE {
+		t.Errorf("mstatus=%#x after sstatus write", cpu.csr[MSTATUS])
+	}
+
+	cpu.writeCSR(FCSR, 3<<FCSR_FRM_SHIFT|FFLAGS_NX)
+	if got, _ := csrRead(cpu, FFLAGS); got != FFLAGS_NX {
+		t.Errorf("fflags=%#x, want NX", got)
+	}
+	if got, _ := csrRead(cpu, FRM); got != 3 {
+		t.Errorf("frm=%d, want 3", got)
+	}
+	cpu.writeCSR(FFLAGS, FFLAGS_DZ)
+	if got, _ := csrRead(cpu, FCSR); got != 3<<FCSR_FRM_SHIFT|FFLAGS_DZ {
+		t.Errorf("fcsr=%#x after fflags write", got)
+	}
+}
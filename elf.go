@@ -3,6 +3,7 @@ package main
 import (
 	"debug/elf"
 	"encoding/binary"
+	"fmt"
 )
 
 func ParseElf(path string) *elf.File {
@@ -13,14 +14,16 @@ func ParseElf(path string) *elf.File {
 	return exe
 }
 
-func loadData2Memory(mem Dram, data []byte, addr uint64) {
-	// _ = m[addr+uint64(len(data))] // check length
+func loadData2Memory(bus *Bus, data []byte, addr uint64) error {
 	for i := range data {
-		mem.Write8(addr+uint64(i), uint64(data[i]))
+		if err := bus.Write(addr+uint64(i), uint64(data[i]), BYTE); err != nil {
+			return fmt.Errorf("Failed to load segment byte at %#x: %w", addr+uint64(i), err)
+		}
 	}
+	return nil
 }
 
-func LoadSegments(f *elf.File, memory Dram) {
+func LoadSegments(f *elf.File, bus *Bus) {
 	fheader := f.FileHeader
 	if fheader.ByteOrder != binary.LittleEndian ||
 		fheader.Type != elf.ET_EXEC ||
@@ -34,7 +37,9 @@ func LoadSegments(f *elf.File, memory Dram) {
 			if n != int(prog.Filesz) {
 				panic("Failed to read 'memsz' bytes")
 			}
-			loadData2Memory(memory, data, prog.Vaddr)
+			if err := loadData2Memory(bus, data, prog.Paddr); err != nil {
+				panic(err)
+			}
 		}
 	}
 }
@@ -1,15 +1,324 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 )
 
-var ECallFromUser = errors.New("Environmental call from user mode")
-var ECallFromSupervisor = errors.New("Environmental call from supervisor mode")
-var ECallFromReserved = errors.New("Can't make a call from mode")
-var ECallFromMachine = errors.New("Environmental call from machine mode")
+type ExceptionCause uint64
 
-func IllegalInst(inst uint32) {
-	panic(fmt.Errorf("Illegal instruction: %#x.", inst))
+// Synchronous exception codes written to mcause/scause
+const (
+	INSTRUCTION_ADDRESS_MISALIGNED ExceptionCause = 0
+	INSTRUCTION_ACCESS_FAULT       ExceptionCause = 1
+	ILLEGAL_INSTRUCTION            ExceptionCause = 2
+	BREAKPOINT                     ExceptionCause = 3
+	LOAD_ADDRESS_MISALIGNED        ExceptionCause = 4
+	LOAD_ACCESS_FAULT              ExceptionCause = 5
+	STORE_AMO_ADDRESS_MISALIGNED   ExceptionCause = 6
+	STORE_AMO_ACCESS_FAULT         ExceptionCause = 7
+	ECALL_FROM_UMODE               ExceptionCause = 8
+	ECALL_FROM_SMODE               ExceptionCause = 9
+	ECALL_FROM_VSMODE              ExceptionCause = 10
+	ECALL_FROM_MMODE               ExceptionCause = 11
+	INSTRUCTION_PAGE_FAULT         ExceptionCause = 12
+	LOAD_PAGE_FAULT                ExceptionCause = 13
+	STORE_AMO_PAGE_FAULT           ExceptionCause = 15
+	INSTRUCTION_GUEST_PAGE_FAULT   ExceptionCause = 20
+	LOAD_GUEST_PAGE_FAULT          ExceptionCause = 21
+	VIRTUAL_INSTRUCTION            ExceptionCause = 22
+	STORE_AMO_GUEST_PAGE_FAULT     ExceptionCause = 23
+)
+
+// Interrupt codes written to mcause/scause with CAUSE_INTERRUPT bit set
+const (
+	SUPERVISOR_SOFTWARE_INTERRUPT         uint64 = 1
+	VIRTUAL_SUPERVISOR_SOFTWARE_INTERRUPT uint64 = 2
+	MACHINE_SOFTWARE_INTERRUPT            uint64 = 3
+	SUPERVISOR_TIMER_INTERRUPT            uint64 = 5
+	VIRTUAL_SUPERVISOR_TIMER_INTERRUPT    uint64 = 6
+	MACHINE_TIMER_INTERRUPT               uint64 = 7
+	SUPERVISOR_EXTERNAL_INTERRUPT         uint64 = 9
+	VIRTUAL_SUPERVISOR_EXTERNAL_INTERRUPT uint64 = 10
+	MACHINE_EXTERNAL_INTERRUPT            uint64 = 11
+	SUPERVISOR_GUEST_EXTERNAL_INTERRUPT   uint64 = 12
+)
+
+// interruptPriority lists interrupts from the highest priority
+var interruptPriority = [...]uint64{
+	MACHINE_EXTERNAL_INTERRUPT,
+	MACHINE_SOFTWARE_INTERRUPT,
+	MACHINE_TIMER_INTERRUPT,
+	SUPERVISOR_EXTERNAL_INTERRUPT,
+	SUPERVISOR_SOFTWARE_INTERRUPT,
+	SUPERVISOR_TIMER_INTERRUPT,
+	SUPERVISOR_GUEST_EXTERNAL_INTERRUPT,
+	VIRTUAL_SUPERVISOR_EXTERNAL_INTERRUPT,
+	VIRTUAL_SUPERVISOR_SOFTWARE_INTERRUPT,
+	VIRTUAL_SUPERVISOR_TIMER_INTERRUPT,
+}
+
+var exceptionNames = map[ExceptionCause]string{
+	INSTRUCTION_ADDRESS_MISALIGNED: "Instruction address misaligned",
+	INSTRUCTION_ACCESS_FAULT:       "Instruction access fault",
+	ILLEGAL_INSTRUCTION:            "Illegal instruction",
+	BREAKPOINT:                     "Breakpoint",
+	LOAD_ADDRESS_MISALIGNED:        "Load address misaligned",
+	LOAD_ACCESS_FAULT:              "Load access fault",
+	STORE_AMO_ADDRESS_MISALIGNED:   "Store/AMO address misaligned",
+	STORE_AMO_ACCESS_FAULT:         "Store/AMO access fault",
+	ECALL_FROM_UMODE:               "Environmental call from user mode",
+	ECALL_FROM_SMODE:               "Environmental call from supervisor mode",
+	ECALL_FROM_VSMODE:              "Environmental call from virtual supervisor mode",
+	ECALL_FROM_MMODE:               "Environmental call from machine mode",
+	INSTRUCTION_PAGE_FAULT:         "Instruction page fault",
+	LOAD_PAGE_FAULT:                "Load page fault",
+	STORE_AMO_PAGE_FAULT:           "Store/AMO page fault",
+	INSTRUCTION_GUEST_PAGE_FAULT:   "Instruction guest-page fault",
+	LOAD_GUEST_PAGE_FAULT:          "Load guest-page fault",
+	VIRTUAL_INSTRUCTION:            "Virtual instruction",
+	STORE_AMO_GUEST_PAGE_FAULT:     "Store/AMO guest-page fault",
+}
+
+// Exception is a synchronous trap raised while executing an instruction.
+// tval is the value written to mtval: faulting address or instruction bits.
+// Traps into M-mode and HS-mode also report tval2 (mtval2/htval), tinst
+// (mtinst/htinst) and whether tval is a guest virtual address.
+type Exception struct {
+	cause ExceptionCause
+	tval  uint64
+	tval2 uint64 // гостевой физический адрес, сдвинутый на 2 бита
+	tinst uint64 // псевдоинструкция неявного доступа к таблицам VS-стадии
+	gva   bool
+}
+
+func (e *Exception) Error() string {
+	name, ok := exceptionNames[e.cause]
+	if !ok {
+		name = "Unknown exception"
+	}
+	return fmt.Sprintf("%s (cause=%d, tval=%#x)", name, e.cause, e.tval)
+}
+
+// raise records an exception for the instruction being executed.
+// Only the first one counts, ExecuteInst turns it into a trap.
+func (cpu *Cpu) raise(cause ExceptionCause, tval uint64) {
+	if cpu.exception == nil {
+		// адрес в tval гостевой, если доступ выполнялся с V=1
+		gva := (cpu.virt || cpu.hlsv) && addressCause(cause)
+		cpu.exception = &Exception{cause: cause, tval: tval, gva: gva}
+	}
+}
+
+// addressCause reports whether tval of the exception holds a virtual address
+func addressCause(cause ExceptionCause) bool {
+	switch cause {
+	case ILLEGAL_INSTRUCTION, ECALL_FROM_UMODE, ECALL_FROM_SMODE, ECALL_FROM_VSMODE,
+		ECALL_FROM_MMODE, VIRTUAL_INSTRUCTION:
+		return false
+	}
+	return true
+}
+
+// IllegalInst raises illegal instruction exception with the instruction
+// bits as tval, compressed instructions are reported as fetched
+func (cpu *Cpu) IllegalInst(inst uint32) {
+	if cpu.ilen == 2 {
+		inst = cpu.rawInst & 0xffff
+	}
+	cpu.raise(ILLEGAL_INSTRUCTION, uint64(inst))
+}
+
+// VirtualInst raises virtual instruction exception: the instruction is
+// permitted in HS-mode but not in VS/VU-mode
+func (cpu *Cpu) VirtualInst(inst uint32) {
+	if cpu.ilen == 2 {
+		inst = cpu.rawInst & 0xffff
+	}
+	cpu.raise(VIRTUAL_INSTRUCTION, uint64(inst))
+}
+
+// InterruptSource is a device that drives interrupt-pending bits of mip
+type InterruptSource interface {
+	Pending(hart uint64) uint64
+}
+
+// ConnectInterrupts wires the source to this hart
+func (cpu *Cpu) ConnectInterrupts(src InterruptSource) {
+	cpu.irqSources = append(cpu.irqSources, src)
+}
+
+// updateInterrupts samples interrupt lines of connected devices
+func (cpu *Cpu) updateInterrupts() {
+	var lines uint64
+	for _, src := range cpu.irqSources {
+		lines |= src.Pending(cpu.csr[MHARTID])
+	}
+	cpu.irqLines = lines
+}
+
+// pendingInterrupt selects the interrupt to take: M-level interrupts are
+// enabled below M-mode or by mstatus.MIE, delegated ones below S-mode,
+// in V-mode or by mstatus.SIE in HS-mode. VS-level interrupts delegated
+// via hideleg are taken only with V=1: in VU-mode or by vsstatus.SIE.
+func (cpu *Cpu) pendingInterrupt() (uint64, bool) {
+	pending := cpu.readCSR(MIP) & cpu.csr[MIE]
+	if pending == 0 {
+		return 0, false
+	}
+	mstatus := cpu.csr[MSTATUS]
+	deleg := cpu.readCSR(MIDELEG)
+	hdeleg := cpu.csr[HIDELEG]
+	var enabled uint64
+	if cpu.privilege < MACHINE_MODE || mstatus&MSTATUS_MIE != 0 {
+		enabled = pending &^ deleg
+	}
+	if enabled == 0 && (cpu.virt || cpu.privilege < SUPERVISOR_MODE ||
+		(cpu.privilege == SUPERVISOR_MODE && mstatus&MSTATUS_SIE != 0)) {
+		enabled = pending & deleg &^ hdeleg
+	}
+	if enabled == 0 && cpu.virt && (cpu.privilege < SUPERVISOR_MODE ||
+		cpu.csr[VSSTATUS]&MSTATUS_SIE != 0) {
+		enabled = pending & hdeleg
+	}
+	for _, cause := range interruptPriority {
+		if enabled&(1<<cause) != 0 {
+			return cause, true
+		}
+	}
+	return 0, false
+}
+
+// takeTrap enters the trap handler: saves pc and cause, pushes
+// xIE/privilege onto the mstatus stack and jumps to xtvec.
+// Traps from S/U-mode go to HS-mode if delegated via medeleg/mideleg,
+// traps from VS/VU-mode further go to VS-mode if delegated via
+// hedeleg/hideleg.
+func (cpu *Cpu) takeTrap(cause uint64, tval uint64, interrupt bool) {
+	var e Exception
+	if interrupt {
+		cpu.countEvent(HPM_EVENT_INTERRUPT)
+	} else {
+		cpu.countEvent(HPM_EVENT_EXCEPTION)
+		if cpu.exception != nil {
+			e = *cpu.exception
+		}
+	}
+	deleg, hdeleg := cpu.csr[MEDELEG], cpu.csr[HEDELEG]
+	if interrupt {
+		deleg, hdeleg = cpu.readCSR(MIDELEG), cpu.csr[HIDELEG]
+	}
+	if cpu.privilege <= SUPERVISOR_MODE && (deleg>>cause)&1 == 1 {
+		if cpu.virt && (hdeleg>>cause)&1 == 1 {
+			cpu.trapToVirtualSupervisor(cause, tval, interrupt)
+			return
+		}
+		cpu.trapToSupervisor(cause, tval, interrupt, &e)
+		return
+	}
+
+	mstatus := cpu.csr[MSTATUS]
+	cpu.csr[MEPC] = cpu.pc
+	cpu.csr[MCAUSE] = cause
+	if interrupt {
+		cpu.csr[MCAUSE] |= CAUSE_INTERRUPT
+	}
+	cpu.csr[MTVAL] = tval
+	cpu.csr[MTVAL2] = e.tval2
+	cpu.csr[MTINST] = e.tinst
+
+	// MPIE <- MIE, MIE <- 0, MPP <- текущий режим, MPV <- V
+	mstatus &^= MSTATUS_MPIE | MSTATUS_MPP | MSTATUS_MPV | MSTATUS_GVA
+	if mstatus&MSTATUS_MIE != 0 {
+		mstatus |= MSTATUS_MPIE
+	}
+	mstatus &^= MSTATUS_MIE
+	mstatus |= uint64(cpu.privilege) << MSTATUS_MPP_SHIFT
+	if cpu.virt {
+		mstatus |= MSTATUS_MPV
+	}
+	if e.gva {
+		mstatus |= MSTATUS_GVA
+	}
+	cpu.csr[MSTATUS] = mstatus
+	cpu.privilege = MACHINE_MODE
+	cpu.virt = false
+
+	cpu.pc = trapVector(cpu.csr[MTVEC], cause, interrupt)
+}
+
+func (cpu *Cpu) trapToSupervisor(cause uint64, tval uint64, interrupt bool, e *Exception) {
+	cpu.csr[SEPC] = cpu.pc
+	cpu.csr[SCAUSE] = cause
+	if interrupt {
+		cpu.csr[SCAUSE] |= CAUSE_INTERRUPT
+	}
+	cpu.csr[STVAL] = tval
+	cpu.csr[HTVAL] = e.tval2
+	cpu.csr[HTINST] = e.tinst
+
+	// SPV <- V, SPVP <- режим гостя, GVA <- гостевой адрес в stval
+	hstatus := cpu.csr[HSTATUS] &^ (HSTATUS_SPV | HSTATUS_GVA)
+	if cpu.virt {
+		hstatus = hstatus&^HSTATUS_SPVP | HSTATUS_SPV | uint64(cpu.privilege)<<HSTATUS_SPVP_SHIFT
+	}
+	if e.gva {
+		hstatus |= HSTATUS_GVA
+	}
+	cpu.csr[HSTATUS] = hstatus
+	cpu.csr[MSTATUS] = supervisorTrapStatus(cpu.csr[MSTATUS], cpu.privilege)
+	cpu.privilege = SUPERVISOR_MODE
+	cpu.virt = false
+
+	cpu.pc = trapVector(cpu.csr[STVEC], cause, interrupt)
+}
+
+// trapToVirtualSupervisor enters the guest handler in VS-mode,
+// vsstatus and the vs* CSRs take the place of sstatus and s* CSRs
+func (cpu *Cpu) trapToVirtualSupervisor(cause uint64, tval uint64, interrupt bool) {
+	if interrupt {
+		// гость видит VS-прерывания как соответствующие S-прерывания
+		cause--
+	}
+	cpu.csr[VSEPC] = cpu.pc
+	cpu.csr[VSCAUSE] = cause
+	if interrupt {
+		cpu.csr[VSCAUSE] |= CAUSE_INTERRUPT
+	}
+	cpu.csr[VSTVAL] = tval
+	cpu.csr[VSSTATUS] = supervisorTrapStatus(cpu.csr[VSSTATUS], cpu.privilege)
+	cpu.privilege = SUPERVISOR_MODE
+
+	cpu.pc = trapVector(cpu.csr[VSTVEC], cause, interrupt)
+}
+
+// supervisorTrapStatus updates sstatus or vsstatus on a trap:
+// SPIE <- SIE, SIE <- 0, SPP <- текущий режим
+func supervisorTrapStatus(status uint64, priv PrivMode) uint64 {
+	status &^= MSTATUS_SPIE | MSTATUS_SPP
+	if status&MSTATUS_SIE != 0 {
+		status |= MSTATUS_SPIE
+	}
+	status &^= MSTATUS_SIE
+	return status | uint64(priv)<<MSTATUS_SPP_SHIFT
+}
+
+// supervisorReturnStatus updates sstatus or vsstatus on sret:
+// SIE <- SPIE, SPIE <- 1, SPP <- U, the returned mode is the old SPP
+func supervisorReturnStatus(status uint64) (uint64, PrivMode) {
+	spp := PrivMode((status & MSTATUS_SPP) >> MSTATUS_SPP_SHIFT)
+	status &^= MSTATUS_SIE | MSTATUS_SPP
+	if status&MSTATUS_SPIE != 0 {
+		status |= MSTATUS_SIE
+	}
+	return status | MSTATUS_SPIE, spp
+}
+
+// trapVector computes handler address for direct (MODE=0)
+// and vectored (MODE=1) xtvec. Exceptions always go to BASE.
+func trapVector(tvec uint64, cause uint64, interrupt bool) uint64 {
+	base := tvec &^ 3
+	if tvec&3 == TVEC_VECTORED && interrupt {
+		return base + 4*cause
+	}
+	return base
 }
@@ -0,0 +1,168 @@
+package main
+
+import "testing"
+
+func TestSyncTraps(t *testing.T) {
+	tests := []struct {
+		name      string
+		privilege PrivMode
+		mtvec     uint64
+		inst      uint32
+		cause     uint64
+		tval      uint64
+	}{
+		{"ecall from U", USER_MODE, 0x80001000, 0x00000073, 8, 0},
+		{"ecall from S", SUPERVISOR_MODE, 0x80001000, 0x00000073, 9, 0},
+		{"ecall from M", MACHINE_MODE, 0x80001000, 0x00000073, 11, 0},
+		{"ebreak", MACHINE_MODE, 0x80001000, 0x00100073, 3, DRAM_BASE},
+		{"illegal instruction", USER_MODE, 0x80001000, 0xffffffff, 2, 0xffffffff},
+		{"vectored mtvec", USER_MODE, 0x80001001, 0x00000073, 8, 0},
+	}
+	cpu := NewCPU()
+	for _, test := range tests {
+		cpu.reset()
+		cpu.privilege = test.privilege
+		cpu.csr[MTVEC] = test.mtvec
+		cpu.csr[MSTATUS] = MSTATUS_MIE
+		cpu.ExecuteInst(test.inst)
+
+		if cpu.pc != 0x80001000 {
+			t.Fatalf("%s: pc=%#x, want 0x80001000", test.name, cpu.pc)
+		}
+		if cpu.privilege != MACHINE_MODE {
+			t.Fatalf("%s: privilege=%d, want machine mode", test.name, cpu.privilege)
+		}
+		if cpu.csr[MCAUSE] != test.cause || cpu.csr[MTVAL] != test.tval || cpu.csr[MEPC] != DRAM_BASE {
+			t.Fatalf("%s: mcause=%d mtval=%#x mepc=%#x, want mcause=%d mtval=%#x mepc=%#x",
+				test.name, cpu.csr[MCAUSE], cpu.csr[MTVAL], cpu.csr[MEPC], test.cause, test.tval, DRAM_BASE)
+		}
+		mstatus := cpu.csr[MSTATUS]
+		mpp := PrivMode((mstatus & MSTATUS_MPP) >> MSTATUS_MPP_SHIFT)
+		if mstatus&MSTATUS_MIE != 0 || mstatus&MSTATUS_MPIE == 0 || mpp != test.privilege {
+			t.Fatalf("%s: wrong mstatus %#x", test.name, mstatus)
+		}
+	}
+}
+
+func TestTrapVector(t *testing.T) {
+	if got := trapVector(0x80001001, 7, true); got != 0x8000101c {
+		t.Fatalf("vectored interrupt: got %#x, want 0x8000101c", got)
+	}
+	if got := trapVector(0x80001000, 7, true); got != 0x80001000 {
+		t.Fatalf("direct interrupt: got %#x, want 0x80001000", got)
+	}
+}
+
+func TestMretSret(t *testing.T) {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.privilege = MACHINE_MODE
+	cpu.csr[MEPC] = 0x80000100
+	cpu.csr[MSTATUS] = MSTATUS_MPIE | MSTATUS_MPRV | uint64(SUPERVISOR_MODE)<<MSTATUS_MPP_SHIFT
+	cpu.ExecuteInst(0x30200073) // mret
+	mstatus := cpu.csr[MSTATUS]
+	if cpu.pc != 0x80000100 || cpu.privilege != SUPERVISOR_MODE {
+		t.Fatalf("mret: pc=%#x privilege=%d, want pc=0x80000100 privilege=1", cpu.pc, cpu.privilege)
+	}
+	if mstatus&MSTATUS_MIE == 0 || mstatus&MSTATUS_MPIE == 0 || mstatus&(MSTATUS_MPP|MSTATUS_MPRV) != 0 {
+		t.Fatalf("mret: wrong mstatus %#x", mstatus)
+	}
+
+	cpu.csr[SEPC] = 0x80000200
+	cpu.csr[MSTATUS] = MSTATUS_SPIE
+	cpu.ExecuteInst(0x10200073) // sret
+	mstatus = cpu.csr[MSTATUS]
+	if cpu.pc != 0x80000200 || cpu.privilege != USER_MODE {
+		t.Fatalf("sret: pc=%#x privilege=%d, want pc=0x80000200 privilege=0", cpu.pc, cpu.privilege)
+	}
+	if mstatus&MSTATUS_SIE == 0 || mstatus&MSTATUS_SPIE == 0 || mstatus&MSTATUS_SPP != 0 {
+		t.Fatalf("sret: wrong mstatus %#x", mstatus)
+	}
+
+	cpu.ExecuteInst(0x30200073) // mret from U-mode
+	if cpu.privilege != MACHINE_MODE || cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("mret in U-mode must raise illegal instruction, mcause=%d", cpu.csr[MCAUSE])
+	}
+}
+
+func TestWfi(t *testing.T) {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.privilege = MACHINE_MODE
+	cpu.ExecuteInst(0x10500073) // wfi
+	cpu.ExecuteInst(0x00100093) // addi x1, x0, 1
+	if cpu.readReg(1) != 0 || cpu.pc != DRAM_BASE+4 {
+		t.Fatalf("hart must idle after wfi: x1=%d pc=%#x", cpu.readReg(1), cpu.pc)
+	}
+	cpu.csr[MIE] = 1 << 7
+	cpu.csr[MIP] = 1 << 7
+	cpu.ExecuteInst(0x00100093) // addi x1, x0, 1
+	if cpu.readReg(1) != 1 || cpu.pc != DRAM_BASE+8 {
+		t.Fatalf("hart must wake up on pending interrupt: x1=%d pc=%#x", cpu.readReg(1), cpu.pc)
+	}
+
+	cpu.privilege = USER_MODE
+	cpu.ExecuteInst(0x10500073) // wfi
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) || cpu.waiting {
+		t.Fatalf("wfi in U-mode must raise illegal instruction")
+	}
+}
+
+func TestTrapDelegation(t *testing.T) {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.csr[MTVEC] = 0x80001000
+	cpu.csr[STVEC] = 0x80002000
+	cpu.writeCSR(MEDELEG, 1<<ECALL_FROM_UMODE|1<<ECALL_FROM_MMODE)
+	if cpu.csr[MEDELEG] != 1<<ECALL_FROM_UMODE {
+		t.Fatalf("ecall from M-mode must not be delegable, medeleg=%#x", cpu.csr[MEDELEG])
+	}
+
+	cpu.privilege = USER_MODE
+	cpu.writeCSR(SSTATUS, MSTATUS_SIE)
+	cpu.ExecuteInst(0x00000073) // ecall
+	if cpu.pc != 0x80002000 || cpu.privilege != SUPERVISOR_MODE {
+		t.Fatalf("delegated ecall: pc=%#x privilege=%d", cpu.pc, cpu.privilege)
+	}
+	sstatus := cpu.readCSR(SSTATUS)
+	if cpu.readCSR(SCAUSE) != 8 || cpu.readCSR(SEPC) != DRAM_BASE ||
+		sstatus&MSTATUS_SPIE == 0 || sstatus&(MSTATUS_SIE|MSTATUS_SPP) != 0 {
+		t.Fatalf("delegated ecall: scause=%d sepc=%#x sstatus=%#x", cpu.readCSR(SCAUSE), cpu.readCSR(SEPC), sstatus)
+	}
+
+	cpu.ExecuteInst(0x00000073) // ecall from S-mode is not delegated
+	if cpu.pc != 0x80001000 || cpu.privilege != MACHINE_MODE || cpu.csr[MCAUSE] != 9 {
+		t.Fatalf("ecall from S-mode: pc=%#x privilege=%d mcause=%d", cpu.pc, cpu.privilege, cpu.csr[MCAUSE])
+	}
+	if mpp := (cpu.csr[MSTATUS] & MSTATUS_MPP) >> MSTATUS_MPP_SHIFT; mpp != uint64(SUPERVISOR_MODE) {
+		t.Fatalf("ecall from S-mode: MPP=%d, want 1", mpp)
+	}
+}
+
+func TestSupervisorViews(t *testing.T) {
+	cpu := NewCPU()
+	cpu.reset()
+	// UXL и SXL сохраняют значение RV64
+	uxl, sxl := XL_64<<MSTATUS_UXL_SHIFT, XL_64<<MSTATUS_SXL_SHIFT
+	cpu.writeCSR(MSTATUS, MSTATUS_MIE|MSTATUS_SIE|MSTATUS_MPP)
+	if got := cpu.readCSR(SSTATUS); got != MSTATUS_SIE|uxl {
+		t.Fatalf("sstatus=%#x, want %#x", got, MSTATUS_SIE|uxl)
+	}
+	cpu.writeCSR(SSTATUS, MSTATUS_SUM|MSTATUS_MPP)
+	if got := cpu.readCSR(MSTATUS); got != MSTATUS_MIE|MSTATUS_SUM|MSTATUS_MPP|uxl|sxl {
+		t.Fatalf("mstatus=%#x after sstatus write", got)
+	}
+
+	cpu.writeCSR(MIDELEG, 0xffff)
+	if got := cpu.readCSR(MIDELEG); got != MIDELEG_MASK|MIDELEG_HYPERVISOR {
+		t.Fatalf("mideleg=%#x, want %#x", got, MIDELEG_MASK|MIDELEG_HYPERVISOR)
+	}
+	cpu.writeCSR(MIE, MIP_MTIP)
+	cpu.writeCSR(SIE, 0xffff)
+	if got := cpu.readCSR(MIE); got != MIP_MTIP|MIDELEG_MASK {
+		t.Fatalf("mie=%#x after sie write", got)
+	}
+	if got := cpu.readCSR(SIE); got != MIDELEG_MASK {
+		t.Fatalf("sie=%#x, want %#x", got, MIDELEG_MASK)
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// NaN-boxing: values narrower than an f register occupy its low bits
+// with all upper bits set
+const (
+	NAN_BOX   uint64 = 0xffffffff00000000
+	NAN_BOX_H uint64 = 0xffffffffffff0000
+)
+
+// ConfigureFLEN sets the width of f registers: 64 for F and D,
+// 128 adds the Q extension. The hart is reset.
+func (cpu *Cpu) ConfigureFLEN(flen uint64) error {
+	if flen != 64 && flen != 128 {
+		return fmt.Errorf("Unsupported FLEN %d", flen)
+	}
+	cpu.flen = flen
+	if flen == 128 {
+		cpu.exts |= EXT_Q
+	} else {
+		cpu.exts &^= EXT_Q
+	}
+	cpu.reset()
+	return nil
+}
+
+// fpEnabled checks mstatus.FS and, with V=1, vsstatus.FS
+func (cpu *Cpu) fpEnabled() bool {
+	off := EXT_STATUS_OFF << MSTATUS_FS_SHIFT
+	if cpu.virt && cpu.csr[VSSTATUS]&MSTATUS_FS == off {
+		return false
+	}
+	return cpu.csr[MSTATUS]&MSTATUS_FS != off
+}
+
+func (cpu *Cpu) markFSDirty() {
+	cpu.csr[MSTATUS] |= EXT_STATUS_DIRTY << MSTATUS_FS_SHIFT
+	if cpu.virt {
+		cpu.csr[VSSTATUS] |= EXT_STATUS_DIRTY << MSTATUS_FS_SHIFT
+	}
+}
+
+// fpBox returns the NaN-boxing bits of format f within the low 64 bits
+func fpBox(f fpFmt) uint64 {
+	switch f {
+	case FMT_H:
+		return NAN_BOX_H
+	case FMT_S:
+		return NAN_BOX
+	}
+	return 0
+}
+
+func (cpu *Cpu) readFReg(reg uint64, f fpFmt) uint64 {
+	val := cpu.fregisters[reg]
+	box := fpBox(f)
+	// неправильно упакованное значение читается как canonical NaN
+	if val&box != box || (cpu.flen > 64 && cpu.fregistersHi[reg] != ^uint64(0)) {
+		return fpCanonicalNaN(f)
+	}
+	return val &^ box
+}
+
+func (cpu *Cpu) writeFReg(reg uint64, f fpFmt, val uint64) {
+	cpu.fregisters[reg] = val | fpBox(f)
+	if cpu.flen > 64 {
+		cpu.fregistersHi[reg] = ^uint64(0)
+	}
+	cpu.markFSDirty()
+}
+
+// readFRegBits returns an operand of format f as raw bits for the
+// softfloat core. Quad-precision values fill the whole register.
+func (cpu *Cpu) readFRegBits(reg uint64, f fpFmt) *big.Int {
+	if f == FMT_Q {
+		r := new(big.Int).SetUint64(cpu.fregistersHi[reg])
+		return r.Lsh(r, 64).Or(r, rawBits(cpu.fregisters[reg]))
+	}
+	return rawBits(cpu.readFReg(reg, f))
+}
+
+func (cpu *Cpu) writeFRegBits(reg uint64, f fpFmt, val *big.Int) {
+	if f == FMT_Q {
+		cpu.fregisters[reg] = lowBits(val, 64).Uint64()
+		cpu.fregistersHi[reg] = new(big.Int).Rsh(val, 64).Uint64()
+		cpu.markFSDirty()
+		return
+	}
+	cpu.writeFReg(reg, f, val.Uint64())
+}
+
+func (cpu *Cpu) accrueFlags(flags uint64) {
+	if flags != 0 {
+		cpu.csr[FCSR] |= flags & FCSR_FFLAGS
+		cpu.markFSDirty()
+	}
+}
+
+// fpCheck raises illegal instruction when FPU is off or format f does
+// not fit into f registers
+func (cpu *Cpu) fpCheck(inst InstWord, f fpFmt) bool {
+	if !cpu.fpEnabled() || (f == FMT_Q && cpu.flen < 128) {
+		cpu.IllegalInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
+// roundingMode resolves the rm field, reserved modes are illegal
+func (cpu *Cpu) roundingMode(inst InstWord, f fpFmt) (uint64, bool) {
+	if !cpu.fpCheck(inst, f) {
+		return 0, false
+	}
+	rm := inst.rm()
+	if rm == RM_DYN {
+		rm = cpu.readCSR(FRM)
+	}
+	if rm > RM_RMM {
+		cpu.IllegalInst(uint32(inst))
+		return 0, false
+	}
+	return rm, true
+}
+
+func (cpu *Cpu) fpLoad(inst InstWord, f fpFmt, size uint8) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, size); ok {
+		cpu.writeFReg(inst.rd(), f, data)
+	}
+}
+
+func (cpu *Cpu) fpStore(inst InstWord, f fpFmt, size uint8) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	cpu.store(addr, cpu.fregisters[inst.rs2()], size)
+}
+
+func (cpu *Cpu) fpArith(inst InstWord, f fpFmt, op func(floatFormat, *big.Int, *big.Int, uint64) (*big.Int, uint64)) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	res, flags := op(f.format(), cpu.readFRegBits(inst.rs1(), f), cpu.readFRegBits(inst.rs2(), f), rm)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fpFused(inst InstWord, f fpFmt, negProd, negAdd bool) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	a, b, c := cpu.readFRegBits(inst.rs1(), f), cpu.readFRegBits(inst.rs2(), f), cpu.readFRegBits(inst.rs3(), f)
+	res, flags := sfMulAdd(f.format(), a, b, c, negProd, negAdd, rm)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fpSquareRoot(inst InstWord, f fpFmt) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	res, flags := sfSqrt(f.format(), cpu.readFRegBits(inst.rs1(), f), rm)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+// fpSignInject implements fsgnj (xor=false, neg=false), fsgnjn and fsgnjx
+func (cpu *Cpu) fpSignInject(inst InstWord, f fpFmt, neg, xor bool) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	ff := f.format()
+	sign := int(ff.expBits + ff.fracBits)
+	a, b := cpu.readFRegBits(inst.rs1(), f), cpu.readFRegBits(inst.rs2(), f)
+	s := b.Bit(sign)
+	switch {
+	case neg:
+		s ^= 1
+	case xor:
+		s ^= a.Bit(sign)
+	}
+	cpu.writeFRegBits(inst.rd(), f, a.SetBit(a, sign, s))
+}
+
+// fpMinMaxOp implements fmin/fmax with op sfMinMax and fminm/fmaxm
+// with op sfMinMaxNaN
+func (cpu *Cpu) fpMinMaxOp(inst InstWord, f fpFmt, op func(floatFormat, *big.Int, *big.Int, bool) (*big.Int, uint64), max bool) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	res, flags := op(f.format(), cpu.readFRegBits(inst.rs1(), f), cpu.readFRegBits(inst.rs2(), f), max)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+// fpCmp implements feq/flt/fle with op sfCompare and fleq/fltq
+// with op sfCompareQuiet
+func (cpu *Cpu) fpCmp(inst InstWord, f fpFmt, op func(floatFormat, *big.Int, *big.Int, bool, bool) (bool, uint64), lt, eq bool) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	res, flags := op(f.format(), cpu.readFRegBits(inst.rs1(), f), cpu.readFRegBits(inst.rs2(), f), lt, eq)
+	if res {
+		cpu.writeReg(inst.rd(), 1)
+	} else {
+		cpu.writeReg(inst.rd(), 0)
+	}
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fpClass(inst InstWord, f fpFmt) {
+	if !cpu.fpCheck(inst, f) {
+		return
+	}
+	cpu.writeReg(inst.rd(), sfClassify(f.format(), cpu.readFRegBits(inst.rs1(), f)))
+}
+
+func (cpu *Cpu) fpCvtToInt(inst InstWord, f fpFmt, signed bool, width uint) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	res, flags := sfToInt(f.format(), cpu.readFRegBits(inst.rs1(), f), signed, width, rm)
+	cpu.writeReg(inst.rd(), res)
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fpCvtFromInt(inst InstWord, f fpFmt, signed bool, width uint) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	res, flags := sfFromInt(f.format(), cpu.readReg(inst.rs1()), signed, width, rm)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fpCvtFmt(inst InstWord, to, from fpFmt) {
+	// проверяется более широкий формат: Q с любой стороны требует FLEN=128
+	rm, ok := cpu.roundingMode(inst, max(to, from))
+	if !ok {
+		return
+	}
+	res, flags := sfConvert(to.format(), from.format(), cpu.readFRegBits(inst.rs1(), from), rm)
+	cpu.writeFRegBits(inst.rd(), to, res)
+	cpu.accrueFlags(flags)
+}
+
+// RVF
+
+func (cpu *Cpu) flw(inst InstWord)     { cpu.fpLoad(inst, FMT_S, WORD) }
+func (cpu *Cpu) fsw(inst InstWord)     { cpu.fpStore(inst, FMT_S, WORD) }
+func (cpu *Cpu) fmaddS(inst InstWord)  { cpu.fpFused(inst, FMT_S, false, false) }
+func (cpu *Cpu) fmsubS(inst InstWord)  { cpu.fpFused(inst, FMT_S, false, true) }
+func (cpu *Cpu) fnmsubS(inst InstWord) { cpu.fpFused(inst, FMT_S, true, false) }
+func (cpu *Cpu) fnmaddS(inst InstWord) { cpu.fpFused(inst, FMT_S, true, true) }
+func (cpu *Cpu) faddS(inst InstWord)   { cpu.fpArith(inst, FMT_S, sfAdd) }
+func (cpu *Cpu) fsubS(inst InstWord)   { cpu.fpArith(inst, FMT_S, sfSub) }
+func (cpu *Cpu) fmulS(inst InstWord)   { cpu.fpArith(inst, FMT_S, sfMul) }
+func (cpu *Cpu) fdivS(inst InstWord)   { cpu.fpArith(inst, FMT_S, sfDiv) }
+func (cpu *Cpu) fsqrtS(inst InstWord)  { cpu.fpSquareRoot(inst, FMT_S) }
+func (cpu *Cpu) fsgnjS(inst InstWord)  { cpu.fpSignInject(inst, FMT_S, false, false) }
+func (cpu *Cpu) fsgnjnS(inst InstWord) { cpu.fpSignInject(inst, FMT_S, true, false) }
+func (cpu *Cpu) fsgnjxS(inst InstWord) { cpu.fpSignInject(inst, FMT_S, false, true) }
+func (cpu *Cpu) fminS(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_S, sfMinMax, false) }
+func (cpu *Cpu) fmaxS(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_S, sfMinMax, true) }
+func (cpu *Cpu) feqS(inst InstWord)    { cpu.fpCmp(inst, FMT_S, sfCompare, false, true) }
+func (cpu *Cpu) fltS(inst InstWord)    { cpu.fpCmp(inst, FMT_S, sfCompare, true, false) }
+func (cpu *Cpu) fleS(inst InstWord)    { cpu.fpCmp(inst, FMT_S, sfCompare, true, true) }
+func (cpu *Cpu) fclassS(inst InstWord) { cpu.fpClass(inst, FMT_S) }
+func (cpu *Cpu) fcvtWS(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_S, true, 32) }
+func (cpu *Cpu) fcvtWuS(inst InstWord) { cpu.fpCvtToInt(inst, FMT_S, false, 32) }
+func (cpu *Cpu) fcvtLS(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_S, true, 64) }
+func (cpu *Cpu) fcvtLuS(inst InstWord) { cpu.fpCvtToInt(inst, FMT_S, false, 64) }
+func (cpu *Cpu) fcvtSW(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_S, true, 32) }
+func (cpu *Cpu) fcvtSWu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_S, false, 32) }
+func (cpu *Cpu) fcvtSL(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_S, true, 64) }
+func (cpu *Cpu) fcvtSLu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_S, false, 64) }
+
+func (cpu *Cpu) fmvXW(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_S) {
+		// копируются младшие 32 бита без проверки NaN-boxing
+		cpu.writeReg(inst.rd(), uint64(signExtend(int64(cpu.fregisters[inst.rs1()]), 32)))
+	}
+}
+
+func (cpu *Cpu) fmvWX(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_S) {
+		cpu.writeFReg(inst.rd(), FMT_S, cpu.readReg(inst.rs1()))
+	}
+}
+
+// RVD
+
+func (cpu *Cpu) fld(inst InstWord)     { cpu.fpLoad(inst, FMT_D, DOUBLEWORD) }
+func (cpu *Cpu) fsd(inst InstWord)     { cpu.fpStore(inst, FMT_D, DOUBLEWORD) }
+func (cpu *Cpu) fmaddD(inst InstWord)  { cpu.fpFused(inst, FMT_D, false, false) }
+func (cpu *Cpu) fmsubD(inst InstWord)  { cpu.fpFused(inst, FMT_D, false, true) }
+func (cpu *Cpu) fnmsubD(inst InstWord) { cpu.fpFused(inst, FMT_D, true, false) }
+func (cpu *Cpu) fnmaddD(inst InstWord) { cpu.fpFused(inst, FMT_D, true, true) }
+func (cpu *Cpu) faddD(inst InstWord)   { cpu.fpArith(inst, FMT_D, sfAdd) }
+func (cpu *Cpu) fsubD(inst InstWord)   { cpu.fpArith(inst, FMT_D, sfSub) }
+func (cpu *Cpu) fmulD(inst InstWord)   { cpu.fpArith(inst, FMT_D, sfMul) }
+func (cpu *Cpu) fdivD(inst InstWord)   { cpu.fpArith(inst, FMT_D, sfDiv) }
+func (cpu *Cpu) fsqrtD(inst InstWord)  { cpu.fpSquareRoot(inst, FMT_D) }
+func (cpu *Cpu) fsgnjD(inst InstWord)  { cpu.fpSignInject(inst, FMT_D, false, false) }
+func (cpu *Cpu) fsgnjnD(inst InstWord) { cpu.fpSignInject(inst, FMT_D, true, false) }
+func (cpu *Cpu) fsgnjxD(inst InstWord) { cpu.fpSignInject(inst, FMT_D, false, true) }
+func (cpu *Cpu) fminD(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_D, sfMinMax, false) }
+func (cpu *Cpu) fmaxD(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_D, sfMinMax, true) }
+func (cpu *Cpu) fcvtSD(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_S, FMT_D) }
+func (cpu *Cpu) fcvtDS(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_D, FMT_S) }
+func (cpu *Cpu) feqD(inst InstWord)    { cpu.fpCmp(inst, FMT_D, sfCompare, false, true) }
+func (cpu *Cpu) fltD(inst InstWord)    { cpu.fpCmp(inst, FMT_D, sfCompare, true, false) }
+func (cpu *Cpu) fleD(inst InstWord)    { cpu.fpCmp(inst, FMT_D, sfCompare, true, true) }
+func (cpu *Cpu) fclassD(inst InstWord) { cpu.fpClass(inst, FMT_D) }
+func (cpu *Cpu) fcvtWD(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_D, true, 32) }
+func (cpu *Cpu) fcvtWuD(inst InstWord) { cpu.fpCvtToInt(inst, FMT_D, false, 32) }
+func (cpu *Cpu) fcvtLD(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_D, true, 64) }
+func (cpu *Cpu) fcvtLuD(inst InstWord) { cpu.fpCvtToInt(inst, FMT_D, false, 64) }
+func (cpu *Cpu) fcvtDW(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_D, true, 32) }
+func (cpu *Cpu) fcvtDWu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_D, false, 32) }
+func (cpu *Cpu) fcvtDL(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_D, true, 64) }
+func (cpu *Cpu) fcvtDLu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_D, false, 64) }
+
+func (cpu *Cpu) fmvXD(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_D) {
+		cpu.writeReg(inst.rd(), cpu.fregisters[inst.rs1()])
+	}
+}
+
+func (cpu *Cpu) fmvDX(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_D) {
+		cpu.writeFReg(inst.rd(), FMT_D, cpu.readReg(inst.rs1()))
+	}
+}
+
+// fmvhXD moves the upper half of a double to rd in RV32
+func (cpu *Cpu) fmvhXD(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_D) {
+		cpu.writeReg(inst.rd(), cpu.fregisters[inst.rs1()]>>32)
+	}
+}
+
+// fmvpDX builds a double from rs1 (low) and rs2 (high) in RV32
+func (cpu *Cpu) fmvpDX(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_D) {
+		lo, hi := uint32(cpu.readReg(inst.rs1())), uint32(cpu.readReg(inst.rs2()))
+		cpu.writeFReg(inst.rd(), FMT_D, uint64(lo)|uint64(hi)<<32)
+	}
+}
+
+// RVZFH, loads, stores, moves and conversions to and from
+// other FP formats also belong to RVZFHMIN
+
+func (cpu *Cpu) flh(inst InstWord)     { cpu.fpLoad(inst, FMT_H, HALFWORD) }
+func (cpu *Cpu) fsh(inst InstWord)     { cpu.fpStore(inst, FMT_H, HALFWORD) }
+func (cpu *Cpu) fmaddH(inst InstWord)  { cpu.fpFused(inst, FMT_H, false, false) }
+func (cpu *Cpu) fmsubH(inst InstWord)  { cpu.fpFused(inst, FMT_H, false, true) }
+func (cpu *Cpu) fnmsubH(inst InstWord) { cpu.fpFused(inst, FMT_H, true, false) }
+func (cpu *Cpu) fnmaddH(inst InstWord) { cpu.fpFused(inst, FMT_H, true, true) }
+func (cpu *Cpu) faddH(inst InstWord)   { cpu.fpArith(inst, FMT_H, sfAdd) }
+func (cpu *Cpu) fsubH(inst InstWord)   { cpu.fpArith(inst, FMT_H, sfSub) }
+func (cpu *Cpu) fmulH(inst InstWord)   { cpu.fpArith(inst, FMT_H, sfMul) }
+func (cpu *Cpu) fdivH(inst InstWord)   { cpu.fpArith(inst, FMT_H, sfDiv) }
+func (cpu *Cpu) fsqrtH(inst InstWord)  { cpu.fpSquareRoot(inst, FMT_H) }
+func (cpu *Cpu) fsgnjH(inst InstWord)  { cpu.fpSignInject(inst, FMT_H, false, false) }
+func (cpu *Cpu) fsgnjnH(inst InstWord) { cpu.fpSignInject(inst, FMT_H, true, false) }
+func (cpu *Cpu) fsgnjxH(inst InstWord) { cpu.fpSignInject(inst, FMT_H, false, true) }
+func (cpu *Cpu) fminH(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_H, sfMinMax, false) }
+func (cpu *Cpu) fmaxH(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_H, sfMinMax, true) }
+func (cpu *Cpu) fcvtSH(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_S, FMT_H) }
+func (cpu *Cpu) fcvtHS(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_H, FMT_S) }
+func (cpu *Cpu) fcvtDH(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_D, FMT_H) }
+func (cpu *Cpu) fcvtHD(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_H, FMT_D) }
+func (cpu *Cpu) feqH(inst InstWord)    { cpu.fpCmp(inst, FMT_H, sfCompare, false, true) }
+func (cpu *Cpu) fltH(inst InstWord)    { cpu.fpCmp(inst, FMT_H, sfCompare, true, false) }
+func (cpu *Cpu) fleH(inst InstWord)    { cpu.fpCmp(inst, FMT_H, sfCompare, true, true) }
+func (cpu *Cpu) fclassH(inst InstWord) { cpu.fpClass(inst, FMT_H) }
+func (cpu *Cpu) fcvtWH(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_H, true, 32) }
+func (cpu *Cpu) fcvtWuH(inst InstWord) { cpu.fpCvtToInt(inst, FMT_H, false, 32) }
+func (cpu *Cpu) fcvtLH(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_H, true, 64) }
+func (cpu *Cpu) fcvtLuH(inst InstWord) { cpu.fpCvtToInt(inst, FMT_H, false, 64) }
+func (cpu *Cpu) fcvtHW(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_H, true, 32) }
+func (cpu *Cpu) fcvtHWu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_H, false, 32) }
+func (cpu *Cpu) fcvtHL(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_H, true, 64) }
+func (cpu *Cpu) fcvtHLu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_H, false, 64) }
+
+func (cpu *Cpu) fmvXH(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_H) {
+		cpu.writeReg(inst.rd(), uint64(signExtend(int64(cpu.fregisters[inst.rs1()]), 16)))
+	}
+}
+
+func (cpu *Cpu) fmvHX(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_H) {
+		cpu.writeFReg(inst.rd(), FMT_H, uint64(uint16(cpu.readReg(inst.rs1()))))
+	}
+}
+
+// RVQ, available with FLEN=128
+
+// flq and fsq access memory as two doublewords, a fault on the upper
+// half leaves the register unchanged but the lower half of fsq stored
+func (cpu *Cpu) flq(inst InstWord) {
+	if !cpu.fpCheck(inst, FMT_Q) {
+		return
+	}
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	lo, ok := cpu.load(addr, DOUBLEWORD)
+	if !ok {
+		return
+	}
+	hi, ok := cpu.load(addr+8, DOUBLEWORD)
+	if !ok {
+		return
+	}
+	cpu.fregisters[inst.rd()] = lo
+	cpu.fregistersHi[inst.rd()] = hi
+	cpu.markFSDirty()
+}
+
+func (cpu *Cpu) fsq(inst InstWord) {
+	if !cpu.fpCheck(inst, FMT_Q) {
+		return
+	}
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	if cpu.store(addr, cpu.fregisters[inst.rs2()], DOUBLEWORD) {
+		cpu.store(addr+8, cpu.fregistersHi[inst.rs2()], DOUBLEWORD)
+	}
+}
+
+func (cpu *Cpu) fmaddQ(inst InstWord)  { cpu.fpFused(inst, FMT_Q, false, false) }
+func (cpu *Cpu) fmsubQ(inst InstWord)  { cpu.fpFused(inst, FMT_Q, false, true) }
+func (cpu *Cpu) fnmsubQ(inst InstWord) { cpu.fpFused(inst, FMT_Q, true, false) }
+func (cpu *Cpu) fnmaddQ(inst InstWord) { cpu.fpFused(inst, FMT_Q, true, true) }
+func (cpu *Cpu) faddQ(inst InstWord)   { cpu.fpArith(inst, FMT_Q, sfAdd) }
+func (cpu *Cpu) fsubQ(inst InstWord)   { cpu.fpArith(inst, FMT_Q, sfSub) }
+func (cpu *Cpu) fmulQ(inst InstWord)   { cpu.fpArith(inst, FMT_Q, sfMul) }
+func (cpu *Cpu) fdivQ(inst InstWord)   { cpu.fpArith(inst, FMT_Q, sfDiv) }
+func (cpu *Cpu) fsqrtQ(inst InstWord)  { cpu.fpSquareRoot(inst, FMT_Q) }
+func (cpu *Cpu) fsgnjQ(inst InstWord)  { cpu.fpSignInject(inst, FMT_Q, false, false) }
+func (cpu *Cpu) fsgnjnQ(inst InstWord) { cpu.fpSignInject(inst, FMT_Q, true, false) }
+func (cpu *Cpu) fsgnjxQ(inst InstWord) { cpu.fpSignInject(inst, FMT_Q, false, true) }
+func (cpu *Cpu) fminQ(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_Q, sfMinMax, false) }
+func (cpu *Cpu) fmaxQ(inst InstWord)   { cpu.fpMinMaxOp(inst, FMT_Q, sfMinMax, true) }
+func (cpu *Cpu) fcvtSQ(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_S, FMT_Q) }
+func (cpu *Cpu) fcvtQS(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_Q, FMT_S) }
+func (cpu *Cpu) fcvtDQ(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_D, FMT_Q) }
+func (cpu *Cpu) fcvtQD(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_Q, FMT_D) }
+func (cpu *Cpu) fcvtHQ(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_H, FMT_Q) }
+func (cpu *Cpu) fcvtQH(inst InstWord)  { cpu.fpCvtFmt(inst, FMT_Q, FMT_H) }
+func (cpu *Cpu) feqQ(inst InstWord)    { cpu.fpCmp(inst, FMT_Q, sfCompare, false, true) }
+func (cpu *Cpu) fltQ(inst InstWord)    { cpu.fpCmp(inst, FMT_Q, sfCompare, true, false) }
+func (cpu *Cpu) fleQ(inst InstWord)    { cpu.fpCmp(inst, FMT_Q, sfCompare, true, true) }
+func (cpu *Cpu) fclassQ(inst InstWord) { cpu.fpClass(inst, FMT_Q) }
+func (cpu *Cpu) fcvtWQ(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_Q, true, 32) }
+func (cpu *Cpu) fcvtWuQ(inst InstWord) { cpu.fpCvtToInt(inst, FMT_Q, false, 32) }
+func (cpu *Cpu) fcvtLQ(inst InstWord)  { cpu.fpCvtToInt(inst, FMT_Q, true, 64) }
+func (cpu *Cpu) fcvtLuQ(inst InstWord) { cpu.fpCvtToInt(inst, FMT_Q, false, 64) }
+func (cpu *Cpu) fcvtQW(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_Q, true, 32) }
+func (cpu *Cpu) fcvtQWu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_Q, false, 32) }
+func (cpu *Cpu) fcvtQL(inst InstWord)  { cpu.fpCvtFromInt(inst, FMT_Q, true, 64) }
+func (cpu *Cpu) fcvtQLu(inst InstWord) { cpu.fpCvtFromInt(inst, FMT_Q, false, 64) }
+
+// RVZFA
+
+func (cpu *Cpu) fpLoadImm(inst InstWord, f fpFmt) {
+	if cpu.fpCheck(inst, f) {
+		cpu.writeFRegBits(inst.rd(), f, fliConst(f.format(), inst.rs1()))
+	}
+}
+
+// fpRound implements fround (exact=false) and froundnx
+func (cpu *Cpu) fpRound(inst InstWord, f fpFmt, exact bool) {
+	rm, ok := cpu.roundingMode(inst, f)
+	if !ok {
+		return
+	}
+	res, flags := sfRoundToInt(f.format(), cpu.readFRegBits(inst.rs1(), f), rm, exact)
+	cpu.writeFRegBits(inst.rd(), f, res)
+	cpu.accrueFlags(flags)
+}
+
+func (cpu *Cpu) fliH(inst InstWord)      { cpu.fpLoadImm(inst, FMT_H) }
+func (cpu *Cpu) fliS(inst InstWord)      { cpu.fpLoadImm(inst, FMT_S) }
+func (cpu *Cpu) fliD(inst InstWord)      { cpu.fpLoadImm(inst, FMT_D) }
+func (cpu *Cpu) fliQ(inst InstWord)      { cpu.fpLoadImm(inst, FMT_Q) }
+func (cpu *Cpu) fminmH(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_H, sfMinMaxNaN, false) }
+func (cpu *Cpu) fmaxmH(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_H, sfMinMaxNaN, true) }
+func (cpu *Cpu) fminmS(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_S, sfMinMaxNaN, false) }
+func (cpu *Cpu) fmaxmS(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_S, sfMinMaxNaN, true) }
+func (cpu *Cpu) fminmD(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_D, sfMinMaxNaN, false) }
+func (cpu *Cpu) fmaxmD(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_D, sfMinMaxNaN, true) }
+func (cpu *Cpu) fminmQ(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_Q, sfMinMaxNaN, false) }
+func (cpu *Cpu) fmaxmQ(inst InstWord)    { cpu.fpMinMaxOp(inst, FMT_Q, sfMinMaxNaN, true) }
+func (cpu *Cpu) froundH(inst InstWord)   { cpu.fpRound(inst, FMT_H, false) }
+func (cpu *Cpu) froundnxH(inst InstWord) { cpu.fpRound(inst, FMT_H, true) }
+func (cpu *Cpu) froundS(inst InstWord)   { cpu.fpRound(inst, FMT_S, false) }
+func (cpu *Cpu) froundnxS(inst InstWord) { cpu.fpRound(inst, FMT_S, true) }
+func (cpu *Cpu) froundD(inst InstWord)   { cpu.fpRound(inst, FMT_D, false) }
+func (cpu *Cpu) froundnxD(inst InstWord) { cpu.fpRound(inst, FMT_D, true) }
+func (cpu *Cpu) froundQ(inst InstWord)   { cpu.fpRound(inst, FMT_Q, false) }
+func (cpu *Cpu) froundnxQ(inst InstWord) { cpu.fpRound(inst, FMT_Q, true) }
+func (cpu *Cpu) fleqH(inst InstWord)     { cpu.fpCmp(inst, FMT_H, sfCompareQuiet, true, true) }
+func (cpu *Cpu) fltqH(inst InstWord)     { cpu.fpCmp(inst, FMT_H, sfCompareQuiet, true, false) }
+func (cpu *Cpu) fleqS(inst InstWord)     { cpu.fpCmp(inst, FMT_S, sfCompareQuiet, true, true) }
+func (cpu *Cpu) fltqS(inst InstWord)     { cpu.fpCmp(inst, FMT_S, sfCompareQuiet, true, false) }
+func (cpu *Cpu) fleqD(inst InstWord)     { cpu.fpCmp(inst, FMT_D, sfCompareQuiet, true, true) }
+func (cpu *Cpu) fltqD(inst InstWord)     { cpu.fpCmp(inst, FMT_D, sfCompareQuiet, true, false) }
+func (cpu *Cpu) fleqQ(inst InstWord)     { cpu.fpCmp(inst, FMT_Q, sfCompareQuiet, true, true) }
+func (cpu *Cpu) fltqQ(inst InstWord)     { cpu.fpCmp(inst, FMT_Q, sfCompareQuiet, true, false) }
+
+// fcvtmodWD converts with modular wrap-around, the rm field is fixed
+// to RTZ by the encoding
+func (cpu *Cpu) fcvtmodWD(inst InstWord) {
+	if !cpu.fpCheck(inst, FMT_D) {
+		return
+	}
+	res, flags := sfToInt32Mod(BINARY64, cpu.readFRegBits(inst.rs1(), FMT_D))
+	cpu.writeReg(inst.rd(), res)
+	cpu.accrueFlags(flags)
+}
+
+// fmvhXQ moves the upper half of a quad-precision register
+func (cpu *Cpu) fmvhXQ(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_Q) {
+		cpu.writeReg(inst.rd(), cpu.fregistersHi[inst.rs1()])
+	}
+}
+
+// fmvpQX builds a quad-precision register from rs1 (low) and rs2 (high)
+func (cpu *Cpu) fmvpQX(inst InstWord) {
+	if cpu.fpCheck(inst, FMT_Q) {
+		cpu.fregisters[inst.rd()] = cpu.readReg(inst.rs1())
+		cpu.fregistersHi[inst.rd()] = cpu.readReg(inst.rs2())
+		cpu.markFSDirty()
+	}
+}
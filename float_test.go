@@ -0,0 +1,472 @@
+package main
+
+import "testing"
+
+// fpInst encodes an OP-FP instruction
+func fpInst(funct7, rs2, rs1, rm, rd uint32) uint32 {
+	return funct7<<25 | rs2<<20 | rs1<<15 | rm<<12 | rd<<7 | 0x53
+}
+
+func newFPCpu() *Cpu {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.csr[MSTATUS] = EXT_STATUS_INITIAL << MSTATUS_FS_SHIFT
+	return cpu
+}
+
+func TestFloatArith(t *testing.T) {
+	const (
+		one32   = 0x3f800000
+		two32   = 0x40000000
+		three32 = 0x40400000
+		one64   = 0x3ff0000000000000
+		three64 = 0x4008000000000000
+		inf64   = 0x7ff0000000000000
+	)
+	tests := []struct {
+		name  string
+		inst  uint32
+		f     fpFmt
+		a, b  uint64
+		want  uint64
+		flags uint64
+	}{
+		{"fadd.s", fpInst(0x00, 2, 1, 0, 3), FMT_S, one32, two32, three32, 0},
+		{"fadd.s rup", fpInst(0x00, 2, 1, 3, 3), FMT_S, one32, 0x33800000, 0x3f800001, FFLAGS_NX},
+		{"fadd.s rdn", fpInst(0x00, 2, 1, 2, 3), FMT_S, one32, 0x33800000, one32, FFLAGS_NX},
+		{"fsub.d", fpInst(0x05, 2, 1, 0, 3), FMT_D, three64, one64, 0x4000000000000000, 0},
+		{"fdiv.d inexact", fpInst(0x0d, 2, 1, 0, 3), FMT_D, one64, three64, 0x3fd5555555555555, FFLAGS_NX},
+		{"fdiv.s by zero", fpInst(0x0c, 2, 1, 0, 3), FMT_S, one32, 0, 0x7f800000, FFLAGS_DZ},
+		{"fmul.d overflow", fpInst(0x09, 2, 1, 0, 3), FMT_D, 0x7fe0000000000000, 0x7fe0000000000000, inf64, FFLAGS_OF | FFLAGS_NX},
+		{"fsqrt.d negative", fpInst(0x2d, 0, 1, 0, 3), FMT_D, 0xbff0000000000000, 0, F64_CANON_NAN, FFLAGS_NV},
+		{"fadd.d inf-inf", fpInst(0x01, 2, 1, 0, 3), FMT_D, inf64, inf64 | F64_SIGN, F64_CANON_NAN, FFLAGS_NV},
+		{"fmin.s -0/+0", fpInst(0x14, 2, 1, 0, 3), FMT_S, 0, F32_SIGN, F32_SIGN, 0},
+		{"fmax.s qNaN", fpInst(0x14, 2, 1, 1, 3), FMT_S, F32_CANON_NAN, one32, one32, 0},
+		{"fmax.d sNaN", fpInst(0x15, 2, 1, 1, 3), FMT_D, 0x7ff0000000000001, one64, one64, FFLAGS_NV},
+		{"fsgnjn.s", fpInst(0x10, 2, 1, 1, 3), FMT_S, one32, one32, one32 | F32_SIGN, 0},
+		{"fsgnjx.d", fpInst(0x11, 2, 1, 2, 3), FMT_D, one64 | F64_SIGN, one64 | F64_SIGN, one64, 0},
+	}
+	for _, test := range tests {
+		cpu := newFPCpu()
+		cpu.writeFReg(1, test.f, test.a)
+		cpu.writeFReg(2, test.f, test.b)
+		cpu.ExecuteInst(test.inst)
+		if got := cpu.readFReg(3, test.f); got != test.want {
+			t.Fatalf("%s: got %#x, want %#x", test.name, got, test.want)
+		}
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("%s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+}
+
+func TestFloatFused(t *testing.T) {
+	cpu := newFPCpu()
+	cpu.writeFReg(1, FMT_D, 0x4000000000000000) // 2.0
+	cpu.writeFReg(2, FMT_D, 0x4008000000000000) // 3.0
+	cpu.writeFReg(3, FMT_D, 0x3ff0000000000000) // 1.0
+	// fnmsub.d f4, f1, f2, f3: -(2*3)+1
+	cpu.ExecuteInst(3<<27 | 1<<25 | 2<<20 | 1<<15 | 4<<7 | 0x4b)
+	if got := cpu.fregisters[4]; got != 0xc014000000000000 {
+		t.Fatalf("fnmsub.d: got %#x, want -5.0", got)
+	}
+
+	// inf*0 + qNaN is invalid
+	cpu.writeFReg(1, FMT_S, 0x7f800000)
+	cpu.writeFReg(2, FMT_S, 0)
+	cpu.writeFReg(3, FMT_S, F32_CANON_NAN)
+	cpu.ExecuteInst(3<<27 | 2<<20 | 1<<15 | 4<<7 | 0x43) // fmadd.s
+	if cpu.readFReg(4, FMT_S) != F32_CANON_NAN || cpu.readCSR(FFLAGS) != FFLAGS_NV {
+		t.Fatalf("fmadd.s inf*0: got %#x fflags=%#x", cpu.fregisters[4], cpu.readCSR(FFLAGS))
+	}
+}
+
+func TestNaNBoxing(t *testing.T) {
+	cpu := newFPCpu()
+	cpu.fregisters[1] = 0x000000003f800000 // не упаковано
+	cpu.writeFReg(2, FMT_S, 0x3f800000)
+	cpu.ExecuteInst(fpInst(0x00, 2, 1, 0, 3)) // fadd.s f3, f1, f2
+	if got := cpu.fregisters[3]; got != NAN_BOX|F32_CANON_NAN {
+		t.Fatalf("fadd.s with unboxed operand: got %#x, want boxed canonical NaN", got)
+	}
+
+	cpu.writeReg(5, 0x80000000)
+	cpu.ExecuteInst(fpInst(0x78, 0, 5, 0, 6)) // fmv.w.x f6, x5
+	if got := cpu.fregisters[6]; got != 0xffffffff80000000 {
+		t.Fatalf("fmv.w.x: got %#x", got)
+	}
+	cpu.ExecuteInst(fpInst(0x70, 0, 6, 0, 7)) // fmv.x.w x7, f6
+	if got := cpu.readReg(7); got != 0xffffffff80000000 {
+		t.Fatalf("fmv.x.w: got %#x, want sign-extended value", got)
+	}
+
+	cpu.bus.Write(DRAM_BASE+0x100, 0x40490fdb, WORD)
+	cpu.writeReg(8, DRAM_BASE+0x100)
+	cpu.ExecuteInst(8<<15 | 2<<12 | 9<<7 | 0x07) // flw f9, 0(x8)
+	if got := cpu.fregisters[9]; got != 0xffffffff40490fdb {
+		t.Fatalf("flw: got %#x", got)
+	}
+	cpu.ExecuteInst(9<<20 | 8<<15 | 3<<12 | 8<<7 | 0x27) // fsd f9, 8(x8)
+	if got := memRead(cpu, DRAM_BASE+0x108, DOUBLEWORD); got != 0xffffffff40490fdb {
+		t.Fatalf("fsd: got %#x", got)
+	}
+}
+
+func TestFloatConvert(t *testing.T) {
+	tests := []struct {
+		name  string
+		inst  uint32
+		f     fpFmt
+		a     uint64
+		rm    uint64
+		want  uint64
+		flags uint64
+	}{
+		{"fcvt.w.s rne", fpInst(0x60, 0, 1, 7, 3), FMT_S, 0x3fc00000, RM_RNE, 2, FFLAGS_NX},
+		{"fcvt.w.s rtz", fpInst(0x60, 0, 1, 7, 3), FMT_S, 0x3fc00000, RM_RTZ, 1, FFLAGS_NX},
+		{"fcvt.w.d rdn", fpInst(0x61, 0, 1, 7, 3), FMT_D, 0xbff8000000000000, RM_RDN, 0xfffffffffffffffe, FFLAGS_NX},
+		{"fcvt.w.d overflow", fpInst(0x61, 0, 1, 0, 3), FMT_D, 0x41f0000000000000, 0, 0x7fffffff, FFLAGS_NV},
+		{"fcvt.wu.d negative", fpInst(0x61, 1, 1, 0, 3), FMT_D, 0xbff0000000000000, 0, 0, FFLAGS_NV},
+		{"fcvt.wu.s max", fpInst(0x60, 1, 1, 0, 3), FMT_S, 0x7f800000, 0, 0xffffffffffffffff, FFLAGS_NV},
+		{"fcvt.l.d NaN", fpInst(0x61, 2, 1, 0, 3), FMT_D, F64_CANON_NAN, 0, 0x7fffffffffffffff, FFLAGS_NV},
+		{"fcvt.lu.s exact", fpInst(0x60, 3, 1, 0, 3), FMT_S, 0x4f800000, 0, 1 << 32, 0},
+		{"fclass.d -inf", fpInst(0x71, 0, 1, 1, 3), FMT_D, 0xfff0000000000000, 0, 1 << 0, 0},
+		{"fclass.s subnormal", fpInst(0x70, 0, 1, 1, 3), FMT_S, 1, 0, 1 << 5, 0},
+		{"flt.d NaN", fpInst(0x51, 2, 1, 1, 3), FMT_D, F64_CANON_NAN, 0, 0, FFLAGS_NV},
+		{"feq.d qNaN", fpInst(0x51, 2, 1, 2, 3), FMT_D, F64_CANON_NAN, 0, 0, 0},
+	}
+	for _, test := range tests {
+		cpu := newFPCpu()
+		cpu.writeCSR(FRM, test.rm)
+		cpu.writeFReg(1, test.f, test.a)
+		cpu.ExecuteInst(test.inst)
+		if got := cpu.readReg(3); got != test.want {
+			t.Fatalf("%s: got %#x, want %#x", test.name, got, test.want)
+		}
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("%s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+
+	cpu := newFPCpu()
+	cpu.writeReg(1, 0xffffffff)               // -1 как 32-битное число
+	cpu.ExecuteInst(fpInst(0x69, 1, 1, 0, 2)) // fcvt.d.wu f2, x1
+	if got := cpu.fregisters[2]; got != 0x41efffffffe00000 {
+		t.Fatalf("fcvt.d.wu: got %#x", got)
+	}
+	cpu.writeReg(1, 0x7fffffffffffffff)
+	cpu.ExecuteInst(fpInst(0x69, 2, 1, 0, 2)) // fcvt.d.l f2, x1
+	if got := cpu.fregisters[2]; got != 0x43e0000000000000 || cpu.readCSR(FFLAGS) != FFLAGS_NX {
+		t.Fatalf("fcvt.d.l: got %#x fflags=%#x", got, cpu.readCSR(FFLAGS))
+	}
+
+	cpu = newFPCpu()
+	cpu.writeFReg(1, FMT_D, 0x3fd5555555555555)
+	cpu.ExecuteInst(fpInst(0x20, 1, 1, 0, 2)) // fcvt.s.d f2, f1
+	if got := cpu.fregisters[2]; got != NAN_BOX|0x3eaaaaab || cpu.readCSR(FFLAGS) != FFLAGS_NX {
+		t.Fatalf("fcvt.s.d: got %#x fflags=%#x", got, cpu.readCSR(FFLAGS))
+	}
+}
+
+func TestFloatState(t *testing.T) {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.csr[MTVEC] = 0x80001000
+
+	// FS = Off: инструкции и CSR FPU недоступны
+	cpu.ExecuteInst(fpInst(0x00, 2, 1, 0, 3))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("fadd.s with FS=Off: mcause=%d, want illegal instruction", cpu.csr[MCAUSE])
+	}
+	cpu.reset()
+	cpu.ExecuteInst(0x003022f3) // csrrs x5, fcsr, x0
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("fcsr read with FS=Off: mcause=%d, want illegal instruction", cpu.csr[MCAUSE])
+	}
+
+	cpu = newFPCpu()
+	if cpu.readCSR(MSTATUS)&MSTATUS_SD != 0 {
+		t.Fatalf("SD set for clean FPU state")
+	}
+	cpu.writeCSR(FCSR, 0xff)
+	if cpu.readCSR(FRM) != 7 || cpu.readCSR(FFLAGS) != 0x1f {
+		t.Fatalf("frm=%#x fflags=%#x, want views of fcsr", cpu.readCSR(FRM), cpu.readCSR(FFLAGS))
+	}
+	status := cpu.readCSR(SSTATUS)
+	if status&MSTATUS_FS != MSTATUS_FS || status&MSTATUS_SD == 0 {
+		t.Fatalf("sstatus=%#x, want FS=Dirty and SD", status)
+	}
+
+	// frm = 7 зарезервирован: dynamic rm недопустим
+	cpu.csr[MTVEC] = 0x80001000
+	cpu.ExecuteInst(fpInst(0x00, 2, 1, 7, 3))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("fadd.s with reserved frm: mcause=%d, want illegal instruction", cpu.csr[MCAUSE])
+	}
+	// sign injection не использует rm
+	cpu.csr[MCAUSE] = 0
+	cpu.pc = DRAM_BASE
+	cpu.ExecuteInst(fpInst(0x10, 2, 1, 0, 3))
+	if cpu.csr[MCAUSE] != 0 || cpu.pc != DRAM_BASE+4 {
+		t.Fatalf("fsgnj.s trapped: mcause=%d", cpu.csr[MCAUSE])
+	}
+}
+
+func TestHalfFloat(t *testing.T) {
+	const (
+		one16   = 0x3c00
+		two16   = 0x4000
+		three16 = 0x4200
+		inf16   = 0x7c00
+	)
+	tests := []struct {
+		name  string
+		inst  uint32
+		a, b  uint64
+		want  uint64
+		flags uint64
+	}{
+		{"fadd.h", fpInst(0x02, 2, 1, 0, 3), one16, two16, three16, 0},
+		{"fdiv.h inexact", fpInst(0x0e, 2, 1, 0, 3), one16, three16, 0x3555, FFLAGS_NX},
+		{"fmul.h overflow", fpInst(0x0a, 2, 1, 0, 3), 0x7bff, two16, inf16, FFLAGS_OF | FFLAGS_NX},
+		{"fsqrt.h", fpInst(0x2e, 0, 1, 0, 3), 0x4400, 0, two16, 0},
+		{"fmin.h qNaN", fpInst(0x16, 2, 1, 0, 3), F16_CANON_NAN, one16, one16, 0},
+		{"fsgnjn.h", fpInst(0x12, 2, 1, 1, 3), one16, one16, one16 | F16_SIGN, 0},
+	}
+	for _, test := range tests {
+		cpu := newFPCpu()
+		cpu.writeFReg(1, FMT_H, test.a)
+		cpu.writeFReg(2, FMT_H, test.b)
+		cpu.ExecuteInst(test.inst)
+		if got := cpu.fregisters[3]; got != test.want|NAN_BOX_H {
+			t.Fatalf("%s: got %#x, want %#x", test.name, got, test.want|NAN_BOX_H)
+		}
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("%s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+
+	cpu := newFPCpu()
+	// single-precision значение не упаковано как half
+	cpu.writeFReg(1, FMT_S, 0x3f800000)
+	cpu.writeFReg(2, FMT_H, one16)
+	cpu.ExecuteInst(fpInst(0x02, 2, 1, 0, 3)) // fadd.h
+	if got := cpu.readFReg(3, FMT_H); got != F16_CANON_NAN {
+		t.Fatalf("fadd.h with unboxed operand: got %#x, want canonical NaN", got)
+	}
+
+	cpu.bus.Write(DRAM_BASE+0x100, 0xbc00, HALFWORD)
+	cpu.writeReg(1, DRAM_BASE+0x100)
+	cpu.ExecuteInst(0x00009207) // flh f4, 0(x1)
+	if got := cpu.fregisters[4]; got != 0xffffffffffffbc00 {
+		t.Fatalf("flh: got %#x", got)
+	}
+	cpu.ExecuteInst(fpInst(0x72, 0, 4, 0, 5)) // fmv.x.h x5, f4
+	if got := cpu.readReg(5); got != 0xffffffffffffbc00 {
+		t.Fatalf("fmv.x.h: got %#x, want sign-extended -1.0", got)
+	}
+	cpu.ExecuteInst(fpInst(0x20, 2, 4, 0, 6)) // fcvt.s.h f6, f4
+	if got := cpu.readFReg(6, FMT_S); got != 0xbf800000 {
+		t.Fatalf("fcvt.s.h: got %#x", got)
+	}
+	cpu.writeFReg(7, FMT_D, 0x40effe0000000000) // 65520.0
+	cpu.ExecuteInst(fpInst(0x22, 1, 7, 0, 8))   // fcvt.h.d f8, f7
+	if got := cpu.readFReg(8, FMT_H); got != inf16 || cpu.readCSR(FFLAGS) != FFLAGS_OF|FFLAGS_NX {
+		t.Fatalf("fcvt.h.d: got %#x fflags=%#x", got, cpu.readCSR(FFLAGS))
+	}
+	cpu.writeFReg(9, FMT_H, 0xc100)           // -2.5
+	cpu.ExecuteInst(fpInst(0x62, 0, 9, 1, 5)) // fcvt.w.h x5, f9, rtz
+	if got := cpu.readReg(5); got != uint64(0xfffffffffffffffe) {
+		t.Fatalf("fcvt.w.h: got %#x, want -2", got)
+	}
+	cpu.writeReg(1, 0xffff)
+	cpu.ExecuteInst(fpInst(0x7a, 0, 1, 0, 10)) // fmv.h.x f10, x1
+	if got := cpu.fregisters[10]; got != 0xffffffffffffffff {
+		t.Fatalf("fmv.h.x: got %#x", got)
+	}
+}
+
+func TestZfa(t *testing.T) {
+	const (
+		one32 = 0x3f800000
+		one64 = 0x3ff0000000000000
+	)
+	tests := []struct {
+		name  string
+		inst  uint32
+		f     fpFmt
+		a, b  uint64
+		want  uint64
+		flags uint64
+	}{
+		{"fli.s 1.0", fpInst(0x78, 1, 16, 0, 3), FMT_S, 0, 0, one32, 0},
+		{"fli.s nan", fpInst(0x78, 1, 31, 0, 3), FMT_S, 0, 0, F32_CANON_NAN, 0},
+		{"fli.d -1.0", fpInst(0x79, 1, 0, 0, 3), FMT_D, 0, 0, one64 | F64_SIGN, 0},
+		{"fli.d 2^-16", fpInst(0x79, 1, 2, 0, 3), FMT_D, 0, 0, 0x3ef0000000000000, 0},
+		{"fli.d min", fpInst(0x79, 1, 1, 0, 3), FMT_D, 0, 0, 0x0010000000000000, 0},
+		{"fli.h min", fpInst(0x7a, 1, 1, 0, 3), FMT_H, 0, 0, 0x0400, 0},
+		{"fli.h 2^-16", fpInst(0x7a, 1, 2, 0, 3), FMT_H, 0, 0, 0x0100, 0},
+		{"fli.h 2^16", fpInst(0x7a, 1, 29, 0, 3), FMT_H, 0, 0, 0x7c00, 0},
+		{"fminm.s qNaN", fpInst(0x14, 2, 1, 2, 3), FMT_S, F32_CANON_NAN, one32, F32_CANON_NAN, 0},
+		{"fmaxm.d sNaN", fpInst(0x15, 2, 1, 3, 3), FMT_D, one64, 0x7ff0000000000001, F64_CANON_NAN, FFLAGS_NV},
+		{"fmaxm.d -0/+0", fpInst(0x15, 2, 1, 3, 3), FMT_D, F64_SIGN, 0, 0, 0},
+		{"fround.s rne", fpInst(0x20, 4, 1, 0, 3), FMT_S, 0x40200000, 0, 0x40000000, 0},
+		{"froundnx.s rne", fpInst(0x20, 5, 1, 0, 3), FMT_S, 0x40200000, 0, 0x40000000, FFLAGS_NX},
+		{"froundnx.s exact", fpInst(0x20, 5, 1, 0, 3), FMT_S, 0x40400000, 0, 0x40400000, 0},
+		{"fround.d -0.5 rup", fpInst(0x21, 4, 1, 3, 3), FMT_D, 0xbfe0000000000000, 0, F64_SIGN, 0},
+		{"fround.d rmm", fpInst(0x21, 4, 1, 4, 3), FMT_D, 0x4004000000000000, 0, 0x4008000000000000, 0},
+		{"fround.d large", fpInst(0x21, 4, 1, 0, 3), FMT_D, 0x7e37e43c8800759c, 0, 0x7e37e43c8800759c, 0},
+		{"fround.h sNaN", fpInst(0x22, 4, 1, 0, 3), FMT_H, 0x7c01, 0, F16_CANON_NAN, FFLAGS_NV},
+	}
+	for _, test := range tests {
+		cpu := newFPCpu()
+		cpu.writeFReg(1, test.f, test.a)
+		cpu.writeFReg(2, test.f, test.b)
+		cpu.ExecuteInst(test.inst)
+		if got := cpu.readFReg(3, test.f); got != test.want {
+			t.Fatalf("%s: got %#x, want %#x", test.name, got, test.want)
+		}
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("%s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+
+	cvt := []struct {
+		name  string
+		a     uint64
+		want  uint64
+		flags uint64
+	}{
+		{"2^32+5", 0x41f0000000500000, 5, FFLAGS_NV},
+		{"3e9", 0x41e65a0bc0000000, uint64(0xffffffffb2d05e00), FFLAGS_NV},
+		{"-1.5", 0xbff8000000000000, ^uint64(0), FFLAGS_NX},
+		{"-2^31", 0xc1e0000000000000, uint64(0xffffffff80000000), 0},
+		{"inf", 0x7ff0000000000000, 0, FFLAGS_NV},
+		{"nan", F64_CANON_NAN, 0, FFLAGS_NV},
+	}
+	for _, test := range cvt {
+		cpu := newFPCpu()
+		cpu.writeFReg(1, FMT_D, test.a)
+		cpu.ExecuteInst(fpInst(0x61, 8, 1, 1, 3)) // fcvtmod.w.d x3, f1, rtz
+		if got := cpu.readReg(3); got != test.want {
+			t.Fatalf("fcvtmod.w.d %s: got %#x, want %#x", test.name, got, test.want)
+		}
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("fcvtmod.w.d %s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+
+	// fltq и fleq не сигнализируют на тихих NaN
+	cpu := newFPCpu()
+	cpu.writeFReg(1, FMT_S, F32_CANON_NAN)
+	cpu.writeFReg(2, FMT_S, one32)
+	cpu.ExecuteInst(fpInst(0x50, 2, 1, 5, 3)) // fltq.s
+	if cpu.readReg(3) != 0 || cpu.readCSR(FFLAGS) != 0 {
+		t.Fatalf("fltq.s qNaN: rd=%d fflags=%#x", cpu.readReg(3), cpu.readCSR(FFLAGS))
+	}
+	cpu.ExecuteInst(fpInst(0x50, 2, 1, 1, 3)) // flt.s
+	if cpu.readCSR(FFLAGS) != FFLAGS_NV {
+		t.Fatalf("flt.s qNaN: fflags=%#x, want NV", cpu.readCSR(FFLAGS))
+	}
+	cpu.writeFReg(1, FMT_D, one64)
+	cpu.writeFReg(2, FMT_D, one64)
+	cpu.ExecuteInst(fpInst(0x51, 2, 1, 4, 3)) // fleq.d
+	if cpu.readReg(3) != 1 {
+		t.Fatalf("fleq.d 1<=1: rd=%d", cpu.readReg(3))
+	}
+}
+
+func TestQuadFloat(t *testing.T) {
+	const (
+		oneHi   = 0x3fff000000000000 // 1.0, младшая половина нулевая
+		twoHi   = 0x4000000000000000
+		third   = 0x5555555555555555
+		thirdHi = 0x3ffd555555555555
+	)
+	cpu := newFPCpu()
+	cpu.csr[MTVEC] = 0x80001000
+	cpu.ExecuteInst(fpInst(0x03, 2, 1, 0, 3)) // fadd.q
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("fadd.q with FLEN=64: mcause=%d, want illegal instruction", cpu.csr[MCAUSE])
+	}
+	if err := cpu.ConfigureFLEN(96); err == nil {
+		t.Fatalf("FLEN=96 must be rejected")
+	}
+	if err := cpu.ConfigureFLEN(128); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.csr[MISA]&misaExt('Q') == 0 {
+		t.Fatalf("misa.Q must be set with FLEN=128")
+	}
+	cpu.csr[MSTATUS] = EXT_STATUS_INITIAL << MSTATUS_FS_SHIFT
+
+	quad := func(reg uint64) (uint64, uint64) { return cpu.fregistersHi[reg], cpu.fregisters[reg] }
+	setQuad := func(reg, hi, lo uint64) { cpu.fregistersHi[reg], cpu.fregisters[reg] = hi, lo }
+
+	cpu.writeFReg(1, FMT_D, 0x3ff0000000000000)
+	if hi, _ := quad(1); hi != ^uint64(0) {
+		t.Fatalf("double must be NaN-boxed in a 128-bit register, hi=%#x", hi)
+	}
+	cpu.ExecuteInst(fpInst(0x23, 1, 1, 0, 1)) // fcvt.q.d f1, f1
+	if hi, lo := quad(1); hi != oneHi || lo != 0 {
+		t.Fatalf("fcvt.q.d: got %#x_%016x", hi, lo)
+	}
+	setQuad(2, 0x4000800000000000, 0)         // 3.0
+	cpu.ExecuteInst(fpInst(0x0f, 2, 1, 0, 3)) // fdiv.q f3, f1, f2
+	if hi, lo := quad(3); hi != thirdHi || lo != third || cpu.readCSR(FFLAGS) != FFLAGS_NX {
+		t.Fatalf("fdiv.q 1/3: got %#x_%016x fflags=%#x", hi, lo, cpu.readCSR(FFLAGS))
+	}
+	cpu.ExecuteInst(fpInst(0x21, 3, 3, 0, 4)) // fcvt.d.q f4, f3
+	if got := cpu.readFReg(4, FMT_D); got != 0x3fd5555555555555 {
+		t.Fatalf("fcvt.d.q: got %#x", got)
+	}
+	// double-операнд из quad-регистра не упакован
+	cpu.ExecuteInst(fpInst(0x01, 3, 3, 0, 5)) // fadd.d f5, f3, f3
+	if got := cpu.readFReg(5, FMT_D); got != F64_CANON_NAN {
+		t.Fatalf("fadd.d with quad operand: got %#x, want canonical NaN", got)
+	}
+
+	setQuad(6, twoHi, 0)
+	cpu.ExecuteInst(fpInst(0x2f, 0, 6, 0, 7)) // fsqrt.q f7, f6
+	if hi, lo := quad(7); hi != 0x3fff6a09e667f3bc || lo != 0xc908b2fb1366ea95 {
+		t.Fatalf("fsqrt.q 2: got %#x_%016x", hi, lo)
+	}
+	cpu.ExecuteInst(fpInst(0x13, 7, 7, 1, 8)) // fsgnjn.q f8, f7, f7
+	if hi, _ := quad(8); hi != 0xbfff6a09e667f3bc {
+		t.Fatalf("fsgnjn.q: got %#x", hi)
+	}
+	cpu.ExecuteInst(fpInst(0x63, 2, 8, 1, 5)) // fcvt.l.q x5, f8, rtz
+	if got := cpu.readReg(5); got != ^uint64(0) {
+		t.Fatalf("fcvt.l.q -sqrt(2): got %#x, want -1", got)
+	}
+	cpu.ExecuteInst(fpInst(0x53, 7, 8, 1, 5)) // flt.q x5, f8, f7
+	if cpu.readReg(5) != 1 {
+		t.Fatalf("flt.q -sqrt(2) < sqrt(2) failed")
+	}
+	cpu.ExecuteInst(fpInst(0x7b, 1, 1, 0, 9)) // fli.q f9, min
+	if hi, lo := quad(9); hi != 0x0001000000000000 || lo != 0 {
+		t.Fatalf("fli.q min: got %#x_%016x", hi, lo)
+	}
+
+	// flq/fsq и перемещения между x и f регистрами
+	cpu.writeReg(1, DRAM_BASE+0x100)
+	cpu.ExecuteInst(0x0070c027) // fsq f7, 0(x1)
+	if memRead(cpu, DRAM_BASE+0x108, DOUBLEWORD) != 0x3fff6a09e667f3bc {
+		t.Fatalf("fsq: upper half not stored")
+	}
+	cpu.bus.Write(DRAM_BASE+0x110, 1, DOUBLEWORD)
+	cpu.bus.Write(DRAM_BASE+0x118, 2, DOUBLEWORD)
+	cpu.ExecuteInst(0x0100c507) // flq f10, 16(x1)
+	if hi, lo := quad(10); hi != 2 || lo != 1 {
+		t.Fatalf("flq: got %#x_%016x", hi, lo)
+	}
+	cpu.ExecuteInst(fpInst(0x73, 1, 7, 0, 5)) // fmvh.x.q x5, f7
+	if got := cpu.readReg(5); got != 0x3fff6a09e667f3bc {
+		t.Fatalf("fmvh.x.q: got %#x", got)
+	}
+	cpu.writeReg(2, 3)
+	cpu.ExecuteInst(fpInst(0x5b, 2, 5, 0, 11)) // fmvp.q.x f11, x5, x2
+	if hi, lo := quad(11); hi != 3 || lo != 0x3fff6a09e667f3bc {
+		t.Fatalf("fmvp.q.x: got %#x_%016x", hi, lo)
+	}
+}
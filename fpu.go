@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+	"math/big"
+)
+
+// Floating-point formats, values are the fmt field of OP-FP instructions
+type fpFmt uint64
+
+const (
+	FMT_S fpFmt = 0
+	FMT_D fpFmt = 1
+	FMT_H fpFmt = 2
+	FMT_Q fpFmt = 3
+)
+
+// fflags bits
+const (
+	FFLAGS_NX uint64 = 1 << 0 // inexact
+	FFLAGS_UF uint64 = 1 << 1 // underflow
+	FFLAGS_OF uint64 = 1 << 2 // overflow
+	FFLAGS_DZ uint64 = 1 << 3 // divide by zero
+	FFLAGS_NV uint64 = 1 << 4 // invalid operation
+)
+
+// fclass bits
+const (
+	FCLASS_NEG_INF       uint64 = 1 << 0
+	FCLASS_NEG_NORMAL    uint64 = 1 << 1
+	FCLASS_NEG_SUBNORMAL uint64 = 1 << 2
+	FCLASS_NEG_ZERO      uint64 = 1 << 3
+	FCLASS_POS_ZERO      uint64 = 1 << 4
+	FCLASS_POS_SUBNORMAL uint64 = 1 << 5
+	FCLASS_POS_NORMAL    uint64 = 1 << 6
+	FCLASS_POS_INF       uint64 = 1 << 7
+	FCLASS_SNAN          uint64 = 1 << 8
+	FCLASS_QNAN          uint64 = 1 << 9
+
+	FCLASS_FINITE = FCLASS_NEG_NORMAL | FCLASS_NEG_SUBNORMAL | FCLASS_NEG_ZERO |
+		FCLASS_POS_ZERO | FCLASS_POS_SUBNORMAL | FCLASS_POS_NORMAL
+)
+
+// Rounding modes
+const (
+	RM_RNE uint64 = 0 // к ближайшему, к чётному при равенстве
+	RM_RTZ uint64 = 1 // к нулю
+	RM_RDN uint64 = 2 // вниз
+	RM_RUP uint64 = 3 // вверх
+	RM_RMM uint64 = 4 // к ближайшему, от нуля при равенстве
+	RM_DYN uint64 = 7 // из frm
+)
+
+const (
+	F16_SIGN      uint64 = 1 << 15
+	F16_CANON_NAN uint64 = 0x7e00
+
+	F32_SIGN      uint64 = 1 << 31
+	F32_CANON_NAN uint64 = 0x7fc00000
+
+	F64_SIGN      uint64 = 1 << 63
+	F64_CANON_NAN uint64 = 0x7ff8000000000000
+)
+
+func (f fpFmt) format() floatFormat {
+	switch f {
+	case FMT_H:
+		return BINARY16
+	case FMT_S:
+		return BINARY32
+	case FMT_Q:
+		return BINARY128
+	}
+	return BINARY64
+}
+
+func fpSignBit(f fpFmt) uint64 {
+	switch f {
+	case FMT_H:
+		return F16_SIGN
+	case FMT_S:
+		return F32_SIGN
+	}
+	return F64_SIGN
+}
+
+func fpCanonicalNaN(f fpFmt) uint64 {
+	switch f {
+	case FMT_H:
+		return F16_CANON_NAN
+	case FMT_S:
+		return F32_CANON_NAN
+	}
+	return F64_CANON_NAN
+}
+
+// Operations below work on raw register bits and return the result
+// with accrued fflags, arithmetic is done by the softfloat core.
+
+func rawBits(a uint64) *big.Int {
+	return new(big.Int).SetUint64(a)
+}
+
+func fpAdd(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfAdd(f.format(), rawBits(a), rawBits(b), rm)
+	return r.Uint64(), flags
+}
+
+func fpSub(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfSub(f.format(), rawBits(a), rawBits(b), rm)
+	return r.Uint64(), flags
+}
+
+func fpMul(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfMul(f.format(), rawBits(a), rawBits(b), rm)
+	return r.Uint64(), flags
+}
+
+func fpDiv(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfDiv(f.format(), rawBits(a), rawBits(b), rm)
+	return r.Uint64(), flags
+}
+
+func fpSqrt(f fpFmt, a uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfSqrt(f.format(), rawBits(a), rm)
+	return r.Uint64(), flags
+}
+
+// fpFma computes (a*b)+c, negProd and negAdd select fmsub/fnmsub/fnmadd
+func fpFma(f fpFmt, a, b, c uint64, negProd, negAdd bool, rm uint64) (uint64, uint64) {
+	r, flags := sfMulAdd(f.format(), rawBits(a), rawBits(b), rawBits(c), negProd, negAdd, rm)
+	return r.Uint64(), flags
+}
+
+func fpMinMax(f fpFmt, a, b uint64, max bool) (uint64, uint64) {
+	r, flags := sfMinMax(f.format(), rawBits(a), rawBits(b), max)
+	return r.Uint64(), flags
+}
+
+func fpCompare(f fpFmt, a, b uint64, lt, eq bool) (bool, uint64) {
+	return sfCompare(f.format(), rawBits(a), rawBits(b), lt, eq)
+}
+
+func fpClassify(f fpFmt, a uint64) uint64 {
+	return sfClassify(f.format(), rawBits(a))
+}
+
+func fpToInt(f fpFmt, a uint64, signed bool, width uint, rm uint64) (uint64, uint64) {
+	return sfToInt(f.format(), rawBits(a), signed, width, rm)
+}
+
+func fpFromInt(f fpFmt, x uint64, signed bool, width uint, rm uint64) (uint64, uint64) {
+	r, flags := sfFromInt(f.format(), x, signed, width, rm)
+	return r.Uint64(), flags
+}
+
+func fpConvert(to fpFmt, from fpFmt, a uint64, rm uint64) (uint64, uint64) {
+	r, flags := sfConvert(to.format(), from.format(), rawBits(a), rm)
+	return r.Uint64(), flags
+}
+
+// fpInf returns +infinity of the format
+func fpInf(f fpFmt) uint64 {
+	ff := f.format()
+	return (1<<ff.expBits - 1) << ff.fracBits
+}
+
+// FLI_TABLE holds the constants of fli indexed by rs1. Entry 1 is the
+// minimum positive normal number of the destination format.
+var FLI_TABLE = [32]float64{
+	-1, 0, 0x1p-16, 0x1p-15, 0x1p-8, 0x1p-7, 0.0625, 0.125,
+	0.25, 0.3125, 0.375, 0.4375, 0.5, 0.625, 0.75, 0.875,
+	1, 1.25, 1.5, 1.75, 2, 2.5, 3, 4,
+	8, 16, 128, 256, 0x1p15, 0x1p16, math.Inf(1), math.NaN(),
+}
+
+// fliConst returns fli constant idx in format f
+func fliConst(f floatFormat, idx uint64) *big.Int {
+	if idx == 1 {
+		return sfPack(f, false, 1, new(big.Int))
+	}
+	// все константы точно представимы в double, 2^16 в half переполняется до inf
+	res, _ := sfConvert(f, BINARY64, rawBits(math.Float64bits(FLI_TABLE[idx])), RM_RNE)
+	return res
+}
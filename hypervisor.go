@@ -0,0 +1,237 @@
+package main
+
+// hstatus fields
+const (
+	HSTATUS_GVA        uint64 = 1 << 6
+	HSTATUS_SPV        uint64 = 1 << 7
+	HSTATUS_SPVP_SHIFT uint64 = 8
+	HSTATUS_SPVP       uint64 = 1 << HSTATUS_SPVP_SHIFT
+	HSTATUS_HU         uint64 = 1 << 9
+	HSTATUS_VTVM       uint64 = 1 << 20
+	HSTATUS_VTW        uint64 = 1 << 21
+	HSTATUS_VTSR       uint64 = 1 << 22
+	HSTATUS_VSXL_64    uint64 = 2 << 32
+
+	// VSBE, VGEIN и VSXL не изменяются: VS-режим всегда RV64 little-endian
+	HSTATUS_WRITABLE uint64 = HSTATUS_GVA | HSTATUS_SPV | HSTATUS_SPVP | HSTATUS_HU |
+		HSTATUS_VTVM | HSTATUS_VTW | HSTATUS_VTSR
+)
+
+// hgatp fields, MODE uses satp encoding: Sv39x4 = 8, Sv48x4 = 9
+const (
+	HGATP_MODE uint64 = 0xf << SATP_MODE_SHIFT
+	HGATP_VMID uint64 = 0x3fff << SATP_ASID_SHIFT
+	// корневая таблица G-стадии в 4 раза больше: гостевой адрес шире на 2 бита
+	HGATP_WIDEN uint64 = 2
+)
+
+// Exceptions that can be delegated to VS-mode: ecalls from HS/VS/M-mode,
+// guest-page faults and virtual instruction are always handled in HS-mode
+const HEDELEG_MASK uint64 = 0x1ff | 1<<INSTRUCTION_PAGE_FAULT | 1<<LOAD_PAGE_FAULT | 1<<STORE_AMO_PAGE_FAULT
+
+// Pseudoinstructions written to htinst/mtinst on guest-page faults of
+// implicit accesses to VS-stage page tables: 64-bit load and store
+const (
+	TINST_PTE_READ  uint64 = 0x00003000
+	TINST_PTE_WRITE uint64 = 0x00003020
+)
+
+// virtualAlias redirects accesses to S-mode CSRs to their VS copies while V=1
+func (cpu *Cpu) virtualAlias(csr uint64) uint64 {
+	if !cpu.virt {
+		return csr
+	}
+	switch csr {
+	case SSTATUS, SIE, STVEC, SSCRATCH, SEPC, SCAUSE, STVAL, SIP, SATP:
+		return csr - SSTATUS + VSSTATUS
+	}
+	return csr
+}
+
+// csrVirtualFault reports whether a csr access permitted with V=0
+// raises virtual instruction exception in VS/VU-mode
+func (cpu *Cpu) csrVirtualFault(csr uint64) bool {
+	switch {
+	case csrLevel(csr) == CSR_LEVEL_HYPERVISOR:
+		return true
+	case csrLevel(csr) == CSR_LEVEL_SUPERVISOR:
+		return cpu.privilege == USER_MODE || (csr == SATP && cpu.csr[HSTATUS]&HSTATUS_VTVM != 0)
+	case csr >= CYCLE && csr <= HPMCOUNTER31:
+		// mcounteren проверен в counterAccessible
+		bit := uint64(1) << (csr - CYCLE)
+		if cpu.privilege == USER_MODE && cpu.csr[SCOUNTEREN]&bit == 0 {
+			return true
+		}
+		return cpu.csr[HCOUNTEREN]&bit == 0
+	}
+	return false
+}
+
+// translateGuest performs two-stage translation: VS-stage with vsatp gives
+// a guest physical address, G-stage with hgatp maps it to a supervisor
+// physical address. Guest translations are not cached in the TLB, so
+// hfence.* and sfence.vma with V=1 have nothing to invalidate.
+func (cpu *Cpu) translateGuest(vaddr uint64, access AccessType, priv PrivMode) (uint64, bool) {
+	gpa := vaddr
+	vsatp := cpu.csr[VSATP]
+	if pagingLevels(vsatp) != 0 {
+		// mstatus.MXR действует на обе стадии
+		status := cpu.csr[VSSTATUS] | cpu.csr[MSTATUS]&MSTATUS_MXR
+		w := newPageWalk(vsatp, priv, status, access, vaddr)
+		w.exec = cpu.hlvx
+		w.guest = true
+		var ok bool
+		if gpa, _, _, ok = cpu.walk(vaddr, access, &w); !ok {
+			return 0, false
+		}
+	}
+	return cpu.gstage(gpa, access, vaddr, 0)
+}
+
+// gstage translates guest physical address with hgatp, leaf PTEs are
+// checked as U-mode ones. For implicit accesses to VS-stage page tables
+// tinst is set: they need read or write permission, while faults are
+// reported for the original access.
+func (cpu *Cpu) gstage(gpa uint64, access AccessType, gva uint64, tinst uint64) (uint64, bool) {
+	hgatp := cpu.csr[HGATP]
+	w := pageWalk{
+		root:   (hgatp & SATP_PPN) * PAGE_SIZE,
+		levels: pagingLevels(hgatp),
+		widen:  HGATP_WIDEN,
+		priv:   USER_MODE,
+		mxr:    cpu.csr[MSTATUS]&MSTATUS_MXR != 0,
+		exec:   tinst == 0 && cpu.hlvx,
+		gstage: true,
+		fault:  access,
+		tval:   gva,
+		tinst:  tinst,
+	}
+	if w.levels == 0 {
+		return gpa, true
+	}
+	perm := access
+	switch tinst {
+	case TINST_PTE_READ:
+		perm = ACCESS_LOAD
+	case TINST_PTE_WRITE:
+		perm = ACCESS_STORE
+	}
+	paddr, _, _, ok := cpu.walk(gpa, perm, &w)
+	return paddr, ok
+}
+
+// hypervisorCheck raises virtual instruction for hypervisor instructions
+// in VS/VU-mode and illegal instruction in U-mode, where hlv/hsv are
+// allowed by hstatus.HU, or without the H extension in misa
+func (cpu *Cpu) hypervisorCheck(inst InstWord, hu bool) bool {
+	switch {
+	case cpu.csr[MISA]&misaExt('H') == 0:
+		cpu.IllegalInst(uint32(inst))
+		return false
+	case cpu.virt:
+		cpu.VirtualInst(uint32(inst))
+		return false
+	case cpu.privilege == USER_MODE && !(hu && cpu.csr[HSTATUS]&HSTATUS_HU != 0):
+		cpu.IllegalInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
+// hlv loads from guest memory with V=1 and the privilege in hstatus.SPVP,
+// hlvx requires execute permission instead of read permission
+func (cpu *Cpu) hlv(inst InstWord, size uint8, exec bool) (uint64, bool) {
+	if !cpu.hypervisorCheck(inst, true) {
+		return 0, false
+	}
+	cpu.hlsv, cpu.hlvx = true, exec
+	data, ok := cpu.load(cpu.readReg(inst.rs1()), size)
+	cpu.hlsv, cpu.hlvx = false, false
+	return data, ok
+}
+
+// hsv stores to guest memory with V=1 and the privilege in hstatus.SPVP
+func (cpu *Cpu) hsv(inst InstWord, size uint8) {
+	if !cpu.hypervisorCheck(inst, true) {
+		return
+	}
+	cpu.hlsv = true
+	cpu.store(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()), size)
+	cpu.hlsv = false
+}
+
+func (cpu *Cpu) hlvB(inst InstWord) {
+	if data, ok := cpu.hlv(inst, BYTE, false); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int8(data))))
+	}
+}
+
+func (cpu *Cpu) hlvBu(inst InstWord) {
+	if data, ok := cpu.hlv(inst, BYTE, false); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hlvH(inst InstWord) {
+	if data, ok := cpu.hlv(inst, HALFWORD, false); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int16(data))))
+	}
+}
+
+func (cpu *Cpu) hlvHu(inst InstWord) {
+	if data, ok := cpu.hlv(inst, HALFWORD, false); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hlvxHu(inst InstWord) {
+	if data, ok := cpu.hlv(inst, HALFWORD, true); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hlvW(inst InstWord) {
+	if data, ok := cpu.hlv(inst, WORD, false); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int32(data))))
+	}
+}
+
+func (cpu *Cpu) hlvWu(inst InstWord) {
+	if data, ok := cpu.hlv(inst, WORD, false); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hlvxWu(inst InstWord) {
+	if data, ok := cpu.hlv(inst, WORD, true); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hlvD(inst InstWord) {
+	if data, ok := cpu.hlv(inst, DOUBLEWORD, false); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+func (cpu *Cpu) hsvB(inst InstWord) { cpu.hsv(inst, BYTE) }
+func (cpu *Cpu) hsvH(inst InstWord) { cpu.hsv(inst, HALFWORD) }
+func (cpu *Cpu) hsvW(inst InstWord) { cpu.hsv(inst, WORD) }
+func (cpu *Cpu) hsvD(inst InstWord) { cpu.hsv(inst, DOUBLEWORD) }
+
+// hfenceVvma orders VS-stage page table updates, guest translations
+// are not cached so only the permission checks remain
+func (cpu *Cpu) hfenceVvma(inst InstWord) {
+	cpu.hypervisorCheck(inst, false)
+}
+
+// hfenceGvma orders G-stage page table updates, in HS-mode it is
+// trapped by mstatus.TVM like sfence.vma
+func (cpu *Cpu) hfenceGvma(inst InstWord) {
+	if !cpu.hypervisorCheck(inst, false) {
+		return
+	}
+	if cpu.privilege == SUPERVISOR_MODE && cpu.csr[MSTATUS]&MSTATUS_TVM != 0 {
+		cpu.IllegalInst(uint32(inst))
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import "testing"
+
+const (
+	ldX3   = 0x0000b183 // ld x3, 0(x1)
+	swX2   = 0x0020a023 // sw x2, 0(x1)
+	hlvD   = 0x6c00c1f3 // hlv.d x3, (x1)
+	hlvxWu = 0x6830c1f3 // hlvx.wu x3, (x1)
+	hsvW   = 0x6a20c073 // hsv.w x2, (x1)
+)
+
+// newGuest builds VS-stage Sv39 tables in guest memory and a G-stage
+// Sv39x4 table mapping the first 2MiB of DRAM one to one, so that
+// the VS-stage tables are reachable. The hart is left in VS-mode.
+func newGuest() (*Cpu, *pageTables, *pageTables) {
+	cpu := NewCPU()
+	vs := newPageTables(cpu, SATP_MODE_SV39)
+	cpu.csr[VSATP], cpu.csr[SATP] = cpu.csr[SATP], 0
+	g := &pageTables{cpu: cpu, root: 0x80200000, next: 0x80204000, levels: 3, widen: HGATP_WIDEN}
+	cpu.csr[HGATP] = SATP_MODE_SV39<<SATP_MODE_SHIFT | g.root/PAGE_SIZE
+	g.mapPage(DRAM_BASE, DRAM_BASE, PTE_R|PTE_W|PTE_U, 1)
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, true
+	return cpu, vs, g
+}
+
+func TestGuestTranslation(t *testing.T) {
+	cpu, vs, g := newGuest()
+	vs.mapPage(0x1000, 0x40000000, PTE_R|PTE_W, 0)
+	vs.mapPage(0x2000, 0x40001000, PTE_R|PTE_W, 0)
+	g.mapPage(0x40000000, 0x80300000, PTE_R|PTE_W|PTE_U, 0)
+	cpu.bus.Write(0x80300000, 0xdeadbeef, DOUBLEWORD)
+
+	cpu.writeReg(1, 0x1000)
+	cpu.ExecuteInst(ldX3)
+	if cpu.exception != nil || cpu.readReg(3) != 0xdeadbeef {
+		t.Fatalf("two-stage load: x3=%#x, exception=%v", cpu.readReg(3), cpu.exception)
+	}
+	if vs.leafPTE(0x1000, 0)&PTE_A == 0 || g.leafPTE(0x40000000, 0)&PTE_A == 0 {
+		t.Fatalf("A bit must be set at both stages")
+	}
+
+	// 0x40001000 не отображён G-стадией
+	cpu.writeReg(1, 0x2008)
+	cpu.ExecuteInst(ldX3)
+	if cpu.csr[MCAUSE] != uint64(LOAD_GUEST_PAGE_FAULT) || cpu.csr[MTVAL] != 0x2008 ||
+		cpu.csr[MTVAL2] != 0x40001008>>2 || cpu.csr[MTINST] != 0 {
+		t.Fatalf("mcause=%d mtval=%#x mtval2=%#x mtinst=%#x",
+			cpu.csr[MCAUSE], cpu.csr[MTVAL], cpu.csr[MTVAL2], cpu.csr[MTINST])
+	}
+	if mstatus := cpu.csr[MSTATUS]; mstatus&MSTATUS_MPV == 0 || mstatus&MSTATUS_GVA == 0 || cpu.virt {
+		t.Fatalf("trap from VS-mode must set MPV and GVA and clear V, mstatus=%#x", mstatus)
+	}
+
+	// корневая таблица VS-стадии вне отображённой гостевой памяти
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, true
+	cpu.csr[VSATP] = SATP_MODE_SV39<<SATP_MODE_SHIFT | 0x50000000/PAGE_SIZE
+	cpu.writeReg(1, 0x1000)
+	cpu.ExecuteInst(swX2)
+	if cpu.csr[MCAUSE] != uint64(STORE_AMO_GUEST_PAGE_FAULT) || cpu.csr[MTVAL2] != 0x50000000>>2 ||
+		cpu.csr[MTINST] != TINST_PTE_READ {
+		t.Fatalf("implicit access: mcause=%d mtval2=%#x mtinst=%#x",
+			cpu.csr[MCAUSE], cpu.csr[MTVAL2], cpu.csr[MTINST])
+	}
+}
+
+func TestHypervisorTraps(t *testing.T) {
+	cpu, vs, _ := newGuest()
+	vs.mapPage(0x1000, 0x40000000, PTE_R|PTE_W, 0)
+	cpu.csr[MEDELEG] = 1<<LOAD_GUEST_PAGE_FAULT | 1<<LOAD_PAGE_FAULT | 1<<ECALL_FROM_VSMODE
+	cpu.csr[STVEC] = 0x80001000
+	cpu.csr[VSTVEC] = 0x80002000
+	cpu.pc = 0x80000100
+
+	cpu.writeReg(1, 0x1000)
+	cpu.ExecuteInst(ldX3)
+	if cpu.privilege != SUPERVISOR_MODE || cpu.virt || cpu.pc != 0x80001000 ||
+		cpu.csr[SCAUSE] != uint64(LOAD_GUEST_PAGE_FAULT) || cpu.csr[STVAL] != 0x1000 ||
+		cpu.csr[HTVAL] != 0x40000000>>2 {
+		t.Fatalf("guest-page fault must go to HS-mode: scause=%d htval=%#x pc=%#x",
+			cpu.csr[SCAUSE], cpu.csr[HTVAL], cpu.pc)
+	}
+	if hstatus := cpu.csr[HSTATUS]; hstatus&(HSTATUS_SPV|HSTATUS_SPVP|HSTATUS_GVA) !=
+		HSTATUS_SPV|HSTATUS_SPVP|HSTATUS_GVA {
+		t.Fatalf("hstatus=%#x", hstatus)
+	}
+
+	cpu.ExecuteInst(0x10200073) // sret
+	if !cpu.virt || cpu.privilege != SUPERVISOR_MODE || cpu.pc != 0x80000100 {
+		t.Fatalf("sret must return to VS-mode, pc=%#x", cpu.pc)
+	}
+
+	// page fault VS-стадии делегирован гостю
+	cpu.csr[HEDELEG] = 1 << LOAD_PAGE_FAULT
+	cpu.writeReg(1, 0x5000)
+	cpu.ExecuteInst(ldX3)
+	if !cpu.virt || cpu.pc != 0x80002000 || cpu.csr[VSCAUSE] != uint64(LOAD_PAGE_FAULT) ||
+		cpu.csr[VSTVAL] != 0x5000 || cpu.csr[VSEPC] != 0x80000100 ||
+		cpu.csr[VSSTATUS]&MSTATUS_SPP == 0 {
+		t.Fatalf("page fault must go to VS-mode: vscause=%d pc=%#x", cpu.csr[VSCAUSE], cpu.pc)
+	}
+
+	cpu.ExecuteInst(0x00000073) // ecall
+	if cpu.virt || cpu.csr[SCAUSE] != uint64(ECALL_FROM_VSMODE) {
+		t.Fatalf("ecall from VS-mode: scause=%d", cpu.csr[SCAUSE])
+	}
+
+	cpu.privilege = MACHINE_MODE
+	cpu.csr[MSTATUS] = MSTATUS_MPV | uint64(USER_MODE)<<MSTATUS_MPP_SHIFT
+	cpu.csr[MEPC] = 0x80000200
+	cpu.ExecuteInst(0x30200073) // mret
+	if !cpu.virt || cpu.privilege != USER_MODE || cpu.pc != 0x80000200 || cpu.csr[MSTATUS]&MSTATUS_MPV != 0 {
+		t.Fatalf("mret must enter VU-mode, pc=%#x", cpu.pc)
+	}
+}
+
+func TestHypervisorCSRs(t *testing.T) {
+	cpu := NewCPU()
+	cpu.writeCSR(HSTATUS, ^uint64(0))
+	if got := cpu.readCSR(HSTATUS); got != HSTATUS_WRITABLE|HSTATUS_VSXL_64 {
+		t.Fatalf("hstatus=%#x", got)
+	}
+	cpu.writeCSR(HEDELEG, ^uint64(0))
+	if got := cpu.readCSR(HEDELEG); got != HEDELEG_MASK {
+		t.Fatalf("hedeleg=%#x, want %#x", got, HEDELEG_MASK)
+	}
+	cpu.writeCSR(HGATP, SATP_MODE_SV57<<SATP_MODE_SHIFT|0x80200)
+	if got := cpu.readCSR(HGATP); got != 0 {
+		t.Fatalf("unsupported hgatp mode must be ignored, hgatp=%#x", got)
+	}
+	cpu.writeCSR(HGATP, SATP_MODE_SV39<<SATP_MODE_SHIFT|0x80203)
+	if got := cpu.readCSR(HGATP); got != SATP_MODE_SV39<<SATP_MODE_SHIFT|0x80200 {
+		t.Fatalf("hgatp root must be 16KiB aligned, hgatp=%#x", got)
+	}
+
+	cpu.writeCSR(HIDELEG, MIP_VSSIP)
+	cpu.writeCSR(HVIP, MIP_VSSIP)
+	if cpu.readCSR(MIP)&MIP_VSSIP == 0 || cpu.readCSR(VSIP) != MIP_SSIP {
+		t.Fatalf("mip=%#x vsip=%#x", cpu.readCSR(MIP), cpu.readCSR(VSIP))
+	}
+
+	// в VS-режиме CSR супервизора отображают VS-копии
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, true
+	cpu.writeReg(1, 0x1234)
+	cpu.ExecuteInst(csrInst(SSCRATCH, 1))
+	if cpu.csr[VSSCRATCH] != 0x1234 || cpu.csr[SSCRATCH] != 0 {
+		t.Fatalf("vsscratch=%#x sscratch=%#x", cpu.csr[VSSCRATCH], cpu.csr[SSCRATCH])
+	}
+	cpu.csr[VSSTATUS] = MSTATUS_SPP
+	if got, ok := csrRead(cpu, SSTATUS); !ok || got != MSTATUS_SPP {
+		t.Fatalf("sstatus in VS-mode=%#x, want vsstatus", got)
+	}
+
+	tests := []struct {
+		name string
+		priv PrivMode
+		virt bool
+		csr  uint64
+		want ExceptionCause
+	}{
+		{"hstatus from VS", SUPERVISOR_MODE, true, HSTATUS, VIRTUAL_INSTRUCTION},
+		{"vsatp from VS", SUPERVISOR_MODE, true, VSATP, VIRTUAL_INSTRUCTION},
+		{"sstatus from VU", USER_MODE, true, SSTATUS, VIRTUAL_INSTRUCTION},
+		{"hstatus from VU", USER_MODE, true, HSTATUS, VIRTUAL_INSTRUCTION},
+		{"hstatus from U", USER_MODE, false, HSTATUS, ILLEGAL_INSTRUCTION},
+		{"cycle from VS", SUPERVISOR_MODE, true, CYCLE, VIRTUAL_INSTRUCTION},
+		{"instret from VS", SUPERVISOR_MODE, true, INSTRET, ILLEGAL_INSTRUCTION},
+	}
+	cpu.csr[MCOUNTEREN] = COUNTER_CY
+	for _, tt := range tests {
+		cpu.privilege, cpu.virt = tt.priv, tt.virt
+		if _, ok := csrRead(cpu, tt.csr); ok || cpu.exception.cause != tt.want {
+			t.Errorf("%s: exception=%v, want cause %d", tt.name, cpu.exception, tt.want)
+		}
+	}
+	cpu.csr[HCOUNTEREN] = COUNTER_CY
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, true
+	if _, ok := csrRead(cpu, CYCLE); !ok {
+		t.Fatalf("cycle must be accessible in VS-mode with hcounteren.CY")
+	}
+}
+
+func TestHypervisorLoadStore(t *testing.T) {
+	cpu, vs, g := newGuest()
+	vs.mapPage(0x1000, 0x40000000, PTE_R|PTE_W, 0)
+	vs.mapPage(0x2000, 0x40000000, PTE_X, 0)
+	g.mapPage(0x40000000, 0x80300000, PTE_R|PTE_W|PTE_X|PTE_U, 0)
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, false
+	cpu.csr[HSTATUS] |= HSTATUS_SPVP
+
+	cpu.writeReg(1, 0x1000)
+	cpu.writeReg(2, 0xcafef00d)
+	cpu.ExecuteInst(hsvW)
+	if got := memRead(cpu, 0x80300000, WORD); cpu.exception != nil || got != 0xcafef00d {
+		t.Fatalf("hsv.w stored %#x, exception=%v", got, cpu.exception)
+	}
+	cpu.ExecuteInst(hlvD)
+	if cpu.exception != nil || cpu.readReg(3) != 0xcafef00d {
+		t.Fatalf("hlv.d loaded %#x, exception=%v", cpu.readReg(3), cpu.exception)
+	}
+
+	// hlvx требует права исполнения вместо чтения
+	cpu.ExecuteInst(hlvxWu)
+	if cpu.csr[MCAUSE] != uint64(LOAD_PAGE_FAULT) || cpu.csr[MSTATUS]&MSTATUS_GVA == 0 ||
+		cpu.csr[MSTATUS]&MSTATUS_MPV != 0 {
+		t.Fatalf("hlvx.wu of a non-executable page: mcause=%d mstatus=%#x", cpu.csr[MCAUSE], cpu.csr[MSTATUS])
+	}
+	cpu.privilege = SUPERVISOR_MODE
+	cpu.writeReg(1, 0x2000)
+	cpu.ExecuteInst(hlvxWu)
+	if cpu.exception != nil || cpu.readReg(3) != 0xcafef00d {
+		t.Fatalf("hlvx.wu of an execute-only page: x3=%#x, exception=%v", cpu.readReg(3), cpu.exception)
+	}
+
+	cpu.privilege = USER_MODE
+	cpu.writeReg(1, 0x1000)
+	cpu.ExecuteInst(hlvD)
+	if cpu.exception == nil || cpu.exception.cause != ILLEGAL_INSTRUCTION {
+		t.Fatalf("hlv.d in U-mode without hstatus.HU must be illegal")
+	}
+	cpu.privilege = USER_MODE
+	cpu.csr[HSTATUS] |= HSTATUS_HU
+	cpu.ExecuteInst(hlvD)
+	if cpu.exception != nil {
+		t.Fatalf("hlv.d in U-mode with hstatus.HU: %v", cpu.exception)
+	}
+
+	cpu.privilege, cpu.virt = SUPERVISOR_MODE, true
+	cpu.ExecuteInst(hlvD)
+	if cpu.exception == nil || cpu.exception.cause != VIRTUAL_INSTRUCTION || cpu.csr[MTVAL] != hlvD {
+		t.Fatalf("hlv.d in VS-mode must raise virtual instruction, mtval=%#x", cpu.csr[MTVAL])
+	}
+}
+
+func TestVirtualInstruction(t *testing.T) {
+	const (
+		wfi        = 0x10500073
+		sret       = 0x10200073
+		sfenceVma  = 0x12000073
+		hfenceGvma = 0x62000073
+		hfenceVvma = 0x22000073
+	)
+	tests := []struct {
+		name    string
+		priv    PrivMode
+		virt    bool
+		hstatus uint64
+		mstatus uint64
+		inst    uint32
+		want    ExceptionCause // 0 - без исключения
+	}{
+		{"wfi VS", SUPERVISOR_MODE, true, 0, 0, wfi, 0},
+		{"wfi VS VTW", SUPERVISOR_MODE, true, HSTATUS_VTW, 0, wfi, VIRTUAL_INSTRUCTION},
+		{"wfi VS TW", SUPERVISOR_MODE, true, HSTATUS_VTW, MSTATUS_TW, wfi, ILLEGAL_INSTRUCTION},
+		{"wfi VU", USER_MODE, true, 0, 0, wfi, VIRTUAL_INSTRUCTION},
+		{"sret VU", USER_MODE, true, 0, 0, sret, VIRTUAL_INSTRUCTION},
+		{"sret VS VTSR", SUPERVISOR_MODE, true, HSTATUS_VTSR, 0, sret, VIRTUAL_INSTRUCTION},
+		{"sret VS TSR", SUPERVISOR_MODE, true, 0, MSTATUS_TSR, sret, 0},
+		{"sfence.vma VS VTVM", SUPERVISOR_MODE, true, HSTATUS_VTVM, 0, sfenceVma, VIRTUAL_INSTRUCTION},
+		{"sfence.vma VS TVM", SUPERVISOR_MODE, true, 0, MSTATUS_TVM, sfenceVma, 0},
+		{"hfence.gvma VS", SUPERVISOR_MODE, true, 0, 0, hfenceGvma, VIRTUAL_INSTRUCTION},
+		{"hfence.gvma HS TVM", SUPERVISOR_MODE, false, 0, MSTATUS_TVM, hfenceGvma, ILLEGAL_INSTRUCTION},
+		{"hfence.vvma HS TVM", SUPERVISOR_MODE, false, 0, MSTATUS_TVM, hfenceVvma, 0},
+		{"hfence.vvma U", USER_MODE, false, HSTATUS_HU, 0, hfenceVvma, ILLEGAL_INSTRUCTION},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		cpu.privilege, cpu.virt = tt.priv, tt.virt
+		cpu.csr[HSTATUS] |= tt.hstatus
+		cpu.csr[MSTATUS] |= tt.mstatus
+		cpu.ExecuteInst(tt.inst)
+		switch {
+		case tt.want == 0 && cpu.exception != nil:
+			t.Errorf("%s: unexpected %v", tt.name, cpu.exception)
+		case tt.want != 0 && (cpu.exception == nil || cpu.exception.cause != tt.want):
+			t.Errorf("%s: exception=%v, want cause %d", tt.name, cpu.exception, tt.want)
+		}
+	}
+}
+
+func TestVirtualInterrupt(t *testing.T) {
+	cpu := NewCPU()
+	cpu.csr[STVEC] = 0x80001000
+	cpu.csr[VSTVEC] = 0x80002000
+	cpu.writeCSR(HIDELEG, MIP_VSSIP)
+	cpu.writeCSR(HIE, MIP_VSSIP)
+	cpu.writeCSR(HVIP, MIP_VSSIP)
+
+	// VS-прерывания не принимаются при V=0
+	cpu.privilege = SUPERVISOR_MODE
+	if cause, ok := cpu.pendingInterrupt(); ok {
+		t.Fatalf("VS-level interrupt %d taken with V=0", cause)
+	}
+	cpu.virt = true
+	if _, ok := cpu.pendingInterrupt(); ok {
+		t.Fatalf("VS-level interrupt taken with vsstatus.SIE=0")
+	}
+
+	cpu.csr[VSSTATUS] = MSTATUS_SIE
+	cpu.Step()
+	if !cpu.virt || cpu.pc != 0x80002000 ||
+		cpu.csr[VSCAUSE] != CAUSE_INTERRUPT|SUPERVISOR_SOFTWARE_INTERRUPT ||
+		cpu.csr[VSSTATUS]&(MSTATUS_SIE|MSTATUS_SPIE) != MSTATUS_SPIE {
+		t.Fatalf("vscause=%#x vsstatus=%#x pc=%#x", cpu.csr[VSCAUSE], cpu.csr[VSSTATUS], cpu.pc)
+	}
+
+	// без делегирования в hideleg прерывание принимает HS-режим
+	cpu.csr[HIDELEG] = 0
+	cpu.Step()
+	if cpu.virt || cpu.pc != 0x80001000 ||
+		cpu.csr[SCAUSE] != CAUSE_INTERRUPT|VIRTUAL_SUPERVISOR_SOFTWARE_INTERRUPT {
+		t.Fatalf("scause=%#x pc=%#x", cpu.csr[SCAUSE], cpu.pc)
+	}
+}
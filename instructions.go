@@ -50,6 +50,43 @@ func mulhsu(s int64, u uint64) uint64 {
 	return uint64(int64(p) - t1)
 }
 
+// clmul returns the low and high halves of the carry-less product
+func clmul(a, b uint64) (lo, hi uint64) {
+	for i := uint(0); i < 64; i++ {
+		if b>>i&1 != 0 {
+			lo ^= a << i
+			if i != 0 {
+				hi ^= a >> (64 - i)
+			}
+		}
+	}
+	return lo, hi
+}
+
+// orcb sets every non-zero byte to 0xff
+func orcb(val uint64) uint64 {
+	var res uint64
+	for i := uint(0); i < 64; i += 8 {
+		if val>>i&0xff != 0 {
+			res |= 0xff << i
+		}
+	}
+	return res
+}
+
+// xperm looks up each width-bit element of idx in the table of
+// width-bit elements packed into lut, out of range indices give 0
+func xperm(lut, idx uint64, width uint) uint64 {
+	var res uint64
+	mask := uint64(1)<<width - 1
+	for i := uint(0); i < 64; i += width {
+		if pos := (idx >> i & mask) * uint64(width); pos < 64 {
+			res |= (lut >> pos & mask) << i
+		}
+	}
+	return res
+}
+
 func signExtend(val int64, bit uint) int64 {
 	return val << (64 - bit) >> (64 - bit)
 }
@@ -89,185 +126,227 @@ func (cpu *Cpu) auipc(inst InstWord) {
 	cpu.writeReg(inst.rd(), cpu.pc+inst.uImm())
 }
 
-func (cpu *Cpu) beq(inst InstWord) {
-	if cpu.readReg(inst.rs1()) == cpu.readReg(inst.rs2()) {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
+// branch jumps to the B-type target if the condition holds
+func (cpu *Cpu) branch(inst InstWord, taken bool) {
+	cpu.countEvent(HPM_EVENT_BRANCH)
+	if taken {
+		cpu.countEvent(HPM_EVENT_BRANCH_TAKEN)
+		cpu.jump(cpu.pc + inst.sbImm())
 	}
 }
 
+func (cpu *Cpu) beq(inst InstWord) {
+	cpu.branch(inst, cpu.readReg(inst.rs1()) == cpu.readReg(inst.rs2()))
+}
+
 func (cpu *Cpu) bge(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	if int64(rs1) >= int64(rs2) {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
-	}
+	cpu.branch(inst, int64(rs1) >= int64(rs2))
 }
 
 func (cpu *Cpu) bgeu(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	if rs1 >= rs2 {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
-	}
+	cpu.branch(inst, rs1 >= rs2)
 }
 
 func (cpu *Cpu) blt(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	if int64(rs1) < int64(rs2) {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
-	}
+	cpu.branch(inst, int64(rs1) < int64(rs2))
 }
 
 func (cpu *Cpu) bltu(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	if rs1 < rs2 {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
-	}
+	cpu.branch(inst, rs1 < rs2)
 }
 
 func (cpu *Cpu) bne(inst InstWord) {
-	if cpu.readReg(inst.rs1()) != cpu.readReg(inst.rs2()) {
-		cpu.pc += inst.sbImm()
-		cpu.pc -= 4
-	}
+	cpu.branch(inst, cpu.readReg(inst.rs1()) != cpu.readReg(inst.rs2()))
 }
 
 func (cpu *Cpu) csrrc(inst InstWord) {
-	csr_data := cpu.readCSR(inst.csr())
+	if !cpu.csrCheck(inst, inst.rs1() != 0) {
+		return
+	}
+	csr_data := cpu.readCSRView(inst.csr())
 	rs_data := cpu.readReg(inst.rs1())
 	cpu.writeReg(inst.rd(), csr_data)
 	if inst.rs1() != 0 {
-		cpu.writeCSR(inst.csr(), csr_data&(^rs_data))
+		cpu.writeCSRView(inst.csr(), cpu.csrModifyBase(inst.csr(), csr_data)&(^rs_data))
 	}
 }
 
 func (cpu *Cpu) csrrci(inst InstWord) {
-	csr_data := cpu.readCSR(inst.csr())
+	if !cpu.csrCheck(inst, inst.rs1() != 0) {
+		return
+	}
+	csr_data := cpu.readCSRView(inst.csr())
 	cpu.writeReg(inst.rd(), csr_data)
 	if rs := inst.rs1(); rs != 0 {
-		cpu.writeCSR(inst.csr(), csr_data&(^rs))
+		cpu.writeCSRView(inst.csr(), cpu.csrModifyBase(inst.csr(), csr_data)&(^rs))
 	}
 }
 
 func (cpu *Cpu) csrrs(inst InstWord) {
-	csr_data := cpu.readCSR(inst.csr())
+	if !cpu.csrCheck(inst, inst.rs1() != 0) {
+		return
+	}
+	csr_data := cpu.readCSRView(inst.csr())
 	rs_data := cpu.readReg(inst.rs1())
 	cpu.writeReg(inst.rd(), csr_data)
 	if inst.rs1() != 0 {
-		cpu.writeCSR(inst.csr(), csr_data|rs_data)
+		cpu.writeCSRView(inst.csr(), cpu.csrModifyBase(inst.csr(), csr_data)|rs_data)
 	}
 }
 
 func (cpu *Cpu) csrrsi(inst InstWord) {
-	csr_data := cpu.readCSR(inst.csr())
+	if !cpu.csrCheck(inst, inst.rs1() != 0) {
+		return
+	}
+	csr_data := cpu.readCSRView(inst.csr())
 	cpu.writeReg(inst.rd(), csr_data)
 	if rs := inst.rs1(); rs != 0 {
-		cpu.writeCSR(inst.csr(), csr_data|rs)
+		cpu.writeCSRView(inst.csr(), cpu.csrModifyBase(inst.csr(), csr_data)|rs)
 	}
 }
 
 func (cpu *Cpu) csrrw(inst InstWord) {
-	// if inst.rd() == 0 {
-	// 	return
-	// } ???
-	csr_data := cpu.readCSR(inst.csr())
+	if !cpu.csrCheck(inst, true) {
+		return
+	}
 	rs_data := cpu.readReg(inst.rs1())
-	cpu.writeReg(inst.rd(), csr_data)
-	cpu.writeCSR(inst.csr(), rs_data)
+	// при rd = x0 CSR не читается, побочных эффектов чтения нет
+	if inst.rd() != 0 {
+		cpu.writeReg(inst.rd(), cpu.readCSRView(inst.csr()))
+	}
+	cpu.writeCSRView(inst.csr(), rs_data)
 }
 
 func (cpu *Cpu) csrrwi(inst InstWord) {
-	// if inst.rd() == 0 {
-	// 	return
-	// } ???
-	csr_data := cpu.readCSR(inst.csr())
-	cpu.writeReg(inst.rd(), csr_data)
-	cpu.writeCSR(inst.csr(), inst.rs1())
+	if !cpu.csrCheck(inst, true) {
+		return
+	}
+	// при rd = x0 CSR не читается, побочных эффектов чтения нет
+	if inst.rd() != 0 {
+		cpu.writeReg(inst.rd(), cpu.readCSRView(inst.csr()))
+	}
+	cpu.writeCSRView(inst.csr(), inst.rs1())
 }
 
 func (cpu *Cpu) div(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	// деление на ноль даёт все единицы, переполнение -2^63 / -1 в Go
+	// даёт тот же результат, что требует ISA
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), ^uint64(0))
+		return
+	}
 	cpu.writeReg(inst.rd(), uint64(int64(rs1)/int64(rs2)))
 }
 
 func (cpu *Cpu) divu(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.zextXLEN(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), ^uint64(0))
+		return
+	}
 	cpu.writeReg(inst.rd(), rs1/rs2)
 }
 
 func (cpu *Cpu) divuw(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), uint64(int32(rs1)/int32(rs2)))
+	rs1, rs2 := uint32(cpu.readReg(inst.rs1())), uint32(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), ^uint64(0))
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(int32(rs1/rs2)))
 }
 
 func (cpu *Cpu) divw(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), uint64(uint32(rs1)/uint32(rs2)))
+	rs1, rs2 := int32(cpu.readReg(inst.rs1())), int32(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), ^uint64(0))
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(rs1/rs2))
 }
 
 func (cpu *Cpu) ebreak(inst InstWord) {
-
+	cpu.raise(BREAKPOINT, cpu.pc)
 }
 
 func (cpu *Cpu) ecall(inst InstWord) {
-	cpu.dumpRegN(10, 11, 12, 13, 14, 15, 16, 17)
 	switch cpu.privilege {
 	case USER_MODE:
-		panic(ECallFromUser)
+		cpu.raise(ECALL_FROM_UMODE, 0)
 	case SUPERVISOR_MODE:
-		panic(ECallFromSupervisor)
-	case RESERVED_MODE:
-		panic(ECallFromReserved)
+		if cpu.virt {
+			cpu.raise(ECALL_FROM_VSMODE, 0)
+			return
+		}
+		cpu.raise(ECALL_FROM_SMODE, 0)
 	case MACHINE_MODE:
-		panic(ECallFromMachine)
+		cpu.raise(ECALL_FROM_MMODE, 0)
 	}
 }
 
+// fence has nothing to order: harts access memory one instruction at
+// a time and devices complete accesses before the instruction retires
 func (cpu *Cpu) fence(inst InstWord) {
+}
 
+// fenceI synchronizes instruction fetch with stores: instructions are
+// fetched from memory each time and there is nothing to invalidate
+func (cpu *Cpu) fenceI(inst InstWord) {
 }
 
 func (cpu *Cpu) jal(inst InstWord) {
-	cpu.writeReg(inst.rd(), cpu.pc+4)
-	// cpu.pc = uint64(int64(cpu.pc) + int64(inst.uImm())) //???
-	cpu.pc = uint64(cpu.pc+inst.ujImm()) - 4
+	link := cpu.pc + cpu.ilen
+	if cpu.jump(cpu.pc + inst.ujImm()) {
+		cpu.writeReg(inst.rd(), link)
+	}
 }
 
 func (cpu *Cpu) jalr(inst InstWord) {
-	cpu.writeReg(inst.rd(), cpu.pc+4)
-	imm := int64(inst) >> 20
-	x := int64(^1)
-	reg := int64(cpu.readReg(inst.rs1()))
-	cpu.pc = uint64((reg + imm) & x)
+	link := cpu.pc + cpu.ilen
+	target := (cpu.readReg(inst.rs1()) + inst.iImm()) &^ 1
+	if cpu.jump(target) {
+		cpu.writeReg(inst.rd(), link)
+	}
 }
 
 func (cpu *Cpu) lb(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.writeReg(inst.rd(), cpu.memory.Read8(addr))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, BYTE); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int8(data))))
+	}
 }
 
 func (cpu *Cpu) lbu(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.writeReg(inst.rd(), cpu.memory.Read8(addr))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, BYTE); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
 }
 
-func (cpu *Cpu) ld(inst InstWord) { // ???
-	// addr := inst.iImm() + inst.rs1()
-	// cpu.writeReg(inst.rd(), cpu.memory.Read8(addr))
+func (cpu *Cpu) ld(inst InstWord) {
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, DOUBLEWORD); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
 }
 
 func (cpu *Cpu) lh(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	data := cpu.memory.Read16(addr)
-	cpu.writeReg(inst.rd(), uint64(int64(int16(data))))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, HALFWORD); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int16(data))))
+	}
 }
 
 func (cpu *Cpu) lhu(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.writeReg(inst.rd(), cpu.memory.Read16(addr))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, HALFWORD); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
 }
 
 func (cpu *Cpu) lui(inst InstWord) {
@@ -275,14 +354,41 @@ func (cpu *Cpu) lui(inst InstWord) {
 }
 
 func (cpu *Cpu) lw(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	data := cpu.memory.Read32(addr)
-	cpu.writeReg(inst.rd(), uint64(int64(int32(data))))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, WORD); ok {
+		cpu.writeReg(inst.rd(), uint64(int64(int32(data))))
+	}
 }
 
 func (cpu *Cpu) lwu(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.writeReg(inst.rd(), cpu.memory.Read32(addr))
+	addr := cpu.readReg(inst.rs1()) + inst.iImm()
+	if data, ok := cpu.load(addr, WORD); ok {
+		cpu.writeReg(inst.rd(), data)
+	}
+}
+
+// mret pops the mstatus privilege stack and returns to mepc
+func (cpu *Cpu) mret(inst InstWord) {
+	if cpu.privilege != MACHINE_MODE {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	mstatus := cpu.csr[MSTATUS]
+	mpp := PrivMode((mstatus & MSTATUS_MPP) >> MSTATUS_MPP_SHIFT)
+	mstatus &^= MSTATUS_MIE | MSTATUS_MPP
+	if mstatus&MSTATUS_MPIE != 0 {
+		mstatus |= MSTATUS_MIE
+	}
+	mstatus |= MSTATUS_MPIE
+	if mpp != MACHINE_MODE {
+		mstatus &^= MSTATUS_MPRV
+	}
+	// V <- MPV при возврате ниже M-режима
+	cpu.virt = mpp != MACHINE_MODE && mstatus&MSTATUS_MPV != 0
+	mstatus &^= MSTATUS_MPV
+	cpu.csr[MSTATUS] = mstatus
+	cpu.privilege = mpp
+	cpu.setPC(cpu.readCSR(MEPC))
 }
 
 func (cpu *Cpu) mul(inst InstWord) {
@@ -293,16 +399,29 @@ func (cpu *Cpu) mul(inst InstWord) {
 
 func (cpu *Cpu) mulh(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if cpu.xlen == 32 {
+		// полное произведение 32-битных значений помещается в 64 бита
+		cpu.writeReg(inst.rd(), uint64(int64(rs1)*int64(rs2)>>32))
+		return
+	}
 	cpu.writeReg(inst.rd(), mulh(int64(rs1), int64(rs2)))
 }
 
 func (cpu *Cpu) mulhsu(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if cpu.xlen == 32 {
+		cpu.writeReg(inst.rd(), uint64(int64(rs1)*int64(uint32(rs2))>>32))
+		return
+	}
 	cpu.writeReg(inst.rd(), mulhsu(int64(rs1), rs2))
 }
 
 func (cpu *Cpu) mulhu(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.zextXLEN(cpu.readReg(inst.rs2()))
+	if cpu.xlen == 32 {
+		cpu.writeReg(inst.rd(), rs1*rs2>>32)
+		return
+	}
 	high_bits, _ := bits.Mul64(rs1, rs2)
 	cpu.writeReg(inst.rd(), high_bits)
 }
@@ -322,58 +441,96 @@ func (cpu *Cpu) ori(inst InstWord) {
 	cpu.writeReg(inst.rd(), rs1|imm)
 }
 
+// pause only hints a spin-wait loop: harts are stepped one instruction
+// at a time, so there is nobody to give the processor to
 func (cpu *Cpu) pause(inst InstWord) {
-
 }
 
 func (cpu *Cpu) rem(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), rs1)
+		return
+	}
 	cpu.writeReg(inst.rd(), uint64(int64(rs1)%int64(rs2)))
 }
 
 func (cpu *Cpu) remu(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.zextXLEN(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), rs1)
+		return
+	}
 	cpu.writeReg(inst.rd(), rs1%rs2)
 }
 
 func (cpu *Cpu) remuw(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), uint64(int32(uint32(rs1)%uint32(rs2))))
+	rs1, rs2 := uint32(cpu.readReg(inst.rs1())), uint32(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), uint64(int32(rs1)))
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(int32(rs1%rs2)))
 }
 
 func (cpu *Cpu) remw(inst InstWord) {
-	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), uint64(int32(rs1)%int32(rs2)))
+	rs1, rs2 := int32(cpu.readReg(inst.rs1())), int32(cpu.readReg(inst.rs2()))
+	if rs2 == 0 {
+		cpu.writeReg(inst.rd(), uint64(rs1))
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(rs1%rs2))
 }
 
 func (cpu *Cpu) sb(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.memory.Write8(addr, cpu.readReg(inst.rs2()))
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	cpu.store(addr, cpu.readReg(inst.rs2()), BYTE)
+}
+
+// sfence.vma flushes cached translations: rs1 selects a page,
+// rs2 selects an address space, x0 means all of them
+func (cpu *Cpu) sfenceVma(inst InstWord) {
+	if cpu.virt {
+		// трансляции гостя не кэшируются, остаётся проверка прав
+		if cpu.privilege == USER_MODE || cpu.csr[HSTATUS]&HSTATUS_VTVM != 0 {
+			cpu.VirtualInst(uint32(inst))
+		}
+		return
+	}
+	if cpu.privilege == USER_MODE ||
+		(cpu.privilege == SUPERVISOR_MODE && cpu.csr[MSTATUS]&MSTATUS_TVM != 0) {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	vaddr, asid := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())&0xffff
+	byAddr, byAsid := inst.rs1() != 0, inst.rs2() != 0
+	cpu.itlb.flush(vaddr, byAddr, asid, byAsid)
+	cpu.dtlb.flush(vaddr, byAddr, asid, byAsid)
 }
 
 func (cpu *Cpu) sh(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.memory.Write16(addr, cpu.readReg(inst.rs2()))
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	cpu.store(addr, cpu.readReg(inst.rs2()), HALFWORD)
 }
 
 func (cpu *Cpu) sw(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.memory.Write32(addr, cpu.readReg(inst.rs2()))
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	cpu.store(addr, cpu.readReg(inst.rs2()), WORD)
 }
 
 func (cpu *Cpu) sd(inst InstWord) {
-	addr := inst.iImm() + inst.rs1()
-	cpu.memory.Write64(addr, cpu.readReg(inst.rs2()))
+	addr := cpu.readReg(inst.rs1()) + inst.sImm()
+	cpu.store(addr, cpu.readReg(inst.rs2()), DOUBLEWORD)
 }
 
 func (cpu *Cpu) sll(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), rs1<<(rs2&0x1f))
+	cpu.writeReg(inst.rd(), rs1<<(rs2&(cpu.xlen-1)))
 }
 
 func (cpu *Cpu) srl(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	cpu.writeReg(inst.rd(), rs1>>(rs2&0x1f))
+	cpu.writeReg(inst.rd(), cpu.zextXLEN(rs1)>>(rs2&(cpu.xlen-1)))
 }
 
 func (cpu *Cpu) slliw(inst InstWord) {
@@ -390,7 +547,7 @@ func (cpu *Cpu) sllw(inst InstWord) {
 
 func (cpu *Cpu) srlw(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	shift := int32(uint32(rs1) << (rs2 & 0x1f))
+	shift := int32(uint32(rs1) >> (rs2 & 0x1f))
 	cpu.writeReg(inst.rd(), uint64(shift))
 }
 
@@ -437,16 +594,13 @@ func (cpu *Cpu) sltiu(inst InstWord) {
 
 func (cpu *Cpu) sra(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
-	if cpu.xlen == 32 {
-		cpu.writeReg(inst.rd(), uint64(int64(rs1)>>rs2))
-	} else {
-		cpu.writeReg(inst.rd(), uint64(int64(rs1)>>(rs2&0x1f)))
-	}
+	// сдвиг берёт log2(XLEN) младших бит rs2: 5 в RV32 и 6 в RV64
+	cpu.writeReg(inst.rd(), uint64(int64(rs1)>>(rs2&(cpu.xlen-1))))
 }
 
 func (cpu *Cpu) srai(inst InstWord) {
 	rs1 := cpu.readReg(inst.rs1())
-	cpu.writeReg(inst.rd(), uint64(int64(rs1)>>(inst.iImm()&0x1f)))
+	cpu.writeReg(inst.rd(), uint64(int64(rs1)>>inst.shamt()))
 }
 
 func (cpu *Cpu) sraiw(inst InstWord) {
@@ -456,17 +610,45 @@ func (cpu *Cpu) sraiw(inst InstWord) {
 
 func (cpu *Cpu) srliw(inst InstWord) {
 	rs1 := cpu.readReg(inst.rs1())
-	cpu.writeReg(inst.rd(), uint64(uint32(rs1)>>(inst.iImm()&0x1f)))
+	cpu.writeReg(inst.rd(), uint64(int32(uint32(rs1)>>(inst.iImm()&0x1f))))
 }
 
 func (cpu *Cpu) srli(inst InstWord) {
 	rs1 := cpu.readReg(inst.rs1())
-	cpu.writeReg(inst.rd(), uint64(rs1>>(inst.iImm()&0x1f)))
+	cpu.writeReg(inst.rd(), cpu.zextXLEN(rs1)>>inst.shamt())
 }
 
 func (cpu *Cpu) slli(inst InstWord) {
 	rs1 := cpu.readReg(inst.rs1())
-	cpu.writeReg(inst.rd(), uint64(rs1<<(inst.iImm()&0x1f)))
+	cpu.writeReg(inst.rd(), rs1<<inst.shamt())
+}
+
+// sret pops the sstatus privilege stack and returns to sepc. In VS-mode
+// vsstatus and vsepc are used, in HS-mode hstatus.SPV restores V.
+func (cpu *Cpu) sret(inst InstWord) {
+	if cpu.virt {
+		if cpu.privilege == USER_MODE || cpu.csr[HSTATUS]&HSTATUS_VTSR != 0 {
+			cpu.VirtualInst(uint32(inst))
+			return
+		}
+		vsstatus, spp := supervisorReturnStatus(cpu.csr[VSSTATUS])
+		cpu.csr[VSSTATUS] = vsstatus
+		cpu.privilege = spp
+		cpu.setPC(cpu.readCSR(SEPC))
+		return
+	}
+	mstatus := cpu.csr[MSTATUS]
+	if cpu.privilege < SUPERVISOR_MODE ||
+		(cpu.privilege == SUPERVISOR_MODE && mstatus&MSTATUS_TSR != 0) {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	mstatus, spp := supervisorReturnStatus(mstatus)
+	cpu.csr[MSTATUS] = mstatus &^ MSTATUS_MPRV
+	cpu.privilege = spp
+	cpu.setPC(cpu.readCSR(SEPC))
+	cpu.virt = cpu.csr[HSTATUS]&HSTATUS_SPV != 0
+	cpu.csr[HSTATUS] &^= HSTATUS_SPV
 }
 
 func (cpu *Cpu) sub(inst InstWord) {
@@ -479,6 +661,30 @@ func (cpu *Cpu) subw(inst InstWord) {
 	cpu.writeReg(inst.rd(), uint64(int32(rs1-rs2)))
 }
 
+// wfi stalls the hart, ExecuteInst resumes it once an enabled interrupt is pending
+func (cpu *Cpu) wfi(inst InstWord) {
+	if !cpu.waitAllowed(inst, cpu.privilege == USER_MODE) {
+		return
+	}
+	cpu.waiting = true
+}
+
+// waitAllowed applies mstatus.TW and hstatus.VTW to wfi and wrs.nto,
+// user is set when the instruction is not permitted in U-mode at all
+func (cpu *Cpu) waitAllowed(inst InstWord, user bool) bool {
+	switch {
+	case cpu.privilege == MACHINE_MODE:
+		return true
+	case cpu.csr[MSTATUS]&MSTATUS_TW != 0 || (user && !cpu.virt):
+		cpu.IllegalInst(uint32(inst))
+		return false
+	case cpu.virt && (user || cpu.csr[HSTATUS]&HSTATUS_VTW != 0):
+		cpu.VirtualInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
 func (cpu *Cpu) xor(inst InstWord) {
 	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
 	cpu.writeReg(inst.rd(), rs1^rs2)
@@ -488,3 +694,317 @@ func (cpu *Cpu) xori(inst InstWord) {
 	rs1, imm := cpu.readReg(inst.rs1()), inst.iImm()
 	cpu.writeReg(inst.rd(), rs1^imm)
 }
+
+func (cpu *Cpu) addUw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))+rs2)
+}
+
+func (cpu *Cpu) sh1add(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1<<1+rs2)
+}
+
+func (cpu *Cpu) sh1addUw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))<<1+rs2)
+}
+
+func (cpu *Cpu) sh2add(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1<<2+rs2)
+}
+
+func (cpu *Cpu) sh2addUw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))<<2+rs2)
+}
+
+func (cpu *Cpu) sh3add(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1<<3+rs2)
+}
+
+func (cpu *Cpu) sh3addUw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))<<3+rs2)
+}
+
+func (cpu *Cpu) slliUw(inst InstWord) {
+	rs1 := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))<<inst.shamt())
+}
+
+func (cpu *Cpu) andn(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1&^rs2)
+}
+
+func (cpu *Cpu) orn(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1|^rs2)
+}
+
+func (cpu *Cpu) xnor(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), ^(rs1 ^ rs2))
+}
+
+func (cpu *Cpu) clz(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.clzw(inst)
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(bits.LeadingZeros64(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) clzw(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(bits.LeadingZeros32(uint32(cpu.readReg(inst.rs1())))))
+}
+
+func (cpu *Cpu) ctz(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.ctzw(inst)
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(bits.TrailingZeros64(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) ctzw(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(bits.TrailingZeros32(uint32(cpu.readReg(inst.rs1())))))
+}
+
+func (cpu *Cpu) cpop(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.cpopw(inst)
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(bits.OnesCount64(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) cpopw(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(bits.OnesCount32(uint32(cpu.readReg(inst.rs1())))))
+}
+
+func (cpu *Cpu) max(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(max(int64(rs1), int64(rs2))))
+}
+
+func (cpu *Cpu) maxu(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), max(rs1, rs2))
+}
+
+func (cpu *Cpu) min(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(min(int64(rs1), int64(rs2))))
+}
+
+func (cpu *Cpu) minu(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), min(rs1, rs2))
+}
+
+func (cpu *Cpu) sextB(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(int8(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) sextH(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(int16(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) zextH(inst InstWord) {
+	cpu.writeReg(inst.rd(), uint64(uint16(cpu.readReg(inst.rs1()))))
+}
+
+func (cpu *Cpu) rol(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.rolw(inst)
+		return
+	}
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(rs1, int(rs2&(cpu.xlen-1))))
+}
+
+func (cpu *Cpu) rolw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	res := bits.RotateLeft32(uint32(rs1), int(rs2&0x1f))
+	cpu.writeReg(inst.rd(), uint64(int32(res)))
+}
+
+func (cpu *Cpu) ror(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.rorw(inst)
+		return
+	}
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(rs1, -int(rs2&(cpu.xlen-1))))
+}
+
+func (cpu *Cpu) rori(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.roriw(inst)
+		return
+	}
+	rs1 := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.RotateLeft64(rs1, -int(inst.shamt())))
+}
+
+func (cpu *Cpu) roriw(inst InstWord) {
+	rs1 := cpu.readReg(inst.rs1())
+	res := bits.RotateLeft32(uint32(rs1), -int(inst.x(20, 5)))
+	cpu.writeReg(inst.rd(), uint64(int32(res)))
+}
+
+func (cpu *Cpu) rorw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	res := bits.RotateLeft32(uint32(rs1), -int(rs2&0x1f))
+	cpu.writeReg(inst.rd(), uint64(int32(res)))
+}
+
+func (cpu *Cpu) orcB(inst InstWord) {
+	cpu.writeReg(inst.rd(), orcb(cpu.readReg(inst.rs1())))
+}
+
+func (cpu *Cpu) rev8(inst InstWord) {
+	if cpu.xlen == 32 {
+		cpu.writeReg(inst.rd(), uint64(bits.ReverseBytes32(uint32(cpu.readReg(inst.rs1())))))
+		return
+	}
+	cpu.writeReg(inst.rd(), bits.ReverseBytes64(cpu.readReg(inst.rs1())))
+}
+
+func (cpu *Cpu) clmul(inst InstWord) {
+	lo, _ := clmul(cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2()))
+	cpu.writeReg(inst.rd(), lo)
+}
+
+func (cpu *Cpu) clmulh(inst InstWord) {
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.zextXLEN(cpu.readReg(inst.rs2()))
+	lo, hi := clmul(rs1, rs2)
+	if cpu.xlen == 32 {
+		hi = lo >> 32
+	}
+	cpu.writeReg(inst.rd(), hi)
+}
+
+// clmulr returns bits 2*XLEN-2:XLEN-1 of the carry-less product
+func (cpu *Cpu) clmulr(inst InstWord) {
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.zextXLEN(cpu.readReg(inst.rs2()))
+	lo, hi := clmul(rs1, rs2)
+	if cpu.xlen == 32 {
+		cpu.writeReg(inst.rd(), lo>>31)
+		return
+	}
+	cpu.writeReg(inst.rd(), hi<<1|lo>>63)
+}
+
+func (cpu *Cpu) bclr(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1&^(1<<(rs2&(cpu.xlen-1))))
+}
+
+func (cpu *Cpu) bclri(inst InstWord) {
+	cpu.writeReg(inst.rd(), cpu.readReg(inst.rs1())&^(1<<inst.shamt()))
+}
+
+func (cpu *Cpu) bext(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1>>(rs2&(cpu.xlen-1))&1)
+}
+
+func (cpu *Cpu) bexti(inst InstWord) {
+	cpu.writeReg(inst.rd(), cpu.readReg(inst.rs1())>>inst.shamt()&1)
+}
+
+func (cpu *Cpu) binv(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1^(1<<(rs2&(cpu.xlen-1))))
+}
+
+func (cpu *Cpu) binvi(inst InstWord) {
+	cpu.writeReg(inst.rd(), cpu.readReg(inst.rs1())^(1<<inst.shamt()))
+}
+
+func (cpu *Cpu) bset(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), rs1|(1<<(rs2&(cpu.xlen-1))))
+}
+
+func (cpu *Cpu) bseti(inst InstWord) {
+	cpu.writeReg(inst.rd(), cpu.readReg(inst.rs1())|(1<<inst.shamt()))
+}
+
+func (cpu *Cpu) pack(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if cpu.xlen == 32 {
+		cpu.writeReg(inst.rd(), uint64(uint16(rs1))|uint64(uint16(rs2))<<16)
+		return
+	}
+	cpu.writeReg(inst.rd(), uint64(uint32(rs1))|rs2<<32)
+}
+
+func (cpu *Cpu) packh(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(uint8(rs1))|uint64(uint8(rs2))<<8)
+}
+
+func (cpu *Cpu) packw(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), uint64(int32(uint32(uint16(rs1))|uint32(rs2)<<16)))
+}
+
+func (cpu *Cpu) brev8(inst InstWord) {
+	// обратный порядок бит в каждом байте
+	rs1 := cpu.readReg(inst.rs1())
+	cpu.writeReg(inst.rd(), bits.ReverseBytes64(bits.Reverse64(rs1)))
+}
+
+// In RV32 indexes beyond 32 bits select zeros of the zero-extended rs1
+
+// zip interleaves the bits of the lower and upper halfwords in RV32
+func (cpu *Cpu) zip(inst InstWord) {
+	rs1 := uint32(cpu.readReg(inst.rs1()))
+	var res uint32
+	for i := 0; i < 16; i++ {
+		res |= (rs1>>i&1)<<(2*i) | (rs1>>(i+16)&1)<<(2*i+1)
+	}
+	cpu.writeReg(inst.rd(), uint64(res))
+}
+
+// unzip gathers even bits to the lower halfword and odd bits to the upper one
+func (cpu *Cpu) unzip(inst InstWord) {
+	rs1 := uint32(cpu.readReg(inst.rs1()))
+	var res uint32
+	for i := 0; i < 16; i++ {
+		res |= (rs1>>(2*i)&1)<<i | (rs1>>(2*i+1)&1)<<(i+16)
+	}
+	cpu.writeReg(inst.rd(), uint64(res))
+}
+
+func (cpu *Cpu) xperm4(inst InstWord) {
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), xperm(rs1, rs2, 4))
+}
+
+func (cpu *Cpu) xperm8(inst InstWord) {
+	rs1, rs2 := cpu.zextXLEN(cpu.readReg(inst.rs1())), cpu.readReg(inst.rs2())
+	cpu.writeReg(inst.rd(), xperm(rs1, rs2, 8))
+}
+
+func (cpu *Cpu) czeroEqz(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if rs2 == 0 {
+		rs1 = 0
+	}
+	cpu.writeReg(inst.rd(), rs1)
+}
+
+func (cpu *Cpu) czeroNez(inst InstWord) {
+	rs1, rs2 := cpu.readReg(inst.rs1()), cpu.readReg(inst.rs2())
+	if rs2 != 0 {
+		rs1 = 0
+	}
+	cpu.writeReg(inst.rd(), rs1)
+}
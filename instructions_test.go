@@ -372,6 +372,42 @@ func TestAllInsts(t *testing.T) {
 	}
 }
 
+func TestDivisionAndWordShifts(t *testing.T) {
+	tests := []struct {
+		name     string
+		inst     uint32
+		rs1, rs2 uint64
+		want     uint64
+	}{
+		{"div by zero", 0x0220c1b3, 7, 0, ^uint64(0)},
+		{"divu by zero", 0x0220d1b3, 7, 0, ^uint64(0)},
+		{"rem by zero", 0x0220e1b3, 7, 0, 7},
+		{"remu by zero", 0x0220f1b3, 7, 0, 7},
+		{"div overflow", 0x0220c1b3, 1 << 63, ^uint64(0), 1 << 63},
+		{"rem overflow", 0x0220e1b3, 1 << 63, ^uint64(0), 0},
+		{"divw", 0x0220c1bb, 0xfffffff9, 2, 0xfffffffffffffffd},
+		{"divuw", 0x0220d1bb, 0xfffffff9, 2, 0x7ffffffc},
+		{"divw by zero", 0x0220c1bb, 7, 0, ^uint64(0)},
+		{"remw by zero", 0x0220e1bb, 0x80000000, 0, 0xffffffff80000000},
+		{"remuw by zero", 0x0220f1bb, 0x80000000, 0, 0xffffffff80000000},
+		{"srlw", 0x0020d1bb, 0xf0000000, 4, 0x0f000000},
+		{"srliw", 0x0000d19b, 0x80000000, 0, 0xffffffff80000000},
+		{"sll uses 6 bits", 0x002091b3, 1, 33, 1 << 33},
+		{"srl uses 6 bits", 0x0020d1b3, 1 << 40, 40, 1},
+		{"srli 40", 0x0280d193, 1 << 40, 0, 1},
+		{"srai 63", 0x43f0d193, 1 << 63, 0, ^uint64(0)},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		cpu.writeReg(1, test.rs1)
+		cpu.writeReg(2, test.rs2)
+		cpu.ExecuteInst(test.inst)
+		if cpu.exception != nil || cpu.readReg(3) != test.want {
+			t.Fatalf("%s: x3=%#x, want %#x", test.name, cpu.readReg(3), test.want)
+		}
+	}
+}
+
 func BenchmarkInst(b *testing.B) {
 	bench_inst := []uint32{
 		0x01e00a13, 0xff1a2313, 0x00400237, 0x023181b3,
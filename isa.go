@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Extension is a set of ISA extensions, one bit per extension
+type Extension uint64
+
+const (
+	EXT_I Extension = 1 << iota
+	EXT_M
+	EXT_A
+	EXT_F
+	EXT_D
+	EXT_Q
+	EXT_C
+	EXT_V
+	EXT_H
+	EXT_ZICSR
+	EXT_ZIFENCEI
+	EXT_ZICNTR
+	EXT_ZIHPM
+	EXT_ZICOND
+	EXT_ZIHINTPAUSE
+	EXT_ZAWRS
+	EXT_ZFH
+	EXT_ZFHMIN
+	EXT_ZFA
+	EXT_ZBA
+	EXT_ZBB
+	EXT_ZBC
+	EXT_ZBS
+	EXT_ZBKB
+	EXT_ZBKC
+	EXT_ZBKX
+	EXT_ZKND
+	EXT_ZKNE
+	EXT_ZKNH
+	EXT_ZKSED
+	EXT_ZKSH
+	EXT_ZKR
+	EXT_ZKT
+)
+
+// DEFAULT_EXTENSIONS enables every implemented extension except Q,
+// which comes with FLEN=128
+const DEFAULT_EXTENSIONS Extension = (EXT_ZKT<<1 - 1) &^ EXT_Q
+
+// Extensions unavailable on RV32: the hypervisor extension and the
+// RV32 encodings of AES and SHA-512 are not implemented
+const RV32_UNSUPPORTED Extension = EXT_H | EXT_ZKND | EXT_ZKNE | EXT_ZKNH
+
+// ISA_EXTENSIONS maps extension names of an ISA string to extension sets,
+// G, B, Zk, Zkn and Zks stand for several extensions
+var ISA_EXTENSIONS = map[string]Extension{
+	"i": EXT_I,
+	"m": EXT_M,
+	"a": EXT_A,
+	"f": EXT_F,
+	"d": EXT_D,
+	"q": EXT_Q,
+	"c": EXT_C,
+	"v": EXT_V,
+	"h": EXT_H,
+	"g": EXT_I | EXT_M | EXT_A | EXT_F | EXT_D | EXT_ZICSR | EXT_ZIFENCEI,
+	"b": EXT_ZBA | EXT_ZBB | EXT_ZBS,
+
+	"zicsr":       EXT_ZICSR,
+	"zifencei":    EXT_ZIFENCEI,
+	"zicntr":      EXT_ZICNTR,
+	"zihpm":       EXT_ZIHPM,
+	"zicond":      EXT_ZICOND,
+	"zihintpause": EXT_ZIHINTPAUSE,
+	"zawrs":       EXT_ZAWRS,
+	"zfh":         EXT_ZFH,
+	"zfhmin":      EXT_ZFHMIN,
+	"zfa":         EXT_ZFA,
+	"zba":         EXT_ZBA,
+	"zbb":         EXT_ZBB,
+	"zbc":         EXT_ZBC,
+	"zbs":         EXT_ZBS,
+	"zbkb":        EXT_ZBKB,
+	"zbkc":        EXT_ZBKC,
+	"zbkx":        EXT_ZBKX,
+	"zknd":        EXT_ZKND,
+	"zkne":        EXT_ZKNE,
+	"zknh":        EXT_ZKNH,
+	"zksed":       EXT_ZKSED,
+	"zksh":        EXT_ZKSH,
+	"zkr":         EXT_ZKR,
+	"zkt":         EXT_ZKT,
+	"zkn":         EXT_ZBKB | EXT_ZBKC | EXT_ZBKX | EXT_ZKNE | EXT_ZKND | EXT_ZKNH,
+	"zks":         EXT_ZBKB | EXT_ZBKC | EXT_ZBKX | EXT_ZKSED | EXT_ZKSH,
+	"zk":          EXT_ZBKB | EXT_ZBKC | EXT_ZBKX | EXT_ZKNE | EXT_ZKND | EXT_ZKNH | EXT_ZKR | EXT_ZKT,
+}
+
+// ISA_DEPENDENCIES lists extensions implied by an enabled extension
+var ISA_DEPENDENCIES = map[Extension]Extension{
+	EXT_Q:      EXT_D,
+	EXT_D:      EXT_F,
+	EXT_V:      EXT_D,
+	EXT_F:      EXT_ZICSR,
+	EXT_ZFH:    EXT_ZFHMIN,
+	EXT_ZFHMIN: EXT_F,
+	EXT_ZFA:    EXT_F,
+	EXT_ZICNTR: EXT_ZICSR,
+	EXT_ZIHPM:  EXT_ZICSR,
+	EXT_ZKR:    EXT_ZICSR,
+}
+
+// ISA_PROPERTIES are extensions that only constrain behaviour the
+// emulator already has, they add no instructions or CSRs of their own
+var ISA_PROPERTIES = map[string]bool{
+	"ziccif":       true, // выборка выровненных инструкций атомарна
+	"ziccrse":      true, // LR/SC в основной памяти всегда продвигаются
+	"ziccamoa":     true, // все AMO выполняются в основной памяти
+	"zicclsm":      true, // невыровненные загрузки и сохранения выполняются
+	"za64rs":       true, // резервирование - блок RESERVATION_GRANULE
+	"za128rs":      true,
+	"zic64b":       true, // блоки кэша считаются 64-байтными
+	"zicbop":       true, // prefetch.* - подсказки в кодировке ori x0
+	"zihintntl":    true, // ntl.* - подсказки в кодировке add x0
+	"zvkt":         true,
+	"ss1p11":       true,
+	"svbare":       true,
+	"sv39":         true,
+	"sv48":         true,
+	"sv57":         true,
+	"ssccptr":      true, // таблицы страниц читаются из основной памяти
+	"sstvecd":      true, // stvec в режиме Direct принимает любой выровненный адрес
+	"sstvala":      true, // stval получает адрес или код инструкции
+	"sscounterenw": true, // scounteren изменяем для всех счётчиков
+	"ssu64xl":      true, // UXL=64 поддерживается
+	"shcounterenw": true,
+	"shvstvala":    true,
+	"shtvala":      true,
+	"shvstvecd":    true,
+	"shvsatpa":     true, // vsatp поддерживает те же режимы, что и satp
+}
+
+// misa bits of single-letter extensions
+var MISA_EXTENSIONS = map[byte]Extension{
+	'I': EXT_I,
+	'M': EXT_M,
+	'A': EXT_A,
+	'F': EXT_F,
+	'D': EXT_D,
+	'Q': EXT_Q,
+	'C': EXT_C,
+	'V': EXT_V,
+	'H': EXT_H,
+	'B': EXT_ZBA | EXT_ZBB | EXT_ZBS,
+}
+
+// номера версий расширений, например 2p1
+var (
+	isaVersion       = regexp.MustCompile(`[0-9]+(p[0-9]+)?$`)
+	isaLetterVersion = regexp.MustCompile(`^[0-9]+(p[0-9]+)?`)
+)
+
+// ParseISA parses an ISA string such as rv64imafdc_zba_zbb_zicsr and
+// returns XLEN and the enabled extensions together with those they
+// depend on. Version numbers after extension names are ignored.
+func ParseISA(isa string) (uint64, Extension, error) {
+	isa = strings.ToLower(isa)
+	var xlen uint64
+	switch {
+	case strings.HasPrefix(isa, "rv32"):
+		xlen = 32
+	case strings.HasPrefix(isa, "rv64"):
+		xlen = 64
+	default:
+		return 0, 0, fmt.Errorf("Unsupported ISA string %q", isa)
+	}
+	tokens := strings.Split(isa[4:], "_")
+	switch {
+	case tokens[0] == "" || (tokens[0][0] != 'i' && tokens[0][0] != 'g' && tokens[0][0] != 'e'):
+		return 0, 0, fmt.Errorf("ISA string %q has no base ISA", isa)
+	case tokens[0][0] == 'e':
+		return 0, 0, fmt.Errorf("RV%dE base ISA is not supported", xlen)
+	}
+	var exts Extension
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if token[0] == 'z' || token[0] == 's' || token[0] == 'x' {
+			// многобуквенное расширение, номер версии отделяется от имени
+			ext, ok := lookupExtension(token)
+			if !ok {
+				ext, ok = lookupExtension(isaVersion.ReplaceAllString(token, ""))
+			}
+			if !ok {
+				return 0, 0, fmt.Errorf("Unsupported extension %q", token)
+			}
+			exts |= ext
+			continue
+		}
+		for i := 0; i < len(token); {
+			ext, ok := ISA_EXTENSIONS[token[i:i+1]]
+			if !ok {
+				return 0, 0, fmt.Errorf("Unsupported extension %q", token[i:i+1])
+			}
+			exts |= ext
+			i++
+			i += len(isaLetterVersion.FindString(token[i:]))
+		}
+	}
+	exts = withDependencies(exts)
+	if xlen == 32 && exts&RV32_UNSUPPORTED != 0 {
+		return 0, 0, fmt.Errorf("ISA string %q has extensions not supported on RV32", isa)
+	}
+	return xlen, exts, nil
+}
+
+// lookupExtension returns the extension set of an ISA string name,
+// properties from ISA_PROPERTIES are supported without adding any
+func lookupExtension(name string) (Extension, bool) {
+	if ext, ok := ISA_EXTENSIONS[name]; ok {
+		return ext, true
+	}
+	return 0, ISA_PROPERTIES[name]
+}
+
+// withDependencies adds the extensions that exts depend on
+func withDependencies(exts Extension) Extension {
+	for {
+		implied := exts
+		for ext, deps := range ISA_DEPENDENCIES {
+			if exts&ext != 0 {
+				implied |= deps
+			}
+		}
+		if implied == exts {
+			return exts
+		}
+		exts = implied
+	}
+}
+
+// misa returns the extension bits of misa for exts. S and U modes are
+// always implemented.
+func (exts Extension) misa() uint64 {
+	misa := misaExt('S') | misaExt('U')
+	for letter, ext := range MISA_EXTENSIONS {
+		if exts&ext == ext {
+			misa |= misaExt(letter)
+		}
+	}
+	return misa
+}
+
+// ConfigureISA configures the hart from an ISA string: XLEN, FLEN and
+// the set of extensions. Instructions and CSRs of other extensions
+// raise illegal instruction. The hart is reset.
+func (cpu *Cpu) ConfigureISA(isa string) error {
+	xlen, exts, err := ParseISA(isa)
+	if err != nil {
+		return err
+	}
+	cpu.mxlen = xlen
+	cpu.flen = 64
+	if exts&EXT_Q != 0 {
+		cpu.flen = 128
+	}
+	cpu.exts = exts
+	cpu.reset()
+	return nil
+}
+
+// enabled reports whether the instruction belongs to an enabled extension
+func (i *Instruction) enabled(exts Extension) bool {
+	return i.ext == 0 || (exts&i.ext != 0 && exts&i.needs == i.needs)
+}
@@ -0,0 +1,118 @@
+package main
+
+import "testing"
+
+func TestParseISA(t *testing.T) {
+	tests := []struct {
+		isa  string
+		xlen uint64
+		has  Extension
+		not  Extension
+		ok   bool
+	}{
+		{"rv64imafdc_zba_zbb_zicsr", 64, EXT_I | EXT_M | EXT_A | EXT_F | EXT_D | EXT_C | EXT_ZBA | EXT_ZBB | EXT_ZICSR,
+			EXT_V | EXT_H | EXT_ZBS | EXT_ZIFENCEI, true},
+		{"rv64gc", 64, EXT_I | EXT_M | EXT_A | EXT_F | EXT_D | EXT_C | EXT_ZICSR | EXT_ZIFENCEI, EXT_V, true},
+		{"RV32IMAC", 32, EXT_I | EXT_M | EXT_A | EXT_C, EXT_ZICSR | EXT_F, true},
+		{"rv64i2p1m2p0_zicsr2p0", 64, EXT_I | EXT_M | EXT_ZICSR, EXT_A, true},
+		{"rv64iv", 64, EXT_V | EXT_D | EXT_F | EXT_ZICSR, EXT_Q, true},
+		{"rv64i_zfh", 64, EXT_ZFH | EXT_ZFHMIN | EXT_F, EXT_D, true},
+		{"rv64ib_zkn", 64, EXT_ZBA | EXT_ZBB | EXT_ZBS | EXT_ZBKB | EXT_ZKNE | EXT_ZKND | EXT_ZKNH, EXT_ZKSED, true},
+		{"rv64i_m_a", 64, EXT_I | EXT_M | EXT_A, 0, true},
+		{"rv32i_zbkb_zks", 32, EXT_ZBKB | EXT_ZKSED | EXT_ZKSH, EXT_ZKNE, true},
+		{"rv128i", 0, 0, 0, false},
+		{"rv64e", 0, 0, 0, false},
+		{"rv64mafd", 0, 0, 0, false},
+		{"rv64ij", 0, 0, 0, false},
+		{"rv64i_zfoo", 0, 0, 0, false},
+		{"rv32imah", 0, 0, 0, false},
+		{"rv32i_zknd", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		xlen, exts, err := ParseISA(tt.isa)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: accepted", tt.isa)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.isa, err)
+			continue
+		}
+		if xlen != tt.xlen || exts&tt.has != tt.has || exts&tt.not != 0 {
+			t.Errorf("%s: xlen=%d exts=%#x", tt.isa, xlen, exts)
+		}
+	}
+}
+
+func TestConfigureISA(t *testing.T) {
+	cpu := NewCPU()
+	if err := cpu.ConfigureISA("rv64imafdc_zba_zbb_zicsr"); err != nil {
+		t.Fatal(err)
+	}
+	want := XL_64<<MISA_MXL_SHIFT | misaExt('I') | misaExt('M') | misaExt('A') | misaExt('F') |
+		misaExt('D') | misaExt('C') | misaExt('S') | misaExt('U')
+	if got, _ := csrRead(cpu, MISA); got != want {
+		t.Fatalf("misa=%#x, want %#x", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		inst  uint32
+		legal bool
+	}{
+		{"andn", rInst(0x20, 7, 0x33), true},
+		{"sh1add", rInst(0x10, 2, 0x33), true},
+		{"clz", unaryInst(0x600, 1, 0x13), true},
+		{"bset", rInst(0x14, 1, 0x33), false},
+		{"clmul", rInst(0x05, 1, 0x33), false},
+		{"czero.eqz", rInst(0x07, 5, 0x33), false},
+		{"vsetvli", 0x00007057, false},
+		{"fence.i", 0x0000100f, false},
+		{"hlv.w", 0x6800c1f3, false},
+		{"csrr cycle", uint32(CYCLE)<<20 | 2<<12 | 3<<7 | 0x73, false},
+		{"csrr vl", uint32(VL)<<20 | 2<<12 | 3<<7 | 0x73, false},
+		{"fli.d", 0xf2108153, false},
+		{"c.addi", 0x0505, true},
+	}
+	for _, tt := range tests {
+		cpu.ExecuteInst(tt.inst)
+		if trapped := cpu.exception != nil; trapped == tt.legal {
+			t.Errorf("%s: trapped=%v, want legal=%v", tt.name, trapped, tt.legal)
+		}
+		cpu.privilege = MACHINE_MODE
+	}
+}
+
+func TestSharedEncodings(t *testing.T) {
+	// andn входит и в Zbb, и в Zbkb, clz - только в Zbb
+	cpu := NewCPU()
+	if err := cpu.ConfigureISA("rv64i_zbkb"); err != nil {
+		t.Fatal(err)
+	}
+	cpu.ExecuteInst(rInst(0x20, 7, 0x33))
+	if cpu.exception != nil {
+		t.Fatalf("andn trapped with Zbkb")
+	}
+	cpu.ExecuteInst(unaryInst(0x600, 1, 0x13))
+	if cpu.exception == nil {
+		t.Fatalf("clz executed without Zbb")
+	}
+}
+
+func TestConfigureISAQuad(t *testing.T) {
+	cpu := NewCPU()
+	if err := cpu.ConfigureISA("rv64gqc"); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.flen != 128 || cpu.csr[MISA]&misaExt('Q') == 0 {
+		t.Fatalf("flen=%d misa=%#x with Q", cpu.flen, cpu.csr[MISA])
+	}
+	if err := cpu.ConfigureISA("rv32imafc_zicsr"); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.flen != 64 || cpu.xlen != 32 || cpu.csr[MISA]&misaExt('D') != 0 {
+		t.Fatalf("flen=%d xlen=%d misa=%#x", cpu.flen, cpu.xlen, cpu.csr[MISA])
+	}
+}
@@ -1,7 +1,27 @@
 package main
 
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
 func main() {
+	isa := flag.String("isa", "", "ISA string of the hart, e.g. rv64imafdc_zicsr")
+	profile := flag.String("profile", "", "profile of the hart, e.g. RVA20U64")
+	flag.Parse()
 	RISCV_CPU := NewCPU()
+	var err error
+	switch {
+	case *profile != "":
+		err = RISCV_CPU.ConfigureProfile(*profile)
+	case *isa != "":
+		err = RISCV_CPU.ConfigureISA(*isa)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	RISCV_CPU.ExecuteInst(0x02a00093)
 	RISCV_CPU.dumpRegN(0, 1)
 }
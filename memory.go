@@ -1,57 +1,43 @@
 package main
 
-type RAM interface {
-	Write(uint64, uint64, uint8)
-	Write8(uint64, uint64)
-	Write16(uint64, uint64)
-	Write32(uint64, uint64)
-	Write64(uint64, uint64)
-	Read(uint64, uint8) uint64
-	Read8(uint64, uint64) uint64
-	Read16(uint64, uint64) uint64
-	Read32(uint64, uint64) uint64
-	Read64(uint64, uint64) uint64
-}
-
 const (
 	MEMORY_SIZE uint64 = 10 * 1024 * 1024 // 10Mb
 	DRAM_BASE   uint64 = 0x80000000       // starting from 2Gb
 )
 
+// Dram is the main memory device, addresses are offsets from DRAM_BASE.
+// Bus guarantees that accesses stay within the slice.
 type Dram []byte
 
 func InitDram(size uint64) Dram {
 	return make([]byte, size)
 }
 
-func (m *Dram) Read(addr uint64, size uint8) uint64 {
+func (m Dram) Read(addr uint64, size uint8) (uint64, error) {
 	switch size {
-	case 8:
-		return m.Read8(addr)
-	case 16:
-		return m.Read16(addr)
-	case 32:
-		return m.Read32(addr)
-	case 64:
-		return m.Read64(addr)
+	case BYTE:
+		return m.Read8(addr), nil
+	case HALFWORD:
+		return m.Read16(addr), nil
+	case WORD:
+		return m.Read32(addr), nil
+	case DOUBLEWORD:
+		return m.Read64(addr), nil
 	default:
-		panic("Store access fault")
+		return 0, ErrAccessSize
 	}
 }
 
 func (m Dram) Read8(addr uint64) uint64 {
-	addr -= DRAM_BASE
 	return uint64(m[addr])
 }
 
 func (m Dram) Read16(addr uint64) uint64 {
-	addr -= DRAM_BASE
 	return uint64(m[addr]) |
 		(uint64(m[addr+1]) << 8)
 }
 
 func (m Dram) Read32(addr uint64) uint64 {
-	addr -= DRAM_BASE
 	return uint64(m[addr]) |
 		(uint64(m[addr+1]) << 8) |
 		(uint64(m[addr+2]) << 16) |
@@ -59,7 +45,6 @@ func (m Dram) Read32(addr uint64) uint64 {
 }
 
 func (m Dram) Read64(addr uint64) uint64 {
-	addr -= DRAM_BASE
 	return uint64(m[addr]) |
 		(uint64(m[addr+1]) << 8) |
 		(uint64(m[addr+2]) << 16) |
@@ -70,33 +55,32 @@ func (m Dram) Read64(addr uint64) uint64 {
 		(uint64(m[addr+7]) << 56)
 }
 
-func (m Dram) Write(addr uint64, value uint64, size uint8) {
+func (m Dram) Write(addr uint64, value uint64, size uint8) error {
 	switch size {
-	case 8:
+	case BYTE:
 		m.Write8(addr, value)
-	case 16:
+	case HALFWORD:
 		m.Write16(addr, value)
-	case 32:
+	case WORD:
 		m.Write32(addr, value)
-	case 64:
+	case DOUBLEWORD:
 		m.Write64(addr, value)
 	default:
-		panic("Store access fault")
+		return ErrAccessSize
 	}
+	return nil
 }
+
 func (m Dram) Write8(addr uint64, val uint64) {
-	addr -= DRAM_BASE
 	m[addr] = uint8(val)
 }
 
 func (m Dram) Write16(addr uint64, val uint64) {
-	addr -= DRAM_BASE
 	m[addr] = uint8(val)
 	m[addr+1] = uint8(val >> 8)
 }
 
 func (m Dram) Write32(addr uint64, val uint64) {
-	addr -= DRAM_BASE
 	m[addr] = uint8(val)
 	m[addr+1] = uint8(val >> 8)
 	m[addr+2] = uint8(val >> 16)
@@ -104,7 +88,6 @@ func (m Dram) Write32(addr uint64, val uint64) {
 }
 
 func (m Dram) Write64(addr uint64, val uint64) {
-	addr -= DRAM_BASE
 	m[addr] = uint8(val)
 	m[addr+1] = uint8(val >> 8)
 	m[addr+2] = uint8(val >> 16)
@@ -114,3 +97,43 @@ func (m Dram) Write64(addr uint64, val uint64) {
 	m[addr+6] = uint8(val >> 48)
 	m[addr+7] = uint8(val >> 56)
 }
+
+// Rom is a read-only memory device, stores to it are access faults
+type Rom struct {
+	data Dram
+}
+
+func NewRom(data []byte) *Rom {
+	return &Rom{data: Dram(data)}
+}
+
+func (r *Rom) Read(addr uint64, size uint8) (uint64, error) {
+	return r.data.Read(addr, size)
+}
+
+func (r *Rom) Write(addr uint64, value uint64, size uint8) error {
+	return ErrReadOnly
+}
+
+// readPhys reads size bits from physical address,
+// false is returned if the access is not permitted
+func (cpu *Cpu) readPhys(addr uint64, size uint8, access AccessType) (uint64, bool) {
+	if !cpu.pmp.check(addr, size, access, cpu.effectivePrivilege(access)) {
+		return 0, false
+	}
+	data, err := cpu.bus.Read(addr, size)
+	return data, err == nil
+}
+
+// writePhys writes size bits to physical address,
+// false is returned if the access is not permitted
+func (cpu *Cpu) writePhys(addr uint64, data uint64, size uint8, access AccessType) bool {
+	if !cpu.pmp.check(addr, size, access, cpu.effectivePrivilege(access)) {
+		return false
+	}
+	if cpu.bus.Write(addr, data, size) != nil {
+		return false
+	}
+	cpu.bus.invalidate(cpu.hartid, addr, size)
+	return true
+}
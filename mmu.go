@@ -0,0 +1,434 @@
+package main
+
+type AccessType uint8
+
+const (
+	ACCESS_FETCH AccessType = iota
+	ACCESS_LOAD
+	ACCESS_STORE
+)
+
+const (
+	PAGE_SIZE  uint64 = 4096
+	PAGE_SHIFT uint64 = 12
+	PTE_SIZE   uint64 = 8
+	VPN_BITS   uint64 = 9
+
+	// Sv32: 4-байтовые PTE и 10-битные индексы
+	SV32_PTE_SIZE uint64 = 4
+	SV32_VPN_BITS uint64 = 10
+
+	SATP_MODE_SHIFT uint64 = 60
+	SATP_MODE_BARE  uint64 = 0
+	SATP_MODE_SV32  uint64 = 1
+	SATP_MODE_SV39  uint64 = 8
+	SATP_MODE_SV48  uint64 = 9
+	SATP_MODE_SV57  uint64 = 10
+	SATP_ASID_SHIFT uint64 = 44
+	SATP_ASID       uint64 = 0xffff << SATP_ASID_SHIFT
+	SATP_PPN        uint64 = (1 << 44) - 1
+)
+
+// PTE fields
+const (
+	PTE_V uint64 = 1 << 0
+	PTE_R uint64 = 1 << 1
+	PTE_W uint64 = 1 << 2
+	PTE_X uint64 = 1 << 3
+	PTE_U uint64 = 1 << 4
+	PTE_G uint64 = 1 << 5
+	PTE_A uint64 = 1 << 6
+	PTE_D uint64 = 1 << 7
+
+	PTE_PPN_SHIFT uint64 = 10
+	PTE_PPN       uint64 = (1 << 44) - 1
+	// N, PBMT и зарезервированные биты: Svnapot/Svpbmt не поддерживаются
+	PTE_RESERVED uint64 = 0x3ff << 54
+)
+
+func pageFault(access AccessType) ExceptionCause {
+	switch access {
+	case ACCESS_FETCH:
+		return INSTRUCTION_PAGE_FAULT
+	case ACCESS_LOAD:
+		return LOAD_PAGE_FAULT
+	default:
+		return STORE_AMO_PAGE_FAULT
+	}
+}
+
+func guestPageFault(access AccessType) ExceptionCause {
+	switch access {
+	case ACCESS_FETCH:
+		return INSTRUCTION_GUEST_PAGE_FAULT
+	case ACCESS_LOAD:
+		return LOAD_GUEST_PAGE_FAULT
+	default:
+		return STORE_AMO_GUEST_PAGE_FAULT
+	}
+}
+
+func accessFault(access AccessType) ExceptionCause {
+	switch access {
+	case ACCESS_FETCH:
+		return INSTRUCTION_ACCESS_FAULT
+	case ACCESS_LOAD:
+		return LOAD_ACCESS_FAULT
+	default:
+		return STORE_AMO_ACCESS_FAULT
+	}
+}
+
+// pagingLevels returns number of page table levels for satp.MODE,
+// 0 means that translation is disabled
+func pagingLevels(satp uint64) uint64 {
+	switch satp >> SATP_MODE_SHIFT {
+	case SATP_MODE_SV32:
+		return 2
+	case SATP_MODE_SV39:
+		return 3
+	case SATP_MODE_SV48:
+		return 4
+	case SATP_MODE_SV57:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// effectivePrivilege is the privilege used for load/store translation,
+// MPRV makes M-mode accesses use MPP, hlv/hsv use hstatus.SPVP
+func (cpu *Cpu) effectivePrivilege(access AccessType) PrivMode {
+	if cpu.hlsv {
+		return PrivMode((cpu.csr[HSTATUS] & HSTATUS_SPVP) >> HSTATUS_SPVP_SHIFT)
+	}
+	mstatus := cpu.csr[MSTATUS]
+	if access != ACCESS_FETCH && cpu.privilege == MACHINE_MODE && mstatus&MSTATUS_MPRV != 0 {
+		return PrivMode((mstatus & MSTATUS_MPP) >> MSTATUS_MPP_SHIFT)
+	}
+	return cpu.privilege
+}
+
+// effectiveVirt reports whether the access uses two-stage translation:
+// in VS/VU-mode, for hlv/hsv and for M-mode accesses with MPRV and MPV
+func (cpu *Cpu) effectiveVirt(access AccessType) bool {
+	if cpu.hlsv {
+		return true
+	}
+	mstatus := cpu.csr[MSTATUS]
+	if access != ACCESS_FETCH && cpu.privilege == MACHINE_MODE && mstatus&MSTATUS_MPRV != 0 {
+		return mstatus&MSTATUS_MPV != 0
+	}
+	return cpu.virt
+}
+
+// pageWalk describes one stage of address translation
+// and how its faults are reported
+type pageWalk struct {
+	root   uint64     // физический адрес корневой таблицы
+	levels uint64     // число уровней таблиц, 0 - трансляция выключена
+	widen  uint64     // дополнительные биты индекса корневой таблицы Sv39x4/Sv48x4
+	sv32   bool       // Sv32: 4-байтовые PTE, адрес расширяется нулями
+	priv   PrivMode   // режим, для которого проверяются права
+	sum    bool       // SUM из mstatus или vsstatus
+	mxr    bool       // MXR из mstatus или vsstatus
+	exec   bool       // hlvx: вместо права чтения требуется исполнение
+	guest  bool       // VS-стадия: таблицы лежат в гостевой физической памяти
+	gstage bool       // G-стадия: ошибки сообщаются как guest-page fault
+	fault  AccessType // тип доступа, сообщаемый в исключении
+	tval   uint64     // адрес, сообщаемый в xtval
+	tinst  uint64     // псевдоинструкция неявного доступа к таблицам VS-стадии
+}
+
+// newPageWalk describes translation of vaddr with satp or vsatp,
+// SUM and MXR are taken from status
+func newPageWalk(atp uint64, priv PrivMode, status uint64, access AccessType, vaddr uint64) pageWalk {
+	return pageWalk{
+		root:   (atp & SATP_PPN) * PAGE_SIZE,
+		levels: pagingLevels(atp),
+		sv32:   atp>>SATP_MODE_SHIFT == SATP_MODE_SV32,
+		priv:   priv,
+		sum:    status&MSTATUS_SUM != 0,
+		mxr:    status&MSTATUS_MXR != 0,
+		fault:  access,
+		tval:   vaddr,
+	}
+}
+
+// vpnBits returns the width of a page table index
+func (w *pageWalk) vpnBits() uint64 {
+	if w.sv32 {
+		return SV32_VPN_BITS
+	}
+	return VPN_BITS
+}
+
+// pteSize returns the size of a page table entry in bits
+func (w *pageWalk) pteSize() uint8 {
+	if w.sv32 {
+		return WORD
+	}
+	return DOUBLEWORD
+}
+
+// translate converts virtual address to physical one. On failure
+// the exception is raised and false is returned.
+func (cpu *Cpu) translate(vaddr uint64, access AccessType) (uint64, bool) {
+	priv := cpu.effectivePrivilege(access)
+	if priv == MACHINE_MODE {
+		return vaddr, true
+	}
+	if cpu.effectiveVirt(access) {
+		return cpu.translateGuest(vaddr, access, priv)
+	}
+	satp := cpu.csr[SATP]
+	w := newPageWalk(satp, priv, cpu.csr[MSTATUS], access, vaddr)
+	if w.levels == 0 {
+		return vaddr, true
+	}
+
+	tlb, miss := cpu.dtlb, HPM_EVENT_DTLB_MISS
+	if access == ACCESS_FETCH {
+		tlb, miss = cpu.itlb, HPM_EVENT_ITLB_MISS
+	}
+	asid := (satp & SATP_ASID) >> SATP_ASID_SHIFT
+	if e := tlb.lookup(vaddr, asid); e != nil {
+		// права проверяются заново: режим, SUM и MXR могли измениться.
+		// Отказ и установка бита D обрабатываются обходом таблиц
+		if cpu.leafAllowed(e.pte, access, &w) && (access != ACCESS_STORE || e.pte&PTE_D != 0) {
+			return e.ppn*PAGE_SIZE | vaddr&(PAGE_SIZE-1), true
+		}
+	} else {
+		cpu.countEvent(miss)
+	}
+
+	paddr, pte, level, ok := cpu.walk(vaddr, access, &w)
+	if ok {
+		tlb.insertSpan(vaddr, asid, paddr/PAGE_SIZE, pte, w.vpnBits()*level)
+	}
+	return paddr, ok
+}
+
+// walk is the page-table walker for Sv32/Sv39/Sv48/Sv57 and G-stage
+// Sv39x4/Sv48x4, it returns physical address, leaf PTE and its level
+func (cpu *Cpu) walk(vaddr uint64, access AccessType, w *pageWalk) (uint64, uint64, uint64, bool) {
+	vaBits := PAGE_SHIFT + w.vpnBits()*w.levels + w.widen
+	if w.gstage || w.sv32 {
+		// гостевой физический адрес и адрес Sv32 расширяются нулями
+		if vaddr>>vaBits != 0 {
+			cpu.walkFault(w, vaddr)
+			return 0, 0, 0, false
+		}
+	} else if uint64(signExtend(int64(vaddr), uint(vaBits))) != vaddr {
+		// старшие биты должны совпадать со старшим битом виртуального адреса
+		cpu.walkFault(w, vaddr)
+		return 0, 0, 0, false
+	}
+
+	table := w.root
+	for i := int(w.levels) - 1; i >= 0; i-- {
+		shift := PAGE_SHIFT + w.vpnBits()*uint64(i)
+		vpnBits := w.vpnBits()
+		if i == int(w.levels)-1 {
+			vpnBits += w.widen
+		}
+		vpn := (vaddr >> shift) & ((1 << vpnBits) - 1)
+		pteAddr := table + vpn*uint64(w.pteSize()/8)
+		pte, ok := cpu.readPTE(pteAddr, w)
+		if !ok {
+			return 0, 0, 0, false
+		}
+		if pte&PTE_V == 0 || (pte&PTE_R == 0 && pte&PTE_W != 0) || pte&PTE_RESERVED != 0 {
+			break
+		}
+		ppn := (pte >> PTE_PPN_SHIFT) & PTE_PPN
+		if pte&(PTE_R|PTE_X) == 0 {
+			// указатель на следующий уровень таблицы
+			table = ppn * PAGE_SIZE
+			continue
+		}
+
+		offsetMask := uint64(1)<<shift - 1
+		if !cpu.leafAllowed(pte, access, w) || (ppn*PAGE_SIZE)&offsetMask != 0 {
+			// нет прав доступа или невыровненная суперстраница
+			break
+		}
+		updated := pte | PTE_A
+		if access == ACCESS_STORE {
+			updated |= PTE_D
+		}
+		if updated != pte && !cpu.writePTE(pteAddr, updated, w) {
+			return 0, 0, 0, false
+		}
+		return (ppn * PAGE_SIZE) | (vaddr & offsetMask), updated, uint64(i), true
+	}
+	cpu.walkFault(w, vaddr)
+	return 0, 0, 0, false
+}
+
+// readPTE reads a page table entry, VS-stage tables lie in guest
+// physical memory and are accessed through the G-stage. PMP checks
+// the implicit read as a load whatever the original access is.
+func (cpu *Cpu) readPTE(addr uint64, w *pageWalk) (uint64, bool) {
+	if w.guest {
+		var ok bool
+		if addr, ok = cpu.gstage(addr, w.fault, w.tval, TINST_PTE_READ); !ok {
+			return 0, false
+		}
+	}
+	pte, ok := cpu.readPhys(addr, w.pteSize(), ACCESS_LOAD)
+	if !ok {
+		cpu.raise(accessFault(w.fault), w.tval)
+	}
+	return pte, ok
+}
+
+// writePTE stores a page table entry with updated A/D bits,
+// PMP checks the update as a store
+func (cpu *Cpu) writePTE(addr uint64, pte uint64, w *pageWalk) bool {
+	if w.guest {
+		var ok bool
+		if addr, ok = cpu.gstage(addr, w.fault, w.tval, TINST_PTE_WRITE); !ok {
+			return false
+		}
+	}
+	if !cpu.writePhys(addr, pte, w.pteSize(), ACCESS_STORE) {
+		cpu.raise(accessFault(w.fault), w.tval)
+		return false
+	}
+	return true
+}
+
+// walkFault raises page fault of the walk. G-stage faults are guest-page
+// faults, the guest physical address goes to htval/mtval2.
+func (cpu *Cpu) walkFault(w *pageWalk, addr uint64) {
+	if !w.gstage {
+		cpu.raise(pageFault(w.fault), w.tval)
+		return
+	}
+	if cpu.exception == nil {
+		cpu.raise(guestPageFault(w.fault), w.tval)
+		cpu.exception.tval2 = addr >> 2
+		cpu.exception.tinst = w.tinst
+	}
+}
+
+// leafAllowed checks U/R/W/X permissions of a leaf PTE
+// with regard to SUM and MXR of the translation stage
+func (cpu *Cpu) leafAllowed(pte uint64, access AccessType, w *pageWalk) bool {
+	if pte&PTE_U != 0 {
+		if w.priv == SUPERVISOR_MODE && (access == ACCESS_FETCH || !w.sum) {
+			return false
+		}
+	} else if w.priv == USER_MODE {
+		return false
+	}
+	switch access {
+	case ACCESS_FETCH:
+		return pte&PTE_X != 0
+	case ACCESS_LOAD:
+		if w.exec {
+			return pte&PTE_X != 0
+		}
+		return pte&PTE_R != 0 || (w.mxr && pte&PTE_X != 0)
+	default:
+		return pte&PTE_W != 0
+	}
+}
+
+// translateRange translates an access of size bits. Misaligned access
+// may cross a page boundary, then the second page is translated too.
+func (cpu *Cpu) translateRange(vaddr uint64, size uint8, access AccessType) (lo uint64, hi uint64, split uint64, ok bool) {
+	n := uint64(size / 8)
+	split = PAGE_SIZE - vaddr&(PAGE_SIZE-1)
+	if lo, ok = cpu.translate(vaddr, access); !ok {
+		return
+	}
+	if split >= n {
+		return lo, 0, n, true
+	}
+	hi, ok = cpu.translate(vaddr+split, access)
+	return
+}
+
+// load reads size bits from virtual address
+func (cpu *Cpu) load(addr uint64, size uint8) (uint64, bool) {
+	cpu.countEvent(HPM_EVENT_LOAD)
+	addr = cpu.truncAddr(addr)
+	lo, hi, split, ok := cpu.translateRange(addr, size, ACCESS_LOAD)
+	if !ok {
+		return 0, false
+	}
+	if split == uint64(size/8) {
+		data, ok := cpu.readPhys(lo, size, ACCESS_LOAD)
+		if !ok {
+			cpu.raise(LOAD_ACCESS_FAULT, addr)
+		}
+		return data, ok
+	}
+	var data uint64
+	for i := uint64(0); i < uint64(size/8); i++ {
+		paddr := lo + i
+		if i >= split {
+			paddr = hi + i - split
+		}
+		b, ok := cpu.readPhys(paddr, BYTE, ACCESS_LOAD)
+		if !ok {
+			cpu.raise(LOAD_ACCESS_FAULT, addr+i)
+			return 0, false
+		}
+		data |= b << (8 * i)
+	}
+	return data, true
+}
+
+// store writes size bits to virtual address
+func (cpu *Cpu) store(addr uint64, data uint64, size uint8) bool {
+	cpu.countEvent(HPM_EVENT_STORE)
+	addr = cpu.truncAddr(addr)
+	lo, hi, split, ok := cpu.translateRange(addr, size, ACCESS_STORE)
+	if !ok {
+		return false
+	}
+	if split == uint64(size/8) {
+		if !cpu.writePhys(lo, data, size, ACCESS_STORE) {
+			cpu.raise(STORE_AMO_ACCESS_FAULT, addr)
+			return false
+		}
+		return true
+	}
+	for i := uint64(0); i < uint64(size/8); i++ {
+		paddr := lo + i
+		if i >= split {
+			paddr = hi + i - split
+		}
+		if !cpu.writePhys(paddr, data>>(8*i), BYTE, ACCESS_STORE) {
+			cpu.raise(STORE_AMO_ACCESS_FAULT, addr+i)
+			return false
+		}
+	}
+	return true
+}
+
+// fetch reads the instruction at pc in 16-bit parcels, the second
+// parcel is read only for 32-bit instructions
+func (cpu *Cpu) fetch() (uint32, bool) {
+	lo, ok := cpu.fetchParcel(cpu.pc)
+	if !ok || lo&3 != 3 {
+		return lo, ok
+	}
+	hi, ok := cpu.fetchParcel(cpu.pc + 2)
+	return lo | hi<<16, ok
+}
+
+func (cpu *Cpu) fetchParcel(addr uint64) (uint32, bool) {
+	paddr, ok := cpu.translate(addr, ACCESS_FETCH)
+	if !ok {
+		return 0, false
+	}
+	parcel, ok := cpu.readPhys(paddr, HALFWORD, ACCESS_FETCH)
+	if !ok {
+		cpu.raise(INSTRUCTION_ACCESS_FAULT, addr)
+	}
+	return uint32(parcel), ok
+}
@@ -0,0 +1,165 @@
+package main
+
+import "testing"
+
+// pageTables builds page tables in guest memory for tests
+type pageTables struct {
+	cpu    *Cpu
+	root   uint64
+	next   uint64
+	levels uint64
+	widen  uint64 // дополнительные биты индекса корневой таблицы G-стадии
+	sv32   bool   // 4-байтовые PTE и 10-битные индексы
+}
+
+func newPageTables(cpu *Cpu, mode uint64) *pageTables {
+	pt := &pageTables{cpu: cpu, root: 0x80100000, next: 0x80101000}
+	allowAllPMP(cpu)
+	cpu.csr[SATP] = mode<<SATP_MODE_SHIFT | pt.root/PAGE_SIZE
+	pt.levels = pagingLevels(cpu.csr[SATP])
+	pt.sv32 = mode == SATP_MODE_SV32
+	return pt
+}
+
+// entry returns the address of the PTE for va in a table at level i
+// and the PTE size
+func (pt *pageTables) entry(table, va, i uint64) (uint64, uint8) {
+	if pt.sv32 {
+		return table + (va>>(PAGE_SHIFT+SV32_VPN_BITS*i)&(1<<SV32_VPN_BITS-1))*SV32_PTE_SIZE, WORD
+	}
+	return table + pt.index(va, i)*PTE_SIZE, DOUBLEWORD
+}
+
+// index returns the PTE index of va in a table at level i
+func (pt *pageTables) index(va, i uint64) uint64 {
+	bits := VPN_BITS
+	if i == pt.levels-1 {
+		bits += pt.widen
+	}
+	return (va >> (PAGE_SHIFT + VPN_BITS*i)) & (1<<bits - 1)
+}
+
+// mapPage maps va to pa with leaf PTE at level (0 - 4KiB page)
+func (pt *pageTables) mapPage(va, pa, flags uint64, level uint64) {
+	table := pt.root
+	for i := pt.levels - 1; ; i-- {
+		pteAddr, size := pt.entry(table, va, i)
+		if i == level {
+			pt.cpu.bus.Write(pteAddr, (pa/PAGE_SIZE)<<PTE_PPN_SHIFT|flags|PTE_V, size)
+			return
+		}
+		pte := memRead(pt.cpu, pteAddr, size)
+		if pte&PTE_V == 0 {
+			pte = (pt.next/PAGE_SIZE)<<PTE_PPN_SHIFT | PTE_V
+			pt.next += PAGE_SIZE
+			pt.cpu.bus.Write(pteAddr, pte, size)
+		}
+		table = (pte >> PTE_PPN_SHIFT) * PAGE_SIZE
+	}
+}
+
+func (pt *pageTables) leafPTE(va uint64, level uint64) uint64 {
+	table := pt.root
+	for i := pt.levels - 1; ; i-- {
+		pteAddr, size := pt.entry(table, va, i)
+		pte := memRead(pt.cpu, pteAddr, size)
+		if i == level {
+			return pte
+		}
+		table = (pte >> PTE_PPN_SHIFT) * PAGE_SIZE
+	}
+}
+
+func TestTranslation(t *testing.T) {
+	for _, mode := range []uint64{SATP_MODE_SV39, SATP_MODE_SV48, SATP_MODE_SV57} {
+		cpu := NewCPU()
+		pt := newPageTables(cpu, mode)
+		pt.mapPage(0x1000, 0x80200000, PTE_R|PTE_W, 0)
+		pt.mapPage(0x200000, 0x80400000, PTE_R, 1) // мегастраница
+		cpu.privilege = SUPERVISOR_MODE
+		cpu.bus.Write(0x80400010, 0xdeadbeef, DOUBLEWORD)
+
+		cpu.writeReg(1, 0x1000)
+		cpu.writeReg(2, 0x12345678)
+		cpu.writeReg(3, 0x200000)
+		cpu.ExecuteInst(0x0020b423) // sd x2, 8(x1)
+		cpu.ExecuteInst(0x0080b203) // ld x4, 8(x1)
+		cpu.ExecuteInst(0x0101b283) // ld x5, 16(x3)
+		if memRead(cpu, 0x80200008, DOUBLEWORD) != 0x12345678 {
+			t.Fatalf("mode %d: store went to %#x", mode, memRead(cpu, 0x80200008, DOUBLEWORD))
+		}
+		if err := cpu.regsMustEq(map[uint]uint64{4: 0x12345678, 5: 0xdeadbeef}); err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if pte := pt.leafPTE(0x1000, 0); pte&(PTE_A|PTE_D) != PTE_A|PTE_D {
+			t.Fatalf("mode %d: A/D bits are not set, pte=%#x", mode, pte)
+		}
+		if pte := pt.leafPTE(0x200000, 1); pte&(PTE_A|PTE_D) != PTE_A {
+			t.Fatalf("mode %d: only A bit must be set, pte=%#x", mode, pte)
+		}
+	}
+}
+
+func TestPageFaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		privilege PrivMode
+		mstatus   uint64
+		flags     uint64
+		inst      uint32
+		addr      uint64
+		cause     ExceptionCause
+	}{
+		{"store to read-only page", SUPERVISOR_MODE, 0, PTE_R, 0x0020b023, 0x1000, STORE_AMO_PAGE_FAULT},
+		{"load from unmapped page", SUPERVISOR_MODE, 0, PTE_R, 0x0000b203, 0x5000, LOAD_PAGE_FAULT},
+		{"S-mode load from U page", SUPERVISOR_MODE, 0, PTE_R | PTE_U, 0x0000b203, 0x1000, LOAD_PAGE_FAULT},
+		{"S-mode load from U page with SUM", SUPERVISOR_MODE, MSTATUS_SUM, PTE_R | PTE_U, 0x0000b203, 0x1000, 0},
+		{"U-mode load from S page", USER_MODE, 0, PTE_R, 0x0000b203, 0x1000, LOAD_PAGE_FAULT},
+		{"load from execute-only page", USER_MODE, 0, PTE_X | PTE_U, 0x0000b203, 0x1000, LOAD_PAGE_FAULT},
+		{"load from execute-only page with MXR", USER_MODE, MSTATUS_MXR, PTE_X | PTE_U, 0x0000b203, 0x1000, 0},
+		{"non-canonical address", SUPERVISOR_MODE, 0, PTE_R, 0x0000b203, 1 << 40, LOAD_PAGE_FAULT},
+		{"MPRV uses MPP", MACHINE_MODE, MSTATUS_MPRV | uint64(USER_MODE)<<MSTATUS_MPP_SHIFT, PTE_R, 0x0000b203, 0x1000, LOAD_PAGE_FAULT},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		pt := newPageTables(cpu, SATP_MODE_SV39)
+		pt.mapPage(0x1000, 0x80200000, test.flags, 0)
+		cpu.privilege = test.privilege
+		cpu.csr[MSTATUS] = test.mstatus
+		cpu.csr[MTVEC] = 0x80001000
+		cpu.writeReg(1, test.addr)
+		cpu.ExecuteInst(test.inst)
+
+		if test.cause == 0 {
+			if cpu.pc != DRAM_BASE+4 {
+				t.Fatalf("%s: unexpected trap, mcause=%d", test.name, cpu.csr[MCAUSE])
+			}
+			continue
+		}
+		if cpu.pc != 0x80001000 || cpu.csr[MCAUSE] != uint64(test.cause) || cpu.csr[MTVAL] != test.addr {
+			t.Fatalf("%s: pc=%#x mcause=%d mtval=%#x, want mcause=%d mtval=%#x",
+				test.name, cpu.pc, cpu.csr[MCAUSE], cpu.csr[MTVAL], test.cause, test.addr)
+		}
+	}
+}
+
+func TestFetchPageFault(t *testing.T) {
+	cpu := NewCPU()
+	pt := newPageTables(cpu, SATP_MODE_SV39)
+	pt.mapPage(0x1000, 0x80200000, PTE_R|PTE_X|PTE_U, 0)
+	pt.mapPage(0x2000, 0x80201000, PTE_R|PTE_U, 0)
+	cpu.bus.Write(0x80200000, 0x00100093, WORD) // addi x1, x0, 1
+	cpu.csr[MTVEC] = 0x80001000
+	cpu.privilege = USER_MODE
+	cpu.pc = 0x1000
+
+	cpu.Step()
+	if cpu.readReg(1) != 1 || cpu.pc != 0x1004 {
+		t.Fatalf("x1=%d pc=%#x, want x1=1 pc=0x1004", cpu.readReg(1), cpu.pc)
+	}
+	cpu.pc = 0x2000
+	cpu.Step()
+	if cpu.csr[MCAUSE] != uint64(INSTRUCTION_PAGE_FAULT) || cpu.csr[MTVAL] != 0x2000 || cpu.csr[MEPC] != 0x2000 {
+		t.Fatalf("mcause=%d mtval=%#x mepc=%#x", cpu.csr[MCAUSE], cpu.csr[MTVAL], cpu.csr[MEPC])
+	}
+}
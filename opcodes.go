@@ -3,6 +3,9 @@ package main
 type Instruction struct {
 	mask    uint32
 	match   uint32
+	xlen    uint64    // 32 или 64 для инструкций одной базовой ISA, 0 - для обеих
+	ext     Extension // любое из этих расширений включает инструкцию, 0 - всегда доступна
+	needs   Extension // расширения, нужные дополнительно к одному из ext
 	execute func(*Cpu, uint32)
 }
 
@@ -11,6 +14,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x33,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.add(InstWord(inst))
 		},
@@ -19,6 +23,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x13,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.addi(InstWord(inst))
 		},
@@ -27,6 +32,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0x707f,
 		match: 0x1b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.addiw(InstWord(inst))
 		},
@@ -35,6 +42,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x3b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.addw(InstWord(inst))
 		},
@@ -43,6 +52,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x7033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.and(InstWord(inst))
 		},
@@ -51,6 +61,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x7013,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.andi(InstWord(inst))
 		},
@@ -59,6 +70,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x7f,
 		match: 0x17,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.auipc(InstWord(inst))
 		},
@@ -67,6 +79,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x63,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.beq(InstWord(inst))
 		},
@@ -75,6 +88,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x5063,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.bge(InstWord(inst))
 		},
@@ -83,6 +97,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x7063,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.bgeu(InstWord(inst))
 		},
@@ -91,6 +106,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x4063,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.blt(InstWord(inst))
 		},
@@ -99,6 +115,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x6063,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.bltu(InstWord(inst))
 		},
@@ -107,6 +124,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x1063,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.bne(InstWord(inst))
 		},
@@ -115,6 +133,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x3073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrc(InstWord(inst))
 		},
@@ -123,6 +142,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x7073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrci(InstWord(inst))
 		},
@@ -131,6 +151,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x2073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrs(InstWord(inst))
 		},
@@ -139,6 +160,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x6073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrsi(InstWord(inst))
 		},
@@ -147,6 +169,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x1073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrw(InstWord(inst))
 		},
@@ -155,6 +178,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVZICSR extension
 		mask:  0x707f,
 		match: 0x5073,
+		ext:   EXT_ZICSR,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.csrrwi(InstWord(inst))
 		},
@@ -163,6 +187,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2004033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.div(InstWord(inst))
 		},
@@ -171,6 +196,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2005033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.divu(InstWord(inst))
 		},
@@ -179,6 +205,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64M extension
 		mask:  0xfe00707f,
 		match: 0x200503b,
+		xlen:  64,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.divuw(InstWord(inst))
 		},
@@ -187,6 +215,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64M extension
 		mask:  0xfe00707f,
 		match: 0x200403b,
+		xlen:  64,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.divw(InstWord(inst))
 		},
@@ -195,6 +225,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xffffffff,
 		match: 0x100073,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.ebreak(InstWord(inst))
 		},
@@ -203,22 +234,43 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xffffffff,
 		match: 0x73,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.ecall(InstWord(inst))
 		},
 	},
+	Instruction{
+		// RVZIHINTPAUSE extension
+		mask:  0xffffffff,
+		match: 0x100000f,
+		ext:   EXT_ZIHINTPAUSE,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.pause(InstWord(inst))
+		},
+	},
 	Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0xf,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.fence(InstWord(inst))
 		},
 	},
+	Instruction{
+		// RVZIFENCEI extension
+		mask:  0x707f,
+		match: 0x100f,
+		ext:   EXT_ZIFENCEI,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fenceI(InstWord(inst))
+		},
+	},
 	Instruction{
 		// RVI extension
 		mask:  0x7f,
 		match: 0x6f,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.jal(InstWord(inst))
 		},
@@ -227,6 +279,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x67,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.jalr(InstWord(inst))
 		},
@@ -235,6 +288,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x3,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lb(InstWord(inst))
 		},
@@ -243,6 +297,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x4003,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lbu(InstWord(inst))
 		},
@@ -251,6 +306,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0x707f,
 		match: 0x3003,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.ld(InstWord(inst))
 		},
@@ -259,6 +316,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x1003,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lh(InstWord(inst))
 		},
@@ -267,6 +325,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x5003,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lhu(InstWord(inst))
 		},
@@ -275,6 +334,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x7f,
 		match: 0x37,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lui(InstWord(inst))
 		},
@@ -283,6 +343,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x2003,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lw(InstWord(inst))
 		},
@@ -291,6 +352,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0x707f,
 		match: 0x6003,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.lwu(InstWord(inst))
 		},
@@ -299,6 +362,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2000033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.mul(InstWord(inst))
 		},
@@ -307,6 +371,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2001033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.mulh(InstWord(inst))
 		},
@@ -315,6 +380,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2002033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.mulhsu(InstWord(inst))
 		},
@@ -323,6 +389,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2003033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.mulhu(InstWord(inst))
 		},
@@ -331,6 +398,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64M extension
 		mask:  0xfe00707f,
 		match: 0x200003b,
+		xlen:  64,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.mulw(InstWord(inst))
 		},
@@ -339,6 +408,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x6033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.or(InstWord(inst))
 		},
@@ -347,22 +417,16 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x6013,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.ori(InstWord(inst))
 		},
 	},
-	Instruction{
-		// RVI extension
-		mask:  0xffffffff,
-		match: 0x100000f,
-		execute: func(cpu *Cpu, inst uint32) {
-			cpu.pause(InstWord(inst))
-		},
-	},
 	Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2006033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.rem(InstWord(inst))
 		},
@@ -371,6 +435,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVM extension
 		mask:  0xfe00707f,
 		match: 0x2007033,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.remu(InstWord(inst))
 		},
@@ -379,6 +444,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64M extension
 		mask:  0xfe00707f,
 		match: 0x200703b,
+		xlen:  64,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.remuw(InstWord(inst))
 		},
@@ -387,6 +454,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64M extension
 		mask:  0xfe00707f,
 		match: 0x200603b,
+		xlen:  64,
+		ext:   EXT_M,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.remw(InstWord(inst))
 		},
@@ -395,6 +464,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x23,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sb(InstWord(inst))
 		},
@@ -403,6 +473,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0x707f,
 		match: 0x3023,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sd(InstWord(inst))
 		},
@@ -411,14 +483,24 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x1023,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sh(InstWord(inst))
 		},
 	},
+	Instruction{
+		// RVS extension
+		mask:  0xfe007fff,
+		match: 0x12000073,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sfenceVma(InstWord(inst))
+		},
+	},
 	Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x1033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sll(InstWord(inst))
 		},
@@ -427,6 +509,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfc00707f,
 		match: 0x1013,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.slli(InstWord(inst))
 		},
@@ -435,6 +519,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x101b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.slliw(InstWord(inst))
 		},
@@ -443,6 +529,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x103b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sllw(InstWord(inst))
 		},
@@ -451,6 +539,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x2033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.slt(InstWord(inst))
 		},
@@ -459,6 +548,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x2013,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.slti(InstWord(inst))
 		},
@@ -467,6 +557,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x3013,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sltiu(InstWord(inst))
 		},
@@ -475,6 +566,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x3033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sltu(InstWord(inst))
 		},
@@ -483,6 +575,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x40005033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sra(InstWord(inst))
 		},
@@ -491,6 +584,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfc00707f,
 		match: 0x40005013,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.srai(InstWord(inst))
 		},
@@ -499,6 +594,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x4000501b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sraiw(InstWord(inst))
 		},
@@ -507,6 +604,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x4000503b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sraw(InstWord(inst))
 		},
@@ -515,6 +614,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x5033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.srl(InstWord(inst))
 		},
@@ -523,6 +623,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfc00707f,
 		match: 0x5013,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.srli(InstWord(inst))
 		},
@@ -531,6 +633,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x501b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.srliw(InstWord(inst))
 		},
@@ -539,6 +643,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x503b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.srlw(InstWord(inst))
 		},
@@ -547,6 +653,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x40000033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sub(InstWord(inst))
 		},
@@ -555,6 +662,8 @@ var INSTRUCTIONS = [...]Instruction{
 		// RV64I extension
 		mask:  0xfe00707f,
 		match: 0x4000003b,
+		xlen:  64,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.subw(InstWord(inst))
 		},
@@ -563,6 +672,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x2023,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.sw(InstWord(inst))
 		},
@@ -571,6 +681,7 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0xfe00707f,
 		match: 0x4033,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.xor(InstWord(inst))
 		},
@@ -579,16 +690,2859 @@ var INSTRUCTIONS = [...]Instruction{
 		// RVI extension
 		mask:  0x707f,
 		match: 0x4013,
+		ext:   EXT_I,
 		execute: func(cpu *Cpu, inst uint32) {
 			cpu.xori(InstWord(inst))
 		},
 	},
 	Instruction{
-		// RVI extension
+		// RVSYSTEM extension
 		mask:  0xffffffff,
 		match: 0x30200073,
 		execute: func(cpu *Cpu, inst uint32) {
-
+			cpu.mret(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVSYSTEM extension
+		mask:  0xffffffff,
+		match: 0x10200073,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sret(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVSYSTEM extension
+		mask:  0xffffffff,
+		match: 0x10500073,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.wfi(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x707f,
+		match: 0x2007,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.flw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x707f,
+		match: 0x2027,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x600007f,
+		match: 0x43,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaddS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x600007f,
+		match: 0x47,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmsubS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x600007f,
+		match: 0x4b,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmsubS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0x600007f,
+		match: 0x4f,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmaddS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00007f,
+		match: 0x53,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.faddS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00007f,
+		match: 0x8000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsubS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00007f,
+		match: 0x10000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmulS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00007f,
+		match: 0x18000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fdivS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0007f,
+		match: 0x58000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsqrtS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0x20000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0x20001053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjnS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0x20002053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjxS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0x28000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0x28001053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0007f,
+		match: 0xc0000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0007f,
+		match: 0xc0100053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWuS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0707f,
+		match: 0xe0000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvXW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0xa0002053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.feqS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0xa0001053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfe00707f,
+		match: 0xa0000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0707f,
+		match: 0xe0001053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fclassS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0007f,
+		match: 0xd0000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0007f,
+		match: 0xd0100053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVF extension
+		mask:  0xfff0707f,
+		match: 0xf0000053,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvWX(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64F extension
+		mask:  0xfff0007f,
+		match: 0xc0200053,
+		xlen:  64,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64F extension
+		mask:  0xfff0007f,
+		match: 0xc0300053,
+		xlen:  64,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLuS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64F extension
+		mask:  0xfff0007f,
+		match: 0xd0200053,
+		xlen:  64,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSL(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64F extension
+		mask:  0xfff0007f,
+		match: 0xd0300053,
+		xlen:  64,
+		ext:   EXT_F,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSLu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x707f,
+		match: 0x3007,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fld(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x707f,
+		match: 0x3027,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsd(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x600007f,
+		match: 0x2000043,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaddD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x600007f,
+		match: 0x2000047,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmsubD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x600007f,
+		match: 0x200004b,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmsubD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0x600007f,
+		match: 0x200004f,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmaddD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00007f,
+		match: 0x2000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.faddD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00007f,
+		match: 0xa000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsubD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00007f,
+		match: 0x12000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmulD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00007f,
+		match: 0x1a000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fdivD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0x5a000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsqrtD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0x22000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0x22001053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjnD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0x22002053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjxD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0x2a000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0x2a001053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0x40100053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0x42000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0xa2002053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.feqD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0xa2001053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfe00707f,
+		match: 0xa2000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0707f,
+		match: 0xe2001053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fclassD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0xc2000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0xc2100053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWuD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0xd2000053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD extension
+		mask:  0xfff0007f,
+		match: 0xd2100053,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0007f,
+		match: 0xc2200053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0007f,
+		match: 0xc2300053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLuD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0707f,
+		match: 0xe2000053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvXD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0007f,
+		match: 0xd2200053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDL(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0007f,
+		match: 0xd2300053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDLu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64D extension
+		mask:  0xfff0707f,
+		match: 0xf2000053,
+		xlen:  64,
+		ext:   EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvDX(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf9f0707f,
+		match: 0x1000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.lrW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x1800202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.scW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x800202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoswapW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoaddW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x2000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoxorW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x6000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoandW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x4000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoorW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0x8000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amominW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0xa000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amomaxW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0xc000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amominuW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVA extension
+		mask:  0xf800707f,
+		match: 0xe000202f,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amomaxuW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf9f0707f,
+		match: 0x1000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.lrD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x1800302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.scD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x800302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoswapD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoaddD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x2000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoxorD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x6000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoandD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x4000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amoorD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0x8000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amominD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0xa000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amomaxD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0xc000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amominuD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64A extension
+		mask:  0xf800707f,
+		match: 0xe000302f,
+		xlen:  64,
+		ext:   EXT_A,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.amomaxuD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBA extension
+		mask:  0xfe00707f,
+		match: 0x20002033,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh1add(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBA extension
+		mask:  0xfe00707f,
+		match: 0x20004033,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh2add(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBA extension
+		mask:  0xfe00707f,
+		match: 0x20006033,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh3add(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBA extension
+		mask:  0xfe00707f,
+		match: 0x0800003b,
+		xlen:  64,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.addUw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBA extension
+		mask:  0xfe00707f,
+		match: 0x2000203b,
+		xlen:  64,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh1addUw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBA extension
+		mask:  0xfe00707f,
+		match: 0x2000403b,
+		xlen:  64,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh2addUw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBA extension
+		mask:  0xfe00707f,
+		match: 0x2000603b,
+		xlen:  64,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sh3addUw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBA extension
+		mask:  0xfc00707f,
+		match: 0x0800101b,
+		xlen:  64,
+		ext:   EXT_ZBA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.slliUw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x40007033,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.andn(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x40006033,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.orn(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x40004033,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.xnor(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x60001013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.clz(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x60101013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.ctz(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x60201013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.cpop(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x0a006033,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.max(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x0a007033,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.maxu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x0a004033,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.min(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x0a005033,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.minu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x60401013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sextB(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x60501013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sextH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfff0707f,
+		match: 0x0800403b,
+		xlen:  64,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.zextH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x60001033,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rol(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfe00707f,
+		match: 0x60005033,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.ror(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfc00707f,
+		match: 0x60005013,
+		xlen:  64,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rori(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBB extension
+		mask:  0xfff0707f,
+		match: 0x28705013,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.orcB(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfff0707f,
+		match: 0x6b805013,
+		xlen:  64,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rev8(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfff0707f,
+		match: 0x6000101b,
+		xlen:  64,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.clzw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfff0707f,
+		match: 0x6010101b,
+		xlen:  64,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.ctzw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfff0707f,
+		match: 0x6020101b,
+		xlen:  64,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.cpopw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfe00707f,
+		match: 0x6000103b,
+		xlen:  64,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rolw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfe00707f,
+		match: 0x6000503b,
+		xlen:  64,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rorw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBB extension
+		mask:  0xfe00707f,
+		match: 0x6000501b,
+		xlen:  64,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.roriw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBC extension
+		mask:  0xfe00707f,
+		match: 0x0a001033,
+		ext:   EXT_ZBC | EXT_ZBKC,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.clmul(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBC extension
+		mask:  0xfe00707f,
+		match: 0x0a003033,
+		ext:   EXT_ZBC | EXT_ZBKC,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.clmulh(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBC extension
+		mask:  0xfe00707f,
+		match: 0x0a002033,
+		ext:   EXT_ZBC,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.clmulr(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBS extension
+		mask:  0xfe00707f,
+		match: 0x48001033,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bclr(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBS extension
+		mask:  0xfc00707f,
+		match: 0x48001013,
+		xlen:  64,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bclri(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBS extension
+		mask:  0xfe00707f,
+		match: 0x48005033,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bext(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBS extension
+		mask:  0xfc00707f,
+		match: 0x48005013,
+		xlen:  64,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bexti(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBS extension
+		mask:  0xfe00707f,
+		match: 0x68001033,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.binv(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBS extension
+		mask:  0xfc00707f,
+		match: 0x68001013,
+		xlen:  64,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.binvi(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBS extension
+		mask:  0xfe00707f,
+		match: 0x28001033,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bset(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBS extension
+		mask:  0xfc00707f,
+		match: 0x28001013,
+		xlen:  64,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bseti(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x8000707f,
+		match: 0x7057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vsetvli(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0xc000707f,
+		match: 0xc0007057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vsetivli(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0xfe00707f,
+		match: 0x80007057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vsetvl(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x57,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opivv(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x1057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opfvv(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x2057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opmvv(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x3057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opivi(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x4057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opivx(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x5057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opfvf(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x6057,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.opmvx(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x7,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vload(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x5007,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vload(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x6007,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vload(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x7007,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vload(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x27,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vstore(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x5027,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vstore(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x6027,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vstore(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVV extension
+		mask:  0x707f,
+		match: 0x7027,
+		ext:   EXT_V,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.vstore(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBKB extension
+		mask:  0xfe00707f,
+		match: 0x08004033,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.pack(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBKB extension
+		mask:  0xfe00707f,
+		match: 0x08007033,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.packh(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZBKB extension
+		mask:  0xfe00707f,
+		match: 0x0800403b,
+		xlen:  64,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.packw(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBKB extension
+		mask:  0xfff0707f,
+		match: 0x68705013,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.brev8(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBKX extension
+		mask:  0xfe00707f,
+		match: 0x28002033,
+		ext:   EXT_ZBKX,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.xperm4(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZBKX extension
+		mask:  0xfe00707f,
+		match: 0x28004033,
+		ext:   EXT_ZBKX,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.xperm8(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNE extension
+		mask:  0xfe00707f,
+		match: 0x32000033,
+		xlen:  64,
+		ext:   EXT_ZKNE,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64es(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNE extension
+		mask:  0xfe00707f,
+		match: 0x36000033,
+		xlen:  64,
+		ext:   EXT_ZKNE,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64esm(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKND extension
+		mask:  0xfe00707f,
+		match: 0x3a000033,
+		xlen:  64,
+		ext:   EXT_ZKND,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64ds(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKND extension
+		mask:  0xfe00707f,
+		match: 0x3e000033,
+		xlen:  64,
+		ext:   EXT_ZKND,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64dsm(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKND extension
+		mask:  0xfff0707f,
+		match: 0x30001013,
+		xlen:  64,
+		ext:   EXT_ZKND,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64im(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKN extension
+		mask:  0xff00707f,
+		match: 0x31001013,
+		xlen:  64,
+		ext:   EXT_ZKND | EXT_ZKNE,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64ks1i(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKN extension
+		mask:  0xfe00707f,
+		match: 0x7e000033,
+		xlen:  64,
+		ext:   EXT_ZKND | EXT_ZKNE,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.aes64ks2(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10001013,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha256sum0(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10101013,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha256sum1(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10201013,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha256sig0(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10301013,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha256sig1(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10401013,
+		xlen:  64,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha512sum0(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10501013,
+		xlen:  64,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha512sum1(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10601013,
+		xlen:  64,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha512sig0(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZKNH extension
+		mask:  0xfff0707f,
+		match: 0x10701013,
+		xlen:  64,
+		ext:   EXT_ZKNH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sha512sig1(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKSH extension
+		mask:  0xfff0707f,
+		match: 0x10801013,
+		ext:   EXT_ZKSH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sm3p0(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKSH extension
+		mask:  0xfff0707f,
+		match: 0x10901013,
+		ext:   EXT_ZKSH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sm3p1(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKSED extension
+		mask:  0x3e00707f,
+		match: 0x30000033,
+		ext:   EXT_ZKSED,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sm4ed(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZKSED extension
+		mask:  0x3e00707f,
+		match: 0x34000033,
+		ext:   EXT_ZKSED,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.sm4ks(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZICOND extension
+		mask:  0xfe00707f,
+		match: 0x0e005033,
+		ext:   EXT_ZICOND,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.czeroEqz(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZICOND extension
+		mask:  0xfe00707f,
+		match: 0x0e007033,
+		ext:   EXT_ZICOND,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.czeroNez(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZAWRS extension
+		mask:  0xffffffff,
+		match: 0x00d00073,
+		ext:   EXT_ZAWRS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.wrsNto(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZAWRS extension
+		mask:  0xffffffff,
+		match: 0x01d00073,
+		ext:   EXT_ZAWRS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.wrsSto(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0x707f,
+		match: 0x00001007,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.flh(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0x707f,
+		match: 0x00001027,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsh(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0xfff0707f,
+		match: 0xe4000053,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvXH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0xfff0707f,
+		match: 0xf4000053,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvHX(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0xfff0007f,
+		match: 0x40200053,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFHMIN extension
+		mask:  0xfff0007f,
+		match: 0x44000053,
+		ext:   EXT_ZFHMIN,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFHMIN extension
+		mask:  0xfff0007f,
+		match: 0x42200053,
+		ext:   EXT_ZFHMIN,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFHMIN extension
+		mask:  0xfff0007f,
+		match: 0x44100053,
+		ext:   EXT_ZFHMIN,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0x600007f,
+		match: 0x04000043,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaddH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0x600007f,
+		match: 0x04000047,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmsubH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0x600007f,
+		match: 0x0400004b,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmsubH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0x600007f,
+		match: 0x0400004f,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmaddH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00007f,
+		match: 0x04000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.faddH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00007f,
+		match: 0x0c000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsubH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00007f,
+		match: 0x14000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmulH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00007f,
+		match: 0x1c000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fdivH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0007f,
+		match: 0x5c000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsqrtH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0x24000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0x24001053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjnH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0x24002053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjxH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0x2c000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0x2c001053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0xa4002053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.feqH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0xa4001053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfe00707f,
+		match: 0xa4000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0707f,
+		match: 0xe4001053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fclassH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0007f,
+		match: 0xc4000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0007f,
+		match: 0xc4100053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWuH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZFH extension
+		mask:  0xfff0007f,
+		match: 0xc4200053,
+		xlen:  64,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZFH extension
+		mask:  0xfff0007f,
+		match: 0xc4300053,
+		xlen:  64,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLuH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0007f,
+		match: 0xd4000053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH extension
+		mask:  0xfff0007f,
+		match: 0xd4100053,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZFH extension
+		mask:  0xfff0007f,
+		match: 0xd4200053,
+		xlen:  64,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHL(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64ZFH extension
+		mask:  0xfff0007f,
+		match: 0xd4300053,
+		xlen:  64,
+		ext:   EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHLu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfff0707f,
+		match: 0xf0100053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fliS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfe00707f,
+		match: 0x28002053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminmS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfe00707f,
+		match: 0x28003053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxmS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfff0007f,
+		match: 0x40400053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfff0007f,
+		match: 0x40500053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundnxS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfe00707f,
+		match: 0xa0004053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleqS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFA extension
+		mask:  0xfe00707f,
+		match: 0xa0005053,
+		ext:   EXT_ZFA,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltqS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xf2100053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fliD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2a002053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminmD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2a003053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxmD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x42400053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x42500053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundnxD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa2004053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleqD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa2005053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltqD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xf4100053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fliH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2c002053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminmH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2c003053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxmH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x44400053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x44500053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundnxH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa4004053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleqH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVZFH_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa4005053,
+		ext:   EXT_ZFA,
+		needs: EXT_ZFH,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltqH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVD_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xc2801053,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtmodWD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x707f,
+		match: 0x00004007,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.flq(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x707f,
+		match: 0x00004027,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsq(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x600007f,
+		match: 0x06000043,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaddQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x600007f,
+		match: 0x06000047,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmsubQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x600007f,
+		match: 0x0600004b,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmsubQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0x600007f,
+		match: 0x0600004f,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fnmaddQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00007f,
+		match: 0x06000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.faddQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00007f,
+		match: 0x0e000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsubQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00007f,
+		match: 0x16000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmulQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00007f,
+		match: 0x1e000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fdivQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0x5e000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsqrtQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0x26000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0x26001053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjnQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0x26002053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fsgnjxQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0x2e000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0x2e001053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0x40300053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtSQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0x46000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQS(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0x42300053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtDQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0x46100053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0xa6002053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.feqQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0xa6001053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfe00707f,
+		match: 0xa6000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0707f,
+		match: 0xe6001053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fclassQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0xc6000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0xc6100053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtWuQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0xd6000053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ extension
+		mask:  0xfff0007f,
+		match: 0xd6100053,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q extension
+		mask:  0xfff0007f,
+		match: 0xc6200053,
+		xlen:  64,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q extension
+		mask:  0xfff0007f,
+		match: 0xc6300053,
+		xlen:  64,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtLuQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q extension
+		mask:  0xfff0007f,
+		match: 0xd6200053,
+		xlen:  64,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQL(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q extension
+		mask:  0xfff0007f,
+		match: 0xd6300053,
+		xlen:  64,
+		ext:   EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQLu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFH extension
+		mask:  0xfff0007f,
+		match: 0x44300053,
+		ext:   EXT_ZFHMIN,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtHQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFH extension
+		mask:  0xfff0007f,
+		match: 0x46200053,
+		ext:   EXT_ZFHMIN,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fcvtQH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xf6100053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fliQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2e002053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fminmQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfe00707f,
+		match: 0x2e003053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmaxmQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x46400053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfff0007f,
+		match: 0x46500053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.froundnxQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa6004053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fleqQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVQ_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xa6005053,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fltqQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xe6100053,
+		xlen:  64,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvhXQ(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64Q_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xb6000053,
+		xlen:  64,
+		ext:   EXT_ZFA,
+		needs: EXT_Q,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvpQX(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfe007fff,
+		match: 0x22000073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hfenceVvma(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfe007fff,
+		match: 0x62000073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hfenceGvma(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x60004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvB(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x60104073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvBu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x64004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x64104073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvHu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x64304073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvxHu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x68004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfff0707f,
+		match: 0x68304073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvxWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfe007fff,
+		match: 0x62004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hsvB(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfe007fff,
+		match: 0x66004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hsvH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RVH extension
+		mask:  0xfe007fff,
+		match: 0x6a004073,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hsvW(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64H extension
+		mask:  0xfff0707f,
+		match: 0x68104073,
+		xlen:  64,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvWu(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64H extension
+		mask:  0xfff0707f,
+		match: 0x6c004073,
+		xlen:  64,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hlvD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV64H extension
+		mask:  0xfe007fff,
+		match: 0x6e004073,
+		xlen:  64,
+		ext:   EXT_H,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.hsvD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32I extension
+		mask:  0xfe00707f,
+		match: 0x00001013,
+		xlen:  32,
+		ext:   EXT_I,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.slli(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32I extension
+		mask:  0xfe00707f,
+		match: 0x00005013,
+		xlen:  32,
+		ext:   EXT_I,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.srli(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32I extension
+		mask:  0xfe00707f,
+		match: 0x40005013,
+		xlen:  32,
+		ext:   EXT_I,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.srai(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBB extension
+		mask:  0xfe00707f,
+		match: 0x60005013,
+		xlen:  32,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rori(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBB extension
+		mask:  0xfff0707f,
+		match: 0x08004033,
+		xlen:  32,
+		ext:   EXT_ZBB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.zextH(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBB extension
+		mask:  0xfff0707f,
+		match: 0x69805013,
+		xlen:  32,
+		ext:   EXT_ZBB | EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.rev8(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBKB extension
+		mask:  0xfff0707f,
+		match: 0x08f01013,
+		xlen:  32,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.zip(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBKB extension
+		mask:  0xfff0707f,
+		match: 0x08f05013,
+		xlen:  32,
+		ext:   EXT_ZBKB,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.unzip(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBS extension
+		mask:  0xfe00707f,
+		match: 0x48001013,
+		xlen:  32,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bclri(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBS extension
+		mask:  0xfe00707f,
+		match: 0x48005013,
+		xlen:  32,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bexti(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBS extension
+		mask:  0xfe00707f,
+		match: 0x68001013,
+		xlen:  32,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.binvi(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32ZBS extension
+		mask:  0xfe00707f,
+		match: 0x28001013,
+		xlen:  32,
+		ext:   EXT_ZBS,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.bseti(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32D_ZFA extension
+		mask:  0xfff0707f,
+		match: 0xe2100053,
+		xlen:  32,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvhXD(InstWord(inst))
+		},
+	},
+	Instruction{
+		// RV32D_ZFA extension
+		mask:  0xfe00707f,
+		match: 0xb2000053,
+		xlen:  32,
+		ext:   EXT_ZFA,
+		needs: EXT_D,
+		execute: func(cpu *Cpu, inst uint32) {
+			cpu.fmvpDX(InstWord(inst))
 		},
 	},
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	PLIC_BASE uint64 = 0xc000000
+	PLIC_SIZE uint64 = 0x4000000
+
+	PLIC_MAX_SOURCES  = 1024
+	PLIC_MAX_PRIORITY = 7
+
+	PLIC_PRIORITY          uint64 = 0x0
+	PLIC_PENDING           uint64 = 0x1000
+	PLIC_ENABLE            uint64 = 0x2000
+	PLIC_ENABLE_STRIDE     uint64 = 0x80
+	PLIC_CONTEXT           uint64 = 0x200000
+	PLIC_CONTEXT_STRIDE    uint64 = 0x1000
+	PLIC_CONTEXT_CLAIM     uint64 = 0x4
+	PLIC_CONTEXTS_PER_HART        = 2 // M- и S-режимы
+)
+
+// IrqLine is the interrupt output of a device
+type IrqLine interface {
+	Interrupting() bool
+}
+
+// Plic is a SiFive-compatible platform-level interrupt controller.
+// Context 2*hart handles M-mode external interrupts of the hart,
+// context 2*hart+1 handles S-mode ones.
+type Plic struct {
+	mu        sync.Mutex
+	sources   int
+	lines     []IrqLine // источник i подключён к lines[i]
+	priority  []uint32
+	pending   []bool
+	inFlight  []bool // запрос выдан через claim и ещё не завершён
+	enable    [][]uint32
+	threshold []uint32
+}
+
+// NewPlic creates PLIC with source IDs 1..sources-1 and
+// contexts for the given number of harts
+func NewPlic(sources int, harts int) *Plic {
+	if sources > PLIC_MAX_SOURCES {
+		sources = PLIC_MAX_SOURCES
+	}
+	contexts := harts * PLIC_CONTEXTS_PER_HART
+	p := &Plic{
+		sources:   sources,
+		lines:     make([]IrqLine, sources),
+		priority:  make([]uint32, sources),
+		pending:   make([]bool, sources),
+		inFlight:  make([]bool, sources),
+		enable:    make([][]uint32, contexts),
+		threshold: make([]uint32, contexts),
+	}
+	for i := range p.enable {
+		p.enable[i] = make([]uint32, (sources+31)/32)
+	}
+	return p
+}
+
+// Connect attaches device interrupt output to source id
+func (p *Plic) Connect(id int, line IrqLine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id > 0 && id < p.sources {
+		p.lines[id] = line
+	}
+}
+
+// sample latches pending bits of level-triggered sources
+func (p *Plic) sample() {
+	for id := 1; id < p.sources; id++ {
+		if line := p.lines[id]; line != nil && !p.inFlight[id] && line.Interrupting() {
+			p.pending[id] = true
+		}
+	}
+}
+
+func (p *Plic) enabled(ctx int, id int) bool {
+	return p.enable[ctx][id/32]&(1<<(id%32)) != 0
+}
+
+// best returns the highest priority pending interrupt of the context
+// above its threshold, ties go to the lowest ID
+func (p *Plic) best(ctx int) int {
+	best, prio := 0, p.threshold[ctx]
+	for id := 1; id < p.sources; id++ {
+		if p.pending[id] && p.priority[id] > prio && p.enabled(ctx, id) {
+			best, prio = id, p.priority[id]
+		}
+	}
+	return best
+}
+
+// Pending returns MEIP/SEIP bits that PLIC drives into mip of the hart
+func (p *Plic) Pending(hart uint64) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ctx := int(hart) * PLIC_CONTEXTS_PER_HART
+	if ctx+1 >= len(p.enable) {
+		return 0
+	}
+	p.sample()
+	var mip uint64
+	if p.best(ctx) != 0 {
+		mip |= MIP_MEIP
+	}
+	if p.best(ctx+1) != 0 {
+		mip |= MIP_SEIP
+	}
+	return mip
+}
+
+func (p *Plic) claim(ctx int) uint32 {
+	p.sample()
+	id := p.best(ctx)
+	if id != 0 {
+		p.pending[id] = false
+		p.inFlight[id] = true
+	}
+	return uint32(id)
+}
+
+func (p *Plic) complete(ctx int, id uint32) {
+	if id > 0 && int(id) < p.sources && p.enabled(ctx, int(id)) {
+		p.inFlight[id] = false
+	}
+}
+
+func (p *Plic) Read(addr uint64, size uint8) (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case addr < PLIC_PENDING:
+		if id := addr / 4; id < uint64(p.sources) {
+			return uint64(p.priority[id]), nil
+		}
+	case addr < PLIC_ENABLE:
+		p.sample()
+		var word uint64
+		for i := 0; i < 32; i++ {
+			if id := int(addr-PLIC_PENDING)/4*32 + i; id < p.sources && p.pending[id] {
+				word |= 1 << i
+			}
+		}
+		return word, nil
+	case addr < PLIC_CONTEXT:
+		ctx := int((addr - PLIC_ENABLE) / PLIC_ENABLE_STRIDE)
+		word := int((addr - PLIC_ENABLE) % PLIC_ENABLE_STRIDE / 4)
+		if ctx < len(p.enable) && word < len(p.enable[ctx]) {
+			return uint64(p.enable[ctx][word]), nil
+		}
+	default:
+		ctx := int((addr - PLIC_CONTEXT) / PLIC_CONTEXT_STRIDE)
+		if ctx >= len(p.threshold) {
+			return 0, nil
+		}
+		switch (addr - PLIC_CONTEXT) % PLIC_CONTEXT_STRIDE {
+		case 0:
+			return uint64(p.threshold[ctx]), nil
+		case PLIC_CONTEXT_CLAIM:
+			return uint64(p.claim(ctx)), nil
+		}
+	}
+	return 0, nil
+}
+
+func (p *Plic) Write(addr uint64, value uint64, size uint8) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case addr < PLIC_PENDING:
+		if id := addr / 4; id > 0 && id < uint64(p.sources) {
+			p.priority[id] = uint32(value) & PLIC_MAX_PRIORITY
+		}
+	case addr < PLIC_ENABLE:
+		// биты ожидания только для чтения
+	case addr < PLIC_CONTEXT:
+		ctx := int((addr - PLIC_ENABLE) / PLIC_ENABLE_STRIDE)
+		word := int((addr - PLIC_ENABLE) % PLIC_ENABLE_STRIDE / 4)
+		if ctx < len(p.enable) && word < len(p.enable[ctx]) {
+			data := uint32(value)
+			if word == 0 {
+				data &^= 1 // источник 0 не существует
+			}
+			if last := p.sources - word*32; last < 32 {
+				data &= 1<<last - 1
+			}
+			p.enable[ctx][word] = data
+		}
+	default:
+		ctx := int((addr - PLIC_CONTEXT) / PLIC_CONTEXT_STRIDE)
+		if ctx >= len(p.threshold) {
+			return nil
+		}
+		switch (addr - PLIC_CONTEXT) % PLIC_CONTEXT_STRIDE {
+		case 0:
+			p.threshold[ctx] = uint32(value) & PLIC_MAX_PRIORITY
+		case PLIC_CONTEXT_CLAIM:
+			p.complete(ctx, uint32(value))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+type testLine struct{ level bool }
+
+func (l *testLine) Interrupting() bool { return l.level }
+
+func TestPlicClaimComplete(t *testing.T) {
+	plic := NewPlic(32, 1)
+	uart, disk := &testLine{}, &testLine{}
+	plic.Connect(10, uart)
+	plic.Connect(1, disk)
+	plic.Write(PLIC_PRIORITY+4*10, 3, WORD)
+	plic.Write(PLIC_PRIORITY+4*1, 3, WORD)
+	plic.Write(PLIC_ENABLE, 1<<10|1<<1|1, WORD) // контекст 0: M-режим хата 0
+
+	if en, _ := plic.Read(PLIC_ENABLE, WORD); en != 1<<10|1<<1 {
+		t.Fatalf("enable=%#x, source 0 must not be enabled", en)
+	}
+	if plic.Pending(0) != 0 {
+		t.Fatalf("no interrupts expected")
+	}
+	uart.level, disk.level = true, true
+	if plic.Pending(0) != MIP_MEIP {
+		t.Fatalf("MEIP expected, got %#x", plic.Pending(0))
+	}
+	if pending, _ := plic.Read(PLIC_PENDING, WORD); pending != 1<<10|1<<1 {
+		t.Fatalf("pending=%#x", pending)
+	}
+
+	// при равном приоритете выигрывает меньший ID
+	claim := PLIC_CONTEXT + PLIC_CONTEXT_CLAIM
+	if id, _ := plic.Read(claim, WORD); id != 1 {
+		t.Fatalf("claimed %d, want 1", id)
+	}
+	if id, _ := plic.Read(claim, WORD); id != 10 {
+		t.Fatalf("claimed %d, want 10", id)
+	}
+	if id, _ := plic.Read(claim, WORD); id != 0 || plic.Pending(0) != 0 {
+		t.Fatalf("claimed %d, sources in flight must not be pending", id)
+	}
+	plic.Write(claim, 10, WORD)
+	if id, _ := plic.Read(claim, WORD); id != 10 {
+		t.Fatalf("after completion source 10 must be pending again, claimed %d", id)
+	}
+
+	plic.Write(claim, 10, WORD)
+	plic.Write(PLIC_CONTEXT, 3, WORD) // порог
+	if plic.Pending(0) != 0 {
+		t.Fatalf("priority 3 must be masked by threshold 3")
+	}
+}
+
+func TestPlicExternalInterrupt(t *testing.T) {
+	cpu := NewCPU()
+	allowAllPMP(cpu)
+	plic := NewPlic(32, 1)
+	cpu.bus.Map("plic", PLIC_BASE, PLIC_SIZE, plic)
+	cpu.ConnectInterrupts(plic)
+	line := &testLine{level: true}
+	plic.Connect(5, line)
+
+	// драйвер S-режима: контекст 1
+	plic.Write(PLIC_PRIORITY+4*5, 1, WORD)
+	plic.Write(PLIC_ENABLE+PLIC_ENABLE_STRIDE, 1<<5, WORD)
+	cpu.writeCSR(MIDELEG, MIP_SEIP)
+	cpu.csr[MIE] = MIP_SEIP
+	cpu.csr[STVEC] = 0x80002000
+	cpu.writeCSR(SSTATUS, MSTATUS_SIE)
+	cpu.privilege = SUPERVISOR_MODE
+
+	cpu.Step()
+	if cpu.pc != 0x80002000 || cpu.readCSR(SCAUSE) != CAUSE_INTERRUPT|SUPERVISOR_EXTERNAL_INTERRUPT {
+		t.Fatalf("pc=%#x scause=%#x", cpu.pc, cpu.readCSR(SCAUSE))
+	}
+
+	cpu.writeReg(1, PLIC_BASE+PLIC_CONTEXT+PLIC_CONTEXT_STRIDE+PLIC_CONTEXT_CLAIM)
+	cpu.ExecuteInst(0x0000a103) // lw x2, 0(x1)
+	if cpu.readReg(2) != 5 {
+		t.Fatalf("claimed %d, want 5", cpu.readReg(2))
+	}
+	line.level = false
+	cpu.ExecuteInst(0x0020a023) // sw x2, 0(x1)
+	cpu.updateInterrupts()
+	if cpu.readCSR(SIP)&MIP_SEIP != 0 {
+		t.Fatalf("SEIP must be cleared after completion")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+const (
+	PMP_MAX_ENTRIES     = 64
+	PMP_DEFAULT_ENTRIES = 16
+
+	PMPCFG0   uint64 = 0x3a0
+	PMPCFG15  uint64 = 0x3af
+	PMPADDR0  uint64 = 0x3b0
+	PMPADDR1  uint64 = 0x3b1
+	PMPADDR63 uint64 = 0x3ef
+)
+
+// pmpcfg fields
+const (
+	PMP_R     uint8 = 1 << 0
+	PMP_W     uint8 = 1 << 1
+	PMP_X     uint8 = 1 << 2
+	PMP_A     uint8 = 3 << 3
+	PMP_L     uint8 = 1 << 7
+	PMP_OFF   uint8 = 0 << 3
+	PMP_TOR   uint8 = 1 << 3
+	PMP_NA4   uint8 = 2 << 3
+	PMP_NAPOT uint8 = 3 << 3
+
+	PMP_CFG_MASK  uint8  = PMP_R | PMP_W | PMP_X | PMP_A | PMP_L
+	PMP_ADDR_MASK uint64 = (1 << 54) - 1
+)
+
+// pmpRegion is the address range [lo, hi) of an active entry
+type pmpRegion struct {
+	index int
+	cfg   uint8
+	lo    uint64
+	hi    uint64
+}
+
+// PMP is the physical memory protection unit
+type PMP struct {
+	entries int // количество реализованных записей
+	cfg     [PMP_MAX_ENTRIES]uint8
+	addr    [PMP_MAX_ENTRIES]uint64
+	regions []pmpRegion // активные записи в порядке приоритета
+	locked  bool        // есть записи, ограничивающие M-режим
+}
+
+func NewPMP(entries int) PMP {
+	if entries > PMP_MAX_ENTRIES {
+		entries = PMP_MAX_ENTRIES
+	}
+	return PMP{entries: entries}
+}
+
+func (p *PMP) isLocked(i int) bool {
+	if p.cfg[i]&PMP_L != 0 {
+		return true
+	}
+	// адрес, служащий нижней границей заблокированной TOR-записи
+	return i+1 < p.entries && p.cfg[i+1]&PMP_L != 0 && p.cfg[i+1]&PMP_A == PMP_TOR
+}
+
+// readCfg returns pmpcfgN register, on RV64 only even N exist
+// and each of them holds 8 entries
+func (p *PMP) readCfg(n uint64) uint64 {
+	if n%2 != 0 {
+		return 0
+	}
+	var data uint64
+	for i := 0; i < 8; i++ {
+		if e := int(n*4) + i; e < p.entries {
+			data |= uint64(p.cfg[e]) << (8 * i)
+		}
+	}
+	return data
+}
+
+func (p *PMP) writeCfg(n uint64, data uint64) {
+	if n%2 != 0 {
+		return
+	}
+	for i := 0; i < 8; i++ {
+		e := int(n*4) + i
+		if e >= p.entries || p.cfg[e]&PMP_L != 0 {
+			continue
+		}
+		cfg := uint8(data>>(8*i)) & PMP_CFG_MASK
+		// W=1 при R=0 зарезервировано
+		if cfg&(PMP_R|PMP_W) == PMP_W {
+			cfg &^= PMP_W
+		}
+		p.cfg[e] = cfg
+	}
+	p.update()
+}
+
+func (p *PMP) readAddr(n uint64) uint64 {
+	if int(n) >= p.entries {
+		return 0
+	}
+	return p.addr[n]
+}
+
+func (p *PMP) writeAddr(n uint64, data uint64) {
+	if int(n) >= p.entries || p.isLocked(int(n)) {
+		return
+	}
+	p.addr[n] = data & PMP_ADDR_MASK
+	p.update()
+}
+
+// update recomputes address ranges of active entries
+func (p *PMP) update() {
+	p.regions = p.regions[:0]
+	p.locked = false
+	for i := 0; i < p.entries; i++ {
+		cfg := p.cfg[i]
+		r := pmpRegion{index: i, cfg: cfg}
+		switch cfg & PMP_A {
+		case PMP_OFF:
+			continue
+		case PMP_TOR:
+			if i > 0 {
+				r.lo = p.addr[i-1] << 2
+			}
+			r.hi = p.addr[i] << 2
+		case PMP_NA4:
+			r.lo = p.addr[i] << 2
+			r.hi = r.lo + 4
+		case PMP_NAPOT:
+			// число младших единиц задаёт размер 2^(t+3)
+			t := uint64(0)
+			for t < 54 && (p.addr[i]>>t)&1 == 1 {
+				t++
+			}
+			r.lo = (p.addr[i] &^ (1<<t - 1)) << 2
+			r.hi = r.lo + 1<<(t+3)
+		}
+		if cfg&PMP_L != 0 {
+			p.locked = true
+		}
+		p.regions = append(p.regions, r)
+	}
+}
+
+// check reports whether the access of size bits at addr is permitted.
+// The lowest-numbered entry matching any byte decides, an access that
+// matches it only partially fails.
+func (p *PMP) check(addr uint64, size uint8, access AccessType, priv PrivMode) bool {
+	if priv == MACHINE_MODE && !p.locked {
+		return true
+	}
+	end := addr + uint64(size/8)
+	for _, r := range p.regions {
+		if end <= r.lo || addr >= r.hi {
+			continue
+		}
+		if addr < r.lo || end > r.hi {
+			return false
+		}
+		if priv == MACHINE_MODE && r.cfg&PMP_L == 0 {
+			return true
+		}
+		switch access {
+		case ACCESS_FETCH:
+			return r.cfg&PMP_X != 0
+		case ACCESS_LOAD:
+			return r.cfg&PMP_R != 0
+		default:
+			return r.cfg&PMP_W != 0
+		}
+	}
+	// если ни одна запись не подошла, доступ разрешён только M-режиму
+	return priv == MACHINE_MODE || p.entries == 0
+}
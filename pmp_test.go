@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+// allowAllPMP opens the whole physical memory to S/U-modes like firmware does
+func allowAllPMP(cpu *Cpu) {
+	cpu.writeCSR(PMPADDR0, PMP_ADDR_MASK)
+	cpu.writeCSR(PMPCFG0, uint64(PMP_NAPOT|PMP_R|PMP_W|PMP_X))
+}
+
+func TestPMPRegions(t *testing.T) {
+	cpu := NewCPU()
+	cpu.pmp = NewPMP(PMP_MAX_ENTRIES)
+	cpu.writeCSR(PMPADDR0, 0x80001000>>2)
+	cpu.writeCSR(PMPADDR1, 0x80002000>>2)                  // TOR [0x80001000, 0x80002000)
+	cpu.writeCSR(PMPADDR0+2, 0x80003000>>2)                // NA4 [0x80003000, 0x80003004)
+	cpu.writeCSR(PMPADDR0+3, (0x80004000|(0x1000/2-1))>>2) // NAPOT 4KiB
+	cpu.writeCSR(PMPADDR63, PMP_ADDR_MASK)                 // NAPOT на всё пространство
+	cpu.writeCSR(PMPCFG0, uint64(PMP_TOR|PMP_R|PMP_W)<<8|
+		uint64(PMP_NA4|PMP_R|PMP_W|PMP_X)<<16|uint64(PMP_NAPOT|PMP_X)<<24)
+	cpu.writeCSR(PMPCFG0+14, uint64(PMP_NAPOT|PMP_R)<<56)
+
+	tests := []struct {
+		addr   uint64
+		size   uint8
+		access AccessType
+		ok     bool
+	}{
+		{0x80001000, DOUBLEWORD, ACCESS_STORE, true},
+		{0x80001ffc, DOUBLEWORD, ACCESS_LOAD, false}, // частичное совпадение
+		{0x80001ff8, DOUBLEWORD, ACCESS_FETCH, false},
+		{0x80003000, WORD, ACCESS_FETCH, true},
+		{0x80003004, WORD, ACCESS_FETCH, false},
+		{0x80004ffc, WORD, ACCESS_FETCH, true},
+		{0x80004ffc, WORD, ACCESS_LOAD, false},
+		{0x80005000, WORD, ACCESS_LOAD, true}, // последняя запись
+		{0x80005000, WORD, ACCESS_STORE, false},
+	}
+	for _, test := range tests {
+		if got := cpu.pmp.check(test.addr, test.size, test.access, USER_MODE); got != test.ok {
+			t.Fatalf("addr %#x access %d: got %v, want %v", test.addr, test.access, got, test.ok)
+		}
+		if !cpu.pmp.check(test.addr, test.size, test.access, MACHINE_MODE) {
+			t.Fatalf("addr %#x: unlocked entries must not restrict M-mode", test.addr)
+		}
+	}
+	if cfg := cpu.readCSR(PMPCFG0) >> 8 & 0xff; cfg != uint64(PMP_TOR|PMP_R|PMP_W) {
+		t.Fatalf("pmp1cfg=%#x", cfg)
+	}
+}
+
+func TestPMPLock(t *testing.T) {
+	cpu := NewCPU()
+	cpu.writeCSR(PMPADDR0, 0x80001000>>2)
+	cpu.writeCSR(PMPADDR1, 0x80002000>>2)
+	cpu.writeCSR(PMPCFG0, uint64(PMP_L|PMP_TOR|PMP_R)<<8)
+
+	cpu.writeCSR(PMPADDR0, 0)
+	cpu.writeCSR(PMPADDR1, 0)
+	cpu.writeCSR(PMPCFG0, 0)
+	if cpu.readCSR(PMPADDR0) != 0x80001000>>2 || cpu.readCSR(PMPADDR1) != 0x80002000>>2 || cpu.readCSR(PMPCFG0) == 0 {
+		t.Fatalf("locked TOR entry must ignore writes to its cfg and both addresses")
+	}
+
+	cpu.csr[MTVEC] = 0x80000100
+	cpu.writeReg(1, 0x80001000)
+	cpu.ExecuteInst(0x0000b023) // sd x0, 0(x1)
+	if cpu.csr[MCAUSE] != uint64(STORE_AMO_ACCESS_FAULT) || cpu.csr[MTVAL] != 0x80001000 {
+		t.Fatalf("locked entry must restrict M-mode: mcause=%d mtval=%#x", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+}
+
+func TestPMPUserAccessFault(t *testing.T) {
+	cpu := NewCPU()
+	cpu.writeCSR(PMPADDR0, (0x80000000|(0x1000/2-1))>>2)
+	cpu.writeCSR(PMPCFG0, uint64(PMP_NAPOT|PMP_R|PMP_W|PMP_X))
+	cpu.csr[MTVEC] = 0x80000100
+	cpu.privilege = USER_MODE
+
+	cpu.writeReg(1, 0x80000800)
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if cpu.privilege != USER_MODE {
+		t.Fatalf("access inside PMP region must succeed, mcause=%d", cpu.csr[MCAUSE])
+	}
+	cpu.writeReg(1, 0x80001000)
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if cpu.csr[MCAUSE] != uint64(LOAD_ACCESS_FAULT) || cpu.csr[MTVAL] != 0x80001000 {
+		t.Fatalf("mcause=%d mtval=%#x, want load access fault", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+}
+
+func TestPMPPageTableAccess(t *testing.T) {
+	cpu := NewCPU()
+	pt := newPageTables(cpu, SATP_MODE_SV39)
+	pt.mapPage(0x1000, 0x80200000, PTE_R|PTE_W|PTE_A|PTE_D, 0)
+	pt.mapPage(0x2000, 0x80201000, PTE_R|PTE_X|PTE_A, 0)
+	pt.mapPage(0x3000, 0x80202000, PTE_R|PTE_W|PTE_A, 0)
+	// таблицы страниц только для чтения, остальная память открыта
+	cpu.writeCSR(PMPADDR0, (0x80100000|(0x10000/2-1))>>2)
+	cpu.writeCSR(PMPADDR1, PMP_ADDR_MASK)
+	cpu.writeCSR(PMPCFG0, uint64(PMP_NAPOT|PMP_R)|uint64(PMP_NAPOT|PMP_R|PMP_W|PMP_X)<<8)
+	cpu.csr[MTVEC] = 0x80000100
+	cpu.privilege = SUPERVISOR_MODE
+
+	cpu.writeReg(1, 0x1000)
+	cpu.ExecuteInst(0x0000b423) // sd x0, 8(x1)
+	if cpu.privilege != SUPERVISOR_MODE {
+		t.Fatalf("store must read PTEs as loads: mcause=%d mtval=%#x", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+
+	cpu.bus.Write(0x80201000, 0x00100293, WORD) // addi x5, x0, 1
+	cpu.pc = 0x2000
+	cpu.Step()
+	if cpu.privilege != SUPERVISOR_MODE || cpu.pc != 0x2004 || cpu.readReg(5) != 1 {
+		t.Fatalf("fetch must read PTEs as loads: mcause=%d mtval=%#x", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+
+	// установка бита D - сохранение в таблицу страниц
+	cpu.writeReg(1, 0x3000)
+	cpu.ExecuteInst(0x0000b423) // sd x0, 8(x1)
+	if cpu.csr[MCAUSE] != uint64(STORE_AMO_ACCESS_FAULT) || cpu.csr[MTVAL] != 0x3008 {
+		t.Fatalf("mcause=%d mtval=%#x, want store access fault", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// withExtensions returns the mandatory extensions of a profile built on base
+func withExtensions(base []string, exts ...string) []string {
+	return append(append([]string{}, base...), exts...)
+}
+
+var (
+	rva20u64 = []string{"i", "m", "a", "f", "d", "c", "zicsr", "zicntr",
+		"ziccif", "ziccrse", "ziccamoa", "za128rs", "zicclsm"}
+	rva22u64 = withExtensions(rva20u64, "zihpm", "zihintpause", "zba", "zbb", "zbs",
+		"za64rs", "zic64b", "zicbom", "zicbop", "zicboz", "zfhmin", "zkt")
+	rva23u64 = withExtensions(rva22u64, "v", "zvfhmin", "zvbb", "zvkt", "zihintntl",
+		"zicond", "zimop", "zcmop", "zcb", "zfa", "zawrs", "supm")
+	rvb23u64 = []string{"i", "m", "a", "f", "d", "c", "zicsr", "zicntr", "zihpm",
+		"ziccif", "ziccrse", "ziccamoa", "zicclsm", "za64rs", "zihintpause", "zba", "zbb",
+		"zbs", "zic64b", "zicbom", "zicbop", "zicboz", "zkt", "zihintntl", "zicond",
+		"zimop", "zcmop", "zcb", "zfa", "zawrs"}
+	// супервизорные расширения профилей RVA23S64 и RVB23S64
+	rv23s64 = []string{"zifencei", "ss1p13", "svbare", "sv39", "svade", "ssccptr",
+		"sstvecd", "sstvala", "sscounterenw", "svpbmt", "svinval", "svnapot", "sstc",
+		"sscofpmf", "ssu64xl"}
+)
+
+// PROFILES lists the mandatory extensions of the RV64 profiles
+var PROFILES = map[string][]string{
+	"RVI20U64": {"i"},
+	"RVA20U64": rva20u64,
+	"RVA22U64": rva22u64,
+	"RVA22S64": withExtensions(rva22u64, "zifencei", "ss1p12", "svbare", "sv39", "svade",
+		"ssccptr", "sstvecd", "sstvala", "sscounterenw", "svpbmt", "svinval"),
+	"RVA23U64": rva23u64,
+	"RVA23S64": withExtensions(withExtensions(rva23u64, rv23s64...), "ssnpm", "h",
+		"ssstateen", "shcounterenw", "shvstvala", "shtvala", "shvstvecd", "shvsatpa", "shgatpa"),
+	"RVB23U64": rvb23u64,
+	"RVB23S64": withExtensions(rvb23u64, rv23s64...),
+}
+
+// ConfigureProfile configures an RV64 hart with exactly the mandatory
+// extensions of a profile such as RVA22U64. Mandatory extensions the
+// emulator does not implement are reported as an error and the hart
+// is left unchanged. On success the hart is reset.
+func (cpu *Cpu) ConfigureProfile(name string) error {
+	mandatory, ok := PROFILES[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("Unknown profile %q", name)
+	}
+	var exts Extension
+	var missing []string
+	for _, ext := range mandatory {
+		bits, ok := lookupExtension(ext)
+		if !ok {
+			missing = append(missing, ext)
+		}
+		exts |= bits
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("Profile %s requires unsupported extensions: %s",
+			strings.ToUpper(name), strings.Join(missing, ", "))
+	}
+	cpu.mxlen = 64
+	cpu.flen = 64
+	cpu.exts = withDependencies(exts)
+	cpu.reset()
+	return nil
+}
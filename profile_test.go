@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSupportedProfiles(t *testing.T) {
+	tests := []struct {
+		profile string
+		misa    string
+		legal   uint32 // инструкция, доступная в профиле
+		illegal uint32 // инструкция вне профиля
+	}{
+		{"RVI20U64", "ISU", 0x00000013, rInst(0x01, 0, 0x33)},                // nop, mul
+		{"rva20u64", "IMAFDCSU", rInst(0x01, 0, 0x33), rInst(0x10, 2, 0x33)}, // mul, sh1add
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		if err := cpu.ConfigureProfile(tt.profile); err != nil {
+			t.Fatalf("%s: %v", tt.profile, err)
+		}
+		want := XL_64 << MISA_MXL_SHIFT
+		for _, letter := range tt.misa {
+			want |= misaExt(byte(letter))
+		}
+		if cpu.csr[MISA] != want {
+			t.Errorf("%s: misa=%#x, want %#x", tt.profile, cpu.csr[MISA], want)
+		}
+		if cpu.ExecuteInst(tt.legal); cpu.exception != nil {
+			t.Errorf("%s: %#x trapped", tt.profile, tt.legal)
+		}
+		if cpu.ExecuteInst(tt.illegal); cpu.exception == nil {
+			t.Errorf("%s: %#x executed", tt.profile, tt.illegal)
+		}
+	}
+}
+
+func TestUnsupportedProfiles(t *testing.T) {
+	tests := []struct {
+		profile string
+		missing []string
+	}{
+		{"RVA22U64", []string{"zicbom", "zicboz"}},
+		{"RVA22S64", []string{"zicbom", "ss1p12", "svade", "svpbmt", "svinval"}},
+		{"RVA23U64", []string{"zvfhmin", "zvbb", "zimop", "zcb", "supm"}},
+		{"RVA23S64", []string{"sstc", "ssstateen", "shgatpa"}},
+		{"RVB23U64", []string{"zcmop"}},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		err := cpu.ConfigureProfile(tt.profile)
+		if err == nil {
+			t.Errorf("%s: configured with unsupported extensions", tt.profile)
+			continue
+		}
+		for _, ext := range tt.missing {
+			if !strings.Contains(err.Error(), ext) {
+				t.Errorf("%s: %q not reported in %v", tt.profile, ext, err)
+			}
+		}
+		if strings.Contains(err.Error(), "zba") {
+			t.Errorf("%s: supported extension reported in %v", tt.profile, err)
+		}
+		if cpu.exts != DEFAULT_EXTENSIONS {
+			t.Errorf("%s: hart changed by a failed configuration", tt.profile)
+		}
+	}
+	if err := NewCPU().ConfigureProfile("RVA99U64"); err == nil {
+		t.Errorf("unknown profile accepted")
+	}
+}
+
+func TestISAProperties(t *testing.T) {
+	if _, _, err := ParseISA("rv64imafdc_zicsr_ziccif_zicclsm_za64rs"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import "fmt"
+
+// Encodings of XLEN in misa.MXL, mstatus.SXL and mstatus.UXL
+const (
+	XL_32 uint64 = 1
+	XL_64 uint64 = 2
+)
+
+// Sv32 layout of satp seen with SXLEN=32. Internally satp is kept
+// in the RV64 layout with MODE = SATP_MODE_SV32.
+const (
+	SATP32_MODE       uint64 = 1 << 31
+	SATP32_ASID_SHIFT uint64 = 22
+	SATP32_ASID       uint64 = 0x1ff << SATP32_ASID_SHIFT
+	SATP32_PPN        uint64 = 1<<22 - 1
+)
+
+// ConfigureXLEN sets MXLEN: 64 for RV64 or 32 for RV32. RV32 harts
+// drop the extensions of RV32_UNSUPPORTED, all their modes are RV32.
+// The hart is reset.
+func (cpu *Cpu) ConfigureXLEN(xlen uint64) error {
+	if xlen != 32 && xlen != 64 {
+		return fmt.Errorf("Unsupported XLEN %d", xlen)
+	}
+	cpu.mxlen = xlen
+	if xlen == 32 {
+		cpu.exts &^= RV32_UNSUPPORTED
+	}
+	cpu.reset()
+	return nil
+}
+
+// resetXLEN sets misa.MXL and the reset values of mstatus.SXL/UXL
+func (cpu *Cpu) resetXLEN() {
+	misa := cpu.csr[MISA] &^ (3 << MISA_MXL_SHIFT)
+	if cpu.mxlen == 32 {
+		cpu.csr[MISA] = misa&^misaExt('H') | XL_32<<MISA_MXL_SHIFT
+		cpu.csr[HSTATUS] = 0
+	} else {
+		cpu.csr[MISA] = misa | XL_64<<MISA_MXL_SHIFT
+		cpu.csr[MSTATUS] |= XL_64<<MSTATUS_SXL_SHIFT | XL_64<<MSTATUS_UXL_SHIFT
+	}
+	cpu.updateXLEN()
+}
+
+// xlenOf returns XLEN of a privilege mode: MXLEN for M-mode, mstatus.SXL
+// for HS-mode and mstatus.UXL for U-mode. VS- and VU-modes are always RV64.
+func (cpu *Cpu) xlenOf(priv PrivMode, virt bool) uint64 {
+	var xl uint64
+	switch {
+	case priv == MACHINE_MODE || cpu.mxlen == 32:
+		return cpu.mxlen
+	case virt:
+		return 64
+	case priv == SUPERVISOR_MODE:
+		xl = (cpu.csr[MSTATUS] & MSTATUS_SXL) >> MSTATUS_SXL_SHIFT
+	default:
+		xl = (cpu.csr[MSTATUS] & MSTATUS_UXL) >> MSTATUS_UXL_SHIFT
+	}
+	if xl == XL_32 {
+		return 32
+	}
+	return 64
+}
+
+// updateXLEN switches to XLEN of the current mode before an instruction,
+// in RV32 pc keeps only the low 32 bits
+func (cpu *Cpu) updateXLEN() {
+	cpu.xlen = cpu.xlenOf(cpu.privilege, cpu.virt)
+	cpu.pc = cpu.truncAddr(cpu.pc)
+}
+
+// zextXLEN zero-extends a register value from XLEN bits
+func (cpu *Cpu) zextXLEN(val uint64) uint64 {
+	if cpu.xlen == 32 {
+		return uint64(uint32(val))
+	}
+	return val
+}
+
+// truncAddr drops the bits of an effective address above XLEN
+func (cpu *Cpu) truncAddr(addr uint64) uint64 {
+	return cpu.zextXLEN(addr)
+}
+
+// legalizeXL keeps mstatus.SXL and UXL legal on writes: both are zero
+// when MXLEN=32, otherwise they hold 32 or 64. SXL is fixed to 64 with
+// the hypervisor extension, whose VS-mode is always RV64.
+func (cpu *Cpu) legalizeXL(old, status uint64) uint64 {
+	if cpu.mxlen == 32 {
+		return status &^ (MSTATUS_SXL | MSTATUS_UXL)
+	}
+	legal := func(mask, shift uint64) bool {
+		xl := (status & mask) >> shift
+		return xl == XL_32 || xl == XL_64
+	}
+	if !legal(MSTATUS_UXL, MSTATUS_UXL_SHIFT) {
+		status = status&^MSTATUS_UXL | old&MSTATUS_UXL
+	}
+	if cpu.csr[MISA]&misaExt('H') != 0 || !legal(MSTATUS_SXL, MSTATUS_SXL_SHIFT) {
+		status = status&^MSTATUS_SXL | old&MSTATUS_SXL
+	}
+	return status
+}
+
+// highHalfOf returns the 64-bit CSR whose upper 32 bits are accessed
+// through an RV32-only csr
+func highHalfOf(csr uint64) (uint64, bool) {
+	switch {
+	case csr == MSTATUSH:
+		return MSTATUS, true
+	case csr >= MCYCLEH && csr <= MHPMCOUNTER31H, csr >= CYCLEH && csr <= HPMCOUNTER31H:
+		return csr - (MCYCLEH - MCYCLE), true
+	}
+	return 0, false
+}
+
+// readCSRView reads csr as csr instructions see it at the current XLEN.
+// RV32 sees the low halves of 64-bit CSRs and reaches the upper ones
+// through *h CSRs; SD, the interrupt bit of xcause and misa.MXL move
+// to the top of 32 bits. satp follows SXLEN rather than the current XLEN.
+func (cpu *Cpu) readCSRView(csr uint64) uint64 {
+	if cpu.virtualAlias(csr) == SATP && cpu.xlenOf(SUPERVISOR_MODE, false) == 32 {
+		return satpToSv32(cpu.readCSR(csr))
+	}
+	if cpu.xlen != 32 {
+		return cpu.readCSR(csr)
+	}
+	if base, ok := highHalfOf(csr); ok {
+		return cpu.readCSR(base) >> 32
+	}
+	if csr >= PMPCFG0 && csr <= PMPCFG15 && csr&1 != 0 {
+		// нечётные pmpcfg RV32 - старшие половины чётных pmpcfg RV64
+		return cpu.readCSR(csr-1) >> 32
+	}
+	data := cpu.readCSR(csr)
+	switch cpu.virtualAlias(csr) {
+	case MSTATUS, SSTATUS, MCAUSE, SCAUSE:
+		return uint64(uint32(data)) | data>>63<<31
+	case MISA:
+		return uint64(uint32(data)) | data>>MISA_MXL_SHIFT<<30
+	}
+	return uint64(uint32(data))
+}
+
+// writeCSRView writes csr as csr instructions do at the current XLEN.
+// In RV32 writes to the low half of mstatus, counters and pmpcfg keep
+// their upper half.
+func (cpu *Cpu) writeCSRView(csr uint64, data uint64) {
+	if cpu.virtualAlias(csr) == SATP && cpu.xlenOf(SUPERVISOR_MODE, false) == 32 {
+		cpu.csr[cpu.virtualAlias(csr)] = satpFromSv32(data)
+		return
+	}
+	if cpu.xlen != 32 {
+		cpu.writeCSR(csr, data)
+		return
+	}
+	data = uint64(uint32(data))
+	if base, ok := highHalfOf(csr); ok {
+		cpu.writeCSR(base, cpu.readCSR(base)&0xffffffff|data<<32)
+		return
+	}
+	if csr >= PMPCFG0 && csr <= PMPCFG15 && csr&1 != 0 {
+		cpu.writeCSR(csr-1, cpu.readCSR(csr-1)&0xffffffff|data<<32)
+		return
+	}
+	switch {
+	case csr == MCAUSE || csr == SCAUSE:
+		data = data&^(1<<31) | data>>31<<63
+	case csr == MSTATUS || csr == SSTATUS || csr == MCYCLE || csr == MINSTRET ||
+		(csr >= MHPMCOUNTER3 && csr <= MHPMCOUNTER31) || (csr >= PMPCFG0 && csr <= PMPCFG15):
+		data |= cpu.readCSR(csr) &^ 0xffffffff
+	}
+	cpu.writeCSR(csr, data)
+}
+
+// satpToSv32 converts satp to the layout of SXLEN=32
+func satpToSv32(satp uint64) uint64 {
+	data := satp&SATP32_PPN | (satp&SATP_ASID)>>SATP_ASID_SHIFT<<SATP32_ASID_SHIFT&SATP32_ASID
+	if satp>>SATP_MODE_SHIFT == SATP_MODE_SV32 {
+		data |= SATP32_MODE
+	}
+	return data
+}
+
+// satpFromSv32 converts satp written with SXLEN=32, MODE 1 selects Sv32
+func satpFromSv32(data uint64) uint64 {
+	satp := data&SATP32_PPN | (data&SATP32_ASID)>>SATP32_ASID_SHIFT<<SATP_ASID_SHIFT
+	if data&SATP32_MODE != 0 {
+		satp |= SATP_MODE_SV32 << SATP_MODE_SHIFT
+	}
+	return satp
+}
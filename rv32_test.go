@@ -0,0 +1,279 @@
+package main
+
+import "testing"
+
+func newRV32CPU(t *testing.T) *Cpu {
+	cpu := NewCPU()
+	if err := cpu.ConfigureXLEN(32); err != nil {
+		t.Fatal(err)
+	}
+	return cpu
+}
+
+func TestConfigureXLEN(t *testing.T) {
+	cpu := NewCPU()
+	if err := cpu.ConfigureXLEN(128); err == nil {
+		t.Fatalf("XLEN 128 must be rejected")
+	}
+	cpu = newRV32CPU(t)
+	if cpu.csr[MISA]>>MISA_MXL_SHIFT != XL_32 || cpu.csr[MISA]&misaExt('H') != 0 {
+		t.Fatalf("misa=%#x, want MXL=1 without H", cpu.csr[MISA])
+	}
+	if cpu.exts&RV32_UNSUPPORTED != 0 {
+		t.Fatalf("exts=%#x, RV32 hart keeps extensions it cannot decode", cpu.exts)
+	}
+	cpu.reset()
+	if cpu.xlen != 32 {
+		t.Fatalf("reset must keep XLEN, got %d", cpu.xlen)
+	}
+}
+
+func TestRV32Arithmetic(t *testing.T) {
+	tests := []struct {
+		name     string
+		inst     uint32
+		rs1, rs2 uint64
+		want     uint64
+	}{
+		{"add overflow", rInst(0, 0, 0x33), 0x7fffffff, 1, 0xffffffff80000000},
+		{"sltu", rInst(0, 3, 0x33), 1, 0x80000000, 1},
+		{"srl", rInst(0, 5, 0x33), 0x80000000, 4, 0x08000000},
+		{"srl uses 5 bits", rInst(0, 5, 0x33), 0x80000000, 33, 0x40000000},
+		{"sll uses 5 bits", rInst(0, 1, 0x33), 1, 32, 1},
+		{"sra", rInst(0x20, 5, 0x33), 0x80000000, 31, ^uint64(0)},
+		{"slli 31", unaryInst(31, 1, 0x13), 1, 0, 0xffffffff80000000},
+		{"srli", unaryInst(1, 5, 0x13), 0xffffffff, 0, 0x7fffffff},
+		{"srai", unaryInst(0x400|4, 5, 0x13), 0x80000000, 0, 0xfffffffff8000000},
+		{"mulh", rInst(1, 1, 0x33), 0x80000000, 0x80000000, 0x40000000},
+		{"mulhsu", rInst(1, 2, 0x33), 0xffffffff, 0xffffffff, ^uint64(0)},
+		{"mulhu", rInst(1, 3, 0x33), 0xffffffff, 0xffffffff, 0xfffffffffffffffe},
+		{"div overflow", rInst(1, 4, 0x33), 0x80000000, 0xffffffff, 0xffffffff80000000},
+		{"div by zero", rInst(1, 4, 0x33), 5, 0, ^uint64(0)},
+		{"divu", rInst(1, 5, 0x33), 0xffffffff, 2, 0x7fffffff},
+		{"rem overflow", rInst(1, 6, 0x33), 0x80000000, 0xffffffff, 0},
+		{"remu by zero", rInst(1, 7, 0x33), 0x80000000, 0, 0xffffffff80000000},
+		// Zbb, Zbc, Zbkb, Zbs
+		{"clz", unaryInst(0x600, 1, 0x13), 1, 0, 31},
+		{"ctz zero", unaryInst(0x601, 1, 0x13), 0, 0, 32},
+		{"cpop", unaryInst(0x602, 1, 0x13), 0xffffffff, 0, 32},
+		{"rev8", unaryInst(0x698, 5, 0x13), 0x12345678, 0, 0x78563412},
+		{"rori", unaryInst(0x600|4, 5, 0x13), 0x12345678, 0, 0xffffffff81234567},
+		{"rol", rInst(0x30, 1, 0x33), 0x80000001, 1, 3},
+		{"bseti 31", unaryInst(0x280|31, 1, 0x13), 0, 0, 0xffffffff80000000},
+		{"bset uses 5 bits", rInst(0x14, 1, 0x33), 0, 63, 0xffffffff80000000},
+		{"pack", rInst(0x04, 4, 0x33), 0x1234abcd, 0x5678ef01, 0xffffffffef01abcd},
+		{"zext.h", rInst(0x04, 4, 0x33) &^ (0x1f << 20), 0xffff8000, 0, 0x8000},
+		{"zip", unaryInst(0x08f, 1, 0x13), 0x0000ffff, 0, 0x55555555},
+		{"unzip", unaryInst(0x08f, 5, 0x13), 0x55555555, 0, 0x0000ffff},
+		{"clmulh", rInst(0x05, 3, 0x33), 0x80000000, 0x80000000, 0x40000000},
+		{"clmulr", rInst(0x05, 2, 0x33), 0x80000000, 2, 2},
+	}
+	for _, test := range tests {
+		cpu := newRV32CPU(t)
+		cpu.writeReg(1, test.rs1)
+		cpu.writeReg(2, test.rs2)
+		cpu.ExecuteInst(test.inst)
+		if cpu.exception != nil || cpu.readReg(3) != test.want {
+			t.Fatalf("%s: x3=%#x, want %#x", test.name, cpu.readReg(3), test.want)
+		}
+	}
+}
+
+func TestRV32IllegalInstructions(t *testing.T) {
+	tests := []struct {
+		name string
+		inst uint32
+	}{
+		{"addiw", unaryInst(1, 0, 0x1b)},
+		{"addw", rInst(0, 0, 0x3b)},
+		{"ld", 0x0000b183},
+		{"sd", 0x0020b023},
+		{"slli 32", unaryInst(32, 1, 0x13)},
+		{"srai 32", unaryInst(0x400|32, 5, 0x13)},
+		{"mulw", rInst(1, 0, 0x3b)},
+		{"amoadd.d", 0x0020b1af},
+		{"fcvt.l.d", 0xc2208153},
+		{"fmv.x.d", 0xe20081d3},
+		{"clzw", unaryInst(0x600, 1, 0x1b)},
+		{"c.slli 32", 0x1502},
+		{"hlv.w", 0x6800c1f3},
+		{"csrr hstatus", csrInst(HSTATUS, 2)},
+	}
+	for _, test := range tests {
+		cpu := newRV32CPU(t)
+		cpu.csr[MSTATUS] |= MSTATUS_FS
+		cpu.ExecuteInst(test.inst)
+		if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+			t.Fatalf("%s: mcause=%d, want illegal instruction", test.name, cpu.csr[MCAUSE])
+		}
+	}
+}
+
+func TestRV32Addresses(t *testing.T) {
+	cpu := newRV32CPU(t)
+	cpu.writeReg(2, 0x12345678)
+	cpu.ExecuteInst(0x800010b7) // lui x1, 0x80001
+	if cpu.readReg(1) != 0xffffffff80001000 {
+		t.Fatalf("lui must sign-extend, x1=%#x", cpu.readReg(1))
+	}
+	cpu.ExecuteInst(0x0020a023) // sw x2, 0(x1)
+	cpu.ExecuteInst(0x0000a183) // lw x3, 0(x1)
+	if memRead(cpu, 0x80001000, WORD) != 0x12345678 || cpu.readReg(3) != 0x12345678 {
+		t.Fatalf("access through sign-extended address: mem=%#x x3=%#x",
+			memRead(cpu, 0x80001000, WORD), cpu.readReg(3))
+	}
+	cpu.ExecuteInst(0x00008267) // jalr x4, 0(x1)
+	if cpu.pc != 0x80001000 || cpu.readReg(4) != 0xffffffff80000010 {
+		t.Fatalf("jalr: pc=%#x link=%#x", cpu.pc, cpu.readReg(4))
+	}
+}
+
+func TestRV32Compressed(t *testing.T) {
+	tests := []struct {
+		name string
+		c    uint32
+		inst uint32
+	}{
+		{"c.flw fa5, 8(a0)", 0x651c, 0x00852787},
+		{"c.fswsp ft1, 8(sp)", 0xe406, 0x00112427},
+		{"c.flwsp ft1, 8(sp)", 0x60a2, 0x00812087},
+		{"c.lw a0, 0(a0)", 0x4108, 0x00052503},
+	}
+	for _, test := range tests {
+		got, ok := expandCompressed(test.c, 32)
+		if !ok || got != test.inst {
+			t.Fatalf("%s (%#04x): got %#08x, want %#08x", test.name, test.c, got, test.inst)
+		}
+	}
+	// c.jal занимает место c.addiw и кодирует смещение так же, как c.j
+	cj, _ := expandCompressed(0xa505, 64)
+	if got, ok := expandCompressed(0x2505, 32); !ok || got != cj|1<<7 {
+		t.Fatalf("c.jal: got %#08x, want %#08x", got, cj|1<<7)
+	}
+	for _, c := range []uint32{0x1502, 0x9101, 0x9501} {
+		if inst, ok := expandCompressed(c, 32); ok {
+			t.Fatalf("%#04x: shift by 32 expanded to %#08x, want illegal", c, inst)
+		}
+	}
+}
+
+func TestRV32CSRs(t *testing.T) {
+	cpu := newRV32CPU(t)
+	if misa, ok := csrRead(cpu, MISA); !ok || misa>>30 != XL_32 || misa&misaExt('I') == 0 {
+		t.Fatalf("misa=%#x", misa)
+	}
+
+	cpu.csr[MSTATUS] |= MSTATUS_FS
+	if mstatus, _ := csrRead(cpu, MSTATUS); mstatus>>31&1 == 0 || mstatus&MSTATUS_FS != MSTATUS_FS {
+		t.Fatalf("mstatus=%#x, SD must be bit 31", mstatus)
+	}
+
+	cpu.csr[MCAUSE] = CAUSE_INTERRUPT | 7
+	if mcause, _ := csrRead(cpu, MCAUSE); mcause != 0xffffffff80000007 {
+		t.Fatalf("mcause=%#x", mcause)
+	}
+	cpu.writeReg(1, 0x80000003)
+	cpu.ExecuteInst(csrInst(MCAUSE, 1)) // csrrw x3, mcause, x1
+	if cpu.csr[MCAUSE] != CAUSE_INTERRUPT|3 {
+		t.Fatalf("mcause=%#x after write", cpu.csr[MCAUSE])
+	}
+
+	cpu.csr[MCYCLE] = 5<<32 | 0x100
+	if h, ok := csrRead(cpu, CYCLEH); !ok || h != 5 {
+		t.Fatalf("cycleh=%d ok=%v", h, ok)
+	}
+	cpu.writeReg(1, 7)
+	cpu.ExecuteInst(csrInst(MCYCLEH, 1))
+	if cpu.csr[MCYCLE]>>32 != 7 || uint32(cpu.csr[MCYCLE]) == 0 {
+		t.Fatalf("mcycle=%#x after mcycleh write", cpu.csr[MCYCLE])
+	}
+
+	cpu.writeReg(1, uint64(PMP_NAPOT|PMP_R))
+	cpu.ExecuteInst(csrInst(PMPCFG0+1, 1))
+	if cfg := cpu.pmp.readCfg(0); cfg>>32&0xff != uint64(PMP_NAPOT|PMP_R) || cfg&0xff != 0 {
+		t.Fatalf("pmpcfg1 must set entry 4, pmpcfg0=%#x", cfg)
+	}
+
+	satp := SATP32_MODE | 5<<SATP32_ASID_SHIFT | 0x80100
+	cpu.writeReg(1, satp)
+	cpu.ExecuteInst(csrInst(SATP, 1))
+	if cpu.csr[SATP] != SATP_MODE_SV32<<SATP_MODE_SHIFT|5<<SATP_ASID_SHIFT|0x80100 {
+		t.Fatalf("satp stored as %#x", cpu.csr[SATP])
+	}
+	if got, _ := csrRead(cpu, SATP); got != uint64(int32(satp)) {
+		t.Fatalf("satp=%#x, want %#x", got, satp)
+	}
+
+	// в RV64 старших половин нет
+	cpu = NewCPU()
+	if _, ok := csrRead(cpu, CYCLEH); ok {
+		t.Fatalf("cycleh must be illegal in RV64")
+	}
+	cpu.writeCSR(SATP, SATP_MODE_SV32<<SATP_MODE_SHIFT|0x80100)
+	if cpu.csr[SATP] != 0 {
+		t.Fatalf("Sv32 must not be selectable with SXLEN=64")
+	}
+}
+
+func TestUXL32(t *testing.T) {
+	cpu := NewCPU()
+	mstatus := cpu.csr[MSTATUS]
+	cpu.writeCSR(MSTATUS, mstatus|MSTATUS_UXL|MSTATUS_SXL)
+	if cpu.csr[MSTATUS] != mstatus {
+		t.Fatalf("reserved UXL and fixed SXL must keep their values, mstatus=%#x", cpu.csr[MSTATUS])
+	}
+	cpu.writeCSR(MSTATUS, mstatus&^MSTATUS_UXL|XL_32<<MSTATUS_UXL_SHIFT)
+
+	cpu.privilege = USER_MODE
+	cpu.writeReg(1, 0x7fffffff)
+	cpu.ExecuteInst(unaryInst(1, 0, 0x13)) // addi x3, x1, 1
+	if cpu.readReg(3) != 0xffffffff80000000 {
+		t.Fatalf("U-mode with UXL=32: x3=%#x", cpu.readReg(3))
+	}
+	cpu.ExecuteInst(unaryInst(1, 0, 0x1b)) // addiw
+	if cpu.privilege != MACHINE_MODE || cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("addiw must be illegal with UXL=32, mcause=%d", cpu.csr[MCAUSE])
+	}
+	// M-режим остаётся RV64
+	cpu.ExecuteInst(unaryInst(1, 0, 0x1b))
+	if cpu.exception != nil {
+		t.Fatalf("addiw must be legal in M-mode")
+	}
+}
+
+func TestSv32Translation(t *testing.T) {
+	cpu := newRV32CPU(t)
+	pt := newPageTables(cpu, SATP_MODE_SV32)
+	pt.mapPage(0x1000, 0x80200000, PTE_R|PTE_W, 0)
+	pt.mapPage(0xfffff000, 0x80201000, PTE_R|PTE_W, 0)
+	pt.mapPage(0x400000, 0x80400000, PTE_R, 1) // мегастраница 4 МиБ
+	cpu.privilege = SUPERVISOR_MODE
+	cpu.bus.Write(0x80400010, 0xdeadbeef, WORD)
+
+	cpu.writeReg(1, 0x1000)
+	cpu.writeReg(2, 0x12345678)
+	cpu.writeReg(5, 0xfffff000)
+	cpu.ExecuteInst(0x0020a423) // sw x2, 8(x1)
+	cpu.ExecuteInst(0x0022a023) // sw x2, 0(x5)
+	if memRead(cpu, 0x80200008, WORD) != 0x12345678 || memRead(cpu, 0x80201000, WORD) != 0x12345678 {
+		t.Fatalf("stores went to wrong pages")
+	}
+	if pte := pt.leafPTE(0x1000, 0); pte&(PTE_A|PTE_D) != PTE_A|PTE_D {
+		t.Fatalf("A/D bits are not set, pte=%#x", pte)
+	}
+
+	cpu.writeReg(1, 0x400000)
+	cpu.ExecuteInst(0x0100a183) // lw x3, 16(x1)
+	if cpu.readReg(3) != 0xffffffffdeadbeef {
+		t.Fatalf("megapage load: x3=%#x", cpu.readReg(3))
+	}
+
+	// sfence.vma с любым адресом мегастраницы сбрасывает её трансляцию
+	pt.mapPage(0x400000, 0x80000000, PTE_R, 1)
+	cpu.writeReg(4, 0x7ff000)
+	cpu.ExecuteInst(0x12020073) // sfence.vma x4, x0
+	cpu.ExecuteInst(0x0100a183) // lw x3, 16(x1)
+	if cpu.readReg(3) != memRead(cpu, 0x80000010, WORD) {
+		t.Fatalf("stale megapage translation after sfence.vma")
+	}
+}
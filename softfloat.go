@@ -0,0 +1,605 @@
+package main
+
+import (
+	"math/big"
+)
+
+// floatFormat describes an IEEE-754 binary interchange format
+type floatFormat struct {
+	expBits  uint
+	fracBits uint
+}
+
+var (
+	BINARY16  = floatFormat{expBits: 5, fracBits: 10}
+	BINARY32  = floatFormat{expBits: 8, fracBits: 23}
+	BINARY64  = floatFormat{expBits: 11, fracBits: 52}
+	BINARY128 = floatFormat{expBits: 15, fracBits: 112}
+)
+
+func (f floatFormat) bias() int      { return 1<<(f.expBits-1) - 1 }
+func (f floatFormat) emin() int      { return 1 - f.bias() }
+func (f floatFormat) emax() int      { return f.bias() }
+func (f floatFormat) precision() int { return int(f.fracBits) + 1 }
+func (f floatFormat) expMax() uint64 { return 1<<f.expBits - 1 }
+
+type sfClass int
+
+const (
+	SF_ZERO sfClass = iota
+	SF_FINITE
+	SF_INF
+	SF_QNAN
+	SF_SNAN
+)
+
+// sfValue is an unpacked number equal to (-1)^sign * sig * 2^exp
+type sfValue struct {
+	class sfClass
+	sign  bool
+	exp   int
+	sig   *big.Int
+}
+
+func (v sfValue) isNaN() bool { return v.class == SF_QNAN || v.class == SF_SNAN }
+
+func sfUnpack(f floatFormat, raw *big.Int) sfValue {
+	v := sfValue{sign: raw.Bit(int(f.expBits+f.fracBits)) == 1}
+	e := new(big.Int).Rsh(raw, f.fracBits).Uint64() & f.expMax()
+	v.sig = lowBits(raw, f.fracBits)
+	switch {
+	case e == f.expMax() && v.sig.Sign() == 0:
+		v.class = SF_INF
+	case e == f.expMax() && v.sig.Bit(int(f.fracBits)-1) == 1:
+		v.class = SF_QNAN
+	case e == f.expMax():
+		v.class = SF_SNAN
+	case e == 0 && v.sig.Sign() == 0:
+		v.class = SF_ZERO
+	case e == 0:
+		// денормализованное число
+		v.class = SF_FINITE
+		v.exp = f.emin() - int(f.fracBits)
+	default:
+		v.class = SF_FINITE
+		v.sig.SetBit(v.sig, int(f.fracBits), 1)
+		v.exp = int(e) - f.bias() - int(f.fracBits)
+	}
+	return v
+}
+
+func lowBits(x *big.Int, n uint) *big.Int {
+	mask := new(big.Int).Lsh(big.NewInt(1), n)
+	mask.Sub(mask, big.NewInt(1))
+	return mask.And(mask, x)
+}
+
+func sfPack(f floatFormat, sign bool, exp uint64, frac *big.Int) *big.Int {
+	raw := new(big.Int).SetUint64(exp)
+	raw.Lsh(raw, f.fracBits)
+	raw.Or(raw, frac)
+	if sign {
+		raw.SetBit(raw, int(f.expBits+f.fracBits), 1)
+	}
+	return raw
+}
+
+func sfZero(f floatFormat, sign bool) *big.Int {
+	return sfPack(f, sign, 0, new(big.Int))
+}
+
+func sfInf(f floatFormat, sign bool) *big.Int {
+	return sfPack(f, sign, f.expMax(), new(big.Int))
+}
+
+// sfNaN returns the RISC-V canonical NaN: positive, quiet, zero payload
+func sfNaN(f floatFormat) *big.Int {
+	frac := new(big.Int).Lsh(big.NewInt(1), f.fracBits-1)
+	return sfPack(f, false, f.expMax(), frac)
+}
+
+func sfMaxFinite(f floatFormat, sign bool) *big.Int {
+	return sfPack(f, sign, f.expMax()-1, lowBits(big.NewInt(-1), f.fracBits))
+}
+
+// sfNaNFlags raises invalid operation for signaling NaN operands
+func sfNaNFlags(ops ...sfValue) uint64 {
+	for _, v := range ops {
+		if v.class == SF_SNAN {
+			return FFLAGS_NV
+		}
+	}
+	return 0
+}
+
+func anyNaN(ops ...sfValue) bool {
+	for _, v := range ops {
+		if v.isNaN() {
+			return true
+		}
+	}
+	return false
+}
+
+// sfRoundSig rounds sig*2^exp to a multiple of 2^lsb. sticky marks
+// nonzero bits below the least significant bit of sig. The result is
+// in units of 2^lsb, inexact reports lost bits.
+func sfRoundSig(sig *big.Int, exp, lsb int, sticky, sign bool, rm uint64) (*big.Int, bool) {
+	r := new(big.Int)
+	var half, rest bool
+	shift := lsb - exp
+	if shift <= 0 {
+		r.Lsh(sig, uint(-shift))
+		rest = sticky
+	} else {
+		r.Rsh(sig, uint(shift))
+		half = sig.Bit(shift-1) == 1
+		rest = sticky || (sig.Sign() != 0 && sig.TrailingZeroBits() < uint(shift-1))
+	}
+	var inc bool
+	switch rm {
+	case RM_RNE:
+		inc = half && (rest || r.Bit(0) == 1)
+	case RM_RMM:
+		inc = half
+	case RM_RDN:
+		inc = (half || rest) && sign
+	case RM_RUP:
+		inc = (half || rest) && !sign
+	}
+	if inc {
+		r.Add(r, big.NewInt(1))
+	}
+	return r, half || rest
+}
+
+// sfRound rounds (-1)^sign * sig * 2^exp to format f. Underflow is
+// detected after rounding as RISC-V requires.
+func sfRound(f floatFormat, sign bool, sig *big.Int, exp int, sticky bool, rm uint64) (*big.Int, uint64) {
+	if sig.Sign() == 0 && !sticky {
+		return sfZero(f, sign), 0
+	}
+	p := f.precision()
+	top := exp + sig.BitLen() - 1 // показатель старшего бита
+	lsb := top - p + 1
+	if minLsb := f.emin() - int(f.fracBits); lsb < minLsb {
+		lsb = minLsb
+	}
+	r, inexact := sfRoundSig(sig, exp, lsb, sticky, sign, rm)
+
+	var flags uint64
+	if inexact {
+		flags |= FFLAGS_NX
+		if top < f.emin() {
+			tiny := true
+			if top == f.emin()-1 {
+				// с неограниченным порядком результат может округлиться до 2^emin
+				u, _ := sfRoundSig(sig, exp, top-p+1, sticky, sign, rm)
+				tiny = u.BitLen() <= p
+			}
+			if tiny {
+				flags |= FFLAGS_UF
+			}
+		}
+	}
+	if r.BitLen() > p {
+		r.Rsh(r, 1)
+		lsb++
+	}
+	if lsb+r.BitLen()-1 > f.emax() {
+		return sfOverflow(f, sign, rm), flags | FFLAGS_OF | FFLAGS_NX
+	}
+	if r.BitLen() < p {
+		return sfPack(f, sign, 0, r), flags
+	}
+	exponent := uint64(lsb + int(f.fracBits) + f.bias())
+	return sfPack(f, sign, exponent, lowBits(r, f.fracBits)), flags
+}
+
+func sfOverflow(f floatFormat, sign bool, rm uint64) *big.Int {
+	switch {
+	case rm == RM_RTZ, rm == RM_RDN && !sign, rm == RM_RUP && sign:
+		return sfMaxFinite(f, sign)
+	default:
+		return sfInf(f, sign)
+	}
+}
+
+// sfAddValues adds two zero or finite values exactly and rounds once
+func sfAddValues(f floatFormat, x, y sfValue, rm uint64) (*big.Int, uint64) {
+	if x.class == SF_ZERO && y.class == SF_ZERO {
+		if x.sign != y.sign {
+			return sfZero(f, rm == RM_RDN), 0
+		}
+		return sfZero(f, x.sign), 0
+	}
+	if x.class == SF_ZERO {
+		return sfRound(f, y.sign, y.sig, y.exp, false, rm)
+	}
+	if y.class == SF_ZERO {
+		return sfRound(f, x.sign, x.sig, x.exp, false, rm)
+	}
+	exp := min(x.exp, y.exp)
+	sx := new(big.Int).Lsh(x.sig, uint(x.exp-exp))
+	sy := new(big.Int).Lsh(y.sig, uint(y.exp-exp))
+	if x.sign {
+		sx.Neg(sx)
+	}
+	if y.sign {
+		sy.Neg(sy)
+	}
+	sum := sx.Add(sx, sy)
+	if sum.Sign() == 0 {
+		// точное сокращение даёт +0, кроме округления вниз
+		return sfZero(f, rm == RM_RDN), 0
+	}
+	sign := sum.Sign() < 0
+	return sfRound(f, sign, sum.Abs(sum), exp, false, rm)
+}
+
+func sfAdd(f floatFormat, a, b *big.Int, rm uint64) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	return sfAddUnpacked(f, x, y, rm)
+}
+
+func sfSub(f floatFormat, a, b *big.Int, rm uint64) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	y.sign = !y.sign
+	return sfAddUnpacked(f, x, y, rm)
+}
+
+func sfAddUnpacked(f floatFormat, x, y sfValue, rm uint64) (*big.Int, uint64) {
+	switch {
+	case anyNaN(x, y):
+		return sfNaN(f), sfNaNFlags(x, y)
+	case x.class == SF_INF && y.class == SF_INF && x.sign != y.sign:
+		return sfNaN(f), FFLAGS_NV
+	case x.class == SF_INF:
+		return sfInf(f, x.sign), 0
+	case y.class == SF_INF:
+		return sfInf(f, y.sign), 0
+	}
+	return sfAddValues(f, x, y, rm)
+}
+
+// sfProduct multiplies two zero or finite values exactly
+func sfProduct(x, y sfValue) sfValue {
+	p := sfValue{class: SF_FINITE, sign: x.sign != y.sign}
+	if x.class == SF_ZERO || y.class == SF_ZERO {
+		p.class = SF_ZERO
+		return p
+	}
+	p.sig = new(big.Int).Mul(x.sig, y.sig)
+	p.exp = x.exp + y.exp
+	return p
+}
+
+func sfMul(f floatFormat, a, b *big.Int, rm uint64) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	sign := x.sign != y.sign
+	switch {
+	case anyNaN(x, y):
+		return sfNaN(f), sfNaNFlags(x, y)
+	case x.class == SF_INF && y.class == SF_ZERO, x.class == SF_ZERO && y.class == SF_INF:
+		return sfNaN(f), FFLAGS_NV
+	case x.class == SF_INF, y.class == SF_INF:
+		return sfInf(f, sign), 0
+	}
+	p := sfProduct(x, y)
+	if p.class == SF_ZERO {
+		return sfZero(f, sign), 0
+	}
+	return sfRound(f, sign, p.sig, p.exp, false, rm)
+}
+
+// sfMulAdd computes (a*b)+c with a single rounding. negProd and negAdd
+// negate the product and the addend for fmsub/fnmsub/fnmadd.
+func sfMulAdd(f floatFormat, a, b, c *big.Int, negProd, negAdd bool, rm uint64) (*big.Int, uint64) {
+	x, y, z := sfUnpack(f, a), sfUnpack(f, b), sfUnpack(f, c)
+	// 0*inf недопустимо, даже если слагаемое - тихий NaN
+	invalid := (x.class == SF_INF && y.class == SF_ZERO) || (x.class == SF_ZERO && y.class == SF_INF)
+	if anyNaN(x, y, z) {
+		if invalid {
+			return sfNaN(f), FFLAGS_NV
+		}
+		return sfNaN(f), sfNaNFlags(x, y, z)
+	}
+	if invalid {
+		return sfNaN(f), FFLAGS_NV
+	}
+	sign := (x.sign != y.sign) != negProd
+	z.sign = z.sign != negAdd
+	if x.class == SF_INF || y.class == SF_INF {
+		if z.class == SF_INF && z.sign != sign {
+			return sfNaN(f), FFLAGS_NV
+		}
+		return sfInf(f, sign), 0
+	}
+	if z.class == SF_INF {
+		return sfInf(f, z.sign), 0
+	}
+	p := sfProduct(x, y)
+	p.sign = sign
+	return sfAddValues(f, p, z, rm)
+}
+
+func sfDiv(f floatFormat, a, b *big.Int, rm uint64) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	sign := x.sign != y.sign
+	switch {
+	case anyNaN(x, y):
+		return sfNaN(f), sfNaNFlags(x, y)
+	case x.class == SF_INF && y.class == SF_INF, x.class == SF_ZERO && y.class == SF_ZERO:
+		return sfNaN(f), FFLAGS_NV
+	case x.class == SF_INF:
+		return sfInf(f, sign), 0
+	case y.class == SF_INF:
+		return sfZero(f, sign), 0
+	case y.class == SF_ZERO:
+		return sfInf(f, sign), FFLAGS_DZ
+	case x.class == SF_ZERO:
+		return sfZero(f, sign), 0
+	}
+	// делимое сдвигается так, чтобы в частном было не меньше p+2 бит
+	k := max(f.precision()+2+y.sig.BitLen()-x.sig.BitLen(), 0)
+	n := new(big.Int).Lsh(x.sig, uint(k))
+	q, r := n.QuoRem(n, y.sig, new(big.Int))
+	return sfRound(f, sign, q, x.exp-k-y.exp, r.Sign() != 0, rm)
+}
+
+func sfSqrt(f floatFormat, a *big.Int, rm uint64) (*big.Int, uint64) {
+	x := sfUnpack(f, a)
+	switch {
+	case x.isNaN():
+		return sfNaN(f), sfNaNFlags(x)
+	case x.class == SF_ZERO:
+		return sfZero(f, x.sign), 0
+	case x.sign:
+		return sfNaN(f), FFLAGS_NV
+	case x.class == SF_INF:
+		return sfInf(f, false), 0
+	}
+	// чётный порядок и не меньше p+2 бит в корне
+	k := max(2*(f.precision()+2)-x.sig.BitLen(), 0)
+	if (x.exp-k)%2 != 0 {
+		k++
+	}
+	n := new(big.Int).Lsh(x.sig, uint(k))
+	r := new(big.Int).Sqrt(n)
+	sticky := new(big.Int).Mul(r, r).Cmp(n) != 0
+	return sfRound(f, false, r, (x.exp-k)/2, sticky, rm)
+}
+
+// sfToInt converts to a width-bit integer, out of range values saturate.
+// The result is sign-extended to 64 bits.
+func sfToInt(f floatFormat, a *big.Int, signed bool, width uint, rm uint64) (uint64, uint64) {
+	var minInt, maxInt uint64
+	if signed {
+		minInt, maxInt = uint64(int64(-1)<<(width-1)), uint64(1)<<(width-1)-1
+	} else {
+		// 32-битный результат расширяется знаком
+		minInt, maxInt = 0, ^uint64(0)
+	}
+	x := sfUnpack(f, a)
+	switch x.class {
+	case SF_QNAN, SF_SNAN:
+		return maxInt, FFLAGS_NV
+	case SF_INF:
+		if x.sign {
+			return minInt, FFLAGS_NV
+		}
+		return maxInt, FFLAGS_NV
+	case SF_ZERO:
+		return 0, 0
+	}
+	r, inexact := sfRoundSig(x.sig, x.exp, 0, false, x.sign, rm)
+	limit := width
+	if signed {
+		limit--
+	}
+	bound := new(big.Int).Lsh(big.NewInt(1), limit)
+	var res uint64
+	switch {
+	case x.sign && r.Sign() == 0:
+		res = 0
+	case x.sign && (!signed || r.Cmp(bound) > 0):
+		return minInt, FFLAGS_NV
+	case x.sign:
+		res = -r.Uint64()
+	case r.Cmp(bound) >= 0:
+		return maxInt, FFLAGS_NV
+	default:
+		res = r.Uint64()
+	}
+	if width < 64 {
+		res = uint64(signExtend(int64(res), width))
+	}
+	if inexact {
+		return res, FFLAGS_NX
+	}
+	return res, 0
+}
+
+// sfToInt32Mod converts to a 32-bit integer rounding toward zero and
+// wrapping modulo 2^32 as fcvtmod.w.d does. Out of range values raise
+// invalid operation, infinities and NaNs convert to zero.
+func sfToInt32Mod(f floatFormat, a *big.Int) (uint64, uint64) {
+	x := sfUnpack(f, a)
+	switch x.class {
+	case SF_QNAN, SF_SNAN, SF_INF:
+		return 0, FFLAGS_NV
+	case SF_ZERO:
+		return 0, 0
+	}
+	r, inexact := sfRoundSig(x.sig, x.exp, 0, false, x.sign, RM_RTZ)
+	res := lowBits(r, 32).Uint64()
+	limit := new(big.Int).Lsh(big.NewInt(1), 31)
+	if x.sign {
+		res = -res
+	}
+	res = uint64(signExtend(int64(res), 32))
+	switch {
+	case r.Cmp(limit) > 0, r.Cmp(limit) == 0 && !x.sign:
+		return res, FFLAGS_NV
+	case inexact:
+		return res, FFLAGS_NX
+	}
+	return res, 0
+}
+
+// sfFromInt converts a width-bit integer to format f
+func sfFromInt(f floatFormat, x uint64, signed bool, width uint, rm uint64) (*big.Int, uint64) {
+	if width < 64 {
+		x &= 1<<width - 1
+	}
+	sign := false
+	if signed {
+		if v := signExtend(int64(x), width); v < 0 {
+			sign = true
+			x = -uint64(v)
+		}
+	}
+	return sfRound(f, sign, new(big.Int).SetUint64(x), 0, false, rm)
+}
+
+// sfConvert converts between formats
+func sfConvert(to, from floatFormat, a *big.Int, rm uint64) (*big.Int, uint64) {
+	x := sfUnpack(from, a)
+	switch x.class {
+	case SF_QNAN, SF_SNAN:
+		return sfNaN(to), sfNaNFlags(x)
+	case SF_INF:
+		return sfInf(to, x.sign), 0
+	case SF_ZERO:
+		return sfZero(to, x.sign), 0
+	}
+	return sfRound(to, x.sign, x.sig, x.exp, false, rm)
+}
+
+// sfRoundToInt rounds to an integral value in the same format, exact
+// selects froundnx which reports inexact results
+func sfRoundToInt(f floatFormat, a *big.Int, rm uint64, exact bool) (*big.Int, uint64) {
+	x := sfUnpack(f, a)
+	switch {
+	case x.isNaN():
+		return sfNaN(f), sfNaNFlags(x)
+	case x.class != SF_FINITE, x.exp >= 0:
+		// бесконечности, нули и большие числа уже целые
+		return a, 0
+	}
+	r, inexact := sfRoundSig(x.sig, x.exp, 0, false, x.sign, rm)
+	var flags uint64
+	if inexact && exact {
+		flags = FFLAGS_NX
+	}
+	if r.Sign() == 0 {
+		return sfZero(f, x.sign), flags
+	}
+	res, _ := sfRound(f, x.sign, r, 0, false, rm)
+	return res, flags
+}
+
+// sfCmp orders two non-NaN values, -0 equals +0
+func sfCmp(f floatFormat, a, b *big.Int) int {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	ma := lowBits(a, f.expBits+f.fracBits)
+	mb := lowBits(b, f.expBits+f.fracBits)
+	switch {
+	case x.class == SF_ZERO && y.class == SF_ZERO:
+		return 0
+	case x.sign != y.sign && x.sign:
+		return -1
+	case x.sign != y.sign:
+		return 1
+	case x.sign:
+		return mb.Cmp(ma)
+	default:
+		return ma.Cmp(mb)
+	}
+}
+
+// sfCompare implements feq (quiet), flt and fle (signaling)
+func sfCompare(f floatFormat, a, b *big.Int, lt, eq bool) (bool, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	if anyNaN(x, y) {
+		if lt {
+			return false, FFLAGS_NV
+		}
+		return false, sfNaNFlags(x, y)
+	}
+	c := sfCmp(f, a, b)
+	return (lt && c < 0) || (eq && c == 0), 0
+}
+
+// sfCompareQuiet implements fltq/fleq which raise invalid operation
+// only for signaling NaN operands
+func sfCompareQuiet(f floatFormat, a, b *big.Int, lt, eq bool) (bool, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	if anyNaN(x, y) {
+		return false, sfNaNFlags(x, y)
+	}
+	return sfCompare(f, a, b, lt, eq)
+}
+
+// sfMinMax implements fmin/fmax: a single NaN operand is ignored
+// and -0 is less than +0
+func sfMinMax(f floatFormat, a, b *big.Int, max bool) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	flags := sfNaNFlags(x, y)
+	switch {
+	case x.isNaN() && y.isNaN():
+		return sfNaN(f), flags
+	case x.isNaN():
+		return b, flags
+	case y.isNaN():
+		return a, flags
+	}
+	c := sfCmp(f, a, b)
+	if c == 0 && x.sign != y.sign {
+		// -0 < +0
+		c = 1
+		if x.sign {
+			c = -1
+		}
+	}
+	if (c < 0) != max {
+		return a, flags
+	}
+	return b, flags
+}
+
+// sfMinMaxNaN implements fminm/fmaxm which, unlike fmin/fmax,
+// propagate a NaN operand as canonical NaN
+func sfMinMaxNaN(f floatFormat, a, b *big.Int, max bool) (*big.Int, uint64) {
+	x, y := sfUnpack(f, a), sfUnpack(f, b)
+	if anyNaN(x, y) {
+		return sfNaN(f), sfNaNFlags(x, y)
+	}
+	return sfMinMax(f, a, b, max)
+}
+
+// sfClassify returns the fclass mask
+func sfClassify(f floatFormat, a *big.Int) uint64 {
+	x := sfUnpack(f, a)
+	var bit uint
+	switch x.class {
+	case SF_SNAN:
+		return 1 << 8
+	case SF_QNAN:
+		return 1 << 9
+	case SF_INF:
+		bit = 7
+	case SF_ZERO:
+		bit = 4
+	default:
+		if x.sig.BitLen() < f.precision() {
+			bit = 5
+		} else {
+			bit = 6
+		}
+	}
+	if x.sign {
+		bit = 7 - bit
+	}
+	return 1 << bit
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var VECTOR_MODES = map[string]uint64{
+	"rne": RM_RNE,
+	"rtz": RM_RTZ,
+	"rdn": RM_RDN,
+	"rup": RM_RUP,
+}
+
+// vectorFunc evaluates a TestFloat function on operands from a vector line
+type vectorFunc func(ops []uint64, rm uint64) (uint64, uint64)
+
+func binaryVector(f floatFormat, op func(floatFormat, *big.Int, *big.Int, uint64) (*big.Int, uint64)) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := op(f, rawBits(ops[0]), rawBits(ops[1]), rm)
+		return r.Uint64(), flags
+	}
+}
+
+func sqrtVector(f floatFormat) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := sfSqrt(f, rawBits(ops[0]), rm)
+		return r.Uint64(), flags
+	}
+}
+
+func mulAddVector(f floatFormat) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := sfMulAdd(f, rawBits(ops[0]), rawBits(ops[1]), rawBits(ops[2]), false, false, rm)
+		return r.Uint64(), flags
+	}
+}
+
+func convertVector(to, from floatFormat) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := sfConvert(to, from, rawBits(ops[0]), rm)
+		return r.Uint64(), flags
+	}
+}
+
+func fromIntVector(f floatFormat, signed bool, width uint) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := sfFromInt(f, ops[0], signed, width, rm)
+		return r.Uint64(), flags
+	}
+}
+
+func toIntVector(f floatFormat, signed bool, width uint) vectorFunc {
+	return func(ops []uint64, rm uint64) (uint64, uint64) {
+		r, flags := sfToInt(f, rawBits(ops[0]), signed, width, rm)
+		if width < 64 {
+			// в векторах результат записан без расширения знака
+			r = uint64(uint32(r))
+		}
+		return r, flags
+	}
+}
+
+var VECTOR_FUNCS = map[string]vectorFunc{
+	"f32_add":     binaryVector(BINARY32, sfAdd),
+	"f32_sub":     binaryVector(BINARY32, sfSub),
+	"f32_mul":     binaryVector(BINARY32, sfMul),
+	"f32_div":     binaryVector(BINARY32, sfDiv),
+	"f32_sqrt":    sqrtVector(BINARY32),
+	"f32_mulAdd":  mulAddVector(BINARY32),
+	"f64_add":     binaryVector(BINARY64, sfAdd),
+	"f64_sub":     binaryVector(BINARY64, sfSub),
+	"f64_mul":     binaryVector(BINARY64, sfMul),
+	"f64_div":     binaryVector(BINARY64, sfDiv),
+	"f64_sqrt":    sqrtVector(BINARY64),
+	"f64_mulAdd":  mulAddVector(BINARY64),
+	"f32_to_f64":  convertVector(BINARY64, BINARY32),
+	"f64_to_f32":  convertVector(BINARY32, BINARY64),
+	"i32_to_f32":  fromIntVector(BINARY32, true, 32),
+	"ui32_to_f32": fromIntVector(BINARY32, false, 32),
+	"i64_to_f32":  fromIntVector(BINARY32, true, 64),
+	"ui64_to_f32": fromIntVector(BINARY32, false, 64),
+	"i32_to_f64":  fromIntVector(BINARY64, true, 32),
+	"ui32_to_f64": fromIntVector(BINARY64, false, 32),
+	"i64_to_f64":  fromIntVector(BINARY64, true, 64),
+	"ui64_to_f64": fromIntVector(BINARY64, false, 64),
+	"f32_to_i32":  toIntVector(BINARY32, true, 32),
+	"f32_to_ui32": toIntVector(BINARY32, false, 32),
+	"f32_to_i64":  toIntVector(BINARY32, true, 64),
+	"f32_to_ui64": toIntVector(BINARY32, false, 64),
+	"f64_to_i32":  toIntVector(BINARY64, true, 32),
+	"f64_to_ui32": toIntVector(BINARY64, false, 32),
+	"f64_to_i64":  toIntVector(BINARY64, true, 64),
+	"f64_to_ui64": toIntVector(BINARY64, false, 64),
+}
+
+func TestSoftfloatVectors(t *testing.T) {
+	files, err := filepath.Glob("testdata/softfloat/*/*.tv")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no test vectors found: %v", err)
+	}
+	for _, path := range files {
+		mode := filepath.Base(filepath.Dir(path))
+		name := strings.TrimSuffix(filepath.Base(path), ".tv")
+		rm, ok := VECTOR_MODES[mode]
+		fn, known := VECTOR_FUNCS[name]
+		if !ok || !known {
+			t.Fatalf("%s: unknown rounding mode or function", path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			fields := strings.Fields(scanner.Text())
+			vals := make([]uint64, len(fields))
+			for i, field := range fields {
+				if vals[i], err = strconv.ParseUint(field, 16, 64); err != nil {
+					t.Fatalf("%s:%d: %v", path, line, err)
+				}
+			}
+			n := len(vals)
+			got, flags := fn(vals[:n-2], rm)
+			if got != vals[n-2] || flags != vals[n-1] {
+				t.Errorf("%s:%d: %s: got %X flags %02X, want %X flags %02X",
+					path, line, scanner.Text(), got, flags, vals[n-2], vals[n-1])
+			}
+		}
+		file.Close()
+	}
+}
+
+// RMM is not available on the host FPU, ties are checked by hand
+func TestSoftfloatRMM(t *testing.T) {
+	tests := []struct {
+		name  string
+		got   func(rm uint64) (uint64, uint64)
+		rne   uint64
+		rmm   uint64
+		flags uint64
+	}{
+		{"f32_add 1+2^-24", binaryFunc(BINARY32, sfAdd, 0x3f800000, 0x33800000), 0x3f800000, 0x3f800001, FFLAGS_NX},
+		{"f32_sub -1-2^-24", binaryFunc(BINARY32, sfSub, 0xbf800000, 0x33800000), 0xbf800000, 0xbf800001, FFLAGS_NX},
+		{"f64_mul 2^-1075", binaryFunc(BINARY64, sfMul, 0x1, 0x3fe0000000000000), 0, 1, FFLAGS_UF | FFLAGS_NX},
+		{"f32_mul overflow", binaryFunc(BINARY32, sfMul, 0x7f7fffff, 0x40000000), 0x7f800000, 0x7f800000, FFLAGS_OF | FFLAGS_NX},
+		{"i64_to_f32 2^24+1", func(rm uint64) (uint64, uint64) {
+			r, flags := sfFromInt(BINARY32, 1<<24+1, true, 64, rm)
+			return r.Uint64(), flags
+		}, 0x4b800000, 0x4b800001, FFLAGS_NX},
+		{"f32_to_i32 -2.5", func(rm uint64) (uint64, uint64) {
+			return sfToInt(BINARY32, rawBits(0xc0200000), true, 32, rm)
+		}, 0xfffffffffffffffe, 0xfffffffffffffffd, FFLAGS_NX},
+		{"f64_to_f32 tie", func(rm uint64) (uint64, uint64) {
+			r, flags := sfConvert(BINARY32, BINARY64, rawBits(0x3ff0000010000000), rm)
+			return r.Uint64(), flags
+		}, 0x3f800000, 0x3f800001, FFLAGS_NX},
+	}
+	for _, test := range tests {
+		if got, flags := test.got(RM_RNE); got != test.rne || flags != test.flags {
+			t.Errorf("%s rne: got %#x flags %#x, want %#x flags %#x", test.name, got, flags, test.rne, test.flags)
+		}
+		if got, flags := test.got(RM_RMM); got != test.rmm || flags != test.flags {
+			t.Errorf("%s rmm: got %#x flags %#x, want %#x flags %#x", test.name, got, flags, test.rmm, test.flags)
+		}
+	}
+}
+
+func binaryFunc(f floatFormat, op func(floatFormat, *big.Int, *big.Int, uint64) (*big.Int, uint64), a, b uint64) func(uint64) (uint64, uint64) {
+	return func(rm uint64) (uint64, uint64) {
+		return binaryVector(f, op)([]uint64{a, b}, rm)
+	}
+}
@@ -0,0 +1,332 @@
+// Generates TestFloat-style test vectors using the host FPU as reference.
+//
+//   gcc -O0 -frounding-math -fno-math-errno -mavx512f -mfma gen.c -lm -o gen
+//   ./gen
+//
+// Every rounding mode gets its own directory (rne, rtz, rdn, rup), every
+// function its own file named after the TestFloat function. A line holds
+// hex operands, the result and fflags, e.g.
+//
+//   3F800000 40000000 40400000 00
+//
+// NaN results are replaced with the RISC-V canonical NaN and saturated
+// integer results follow the RISC-V rules, flag bits match fflags.
+// RMM is not supported by x86 and is covered by unit tests instead.
+#include <fenv.h>
+#include <immintrin.h>
+#include <math.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <string.h>
+#include <sys/stat.h>
+
+#define COUNT 250
+
+static const struct {
+    const char *dir;
+    int mode;
+} modes[] = {
+    {"rne", FE_TONEAREST},
+    {"rtz", FE_TOWARDZERO},
+    {"rdn", FE_DOWNWARD},
+    {"rup", FE_UPWARD},
+};
+
+static uint64_t state = 0x2545f4914f6cdd1dull;
+
+static uint64_t rnd(void) {
+    state ^= state << 13;
+    state ^= state >> 7;
+    state ^= state << 17;
+    return state;
+}
+
+static unsigned fflags(void) {
+    unsigned flags = 0;
+    if (fetestexcept(FE_INEXACT)) flags |= 0x01;
+    if (fetestexcept(FE_UNDERFLOW)) flags |= 0x02;
+    if (fetestexcept(FE_OVERFLOW)) flags |= 0x04;
+    if (fetestexcept(FE_DIVBYZERO)) flags |= 0x08;
+    if (fetestexcept(FE_INVALID)) flags |= 0x10;
+    return flags;
+}
+
+// significand with random bit runs, often with trailing zeros to hit ties
+static uint64_t randSig(int bits) {
+    uint64_t sig;
+    switch (rnd() % 4) {
+    case 0:
+        sig = rnd();
+        break;
+    case 1: {
+        int a = rnd() % bits, b = rnd() % bits;
+        sig = (a > b ? (1ull << a) - (1ull << b) : (1ull << b) - (1ull << a));
+        break;
+    }
+    case 2:
+        sig = rnd() & ~((1ull << (rnd() % bits)) - 1);
+        break;
+    default:
+        sig = ~rnd() >> (rnd() % 64) | 1;
+        break;
+    }
+    return sig & ((1ull << bits) - 1);
+}
+
+static uint64_t randFloat(int expBits, int fracBits, int near, int useNear) {
+    uint64_t expMax = (1ull << expBits) - 1;
+    uint64_t sign = rnd() & 1;
+    uint64_t exp;
+    switch (rnd() % 16) {
+    case 0: // специальные значения
+        switch (rnd() % 8) {
+        case 0: return sign << (expBits + fracBits);
+        case 1: return (sign << (expBits + fracBits)) | (expMax << fracBits);
+        case 2: return (expMax << fracBits) | (1ull << (fracBits - 1)) | (rnd() & 1);
+        case 3: return (expMax << fracBits) | 1;
+        case 4: return (sign << (expBits + fracBits)) | 1;
+        case 5: return (sign << (expBits + fracBits)) | (1ull << fracBits);
+        case 6: return (sign << (expBits + fracBits)) | ((expMax - 1) << fracBits) | ((1ull << fracBits) - 1);
+        default: return (sign << (expBits + fracBits)) | ((uint64_t)((1 << (expBits - 1)) - 1) << fracBits);
+        }
+    case 1: // денормализованные
+        exp = 0;
+        break;
+    case 2: // около границ диапазона
+        exp = rnd() & 1 ? 1 + rnd() % 4 : expMax - 1 - rnd() % 4;
+        break;
+    default:
+        if (useNear) {
+            int e = near + (int)(rnd() % 2 * (rnd() % (fracBits + 4))) * (rnd() & 1 ? 1 : -1);
+            if (e < 1) e = 1;
+            if (e > (int)expMax - 1) e = expMax - 1;
+            exp = e;
+        } else {
+            exp = 1 + rnd() % (expMax - 1);
+        }
+    }
+    return (sign << (expBits + fracBits)) | (exp << fracBits) | randSig(fracBits);
+}
+
+static uint32_t f32(int near, int useNear) { return randFloat(8, 23, near, useNear); }
+static uint64_t f64(int near, int useNear) { return randFloat(11, 52, near, useNear); }
+static int exp32(uint32_t a) { return (a >> 23) & 0xff; }
+static int exp64(uint64_t a) { return (a >> 52) & 0x7ff; }
+
+static float asF32(uint32_t a) { float f; memcpy(&f, &a, 4); return f; }
+static double asF64(uint64_t a) { double d; memcpy(&d, &a, 8); return d; }
+
+static uint32_t bits32(float f) {
+    uint32_t a;
+    if (isnan(f)) return 0x7fc00000;
+    memcpy(&a, &f, 4);
+    return a;
+}
+
+static uint64_t bits64(double d) {
+    uint64_t a;
+    if (isnan(d)) return 0x7ff8000000000000ull;
+    memcpy(&a, &d, 8);
+    return a;
+}
+
+static FILE *open(const char *dir, const char *name) {
+    char path[256];
+    snprintf(path, sizeof path, "%s/%s.tv", dir, name);
+    return fopen(path, "w");
+}
+
+enum { ADD, SUB, MUL, DIV, SQRT, MULADD };
+static const char *ops[] = {"add", "sub", "mul", "div", "sqrt", "mulAdd"};
+
+static void genF32(const char *dir, int op) {
+    char name[32];
+    snprintf(name, sizeof name, "f32_%s", ops[op]);
+    FILE *out = open(dir, name);
+    for (int i = 0; i < COUNT; i++) {
+        volatile float a, b, c, r;
+        uint32_t x = f32(0, 0), y = f32(exp32(x), op != MUL && op != DIV);
+        uint32_t z = f32(exp32(x) + exp32(y) - 127, 1);
+        a = asF32(x), b = asF32(y), c = asF32(z);
+        feclearexcept(FE_ALL_EXCEPT);
+        switch (op) {
+        case ADD: r = a + b; break;
+        case SUB: r = a - b; break;
+        case MUL: r = a * b; break;
+        case DIV: r = a / b; break;
+        case SQRT: r = _mm_cvtss_f32(_mm_sqrt_ss(_mm_set_ss(a))); break;
+        default: r = _mm_cvtss_f32(_mm_fmadd_ss(_mm_set_ss(a), _mm_set_ss(b), _mm_set_ss(c))); break;
+        }
+        unsigned flags = fflags();
+        if (op == SQRT)
+            fprintf(out, "%08X %08X %02X\n", x, bits32(r), flags);
+        else if (op == MULADD)
+            fprintf(out, "%08X %08X %08X %08X %02X\n", x, y, z, bits32(r), flags);
+        else
+            fprintf(out, "%08X %08X %08X %02X\n", x, y, bits32(r), flags);
+    }
+    fclose(out);
+}
+
+static void genF64(const char *dir, int op) {
+    char name[32];
+    snprintf(name, sizeof name, "f64_%s", ops[op]);
+    FILE *out = open(dir, name);
+    for (int i = 0; i < COUNT; i++) {
+        volatile double a, b, c, r;
+        uint64_t x = f64(0, 0), y = f64(exp64(x), op != MUL && op != DIV);
+        uint64_t z = f64(exp64(x) + exp64(y) - 1023, 1);
+        a = asF64(x), b = asF64(y), c = asF64(z);
+        feclearexcept(FE_ALL_EXCEPT);
+        switch (op) {
+        case ADD: r = a + b; break;
+        case SUB: r = a - b; break;
+        case MUL: r = a * b; break;
+        case DIV: r = a / b; break;
+        case SQRT: r = _mm_cvtsd_f64(_mm_sqrt_sd(_mm_set_sd(0), _mm_set_sd(a))); break;
+        default: r = _mm_cvtsd_f64(_mm_fmadd_sd(_mm_set_sd(a), _mm_set_sd(b), _mm_set_sd(c))); break;
+        }
+        unsigned flags = fflags();
+        if (op == SQRT)
+            fprintf(out, "%016llX %016llX %02X\n", (unsigned long long)x, (unsigned long long)bits64(r), flags);
+        else if (op == MULADD)
+            fprintf(out, "%016llX %016llX %016llX %016llX %02X\n", (unsigned long long)x,
+                    (unsigned long long)y, (unsigned long long)z, (unsigned long long)bits64(r), flags);
+        else
+            fprintf(out, "%016llX %016llX %016llX %02X\n", (unsigned long long)x,
+                    (unsigned long long)y, (unsigned long long)bits64(r), flags);
+    }
+    fclose(out);
+}
+
+static void genFloatConvert(const char *dir) {
+    FILE *out = open(dir, "f64_to_f32");
+    for (int i = 0; i < COUNT; i++) {
+        uint64_t x = i % 2 ? f64(0, 0) : f64(1023 + (int)(rnd() % 300) - 150, 1);
+        volatile double a = asF64(x);
+        feclearexcept(FE_ALL_EXCEPT);
+        volatile float r = a;
+        unsigned flags = fflags();
+        fprintf(out, "%016llX %08X %02X\n", (unsigned long long)x, bits32(r), flags);
+    }
+    fclose(out);
+    out = open(dir, "f32_to_f64");
+    for (int i = 0; i < COUNT; i++) {
+        uint32_t x = f32(0, 0);
+        volatile float a = asF32(x);
+        feclearexcept(FE_ALL_EXCEPT);
+        volatile double r = a;
+        unsigned flags = fflags();
+        fprintf(out, "%08X %016llX %02X\n", x, (unsigned long long)bits64(r), flags);
+    }
+    fclose(out);
+}
+
+static uint64_t randInt(void) {
+    uint64_t x = randSig(64);
+    return rnd() % 4 == 0 ? x >> (rnd() % 64) : x;
+}
+
+static void genIntToFloat(const char *dir) {
+    static const char *names[] = {
+        "i32_to_f32", "ui32_to_f32", "i64_to_f32", "ui64_to_f32",
+        "i32_to_f64", "ui32_to_f64", "i64_to_f64", "ui64_to_f64",
+    };
+    for (int n = 0; n < 8; n++) {
+        FILE *out = open(dir, names[n]);
+        for (int i = 0; i < COUNT; i++) {
+            uint64_t x = randInt();
+            int wide = n & 2, uns = n & 1, dbl = n & 4;
+            if (!wide) x = (uint32_t)x;
+            volatile uint64_t v = x;
+            volatile float f;
+            volatile double d;
+            feclearexcept(FE_ALL_EXCEPT);
+            if (dbl) {
+                if (!wide) d = uns ? (double)(uint32_t)v : (double)(int32_t)v;
+                else d = uns ? (double)v : (double)(int64_t)v;
+            } else {
+                if (!wide) f = uns ? (float)(uint32_t)v : (float)(int32_t)v;
+                else f = uns ? (float)v : (float)(int64_t)v;
+            }
+            unsigned flags = fflags();
+            if (wide)
+                fprintf(out, "%016llX ", (unsigned long long)x);
+            else
+                fprintf(out, "%08X ", (uint32_t)x);
+            if (dbl)
+                fprintf(out, "%016llX %02X\n", (unsigned long long)bits64(d), flags);
+            else
+                fprintf(out, "%08X %02X\n", bits32(f), flags);
+        }
+        fclose(out);
+    }
+}
+
+// RISC-V saturation for invalid conversions
+static uint64_t saturate(int isNaN, int neg, int wide, int uns) {
+    if (uns) return isNaN || !neg ? (wide ? ~0ull : 0xffffffffull) : 0;
+    if (isNaN || !neg) return wide ? 0x7fffffffffffffffull : 0x7fffffff;
+    return wide ? 0x8000000000000000ull : 0x80000000;
+}
+
+static void genFloatToInt(const char *dir) {
+    static const char *names[] = {
+        "f32_to_i32", "f32_to_ui32", "f32_to_i64", "f32_to_ui64",
+        "f64_to_i32", "f64_to_ui32", "f64_to_i64", "f64_to_ui64",
+    };
+    for (int n = 0; n < 8; n++) {
+        FILE *out = open(dir, names[n]);
+        for (int i = 0; i < COUNT; i++) {
+            int wide = n & 2, uns = n & 1, dbl = n & 4;
+            // показатели вокруг разрядности целого
+            int e = rnd() % 3 ? (int)(rnd() % (wide ? 70 : 38)) - 4 : (int)(rnd() % 200) - 100;
+            uint64_t x = dbl ? f64(1023 + e, 1) : f32(127 + e, 1);
+            int isNaN = dbl ? isnan(asF64(x)) : isnan(asF32(x));
+            int neg = dbl ? x >> 63 : x >> 31;
+            __m128d vd = _mm_set_sd(asF64(x));
+            __m128 vf = _mm_set_ss(asF32(x));
+            uint64_t r;
+            feclearexcept(FE_ALL_EXCEPT);
+            switch (n) {
+            case 0: r = (uint32_t)_mm_cvtss_si32(vf); break;
+            case 1: r = _mm_cvtss_u32(vf); break;
+            case 2: r = _mm_cvtss_si64(vf); break;
+            case 3: r = _mm_cvtss_u64(vf); break;
+            case 4: r = (uint32_t)_mm_cvtsd_si32(vd); break;
+            case 5: r = _mm_cvtsd_u32(vd); break;
+            case 6: r = _mm_cvtsd_si64(vd); break;
+            default: r = _mm_cvtsd_u64(vd); break;
+            }
+            unsigned flags = fflags();
+            if (flags & 0x10) {
+                r = saturate(isNaN, neg, wide, uns);
+                flags = 0x10;
+            }
+            if (dbl)
+                fprintf(out, "%016llX ", (unsigned long long)x);
+            else
+                fprintf(out, "%08X ", (uint32_t)x);
+            if (wide)
+                fprintf(out, "%016llX %02X\n", (unsigned long long)r, flags);
+            else
+                fprintf(out, "%08X %02X\n", (uint32_t)r, flags);
+        }
+        fclose(out);
+    }
+}
+
+int main(void) {
+    for (int m = 0; m < 4; m++) {
+        mkdir(modes[m].dir, 0755);
+        fesetround(modes[m].mode);
+        for (int op = ADD; op <= MULADD; op++) {
+            genF32(modes[m].dir, op);
+            genF64(modes[m].dir, op);
+        }
+        genFloatConvert(modes[m].dir);
+        genIntToFloat(modes[m].dir);
+        genFloatToInt(modes[m].dir);
+    }
+    return 0;
+}
@@ -0,0 +1,137 @@
+package main
+
+const (
+	DEFAULT_TLB_ENTRIES = 64
+	DEFAULT_TLB_WAYS    = 4
+)
+
+type TLBStats struct {
+	Hits    uint64
+	Misses  uint64
+	Flushes uint64
+}
+
+// tlbEntry caches a leaf PTE for one 4KiB virtual page.
+// Superpages are cached per 4KiB page, their span is kept for sfence.vma.
+type tlbEntry struct {
+	valid   bool
+	vpn     uint64
+	asid    uint64
+	ppn     uint64
+	pte     uint64
+	span    uint64 // число младших бит VPN внутри листовой страницы
+	lastUse uint64
+}
+
+// matches checks that vpn belongs to the page mapped by the entry
+func (e *tlbEntry) matches(vpn uint64) bool {
+	return e.vpn>>e.span == vpn>>e.span
+}
+
+// TLB is a set-associative translation cache with LRU replacement
+type TLB struct {
+	sets  [][]tlbEntry
+	clock uint64
+	stats TLBStats
+}
+
+func NewTLB(entries, ways int) *TLB {
+	if ways <= 0 || entries < ways {
+		ways = 1
+	}
+	if entries <= 0 {
+		entries = 1
+	}
+	tlb := &TLB{sets: make([][]tlbEntry, entries/ways)}
+	for i := range tlb.sets {
+		tlb.sets[i] = make([]tlbEntry, ways)
+	}
+	return tlb
+}
+
+func (tlb *TLB) set(vpn uint64) []tlbEntry {
+	return tlb.sets[vpn%uint64(len(tlb.sets))]
+}
+
+// lookup returns entry for vaddr in address space asid or nil on miss
+func (tlb *TLB) lookup(vaddr uint64, asid uint64) *tlbEntry {
+	vpn := vaddr >> PAGE_SHIFT
+	set := tlb.set(vpn)
+	for i := range set {
+		e := &set[i]
+		if e.valid && e.vpn == vpn && (e.asid == asid || e.pte&PTE_G != 0) {
+			tlb.clock++
+			e.lastUse = tlb.clock
+			tlb.stats.Hits++
+			return e
+		}
+	}
+	tlb.stats.Misses++
+	return nil
+}
+
+// insert caches a leaf of Sv39/Sv48/Sv57 found at level
+func (tlb *TLB) insert(vaddr, asid, ppn, pte, level uint64) {
+	tlb.insertSpan(vaddr, asid, ppn, pte, VPN_BITS*level)
+}
+
+// insertSpan caches a leaf covering 2^span 4KiB pages
+func (tlb *TLB) insertSpan(vaddr, asid, ppn, pte, span uint64) {
+	vpn := vaddr >> PAGE_SHIFT
+	set := tlb.set(vpn)
+	victim := &set[0]
+	for i := range set {
+		e := &set[i]
+		if !e.valid || e.vpn == vpn {
+			victim = e
+			break
+		}
+		if e.lastUse < victim.lastUse {
+			victim = e
+		}
+	}
+	tlb.clock++
+	*victim = tlbEntry{
+		valid:   true,
+		vpn:     vpn,
+		asid:    asid,
+		ppn:     ppn,
+		pte:     pte,
+		span:    span,
+		lastUse: tlb.clock,
+	}
+}
+
+// flush invalidates entries as sfence.vma does: all entries,
+// entries of one address space (except global ones) and/or one page
+func (tlb *TLB) flush(vaddr uint64, byAddr bool, asid uint64, byAsid bool) {
+	tlb.stats.Flushes++
+	vpn := vaddr >> PAGE_SHIFT
+	for _, set := range tlb.sets {
+		for i := range set {
+			e := &set[i]
+			if byAddr && !e.matches(vpn) {
+				continue
+			}
+			if byAsid && (e.asid != asid || e.pte&PTE_G != 0) {
+				continue
+			}
+			e.valid = false
+		}
+	}
+}
+
+func (tlb *TLB) Stats() TLBStats {
+	return tlb.stats
+}
+
+// TLBStats returns hit/miss counters of instruction and data TLBs
+func (cpu *Cpu) TLBStats() (itlb TLBStats, dtlb TLBStats) {
+	return cpu.itlb.Stats(), cpu.dtlb.Stats()
+}
+
+// ConfigureTLB replaces both TLBs with empty ones of the given geometry
+func (cpu *Cpu) ConfigureTLB(entries, ways int) {
+	cpu.itlb = NewTLB(entries, ways)
+	cpu.dtlb = NewTLB(entries, ways)
+}
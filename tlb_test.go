@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestTLBHitsAndSfence(t *testing.T) {
+	cpu := NewCPU()
+	pt := newPageTables(cpu, SATP_MODE_SV39)
+	pt.mapPage(0x1000, 0x80200000, PTE_R|PTE_W, 0)
+	cpu.bus.Write(0x80200000, 1, DOUBLEWORD)
+	cpu.bus.Write(0x80300000, 2, DOUBLEWORD)
+	cpu.privilege = SUPERVISOR_MODE
+	cpu.writeReg(1, 0x1000)
+
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if _, dtlb := cpu.TLBStats(); dtlb.Hits != 1 || dtlb.Misses != 1 {
+		t.Fatalf("dtlb hits=%d misses=%d, want 1/1", dtlb.Hits, dtlb.Misses)
+	}
+
+	// без sfence.vma используется закэшированная трансляция
+	pt.mapPage(0x1000, 0x80300000, PTE_R|PTE_W, 0)
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if cpu.readReg(4) != 1 {
+		t.Fatalf("stale translation expected before sfence.vma, x4=%d", cpu.readReg(4))
+	}
+	cpu.ExecuteInst(0x12008073) // sfence.vma x1, x0
+	cpu.ExecuteInst(0x0000b203) // ld x4, 0(x1)
+	if cpu.readReg(4) != 2 {
+		t.Fatalf("new translation expected after sfence.vma, x4=%d", cpu.readReg(4))
+	}
+}
+
+func TestTLBFlush(t *testing.T) {
+	tlb := NewTLB(16, 2)
+	tlb.insert(0x1000, 1, 0x80200, PTE_V|PTE_R, 0)
+	tlb.insert(0x2000, 2, 0x80201, PTE_V|PTE_R, 0)
+	tlb.insert(0x3000, 1, 0x80202, PTE_V|PTE_R|PTE_G, 0)
+	tlb.insert(0x201000, 1, 0x80401, PTE_V|PTE_R, 1) // часть мегастраницы
+
+	tlb.flush(0, false, 1, true)
+	if tlb.lookup(0x1000, 1) != nil || tlb.lookup(0x201000, 1) != nil {
+		t.Fatalf("entries of ASID 1 must be flushed")
+	}
+	if tlb.lookup(0x2000, 2) == nil || tlb.lookup(0x3000, 1) == nil {
+		t.Fatalf("other ASIDs and global entries must survive ASID flush")
+	}
+
+	tlb.insert(0x201000, 1, 0x80401, PTE_V|PTE_R, 1)
+	tlb.flush(0x3ff000, true, 0, false)
+	if tlb.lookup(0x201000, 1) != nil {
+		t.Fatalf("flush by address must drop the whole superpage")
+	}
+	if tlb.lookup(0x3000, 5) == nil {
+		t.Fatalf("global entry must match any ASID")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	UART_BASE      uint64 = 0x10000000
+	UART_SIZE      uint64 = 0x100
+	UART_FIFO_SIZE        = 16
+)
+
+// NS16550A registers
+const (
+	UART_RBR uint64 = 0 // приём (чтение, DLAB=0)
+	UART_THR uint64 = 0 // передача (запись, DLAB=0)
+	UART_DLL uint64 = 0 // делитель, младший байт (DLAB=1)
+	UART_IER uint64 = 1
+	UART_DLM uint64 = 1 // делитель, старший байт (DLAB=1)
+	UART_IIR uint64 = 2 // чтение
+	UART_FCR uint64 = 2 // запись
+	UART_LCR uint64 = 3
+	UART_MCR uint64 = 4
+	UART_LSR uint64 = 5
+	UART_MSR uint64 = 6
+	UART_SCR uint64 = 7
+)
+
+const (
+	UART_IER_RDI  uint8 = 1 << 0 // есть принятые данные
+	UART_IER_THRI uint8 = 1 << 1 // THR пуст
+	UART_IER_RLSI uint8 = 1 << 2 // статус линии
+	UART_IER_MASK uint8 = 0x0f
+
+	UART_IIR_NO_INT uint8 = 0x01
+	UART_IIR_THRI   uint8 = 0x02
+	UART_IIR_RDI    uint8 = 0x04
+	UART_IIR_RLSI   uint8 = 0x06
+	UART_IIR_RX_TO  uint8 = 0x0c
+	UART_IIR_FIFO   uint8 = 0xc0
+
+	UART_FCR_ENABLE   uint8 = 1 << 0
+	UART_FCR_CLEAR_RX uint8 = 1 << 1
+	UART_FCR_CLEAR_TX uint8 = 1 << 2
+
+	UART_LCR_DLAB uint8 = 1 << 7
+	UART_MCR_LOOP uint8 = 1 << 4
+
+	UART_LSR_DR   uint8 = 1 << 0
+	UART_LSR_OE   uint8 = 1 << 1
+	UART_LSR_THRE uint8 = 1 << 5
+	UART_LSR_TEMT uint8 = 1 << 6
+)
+
+// Uart is a NS16550A-compatible serial port. Received bytes come from
+// in, transmitted bytes go to out. Transmission completes immediately,
+// so THR is always empty after the write.
+type Uart struct {
+	mu         sync.Mutex
+	rx         chan byte // приёмный FIFO
+	out        io.Writer
+	ier        uint8
+	fcr        uint8
+	lcr        uint8
+	mcr        uint8
+	lsr        uint8
+	scr        uint8
+	dll        uint8
+	dlm        uint8
+	thrPending bool // прерывание "THR пуст" ещё не обслужено
+}
+
+func NewUart(in io.Reader, out io.Writer) *Uart {
+	u := &Uart{
+		rx:  make(chan byte, UART_FIFO_SIZE),
+		out: out,
+		lsr: UART_LSR_THRE | UART_LSR_TEMT,
+	}
+	if in != nil {
+		go u.receive(in)
+	}
+	return u
+}
+
+// NewStdioUart connects the console to the host terminal
+func NewStdioUart() *Uart {
+	return NewUart(os.Stdin, os.Stdout)
+}
+
+// NewFileUart reads guest input from inPath and writes guest output
+// to outPath. Either path may be empty. Named pipes work as well.
+func NewFileUart(inPath, outPath string) (*Uart, error) {
+	var in io.Reader
+	var out io.Writer
+	if inPath != "" {
+		f, err := os.Open(inPath)
+		if err != nil {
+			return nil, err
+		}
+		in = f
+	}
+	if outPath != "" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+	}
+	return NewUart(in, out), nil
+}
+
+// NewPipeUart returns the host ends of the console:
+// writes to input reach the guest, guest output is read from output
+func NewPipeUart() (uart *Uart, input io.WriteCloser, output io.Reader) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	return NewUart(inR, outW), inW, outR
+}
+
+func (u *Uart) receive(in io.Reader) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := in.Read(buf); err != nil {
+			return
+		}
+		u.rx <- buf[0]
+	}
+}
+
+// triggerLevel is the RX FIFO fill level that raises data interrupt
+func (u *Uart) triggerLevel() int {
+	if u.fcr&UART_FCR_ENABLE == 0 {
+		return 1
+	}
+	return [4]int{1, 4, 8, 14}[u.fcr>>6]
+}
+
+// iir returns the highest priority pending interrupt
+func (u *Uart) iir() uint8 {
+	fifo := uint8(0)
+	if u.fcr&UART_FCR_ENABLE != 0 {
+		fifo = UART_IIR_FIFO
+	}
+	switch {
+	case u.ier&UART_IER_RLSI != 0 && u.lsr&UART_LSR_OE != 0:
+		return fifo | UART_IIR_RLSI
+	case u.ier&UART_IER_RDI != 0 && len(u.rx) >= u.triggerLevel():
+		return fifo | UART_IIR_RDI
+	case u.ier&UART_IER_RDI != 0 && len(u.rx) > 0:
+		return fifo | UART_IIR_RX_TO
+	case u.ier&UART_IER_THRI != 0 && u.thrPending:
+		return fifo | UART_IIR_THRI
+	default:
+		return fifo | UART_IIR_NO_INT
+	}
+}
+
+// Interrupting reports the level of the interrupt output
+func (u *Uart) Interrupting() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.iir()&UART_IIR_NO_INT == 0
+}
+
+func (u *Uart) Read(addr uint64, size uint8) (uint64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	dlab := u.lcr&UART_LCR_DLAB != 0
+	switch addr {
+	case UART_RBR:
+		if dlab {
+			return uint64(u.dll), nil
+		}
+		select {
+		case b := <-u.rx:
+			return uint64(b), nil
+		default:
+			return 0, nil
+		}
+	case UART_IER:
+		if dlab {
+			return uint64(u.dlm), nil
+		}
+		return uint64(u.ier), nil
+	case UART_IIR:
+		iir := u.iir()
+		if iir&^UART_IIR_FIFO == UART_IIR_THRI {
+			u.thrPending = false
+		}
+		return uint64(iir), nil
+	case UART_LCR:
+		return uint64(u.lcr), nil
+	case UART_MCR:
+		return uint64(u.mcr), nil
+	case UART_LSR:
+		lsr := u.lsr
+		if len(u.rx) > 0 {
+			lsr |= UART_LSR_DR
+		}
+		u.lsr &^= UART_LSR_OE
+		return uint64(lsr), nil
+	case UART_MSR:
+		return 0, nil
+	case UART_SCR:
+		return uint64(u.scr), nil
+	default:
+		return 0, nil
+	}
+}
+
+func (u *Uart) Write(addr uint64, value uint64, size uint8) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	b := uint8(value)
+	dlab := u.lcr&UART_LCR_DLAB != 0
+	switch addr {
+	case UART_THR:
+		if dlab {
+			u.dll = b
+			break
+		}
+		u.transmit(b)
+		u.thrPending = true
+	case UART_IER:
+		if dlab {
+			u.dlm = b
+			break
+		}
+		// включение прерывания при уже пустом THR сразу его вызывает
+		if b&UART_IER_THRI != 0 && u.ier&UART_IER_THRI == 0 {
+			u.thrPending = true
+		}
+		u.ier = b & UART_IER_MASK
+	case UART_FCR:
+		if b&UART_FCR_CLEAR_RX != 0 {
+			u.drain()
+		}
+		u.fcr = b &^ (UART_FCR_CLEAR_RX | UART_FCR_CLEAR_TX)
+	case UART_LCR:
+		u.lcr = b
+	case UART_MCR:
+		u.mcr = b & 0x1f
+	case UART_SCR:
+		u.scr = b
+	}
+	return nil
+}
+
+func (u *Uart) transmit(b byte) {
+	if u.mcr&UART_MCR_LOOP != 0 {
+		select {
+		case u.rx <- b:
+		default:
+			u.lsr |= UART_LSR_OE
+		}
+		return
+	}
+	if u.out != nil {
+		u.out.Write([]byte{b})
+	}
+}
+
+func (u *Uart) drain() {
+	for {
+		select {
+		case <-u.rx:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUartTransmit(t *testing.T) {
+	var out bytes.Buffer
+	cpu := NewCPU()
+	uart := NewUart(nil, &out)
+	if err := cpu.bus.Map("uart", UART_BASE, UART_SIZE, uart); err != nil {
+		t.Fatal(err)
+	}
+	cpu.writeReg(1, UART_BASE)
+	cpu.ExecuteProgram([]uint32{
+		0x04800113, // addi x2, x0, 'H'
+		0x00208023, // sb x2, 0(x1)
+		0x06900113, // addi x2, x0, 'i'
+		0x00208023, // sb x2, 0(x1)
+		0x0050c183, // lbu x3, 5(x1)
+	})
+	if out.String() != "Hi" {
+		t.Fatalf("output %q, want \"Hi\"", out.String())
+	}
+	if lsr := uint8(cpu.readReg(3)); lsr&UART_LSR_THRE == 0 || lsr&UART_LSR_DR != 0 {
+		t.Fatalf("wrong LSR %#x", lsr)
+	}
+}
+
+func TestUartReceive(t *testing.T) {
+	uart := NewUart(strings.NewReader("ok"), nil)
+	uart.Write(UART_IER, uint64(UART_IER_RDI), BYTE)
+	deadline := time.Now().Add(time.Second)
+	for len(uart.rx) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !uart.Interrupting() {
+		t.Fatalf("received data must raise interrupt")
+	}
+	if iir, _ := uart.Read(UART_IIR, BYTE); uint8(iir) != UART_IIR_RDI {
+		t.Fatalf("IIR=%#x, want %#x", iir, UART_IIR_RDI)
+	}
+	var got []byte
+	for {
+		lsr, _ := uart.Read(UART_LSR, BYTE)
+		if uint8(lsr)&UART_LSR_DR == 0 {
+			break
+		}
+		b, _ := uart.Read(UART_RBR, BYTE)
+		got = append(got, byte(b))
+	}
+	if string(got) != "ok" || uart.Interrupting() {
+		t.Fatalf("received %q, interrupting=%v", got, uart.Interrupting())
+	}
+}
+
+func TestUartInterrupts(t *testing.T) {
+	uart := NewUart(nil, nil)
+	uart.Write(UART_FCR, uint64(UART_FCR_ENABLE|0x40), BYTE) // порог 4 байта
+	uart.Write(UART_MCR, uint64(UART_MCR_LOOP), BYTE)
+	uart.Write(UART_IER, uint64(UART_IER_THRI|UART_IER_RDI), BYTE)
+
+	// THR пуст сразу после разрешения прерывания
+	if iir, _ := uart.Read(UART_IIR, BYTE); uint8(iir) != UART_IIR_FIFO|UART_IIR_THRI {
+		t.Fatalf("IIR=%#x, want THR empty", iir)
+	}
+	if uart.Interrupting() {
+		t.Fatalf("reading IIR must clear THR empty interrupt")
+	}
+
+	uart.Write(UART_THR, 'a', BYTE)
+	if iir, _ := uart.Read(UART_IIR, BYTE); uint8(iir) != UART_IIR_FIFO|UART_IIR_RX_TO {
+		t.Fatalf("IIR=%#x, want character timeout", iir)
+	}
+	for _, b := range []byte("bcd") {
+		uart.Write(UART_THR, uint64(b), BYTE)
+	}
+	if iir, _ := uart.Read(UART_IIR, BYTE); uint8(iir) != UART_IIR_FIFO|UART_IIR_RDI {
+		t.Fatalf("IIR=%#x, want data available", iir)
+	}
+
+	uart.Write(UART_LCR, uint64(UART_LCR_DLAB), BYTE)
+	uart.Write(UART_DLL, 0x03, BYTE)
+	uart.Write(UART_LCR, 0x03, BYTE)
+	if dll := uart.dll; dll != 3 || len(uart.rx) != 4 {
+		t.Fatalf("divisor latch write must not transmit, dll=%d rx=%d", dll, len(uart.rx))
+	}
+}
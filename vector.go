@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Vector unit parameters
+const (
+	DEFAULT_VLEN uint64 = 128
+	DEFAULT_ELEN uint64 = 64
+	MAX_VLEN     uint64 = 65536
+)
+
+// vtype fields
+const (
+	VTYPE_VLMUL      uint64 = 7
+	VTYPE_VSEW_SHIFT uint64 = 3
+	VTYPE_VSEW       uint64 = 7 << VTYPE_VSEW_SHIFT
+	VTYPE_VTA        uint64 = 1 << 6
+	VTYPE_VMA        uint64 = 1 << 7
+	VTYPE_VILL       uint64 = 1 << 63
+)
+
+// vcsr fields
+const (
+	VCSR_VXSAT      uint64 = 1
+	VCSR_VXRM_SHIFT uint64 = 1
+	VCSR_VXRM       uint64 = 3 << VCSR_VXRM_SHIFT
+)
+
+// Fixed-point rounding modes
+const (
+	VXRM_RNU uint64 = 0 // к ближайшему, вверх при равенстве
+	VXRM_RNE uint64 = 1 // к ближайшему, к чётному при равенстве
+	VXRM_RDN uint64 = 2 // отбрасывание
+	VXRM_ROD uint64 = 3 // к нечётному
+)
+
+// VectorConfig describes the vector unit of a hart
+type VectorConfig struct {
+	VLEN uint64 // бит в векторном регистре
+	ELEN uint64 // максимальная ширина элемента
+	// AgnosticOnes makes tail and masked-off elements all ones under
+	// agnostic policies, otherwise they are left undisturbed
+	AgnosticOnes bool
+}
+
+// ConfigureVector replaces the vector register file, VLEN must be
+// a power of two not less than ELEN, ELEN is 32 or 64
+func (cpu *Cpu) ConfigureVector(cfg VectorConfig) error {
+	if cfg.ELEN != 32 && cfg.ELEN != 64 {
+		return fmt.Errorf("Unsupported ELEN %d", cfg.ELEN)
+	}
+	if cfg.VLEN < cfg.ELEN || cfg.VLEN > MAX_VLEN || cfg.VLEN&(cfg.VLEN-1) != 0 {
+		return fmt.Errorf("Unsupported VLEN %d", cfg.VLEN)
+	}
+	cpu.vcfg = cfg
+	cpu.vregisters = make([]byte, 32*cfg.VLEN/8)
+	cpu.resetVector()
+	return nil
+}
+
+func (cpu *Cpu) resetVector() {
+	for i := range cpu.vregisters {
+		cpu.vregisters[i] = 0
+	}
+	cpu.csr[VTYPE] = VTYPE_VILL
+	cpu.csr[VL] = 0
+	cpu.csr[VSTART] = 0
+	cpu.csr[VCSR] = 0
+}
+
+func (cpu *Cpu) vlenb() uint64 {
+	return cpu.vcfg.VLEN / 8
+}
+
+// vsEnabled checks mstatus.VS and, with V=1, vsstatus.VS
+func (cpu *Cpu) vsEnabled() bool {
+	off := EXT_STATUS_OFF << MSTATUS_VS_SHIFT
+	if cpu.virt && cpu.csr[VSSTATUS]&MSTATUS_VS == off {
+		return false
+	}
+	return cpu.csr[MSTATUS]&MSTATUS_VS != off
+}
+
+func (cpu *Cpu) markVSDirty() {
+	cpu.csr[MSTATUS] |= EXT_STATUS_DIRTY << MSTATUS_VS_SHIFT
+	if cpu.virt {
+		cpu.csr[VSSTATUS] |= EXT_STATUS_DIRTY << MSTATUS_VS_SHIFT
+	}
+}
+
+// vsCheck raises illegal instruction when the vector unit is off
+func (cpu *Cpu) vsCheck(inst InstWord) bool {
+	if !cpu.vsEnabled() {
+		cpu.IllegalInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
+// vconf is the decoded vtype
+type vconf struct {
+	sew   uint64 // ширина элемента в битах
+	lmul8 uint64 // LMUL*8: дробные 1/8, 1/4, 1/2 дают 1, 2, 4
+	vlmax uint64
+	ta    bool
+	ma    bool
+}
+
+// decodeVtype validates vtype against the configured VLEN/ELEN
+func (cpu *Cpu) decodeVtype(vtype uint64) (vconf, bool) {
+	if vtype&^(VTYPE_VLMUL|VTYPE_VSEW|VTYPE_VTA|VTYPE_VMA) != 0 {
+		return vconf{}, false
+	}
+	vsew := (vtype & VTYPE_VSEW) >> VTYPE_VSEW_SHIFT
+	vlmul := vtype & VTYPE_VLMUL
+	if vsew > 3 || vlmul == 4 {
+		return vconf{}, false
+	}
+	vc := vconf{
+		sew: 8 << vsew,
+		ta:  vtype&VTYPE_VTA != 0,
+		ma:  vtype&VTYPE_VMA != 0,
+	}
+	if vlmul < 4 {
+		vc.lmul8 = 8 << vlmul
+	} else {
+		vc.lmul8 = 8 >> (8 - vlmul)
+	}
+	// дробный LMUL должен вмещать хотя бы один элемент шириной ELEN
+	if vc.sew > cpu.vcfg.ELEN || vc.sew*8 > vc.lmul8*cpu.vcfg.ELEN {
+		return vconf{}, false
+	}
+	vc.vlmax = vc.lmul8 * cpu.vcfg.VLEN / (8 * vc.sew)
+	return vc, true
+}
+
+// vtypeCheck returns the current configuration,
+// instructions depending on it are illegal while vtype.vill is set
+func (cpu *Cpu) vtypeCheck(inst InstWord) (vconf, bool) {
+	vc, ok := cpu.decodeVtype(cpu.csr[VTYPE])
+	if !ok {
+		cpu.IllegalInst(uint32(inst))
+	}
+	return vc, ok
+}
+
+// setVectorConfig implements vset{i}vl{i}: unsupported vtype sets vill
+func (cpu *Cpu) setVectorConfig(inst InstWord, vtype uint64, avl uint64) {
+	if vc, ok := cpu.decodeVtype(vtype); ok {
+		cpu.csr[VTYPE] = vtype
+		cpu.csr[VL] = min(avl, vc.vlmax)
+	} else {
+		cpu.csr[VTYPE] = VTYPE_VILL
+		cpu.csr[VL] = 0
+	}
+	cpu.csr[VSTART] = 0
+	cpu.writeReg(inst.rd(), cpu.csr[VL])
+	cpu.markVSDirty()
+}
+
+// vsetAVL selects the application vector length: rs1=x0 requests
+// VLMAX, or keeps current vl if rd is x0 too
+func (cpu *Cpu) vsetAVL(inst InstWord) uint64 {
+	switch {
+	case inst.rs1() != 0:
+		return cpu.readReg(inst.rs1())
+	case inst.rd() != 0:
+		return ^uint64(0)
+	default:
+		return cpu.csr[VL]
+	}
+}
+
+func (cpu *Cpu) vsetvli(inst InstWord) {
+	if cpu.vsCheck(inst) {
+		cpu.setVectorConfig(inst, inst.x(20, 11), cpu.vsetAVL(inst))
+	}
+}
+
+func (cpu *Cpu) vsetivli(inst InstWord) {
+	if cpu.vsCheck(inst) {
+		cpu.setVectorConfig(inst, inst.x(20, 10), inst.x(15, 5))
+	}
+}
+
+func (cpu *Cpu) vsetvl(inst InstWord) {
+	if cpu.vsCheck(inst) {
+		cpu.setVectorConfig(inst, cpu.readReg(inst.rs2()), cpu.vsetAVL(inst))
+	}
+}
+
+// readVElem reads element idx of eew bits from the register group
+// starting at reg, groups occupy consecutive registers
+func (cpu *Cpu) readVElem(reg, idx, eew uint64) uint64 {
+	off := reg*cpu.vlenb() + idx*eew/8
+	var val uint64
+	for i := uint64(0); i < eew/8; i++ {
+		val |= uint64(cpu.vregisters[off+i]) << (8 * i)
+	}
+	return val
+}
+
+func (cpu *Cpu) writeVElem(reg, idx, eew, val uint64) {
+	off := reg*cpu.vlenb() + idx*eew/8
+	for i := uint64(0); i < eew/8; i++ {
+		cpu.vregisters[off+i] = byte(val >> (8 * i))
+	}
+}
+
+func (cpu *Cpu) readVMask(reg, idx uint64) bool {
+	return cpu.vregisters[reg*cpu.vlenb()+idx/8]>>(idx%8)&1 != 0
+}
+
+func (cpu *Cpu) writeVMask(reg, idx uint64, set bool) {
+	b := &cpu.vregisters[reg*cpu.vlenb()+idx/8]
+	if set {
+		*b |= 1 << (idx % 8)
+	} else {
+		*b &^= 1 << (idx % 8)
+	}
+}
+
+// vActive reports whether element i is enabled by v0.t, vm=1 means unmasked
+func (cpu *Cpu) vActive(inst InstWord, i uint64) bool {
+	return inst.x(25, 1) == 1 || cpu.readVMask(0, i)
+}
+
+// vAgnostic fills an element whose value is not defined by the policy
+func (cpu *Cpu) vAgnostic(reg, idx, eew uint64) {
+	if cpu.vcfg.AgnosticOnes {
+		cpu.writeVElem(reg, idx, eew, ^uint64(0))
+	}
+}
+
+// vTail applies the tail policy to elements past vl. With fractional
+// LMUL the tail extends to the end of the register.
+func (cpu *Cpu) vTail(vc vconf, reg, eew uint64) {
+	if !vc.ta {
+		return
+	}
+	end := max(vc.vlmax, cpu.vcfg.VLEN/eew)
+	for i := cpu.csr[VL]; i < end; i++ {
+		cpu.vAgnostic(reg, i, eew)
+	}
+}
+
+// vForEach computes active body elements of the destination group
+// and applies mask and tail policies to the rest
+func (cpu *Cpu) vForEach(inst InstWord, vc vconf, vd, eew uint64, op func(i uint64) uint64) {
+	cpu.vForEachFrom(inst, vc, vd, eew, cpu.csr[VSTART], op)
+}
+
+// vForEachFrom is vForEach with elements below start left unchanged
+func (cpu *Cpu) vForEachFrom(inst InstWord, vc vconf, vd, eew, start uint64, op func(i uint64) uint64) {
+	for i := start; i < cpu.csr[VL]; i++ {
+		if cpu.vActive(inst, i) {
+			cpu.writeVElem(vd, i, eew, op(i))
+		} else if vc.ma {
+			cpu.vAgnostic(vd, i, eew)
+		}
+	}
+	cpu.vTail(vc, vd, eew)
+}
+
+// vForEachMask is vForEach for instructions producing a mask,
+// their tail is always agnostic
+func (cpu *Cpu) vForEachMask(inst InstWord, vc vconf, vd uint64, op func(i uint64) bool) {
+	for i := cpu.csr[VSTART]; i < cpu.csr[VL]; i++ {
+		if cpu.vActive(inst, i) {
+			cpu.writeVMask(vd, i, op(i))
+		} else if vc.ma && cpu.vcfg.AgnosticOnes {
+			cpu.writeVMask(vd, i, true)
+		}
+	}
+	if cpu.vcfg.AgnosticOnes {
+		for i := cpu.csr[VL]; i < cpu.vcfg.VLEN; i++ {
+			cpu.writeVMask(vd, i, true)
+		}
+	}
+}
+
+// vRegs returns the number of registers in a group of EMUL*8 = emul8
+func vRegs(emul8 uint64) uint64 {
+	return max(1, emul8/8)
+}
+
+// vAligned checks that a register group of EMUL*8 = emul8 is valid
+func vAligned(reg, emul8 uint64) bool {
+	return emul8 >= 1 && emul8 <= 64 && reg%vRegs(emul8) == 0
+}
+
+// vMaskOverlap reports a masked instruction writing v0 as data
+func vMaskOverlap(inst InstWord, vd uint64) bool {
+	return inst.x(25, 1) == 0 && vd == 0
+}
+
+func vMask(sew uint64) uint64 {
+	if sew == 64 {
+		return ^uint64(0)
+	}
+	return 1<<sew - 1
+}
+
+func vSext(val, sew uint64) int64 {
+	return signExtend(int64(val), uint(sew))
+}
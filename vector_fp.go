@@ -0,0 +1,536 @@
+package main
+
+import (
+	"math"
+)
+
+// vfOp computes an element from vs2 (a) and vs1/rs1 (b)
+type vfOp func(f fpFmt, a, b uint64, rm uint64) (uint64, uint64)
+
+// VECTOR_FP_OPS decodes OPFVV (index 0) and OPFVF (index 1) by funct6
+var VECTOR_FP_OPS = [2][64]vectorOp{
+	{
+		0x00: vfBinary(fpAdd),
+		0x01: vfReduce(fpAdd, false),
+		0x02: vfBinary(fpSub),
+		0x03: vfReduce(fpAdd, false),
+		0x04: vfBinary(vfMin),
+		0x05: vfReduce(vfMin, false),
+		0x06: vfBinary(vfMax),
+		0x07: vfReduce(vfMax, false),
+		0x08: vfBinary(vfSgnj),
+		0x09: vfBinary(vfSgnjn),
+		0x0a: vfBinary(vfSgnjx),
+		0x10: vwfunary0,
+		0x12: vfunary0,
+		0x13: vfunary1,
+		0x18: vfCompare(vfEq),
+		0x19: vfCompare(vfLe),
+		0x1b: vfCompare(vfLt),
+		0x1c: vfCompare(vfNe),
+		0x20: vfBinary(fpDiv),
+		0x24: vfBinary(fpMul),
+		0x28: vfFused(false, false, false),
+		0x29: vfFused(false, true, true),
+		0x2a: vfFused(false, false, true),
+		0x2b: vfFused(false, true, false),
+		0x2c: vfFused(true, false, false),
+		0x2d: vfFused(true, true, true),
+		0x2e: vfFused(true, false, true),
+		0x2f: vfFused(true, true, false),
+		0x30: vfWiden(fpAdd, false),
+		0x31: vfReduce(fpAdd, true),
+		0x32: vfWiden(fpSub, false),
+		0x33: vfReduce(fpAdd, true),
+		0x34: vfWiden(fpAdd, true),
+		0x36: vfWiden(fpSub, true),
+		0x38: vfWiden(fpMul, false),
+		0x3c: vfWidenFused(false, false),
+		0x3d: vfWidenFused(true, true),
+		0x3e: vfWidenFused(false, true),
+		0x3f: vfWidenFused(true, false),
+	},
+	{
+		0x00: vfBinary(fpAdd),
+		0x02: vfBinary(fpSub),
+		0x04: vfBinary(vfMin),
+		0x06: vfBinary(vfMax),
+		0x08: vfBinary(vfSgnj),
+		0x09: vfBinary(vfSgnjn),
+		0x0a: vfBinary(vfSgnjx),
+		0x0e: vfslide1up,
+		0x0f: vfslide1down,
+		0x10: vfmvSF,
+		0x17: vfmerge,
+		0x18: vfCompare(vfEq),
+		0x19: vfCompare(vfLe),
+		0x1b: vfCompare(vfLt),
+		0x1c: vfCompare(vfNe),
+		0x1d: vfCompare(vfGt),
+		0x1f: vfCompare(vfGe),
+		0x20: vfBinary(fpDiv),
+		0x21: vfBinary(vfRdiv),
+		0x24: vfBinary(fpMul),
+		0x27: vfBinary(vfRsub),
+		0x28: vfFused(false, false, false),
+		0x29: vfFused(false, true, true),
+		0x2a: vfFused(false, false, true),
+		0x2b: vfFused(false, true, false),
+		0x2c: vfFused(true, false, false),
+		0x2d: vfFused(true, true, true),
+		0x2e: vfFused(true, false, true),
+		0x2f: vfFused(true, true, false),
+		0x30: vfWiden(fpAdd, false),
+		0x32: vfWiden(fpSub, false),
+		0x34: vfWiden(fpAdd, true),
+		0x36: vfWiden(fpSub, true),
+		0x38: vfWiden(fpMul, false),
+		0x3c: vfWidenFused(false, false),
+		0x3d: vfWidenFused(true, true),
+		0x3e: vfWidenFused(false, true),
+		0x3f: vfWidenFused(true, false),
+	},
+}
+
+// vfFmt maps SEW to the scalar format of elements
+func vfFmt(sew uint64) fpFmt {
+	if sew == 32 {
+		return FMT_S
+	}
+	return FMT_D
+}
+
+// vfCheck validates an FP operation on sew-bit elements and returns
+// the dynamic rounding mode, vector FP has no rm field
+func (cpu *Cpu) vfCheck(inst InstWord, sew uint64) (uint64, bool) {
+	rm := cpu.readCSR(FRM)
+	if !cpu.fpEnabled() || (sew != 32 && sew != 64) || rm > RM_RMM {
+		cpu.IllegalInst(uint32(inst))
+		return 0, false
+	}
+	return rm, true
+}
+
+func vfMin(f fpFmt, a, b uint64, rm uint64) (uint64, uint64)  { return fpMinMax(f, a, b, false) }
+func vfMax(f fpFmt, a, b uint64, rm uint64) (uint64, uint64)  { return fpMinMax(f, a, b, true) }
+func vfRsub(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) { return fpSub(f, b, a, rm) }
+func vfRdiv(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) { return fpDiv(f, b, a, rm) }
+
+func vfSgnj(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	return a&^fpSignBit(f) | b&fpSignBit(f), 0
+}
+
+func vfSgnjn(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	return a&^fpSignBit(f) | ^b&fpSignBit(f), 0
+}
+
+func vfSgnjx(f fpFmt, a, b uint64, rm uint64) (uint64, uint64) {
+	return a ^ b&fpSignBit(f), 0
+}
+
+// vfBinary builds vd[i] = op(vs2[i], vs1[i] or f[rs1])
+func vfBinary(op vfOp) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		rm, ok := cpu.vfCheck(inst, vc.sew)
+		if !ok || !cpu.vCheckRegs(inst, vc, false) {
+			return
+		}
+		f := vfFmt(vc.sew)
+		var flags uint64
+		cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+			res, fl := op(f, cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false), rm)
+			flags |= fl
+			return res
+		})
+		cpu.accrueFlags(flags)
+	}
+}
+
+// vfFused builds multiply-add instructions: vfmacc-style ones add to vd,
+// vfmadd-style ones multiply vd and add vs2
+func vfFused(addendVd, negProd, negAdd bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		rm, ok := cpu.vfCheck(inst, vc.sew)
+		if !ok || !cpu.vCheckRegs(inst, vc, false) {
+			return
+		}
+		f, vd := vfFmt(vc.sew), inst.rd()
+		var flags uint64
+		cpu.vForEach(inst, vc, vd, vc.sew, func(i uint64) uint64 {
+			a, b, d := cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false), cpu.readVElem(vd, i, vc.sew)
+			var res, fl uint64
+			if addendVd {
+				res, fl = fpFma(f, b, a, d, negProd, negAdd, rm)
+			} else {
+				res, fl = fpFma(f, b, d, a, negProd, negAdd, rm)
+			}
+			flags |= fl
+			return res
+		})
+		cpu.accrueFlags(flags)
+	}
+}
+
+// vfWidenValue converts a single to double, it is exact
+func vfWidenValue(a uint64) (uint64, uint64) {
+	return fpConvert(FMT_D, FMT_S, a, RM_RNE)
+}
+
+// vfWiden builds 2*SEW vd[i] = op(vs2[i], src1) computed on widened operands
+func vfWiden(op vfOp, wideVs2 bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		rm, ok := cpu.vfCheck(inst, vc.sew)
+		if !ok || !cpu.vWidenCheck(inst, vc, wideVs2) {
+			return
+		}
+		var flags uint64
+		cpu.vForEach(inst, vc, inst.rd(), 2*vc.sew, func(i uint64) uint64 {
+			b, fb := vfWidenValue(cpu.vSrc1(inst, i, vc.sew, false))
+			a, fa := cpu.readVElem(inst.rs2(), i, 2*vc.sew), uint64(0)
+			if !wideVs2 {
+				a, fa = vfWidenValue(cpu.readVElem(inst.rs2(), i, vc.sew))
+			}
+			res, fl := op(FMT_D, a, b, rm)
+			flags |= fa | fb | fl
+			return res
+		})
+		cpu.accrueFlags(flags)
+	}
+}
+
+// vfWidenFused builds widening multiply-add to 2*SEW vd
+func vfWidenFused(negProd, negAdd bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		rm, ok := cpu.vfCheck(inst, vc.sew)
+		if !ok || !cpu.vWidenCheck(inst, vc, false) {
+			return
+		}
+		vd := inst.rd()
+		var flags uint64
+		cpu.vForEach(inst, vc, vd, 2*vc.sew, func(i uint64) uint64 {
+			a, fa := vfWidenValue(cpu.readVElem(inst.rs2(), i, vc.sew))
+			b, fb := vfWidenValue(cpu.vSrc1(inst, i, vc.sew, false))
+			res, fl := fpFma(FMT_D, b, a, cpu.readVElem(vd, i, 2*vc.sew), negProd, negAdd, rm)
+			flags |= fa | fb | fl
+			return res
+		})
+		cpu.accrueFlags(flags)
+	}
+}
+
+// vfReduce builds FP reductions. Unordered sums are computed in
+// element order, which is one of the orders the spec allows.
+func vfReduce(op vfOp, widen bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		rm, ok := cpu.vfCheck(inst, vc.sew)
+		if !ok {
+			return
+		}
+		dsew := vc.sew
+		if widen {
+			dsew = 2 * vc.sew
+		}
+		if cpu.csr[VSTART] != 0 || dsew > cpu.vcfg.ELEN || !vAligned(inst.rs2(), vc.lmul8) {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+		if cpu.csr[VL] == 0 {
+			return
+		}
+		f := vfFmt(dsew)
+		acc := cpu.readVElem(inst.rs1(), 0, dsew)
+		var flags uint64
+		for i := uint64(0); i < cpu.csr[VL]; i++ {
+			if !cpu.vActive(inst, i) {
+				continue
+			}
+			elem, fe := cpu.readVElem(inst.rs2(), i, vc.sew), uint64(0)
+			if widen {
+				elem, fe = vfWidenValue(elem)
+			}
+			var fl uint64
+			acc, fl = op(f, acc, elem, rm)
+			flags |= fe | fl
+		}
+		cpu.vReduceResult(vc, inst.rd(), dsew, acc)
+		cpu.accrueFlags(flags)
+	}
+}
+
+func vfEq(f fpFmt, a, b uint64) (bool, uint64) { return fpCompare(f, a, b, false, true) }
+func vfLe(f fpFmt, a, b uint64) (bool, uint64) { return fpCompare(f, a, b, true, true) }
+func vfLt(f fpFmt, a, b uint64) (bool, uint64) { return fpCompare(f, a, b, true, false) }
+func vfGt(f fpFmt, a, b uint64) (bool, uint64) { return fpCompare(f, b, a, true, false) }
+func vfGe(f fpFmt, a, b uint64) (bool, uint64) { return fpCompare(f, b, a, true, true) }
+
+func vfNe(f fpFmt, a, b uint64) (bool, uint64) {
+	eq, flags := fpCompare(f, a, b, false, true)
+	return !eq, flags
+}
+
+// vfCompare builds mask vd[i] = op(vs2[i], src1)
+func vfCompare(op func(f fpFmt, a, b uint64) (bool, uint64)) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if _, ok := cpu.vfCheck(inst, vc.sew); !ok || !cpu.vCheckRegs(inst, vc, true) {
+			return
+		}
+		f := vfFmt(vc.sew)
+		var flags uint64
+		cpu.vForEachMask(inst, vc, inst.rd(), func(i uint64) bool {
+			res, fl := op(f, cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false))
+			flags |= fl
+			return res
+		})
+		cpu.accrueFlags(flags)
+	}
+}
+
+// vwfunary0 implements vfmv.f.s
+func vwfunary0(cpu *Cpu, inst InstWord, vc vconf) {
+	if inst.rs1() != 0 || inst.x(25, 1) == 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if _, ok := cpu.vfCheck(inst, vc.sew); ok {
+		cpu.writeFReg(inst.rd(), vfFmt(vc.sew), cpu.readVElem(inst.rs2(), 0, vc.sew))
+	}
+}
+
+// vfmvSF implements vfmv.s.f
+func vfmvSF(cpu *Cpu, inst InstWord, vc vconf) {
+	if inst.rs2() != 0 || inst.x(25, 1) == 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if _, ok := cpu.vfCheck(inst, vc.sew); ok && cpu.csr[VSTART] < cpu.csr[VL] {
+		cpu.vReduceResult(vc, inst.rd(), vc.sew, cpu.readFReg(inst.rs1(), vfFmt(vc.sew)))
+	}
+}
+
+// vfmerge implements vfmerge.vfm and vfmv.v.f
+func vfmerge(cpu *Cpu, inst InstWord, vc vconf) {
+	if _, ok := cpu.vfCheck(inst, vc.sew); ok {
+		vmerge(cpu, inst, vc)
+	}
+}
+
+func vfslide1up(cpu *Cpu, inst InstWord, vc vconf) {
+	if _, ok := cpu.vfCheck(inst, vc.sew); ok {
+		cpu.vSlide1(inst, vc, true, cpu.readFReg(inst.rs1(), vfFmt(vc.sew)))
+	}
+}
+
+func vfslide1down(cpu *Cpu, inst InstWord, vc vconf) {
+	if _, ok := cpu.vfCheck(inst, vc.sew); ok {
+		cpu.vSlide1(inst, vc, false, cpu.readFReg(inst.rs1(), vfFmt(vc.sew)))
+	}
+}
+
+// vfunary0 implements conversions, vs1 selects the operation:
+// bits 4:3 are single-width, widening or narrowing, bits 2:0 the kind
+func vfunary0(cpu *Cpu, inst InstWord, vc vconf) {
+	rm, ok := cpu.vfCheckConvert(inst, vc)
+	if !ok {
+		return
+	}
+	kind := inst.rs1() & 7
+	srcEew, dstEew := vc.sew, vc.sew
+	switch inst.rs1() >> 3 {
+	case 1:
+		dstEew = 2 * vc.sew
+	case 2:
+		srcEew = 2 * vc.sew
+	}
+	var flags uint64
+	cpu.vForEach(inst, vc, inst.rd(), dstEew, func(i uint64) uint64 {
+		a := cpu.readVElem(inst.rs2(), i, srcEew)
+		var res, fl uint64
+		switch kind {
+		case 0, 1:
+			res, fl = fpToInt(vfFmt(srcEew), a, kind == 1, uint(dstEew), rm)
+		case 6, 7:
+			res, fl = fpToInt(vfFmt(srcEew), a, kind == 7, uint(dstEew), RM_RTZ)
+		case 2, 3:
+			res, fl = fpFromInt(vfFmt(dstEew), a, kind == 3, uint(srcEew), rm)
+		case 4:
+			res, fl = fpConvert(vfFmt(dstEew), vfFmt(srcEew), a, rm)
+		case 5:
+			// округление к нечётному: отбрасывание с установкой
+			// младшего бита у неточного конечного результата
+			res, fl = fpConvert(vfFmt(dstEew), vfFmt(srcEew), a, RM_RTZ)
+			if fl&FFLAGS_NX != 0 && fpClassify(vfFmt(dstEew), res)&FCLASS_FINITE != 0 {
+				res |= 1
+			}
+		}
+		flags |= fl
+		return res
+	})
+	cpu.accrueFlags(flags)
+}
+
+// vfCheckConvert validates element widths of a conversion:
+// FP sides are 32 or 64 bits, integer sides at least 8 bits
+func (cpu *Cpu) vfCheckConvert(inst InstWord, vc vconf) (uint64, bool) {
+	kind := inst.rs1() & 7
+	srcEew, dstEew := vc.sew, vc.sew
+	srcMul, dstMul := vc.lmul8, vc.lmul8
+	valid := true
+	switch inst.rs1() >> 3 {
+	case 0:
+		valid = kind != 4 && kind != 5
+	case 1:
+		dstEew, dstMul = 2*vc.sew, 2*vc.lmul8
+		valid = kind != 5
+	case 2:
+		srcEew, srcMul = 2*vc.sew, 2*vc.lmul8
+	default:
+		valid = false
+	}
+	fpSrc := kind <= 1 || kind >= 4
+	fpDst := kind >= 2 && kind <= 5
+	isFP := func(eew uint64) bool { return eew == 32 || eew == 64 }
+	valid = valid && max(srcEew, dstEew) <= cpu.vcfg.ELEN &&
+		(!fpSrc || isFP(srcEew)) && (!fpDst || isFP(dstEew)) &&
+		vAligned(inst.rs2(), srcMul) && vAligned(inst.rd(), dstMul) && !vMaskOverlap(inst, inst.rd())
+	rm := cpu.readCSR(FRM)
+	if !valid || !cpu.fpEnabled() || rm > RM_RMM {
+		cpu.IllegalInst(uint32(inst))
+		return 0, false
+	}
+	return rm, true
+}
+
+// vfunary1 implements vfsqrt, vfrsqrt7, vfrec7 and vfclass
+func vfunary1(cpu *Cpu, inst InstWord, vc vconf) {
+	rm, ok := cpu.vfCheck(inst, vc.sew)
+	if !ok || !cpu.vCheckRegs(inst, vc, false) {
+		return
+	}
+	f := vfFmt(vc.sew)
+	var op func(a uint64) (uint64, uint64)
+	switch inst.rs1() {
+	case 0x00:
+		op = func(a uint64) (uint64, uint64) { return fpSqrt(f, a, rm) }
+	case 0x04:
+		op = func(a uint64) (uint64, uint64) { return vfRsqrt7(f, a) }
+	case 0x05:
+		op = func(a uint64) (uint64, uint64) { return vfRec7(f, a, rm) }
+	case 0x10:
+		op = func(a uint64) (uint64, uint64) { return fpClassify(f, a), 0 }
+	default:
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	var flags uint64
+	cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+		res, fl := op(cpu.readVElem(inst.rs2(), i, vc.sew))
+		flags |= fl
+		return res
+	})
+	cpu.accrueFlags(flags)
+}
+
+// VFREC7_TABLE and VFRSQRT7_TABLE are the 7-bit estimate tables of the
+// specification: an entry is round(2^8/y)-2^7 where y is 1/x or 1/sqrt(x)
+// of the midpoint x of the input interval selected by the index
+var VFREC7_TABLE = func() (t [128]uint64) {
+	for i := range t {
+		x := 1 + (float64(i)+0.5)/128
+		t[i] = uint64(math.Round(256/x)) - 128
+	}
+	return
+}()
+
+var VFRSQRT7_TABLE = func() (t [128]uint64) {
+	for i := range t {
+		// старший бит индекса - младший бит порядка,
+		// чётному порядку соответствует интервал [2, 4)
+		x := 1 + (float64(i&63)+0.5)/64
+		if i < 64 {
+			x *= 2
+		}
+		t[i] = uint64(math.Round(256/math.Sqrt(x))) - 128
+	}
+	return
+}()
+
+// vfNormalize splits a non-zero finite value into an exponent and
+// fraction, subnormals get a negative exponent and hidden bit dropped
+func vfNormalize(ff floatFormat, a uint64) (exp int64, frac uint64) {
+	fracBits := uint64(ff.fracBits)
+	exp = int64(a >> fracBits & (1<<ff.expBits - 1))
+	frac = a & (1<<fracBits - 1)
+	if exp == 0 {
+		for frac>>(fracBits-1)&1 == 0 {
+			exp--
+			frac <<= 1
+		}
+		frac = frac << 1 & (1<<fracBits - 1)
+	}
+	return exp, frac
+}
+
+// vfRsqrt7 estimates 1/sqrt(a) to 7 bits
+func vfRsqrt7(f fpFmt, a uint64) (uint64, uint64) {
+	ff := f.format()
+	sign := a & fpSignBit(f)
+	class := fpClassify(f, a)
+	switch {
+	case class&FCLASS_SNAN != 0:
+		return fpCanonicalNaN(f), FFLAGS_NV
+	case class&FCLASS_QNAN != 0:
+		return fpCanonicalNaN(f), 0
+	case class&(FCLASS_NEG_ZERO|FCLASS_POS_ZERO) != 0:
+		return sign | fpInf(f), FFLAGS_DZ
+	case sign != 0:
+		return fpCanonicalNaN(f), FFLAGS_NV
+	case class&FCLASS_POS_INF != 0:
+		return 0, 0
+	}
+	fracBits := uint64(ff.fracBits)
+	exp, frac := vfNormalize(ff, a)
+	idx := uint64(exp&1)<<6 | frac>>(fracBits-6)
+	bias := int64(1)<<(ff.expBits-1) - 1
+	outExp := uint64((3*bias - 1 - exp) / 2)
+	return outExp<<fracBits | VFRSQRT7_TABLE[idx]<<(fracBits-7), 0
+}
+
+// vfRec7 estimates 1/a to 7 bits, results of tiny inputs overflow
+func vfRec7(f fpFmt, a uint64, rm uint64) (uint64, uint64) {
+	ff := f.format()
+	sign := a & fpSignBit(f)
+	class := fpClassify(f, a)
+	switch {
+	case class&FCLASS_SNAN != 0:
+		return fpCanonicalNaN(f), FFLAGS_NV
+	case class&FCLASS_QNAN != 0:
+		return fpCanonicalNaN(f), 0
+	case class&(FCLASS_NEG_ZERO|FCLASS_POS_ZERO) != 0:
+		return sign | fpInf(f), FFLAGS_DZ
+	case class&(FCLASS_NEG_INF|FCLASS_POS_INF) != 0:
+		return sign, 0
+	}
+	fracBits := uint64(ff.fracBits)
+	exp, frac := vfNormalize(ff, a)
+	if exp < -1 {
+		// результат не представим: бесконечность или наибольшее
+		// конечное в зависимости от направления округления
+		toMax := rm == RM_RTZ || rm == RM_RDN && sign == 0 || rm == RM_RUP && sign != 0
+		if toMax {
+			return sign | (fpInf(f) - 1), FFLAGS_OF | FFLAGS_NX
+		}
+		return sign | fpInf(f), FFLAGS_OF | FFLAGS_NX
+	}
+	bias := int64(1)<<(ff.expBits-1) - 1
+	outExp := 2*bias - 1 - exp
+	outFrac := VFREC7_TABLE[frac>>(fracBits-7)] << (fracBits - 7)
+	if outExp <= 0 {
+		// субнормальный результат: явная единица и сдвиг
+		outFrac = outFrac>>1 | 1<<(fracBits-1)
+		if outExp < 0 {
+			outFrac >>= 1
+		}
+		outExp = 0
+	}
+	return sign | uint64(outExp)<<fracBits | outFrac, 0
+}
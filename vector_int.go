@@ -0,0 +1,1093 @@
+package main
+
+import (
+	"math/bits"
+)
+
+// OP-V categories selected by funct3
+const (
+	OPIVV uint64 = 0
+	OPFVV uint64 = 1
+	OPMVV uint64 = 2
+	OPIVI uint64 = 3
+	OPIVX uint64 = 4
+	OPFVF uint64 = 5
+	OPMVX uint64 = 6
+	OPCFG uint64 = 7
+)
+
+// vectorOp executes an OP-V instruction with a valid vtype
+type vectorOp func(cpu *Cpu, inst InstWord, vc vconf)
+
+// vIntOp computes an element from vs2 (a) and vs1/rs1/imm (b)
+type vIntOp func(cpu *Cpu, a, b, sew uint64) uint64
+
+// VECTOR_OPS decodes OP-V instructions by funct3 and funct6
+var VECTOR_OPS = [7][64]vectorOp{
+	OPIVV: {
+		0x00: vBinary(vAdd, false),
+		0x02: vBinary(vSub, false),
+		0x04: vBinary(vMinu, false),
+		0x05: vBinary(vMin, false),
+		0x06: vBinary(vMaxu, false),
+		0x07: vBinary(vMax, false),
+		0x09: vBinary(vAnd, false),
+		0x0a: vBinary(vOr, false),
+		0x0b: vBinary(vXor, false),
+		0x0c: vrgather,
+		0x0e: vrgatherei16,
+		0x10: vAddCarry(false),
+		0x11: vCarryOut(false),
+		0x12: vAddCarry(true),
+		0x13: vCarryOut(true),
+		0x17: vmerge,
+		0x18: vCompare(vEq),
+		0x19: vCompare(vNe),
+		0x1a: vCompare(vLtu),
+		0x1b: vCompare(vLt),
+		0x1c: vCompare(vLeu),
+		0x1d: vCompare(vLe),
+		0x20: vBinary(vSaddu, false),
+		0x21: vBinary(vSadd, false),
+		0x22: vBinary(vSsubu, false),
+		0x23: vBinary(vSsub, false),
+		0x25: vBinary(vSll, false),
+		0x27: vBinary(vSmul, false),
+		0x28: vBinary(vSrl, false),
+		0x29: vBinary(vSra, false),
+		0x2a: vBinary(vSsrl, false),
+		0x2b: vBinary(vSsra, false),
+		0x2c: vNarrow(vNsrl),
+		0x2d: vNarrow(vNsra),
+		0x2e: vNarrow(vNclipu),
+		0x2f: vNarrow(vNclip),
+		0x30: vReduce(vAdd, VWIDEN_UNSIGNED),
+		0x31: vReduce(vAdd, VWIDEN_SIGNED),
+	},
+	OPIVX: {
+		0x00: vBinary(vAdd, false),
+		0x02: vBinary(vSub, false),
+		0x03: vBinary(vRsub, false),
+		0x04: vBinary(vMinu, false),
+		0x05: vBinary(vMin, false),
+		0x06: vBinary(vMaxu, false),
+		0x07: vBinary(vMax, false),
+		0x09: vBinary(vAnd, false),
+		0x0a: vBinary(vOr, false),
+		0x0b: vBinary(vXor, false),
+		0x0c: vrgather,
+		0x0e: vslideup,
+		0x0f: vslidedown,
+		0x10: vAddCarry(false),
+		0x11: vCarryOut(false),
+		0x12: vAddCarry(true),
+		0x13: vCarryOut(true),
+		0x17: vmerge,
+		0x18: vCompare(vEq),
+		0x19: vCompare(vNe),
+		0x1a: vCompare(vLtu),
+		0x1b: vCompare(vLt),
+		0x1c: vCompare(vLeu),
+		0x1d: vCompare(vLe),
+		0x1e: vCompare(vGtu),
+		0x1f: vCompare(vGt),
+		0x20: vBinary(vSaddu, false),
+		0x21: vBinary(vSadd, false),
+		0x22: vBinary(vSsubu, false),
+		0x23: vBinary(vSsub, false),
+		0x25: vBinary(vSll, false),
+		0x27: vBinary(vSmul, false),
+		0x28: vBinary(vSrl, false),
+		0x29: vBinary(vSra, false),
+		0x2a: vBinary(vSsrl, false),
+		0x2b: vBinary(vSsra, false),
+		0x2c: vNarrow(vNsrl),
+		0x2d: vNarrow(vNsra),
+		0x2e: vNarrow(vNclipu),
+		0x2f: vNarrow(vNclip),
+	},
+	OPIVI: {
+		0x00: vBinary(vAdd, false),
+		0x03: vBinary(vRsub, false),
+		0x09: vBinary(vAnd, false),
+		0x0a: vBinary(vOr, false),
+		0x0b: vBinary(vXor, false),
+		0x0c: vrgather,
+		0x0e: vslideup,
+		0x0f: vslidedown,
+		0x10: vAddCarry(false),
+		0x11: vCarryOut(false),
+		0x17: vmerge,
+		0x18: vCompare(vEq),
+		0x19: vCompare(vNe),
+		0x1c: vCompare(vLeu),
+		0x1d: vCompare(vLe),
+		0x1e: vCompare(vGtu),
+		0x1f: vCompare(vGt),
+		0x20: vBinary(vSaddu, false),
+		0x21: vBinary(vSadd, false),
+		0x25: vBinary(vSll, true),
+		0x27: vmvNr,
+		0x28: vBinary(vSrl, true),
+		0x29: vBinary(vSra, true),
+		0x2a: vBinary(vSsrl, true),
+		0x2b: vBinary(vSsra, true),
+		0x2c: vNarrow(vNsrl),
+		0x2d: vNarrow(vNsra),
+		0x2e: vNarrow(vNclipu),
+		0x2f: vNarrow(vNclip),
+	},
+	OPMVV: {
+		0x00: vReduce(vAdd, VWIDEN_NONE),
+		0x01: vReduce(vAnd, VWIDEN_NONE),
+		0x02: vReduce(vOr, VWIDEN_NONE),
+		0x03: vReduce(vXor, VWIDEN_NONE),
+		0x04: vReduce(vMinu, VWIDEN_NONE),
+		0x05: vReduce(vMin, VWIDEN_NONE),
+		0x06: vReduce(vMaxu, VWIDEN_NONE),
+		0x07: vReduce(vMax, VWIDEN_NONE),
+		0x08: vBinary(vAaddu, false),
+		0x09: vBinary(vAadd, false),
+		0x0a: vBinary(vAsubu, false),
+		0x0b: vBinary(vAsub, false),
+		0x10: vwxunary0,
+		0x12: vxunary0,
+		0x14: vmunary0,
+		0x17: vcompress,
+		0x18: vMaskLogic(func(a, b bool) bool { return a && !b }),
+		0x19: vMaskLogic(func(a, b bool) bool { return a && b }),
+		0x1a: vMaskLogic(func(a, b bool) bool { return a || b }),
+		0x1b: vMaskLogic(func(a, b bool) bool { return a != b }),
+		0x1c: vMaskLogic(func(a, b bool) bool { return a || !b }),
+		0x1d: vMaskLogic(func(a, b bool) bool { return !(a && b) }),
+		0x1e: vMaskLogic(func(a, b bool) bool { return !(a || b) }),
+		0x1f: vMaskLogic(func(a, b bool) bool { return a == b }),
+		0x20: vBinary(vDivu, false),
+		0x21: vBinary(vDiv, false),
+		0x22: vBinary(vRemu, false),
+		0x23: vBinary(vRem, false),
+		0x24: vBinary(vMulhu, false),
+		0x25: vBinary(vMul, false),
+		0x26: vBinary(vMulhsu, false),
+		0x27: vBinary(vMulh, false),
+		0x29: vMulAdd(vMadd),
+		0x2b: vMulAdd(vNmsub),
+		0x2d: vMulAdd(vMacc),
+		0x2f: vMulAdd(vNmsac),
+		0x30: vWiden(vAdd, VWIDEN_UNSIGNED, false),
+		0x31: vWiden(vAdd, VWIDEN_SIGNED, false),
+		0x32: vWiden(vSub, VWIDEN_UNSIGNED, false),
+		0x33: vWiden(vSub, VWIDEN_SIGNED, false),
+		0x34: vWiden(vAdd, VWIDEN_UNSIGNED, true),
+		0x35: vWiden(vAdd, VWIDEN_SIGNED, true),
+		0x36: vWiden(vSub, VWIDEN_UNSIGNED, true),
+		0x37: vWiden(vSub, VWIDEN_SIGNED, true),
+		0x38: vWiden(vMul, VWIDEN_UNSIGNED, false),
+		0x3a: vWiden(vMul, VWIDEN_SIGNED_UNSIGNED, false),
+		0x3b: vWiden(vMul, VWIDEN_SIGNED, false),
+		0x3c: vWidenMac(VWIDEN_UNSIGNED),
+		0x3d: vWidenMac(VWIDEN_SIGNED),
+		0x3f: vWidenMac(VWIDEN_SIGNED_UNSIGNED),
+	},
+	OPMVX: {
+		0x08: vBinary(vAaddu, false),
+		0x09: vBinary(vAadd, false),
+		0x0a: vBinary(vAsubu, false),
+		0x0b: vBinary(vAsub, false),
+		0x0e: vslide1up,
+		0x0f: vslide1down,
+		0x10: vmvSX,
+		0x20: vBinary(vDivu, false),
+		0x21: vBinary(vDiv, false),
+		0x22: vBinary(vRemu, false),
+		0x23: vBinary(vRem, false),
+		0x24: vBinary(vMulhu, false),
+		0x25: vBinary(vMul, false),
+		0x26: vBinary(vMulhsu, false),
+		0x27: vBinary(vMulh, false),
+		0x29: vMulAdd(vMadd),
+		0x2b: vMulAdd(vNmsub),
+		0x2d: vMulAdd(vMacc),
+		0x2f: vMulAdd(vNmsac),
+		0x30: vWiden(vAdd, VWIDEN_UNSIGNED, false),
+		0x31: vWiden(vAdd, VWIDEN_SIGNED, false),
+		0x32: vWiden(vSub, VWIDEN_UNSIGNED, false),
+		0x33: vWiden(vSub, VWIDEN_SIGNED, false),
+		0x34: vWiden(vAdd, VWIDEN_UNSIGNED, true),
+		0x35: vWiden(vAdd, VWIDEN_SIGNED, true),
+		0x36: vWiden(vSub, VWIDEN_UNSIGNED, true),
+		0x37: vWiden(vSub, VWIDEN_SIGNED, true),
+		0x38: vWiden(vMul, VWIDEN_UNSIGNED, false),
+		0x3a: vWiden(vMul, VWIDEN_SIGNED_UNSIGNED, false),
+		0x3b: vWiden(vMul, VWIDEN_SIGNED, false),
+		0x3c: vWidenMac(VWIDEN_UNSIGNED),
+		0x3d: vWidenMac(VWIDEN_SIGNED),
+		0x3e: vWidenMac(VWIDEN_UNSIGNED_SIGNED),
+		0x3f: vWidenMac(VWIDEN_SIGNED_UNSIGNED),
+	},
+	OPFVV: VECTOR_FP_OPS[0],
+	OPFVF: VECTOR_FP_OPS[1],
+}
+
+func (cpu *Cpu) opivv(inst InstWord) { cpu.vArith(inst, OPIVV) }
+func (cpu *Cpu) opfvv(inst InstWord) { cpu.vArith(inst, OPFVV) }
+func (cpu *Cpu) opmvv(inst InstWord) { cpu.vArith(inst, OPMVV) }
+func (cpu *Cpu) opivi(inst InstWord) { cpu.vArith(inst, OPIVI) }
+func (cpu *Cpu) opivx(inst InstWord) { cpu.vArith(inst, OPIVX) }
+func (cpu *Cpu) opfvf(inst InstWord) { cpu.vArith(inst, OPFVF) }
+func (cpu *Cpu) opmvx(inst InstWord) { cpu.vArith(inst, OPMVX) }
+
+// vArith dispatches OP-V arithmetic. Execution starts from vstart,
+// vstart is cleared when the instruction completes.
+func (cpu *Cpu) vArith(inst InstWord, funct3 uint64) {
+	op := VECTOR_OPS[funct3][inst.x(26, 6)]
+	if op == nil {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if !cpu.vsCheck(inst) {
+		return
+	}
+	vc, ok := cpu.vtypeCheck(inst)
+	if !ok {
+		return
+	}
+	op(cpu, inst, vc)
+	if cpu.exception == nil {
+		cpu.csr[VSTART] = 0
+		cpu.markVSDirty()
+	}
+}
+
+// vSrc1 reads the first source operand of element i: vs1, rs1 or
+// imm5, which is sign-extended unless uimm is set
+func (cpu *Cpu) vSrc1(inst InstWord, i, sew uint64, uimm bool) uint64 {
+	switch inst.x(12, 3) {
+	case OPIVV, OPMVV, OPFVV:
+		return cpu.readVElem(inst.rs1(), i, sew)
+	case OPIVI:
+		if uimm {
+			return inst.x(15, 5)
+		}
+		return uint64(signExtend(int64(inst.x(15, 5)), 5)) & vMask(sew)
+	case OPFVF:
+		return cpu.readFReg(inst.rs1(), vfFmt(sew))
+	default:
+		return cpu.readReg(inst.rs1()) & vMask(sew)
+	}
+}
+
+// vVectorSrc1 reports whether vs1 is a vector register operand
+func vVectorSrc1(inst InstWord) bool {
+	switch inst.x(12, 3) {
+	case OPIVV, OPMVV, OPFVV:
+		return true
+	}
+	return false
+}
+
+// vCheckRegs validates register groups of a single-width operation
+func (cpu *Cpu) vCheckRegs(inst InstWord, vc vconf, vdMask bool) bool {
+	ok := vAligned(inst.rs2(), vc.lmul8) &&
+		(!vVectorSrc1(inst) || vAligned(inst.rs1(), vc.lmul8))
+	if !vdMask {
+		ok = ok && vAligned(inst.rd(), vc.lmul8) && !vMaskOverlap(inst, inst.rd())
+	}
+	if !ok {
+		cpu.IllegalInst(uint32(inst))
+	}
+	return ok
+}
+
+// vBinary builds vd[i] = op(vs2[i], src1), uimm selects unsigned imm5
+func vBinary(op vIntOp, uimm bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vCheckRegs(inst, vc, false) {
+			return
+		}
+		cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+			return op(cpu, cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, uimm), vc.sew)
+		})
+	}
+}
+
+// vMulAdd builds vd[i] = op(vs2[i], src1, vd[i])
+func vMulAdd(op func(a, b, d uint64) uint64) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vCheckRegs(inst, vc, false) {
+			return
+		}
+		vd := inst.rd()
+		cpu.vForEach(inst, vc, vd, vc.sew, func(i uint64) uint64 {
+			return op(cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false), cpu.readVElem(vd, i, vc.sew))
+		})
+	}
+}
+
+func vMacc(a, b, d uint64) uint64  { return b*a + d }
+func vNmsac(a, b, d uint64) uint64 { return d - b*a }
+func vMadd(a, b, d uint64) uint64  { return b*d + a }
+func vNmsub(a, b, d uint64) uint64 { return a - b*d }
+
+// Operand extension of widening instructions
+type vWidenKind int
+
+const (
+	VWIDEN_NONE vWidenKind = iota
+	VWIDEN_UNSIGNED
+	VWIDEN_SIGNED
+	VWIDEN_SIGNED_UNSIGNED // vs2 со знаком, vs1/rs1 без знака
+	VWIDEN_UNSIGNED_SIGNED // vs2 без знака, rs1 со знаком
+)
+
+// extend returns the operands extended to 64 bits
+func (k vWidenKind) extend(a, b, sew uint64) (uint64, uint64) {
+	sa := k == VWIDEN_SIGNED || k == VWIDEN_SIGNED_UNSIGNED
+	sb := k == VWIDEN_SIGNED || k == VWIDEN_UNSIGNED_SIGNED
+	if sa {
+		a = uint64(vSext(a, sew))
+	}
+	if sb {
+		b = uint64(vSext(b, sew))
+	}
+	return a, b
+}
+
+// vWidenCheck validates a widening operation: vd and optionally vs2
+// have 2*SEW and 2*LMUL
+func (cpu *Cpu) vWidenCheck(inst InstWord, vc vconf, wideVs2 bool) bool {
+	vs2Mul := vc.lmul8
+	if wideVs2 {
+		vs2Mul = 2 * vc.lmul8
+	}
+	ok := 2*vc.sew <= cpu.vcfg.ELEN && vAligned(inst.rd(), 2*vc.lmul8) &&
+		vAligned(inst.rs2(), vs2Mul) && !vMaskOverlap(inst, inst.rd()) &&
+		(!vVectorSrc1(inst) || vAligned(inst.rs1(), vc.lmul8))
+	if !ok {
+		cpu.IllegalInst(uint32(inst))
+	}
+	return ok
+}
+
+// vWiden builds 2*SEW vd[i] = op(vs2[i], src1), wideVs2 selects .w forms
+func vWiden(op vIntOp, kind vWidenKind, wideVs2 bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vWidenCheck(inst, vc, wideVs2) {
+			return
+		}
+		dsew := 2 * vc.sew
+		cpu.vForEach(inst, vc, inst.rd(), dsew, func(i uint64) uint64 {
+			b := cpu.vSrc1(inst, i, vc.sew, false)
+			if wideVs2 {
+				a := cpu.readVElem(inst.rs2(), i, dsew)
+				_, b = kind.extend(0, b, vc.sew)
+				return op(cpu, a, b, dsew)
+			}
+			a, b := kind.extend(cpu.readVElem(inst.rs2(), i, vc.sew), b, vc.sew)
+			return op(cpu, a, b, dsew)
+		})
+	}
+}
+
+// vWidenMac builds 2*SEW vd[i] += vs1[i]*vs2[i], kind describes vs2 and vs1
+func vWidenMac(kind vWidenKind) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vWidenCheck(inst, vc, false) {
+			return
+		}
+		dsew := 2 * vc.sew
+		vd := inst.rd()
+		cpu.vForEach(inst, vc, vd, dsew, func(i uint64) uint64 {
+			a, b := kind.extend(cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false), vc.sew)
+			return a*b + cpu.readVElem(vd, i, dsew)
+		})
+	}
+}
+
+// vNarrow builds SEW vd[i] = op(2*SEW vs2[i], src1)
+func vNarrow(op vIntOp) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		ok := 2*vc.sew <= cpu.vcfg.ELEN && vAligned(inst.rs2(), 2*vc.lmul8) &&
+			vAligned(inst.rd(), vc.lmul8) && !vMaskOverlap(inst, inst.rd()) &&
+			(!vVectorSrc1(inst) || vAligned(inst.rs1(), vc.lmul8))
+		if !ok {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+		cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+			return op(cpu, cpu.readVElem(inst.rs2(), i, 2*vc.sew), cpu.vSrc1(inst, i, vc.sew, true), vc.sew)
+		})
+	}
+}
+
+// vCompare builds mask vd[i] = op(vs2[i], src1)
+func vCompare(op func(a, b, sew uint64) bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vCheckRegs(inst, vc, true) {
+			return
+		}
+		cpu.vForEachMask(inst, vc, inst.rd(), func(i uint64) bool {
+			return op(cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false), vc.sew)
+		})
+	}
+}
+
+func vEq(a, b, sew uint64) bool  { return a == b }
+func vNe(a, b, sew uint64) bool  { return a != b }
+func vLtu(a, b, sew uint64) bool { return a < b }
+func vLt(a, b, sew uint64) bool  { return vSext(a, sew) < vSext(b, sew) }
+func vLeu(a, b, sew uint64) bool { return a <= b }
+func vLe(a, b, sew uint64) bool  { return vSext(a, sew) <= vSext(b, sew) }
+func vGtu(a, b, sew uint64) bool { return a > b }
+func vGt(a, b, sew uint64) bool  { return vSext(a, sew) > vSext(b, sew) }
+
+// vAddCarry builds vadc/vsbc: vd[i] = vs2[i] ± src1 ± v0[i]
+func vAddCarry(sub bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		// немаскированная форма зарезервирована
+		if inst.x(25, 1) != 0 {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+		if !cpu.vCheckRegs(inst, vc, false) {
+			return
+		}
+		for i := cpu.csr[VSTART]; i < cpu.csr[VL]; i++ {
+			a, b := cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false)
+			var c uint64
+			if cpu.readVMask(0, i) {
+				c = 1
+			}
+			if sub {
+				cpu.writeVElem(inst.rd(), i, vc.sew, a-b-c)
+			} else {
+				cpu.writeVElem(inst.rd(), i, vc.sew, a+b+c)
+			}
+		}
+		cpu.vTail(vc, inst.rd(), vc.sew)
+	}
+}
+
+// vCarryOut builds vmadc/vmsbc: carry or borrow out of vs2[i] ± src1 ± v0[i],
+// carry-in is used only in the vm=0 form
+func vCarryOut(sub bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if !cpu.vCheckRegs(inst, vc, true) {
+			return
+		}
+		for i := cpu.csr[VSTART]; i < cpu.csr[VL]; i++ {
+			a, b := cpu.readVElem(inst.rs2(), i, vc.sew), cpu.vSrc1(inst, i, vc.sew, false)
+			var c uint64
+			if inst.x(25, 1) == 0 && cpu.readVMask(0, i) {
+				c = 1
+			}
+			var res, out uint64
+			if sub {
+				res, out = bits.Sub64(a, b, c)
+			} else {
+				res, out = bits.Add64(a, b, c)
+			}
+			if vc.sew < 64 {
+				out = res >> vc.sew & 1
+			}
+			cpu.writeVMask(inst.rd(), i, out != 0)
+		}
+		if cpu.vcfg.AgnosticOnes {
+			for i := cpu.csr[VL]; i < cpu.vcfg.VLEN; i++ {
+				cpu.writeVMask(inst.rd(), i, true)
+			}
+		}
+	}
+}
+
+// vmerge implements vmerge.vvm/vxm/vim and vmv.v.v/x/i (vm=1, vs2=v0)
+func vmerge(cpu *Cpu, inst InstWord, vc vconf) {
+	vm := inst.x(25, 1)
+	if vm == 1 && inst.rs2() != 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if !cpu.vCheckRegs(inst, vc, false) {
+		return
+	}
+	for i := cpu.csr[VSTART]; i < cpu.csr[VL]; i++ {
+		val := cpu.vSrc1(inst, i, vc.sew, false)
+		if vm == 0 && !cpu.readVMask(0, i) {
+			val = cpu.readVElem(inst.rs2(), i, vc.sew)
+		}
+		cpu.writeVElem(inst.rd(), i, vc.sew, val)
+	}
+	cpu.vTail(vc, inst.rd(), vc.sew)
+}
+
+// Single-width integer operations
+
+func vAdd(cpu *Cpu, a, b, sew uint64) uint64  { return a + b }
+func vSub(cpu *Cpu, a, b, sew uint64) uint64  { return a - b }
+func vRsub(cpu *Cpu, a, b, sew uint64) uint64 { return b - a }
+func vAnd(cpu *Cpu, a, b, sew uint64) uint64  { return a & b }
+func vOr(cpu *Cpu, a, b, sew uint64) uint64   { return a | b }
+func vXor(cpu *Cpu, a, b, sew uint64) uint64  { return a ^ b }
+func vMinu(cpu *Cpu, a, b, sew uint64) uint64 { return min(a, b) }
+func vMaxu(cpu *Cpu, a, b, sew uint64) uint64 { return max(a, b) }
+func vMul(cpu *Cpu, a, b, sew uint64) uint64  { return a * b }
+func vSll(cpu *Cpu, a, b, sew uint64) uint64  { return a << (b & (sew - 1)) }
+func vSrl(cpu *Cpu, a, b, sew uint64) uint64  { return a >> (b & (sew - 1)) }
+
+func vSra(cpu *Cpu, a, b, sew uint64) uint64 {
+	return uint64(vSext(a, sew) >> (b & (sew - 1)))
+}
+
+func vMin(cpu *Cpu, a, b, sew uint64) uint64 {
+	if vSext(a, sew) < vSext(b, sew) {
+		return a
+	}
+	return b
+}
+
+func vMax(cpu *Cpu, a, b, sew uint64) uint64 {
+	if vSext(a, sew) > vSext(b, sew) {
+		return a
+	}
+	return b
+}
+
+func vMulh(cpu *Cpu, a, b, sew uint64) uint64 {
+	if sew == 64 {
+		return mulh(int64(a), int64(b))
+	}
+	return uint64(vSext(a, sew) * vSext(b, sew) >> sew)
+}
+
+func vMulhu(cpu *Cpu, a, b, sew uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	if sew == 64 {
+		return hi
+	}
+	return lo >> sew
+}
+
+// vMulhsu multiplies signed vs2 by unsigned vs1
+func vMulhsu(cpu *Cpu, a, b, sew uint64) uint64 {
+	if sew == 64 {
+		return mulhsu(int64(a), b)
+	}
+	return uint64(vSext(a, sew) * int64(b) >> sew)
+}
+
+// Division by zero and overflow follow the scalar M extension
+
+func vDivu(cpu *Cpu, a, b, sew uint64) uint64 {
+	if b == 0 {
+		return ^uint64(0)
+	}
+	return a / b
+}
+
+func vRemu(cpu *Cpu, a, b, sew uint64) uint64 {
+	if b == 0 {
+		return a
+	}
+	return a % b
+}
+
+func vDiv(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	switch {
+	case y == 0:
+		return ^uint64(0)
+	case y == -1 && x == -1<<(sew-1):
+		return a
+	}
+	return uint64(x / y)
+}
+
+func vRem(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	switch {
+	case y == 0:
+		return a
+	case y == -1:
+		return 0
+	}
+	return uint64(x % y)
+}
+
+// Fixed-point operations
+
+func (cpu *Cpu) setVxsat() {
+	cpu.csr[VCSR] |= VCSR_VXSAT
+}
+
+// vRoundInc returns the rounding increment for a value shifted right:
+// out is the shifted value, half the most significant discarded bit,
+// sticky the rest of discarded bits
+func vRoundInc(vxrm, out uint64, half, sticky bool) uint64 {
+	var inc bool
+	switch vxrm {
+	case VXRM_RNU:
+		inc = half
+	case VXRM_RNE:
+		inc = half && (sticky || out&1 != 0)
+	case VXRM_ROD:
+		inc = out&1 == 0 && (half || sticky)
+	}
+	if inc {
+		return 1
+	}
+	return 0
+}
+
+func (cpu *Cpu) vxrm() uint64 {
+	return cpu.readCSR(VXRM)
+}
+
+// vShiftRound shifts val right by sh with vxrm rounding, signed values
+// are shifted arithmetically
+func (cpu *Cpu) vShiftRound(val, sh uint64, signed bool) uint64 {
+	if sh == 0 {
+		return val
+	}
+	out := val >> sh
+	if signed {
+		out = uint64(int64(val) >> sh)
+	}
+	half := val>>(sh-1)&1 != 0
+	sticky := sh > 1 && val&(1<<(sh-1)-1) != 0
+	return out + vRoundInc(cpu.vxrm(), out, half, sticky)
+}
+
+func vSaddu(cpu *Cpu, a, b, sew uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 || sum > vMask(sew) {
+		cpu.setVxsat()
+		return vMask(sew)
+	}
+	return sum
+}
+
+func vSsubu(cpu *Cpu, a, b, sew uint64) uint64 {
+	if a < b {
+		cpu.setVxsat()
+		return 0
+	}
+	return a - b
+}
+
+// vSaturate clamps a signed result to sew bits, overflow is the
+// direction of 64-bit overflow if it happened
+func (cpu *Cpu) vSaturate(val int64, overflow int, sew uint64) uint64 {
+	hi, lo := int64(1)<<(sew-1)-1, int64(-1)<<(sew-1)
+	switch {
+	case overflow > 0 || overflow == 0 && val > hi:
+		cpu.setVxsat()
+		return uint64(hi)
+	case overflow < 0 || overflow == 0 && val < lo:
+		cpu.setVxsat()
+		return uint64(lo)
+	}
+	return uint64(val)
+}
+
+func vSadd(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	s := x + y
+	overflow := 0
+	if (s^x)&(s^y) < 0 {
+		overflow = 1
+		if x < 0 {
+			overflow = -1
+		}
+	}
+	return cpu.vSaturate(s, overflow, sew)
+}
+
+func vSsub(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	s := x - y
+	overflow := 0
+	if (x^y)&(s^x) < 0 {
+		overflow = 1
+		if x < 0 {
+			overflow = -1
+		}
+	}
+	return cpu.vSaturate(s, overflow, sew)
+}
+
+// Averaging operations compute (a ± b) >> 1 without losing the carry
+
+func vAaddu(cpu *Cpu, a, b, sew uint64) uint64 {
+	out := a>>1 + b>>1 + a&b&1
+	return out + vRoundInc(cpu.vxrm(), out, (a^b)&1 != 0, false)
+}
+
+func vAadd(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	out := uint64(x>>1 + y>>1 + x&y&1)
+	return out + vRoundInc(cpu.vxrm(), out, (x^y)&1 != 0, false)
+}
+
+func vAsubu(cpu *Cpu, a, b, sew uint64) uint64 {
+	out := a>>1 - b>>1 - ^a&b&1
+	return out + vRoundInc(cpu.vxrm(), out, (a^b)&1 != 0, false)
+}
+
+func vAsub(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	out := uint64(x>>1 - y>>1 - ^x&y&1)
+	return out + vRoundInc(cpu.vxrm(), out, (x^y)&1 != 0, false)
+}
+
+// vSmul computes (a*b) >> (sew-1) with rounding and saturation
+func vSmul(cpu *Cpu, a, b, sew uint64) uint64 {
+	x, y := vSext(a, sew), vSext(b, sew)
+	minVal := int64(-1) << (sew - 1)
+	if x == minVal && y == minVal {
+		cpu.setVxsat()
+		return uint64(-(minVal + 1))
+	}
+	hi, lo := bits.Mul64(uint64(x), uint64(y))
+	hi = hi - uint64(x>>63&y) - uint64(y>>63&x)
+	sh := sew - 1
+	out := lo>>sh | hi<<(64-sh)
+	half := lo>>(sh-1)&1 != 0
+	sticky := lo&(1<<(sh-1)-1) != 0
+	return out + vRoundInc(cpu.vxrm(), out, half, sticky)
+}
+
+func vSsrl(cpu *Cpu, a, b, sew uint64) uint64 {
+	return cpu.vShiftRound(a, b&(sew-1), false)
+}
+
+func vSsra(cpu *Cpu, a, b, sew uint64) uint64 {
+	return cpu.vShiftRound(uint64(vSext(a, sew)), b&(sew-1), true)
+}
+
+// Narrowing operations, a has 2*sew bits
+
+func vNsrl(cpu *Cpu, a, b, sew uint64) uint64 {
+	return a >> (b & (2*sew - 1))
+}
+
+func vNsra(cpu *Cpu, a, b, sew uint64) uint64 {
+	return uint64(vSext(a, 2*sew) >> (b & (2*sew - 1)))
+}
+
+func vNclipu(cpu *Cpu, a, b, sew uint64) uint64 {
+	res := cpu.vShiftRound(a, b&(2*sew-1), false)
+	if res > vMask(sew) {
+		cpu.setVxsat()
+		return vMask(sew)
+	}
+	return res
+}
+
+func vNclip(cpu *Cpu, a, b, sew uint64) uint64 {
+	res := int64(cpu.vShiftRound(uint64(vSext(a, 2*sew)), b&(2*sew-1), true))
+	return cpu.vSaturate(res, 0, sew)
+}
+
+// vReduce builds reductions vd[0] = op(vs1[0], active vs2[*]),
+// widening reductions accumulate 2*SEW with extended vs2
+func vReduce(op vIntOp, widen vWidenKind) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		dsew := vc.sew
+		if widen != VWIDEN_NONE {
+			dsew = 2 * vc.sew
+		}
+		if cpu.csr[VSTART] != 0 || dsew > cpu.vcfg.ELEN || !vAligned(inst.rs2(), vc.lmul8) {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+		if cpu.csr[VL] == 0 {
+			return
+		}
+		acc := cpu.readVElem(inst.rs1(), 0, dsew)
+		for i := uint64(0); i < cpu.csr[VL]; i++ {
+			if cpu.vActive(inst, i) {
+				elem, _ := widen.extend(cpu.readVElem(inst.rs2(), i, vc.sew), 0, vc.sew)
+				acc = op(cpu, elem&vMask(dsew), acc, dsew) & vMask(dsew)
+			}
+		}
+		cpu.vReduceResult(vc, inst.rd(), dsew, acc)
+	}
+}
+
+// vReduceResult writes a scalar result to element 0, the rest is tail
+func (cpu *Cpu) vReduceResult(vc vconf, vd, eew, val uint64) {
+	cpu.writeVElem(vd, 0, eew, val)
+	if vc.ta {
+		for i := uint64(1); i < cpu.vcfg.VLEN/eew; i++ {
+			cpu.vAgnostic(vd, i, eew)
+		}
+	}
+}
+
+// vMaskLogic builds mask-register logical instructions vd = op(vs2, vs1)
+func vMaskLogic(op func(a, b bool) bool) vectorOp {
+	return func(cpu *Cpu, inst InstWord, vc vconf) {
+		if inst.x(25, 1) == 0 {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+		cpu.vForEachMask(inst, vc, inst.rd(), func(i uint64) bool {
+			return op(cpu.readVMask(inst.rs2(), i), cpu.readVMask(inst.rs1(), i))
+		})
+	}
+}
+
+// vwxunary0 implements vmv.x.s, vcpop.m and vfirst.m
+func vwxunary0(cpu *Cpu, inst InstWord, vc vconf) {
+	switch inst.rs1() {
+	case 0x00:
+		if inst.x(25, 1) == 0 {
+			break
+		}
+		cpu.writeReg(inst.rd(), uint64(vSext(cpu.readVElem(inst.rs2(), 0, vc.sew), vc.sew)))
+		return
+	case 0x10:
+		var n uint64
+		for i := uint64(0); i < cpu.csr[VL]; i++ {
+			if cpu.vActive(inst, i) && cpu.readVMask(inst.rs2(), i) {
+				n++
+			}
+		}
+		cpu.writeReg(inst.rd(), n)
+		return
+	case 0x11:
+		first := ^uint64(0)
+		for i := uint64(0); i < cpu.csr[VL]; i++ {
+			if cpu.vActive(inst, i) && cpu.readVMask(inst.rs2(), i) {
+				first = i
+				break
+			}
+		}
+		cpu.writeReg(inst.rd(), first)
+		return
+	}
+	cpu.IllegalInst(uint32(inst))
+}
+
+// vmvSX implements vmv.s.x
+func vmvSX(cpu *Cpu, inst InstWord, vc vconf) {
+	if inst.rs2() != 0 || inst.x(25, 1) == 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if cpu.csr[VSTART] < cpu.csr[VL] {
+		cpu.vReduceResult(vc, inst.rd(), vc.sew, cpu.readReg(inst.rs1()))
+	}
+}
+
+// vxunary0 implements vzext.vf2/4/8 and vsext.vf2/4/8
+func vxunary0(cpu *Cpu, inst InstWord, vc vconf) {
+	var frac uint64
+	switch inst.rs1() >> 1 {
+	case 1:
+		frac = 8
+	case 2:
+		frac = 4
+	case 3:
+		frac = 2
+	}
+	signed := inst.rs1()&1 != 0
+	if frac == 0 || vc.sew/frac < 8 || !vAligned(inst.rs2(), vc.lmul8/frac) ||
+		!vAligned(inst.rd(), vc.lmul8) || vMaskOverlap(inst, inst.rd()) {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	eew := vc.sew / frac
+	cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+		val := cpu.readVElem(inst.rs2(), i, eew)
+		if signed {
+			return uint64(vSext(val, eew))
+		}
+		return val
+	})
+}
+
+// vmunary0 implements vmsbf, vmsof, vmsif, viota and vid
+func vmunary0(cpu *Cpu, inst InstWord, vc vconf) {
+	vd, vs2 := inst.rd(), inst.rs2()
+	switch inst.rs1() {
+	case 0x01, 0x02, 0x03:
+		if cpu.csr[VSTART] != 0 || vd == vs2 || vMaskOverlap(inst, vd) {
+			break
+		}
+		kind := inst.rs1()
+		found := false
+		cpu.vForEachMask(inst, vc, vd, func(i uint64) bool {
+			bit := cpu.readVMask(vs2, i)
+			var res bool
+			switch kind {
+			case 0x01: // vmsbf: до первого установленного бита
+				res = !found && !bit
+			case 0x02: // vmsof: только первый установленный бит
+				res = !found && bit
+			case 0x03: // vmsif: включая первый установленный бит
+				res = !found
+			}
+			found = found || bit
+			return res
+		})
+		return
+	case 0x10:
+		if cpu.csr[VSTART] != 0 || !vAligned(vd, vc.lmul8) || vMaskOverlap(inst, vd) {
+			break
+		}
+		var count uint64
+		cpu.vForEach(inst, vc, vd, vc.sew, func(i uint64) uint64 {
+			res := count
+			if cpu.readVMask(vs2, i) {
+				count++
+			}
+			return res
+		})
+		return
+	case 0x11:
+		if vs2 != 0 || !vAligned(vd, vc.lmul8) || vMaskOverlap(inst, vd) {
+			break
+		}
+		cpu.vForEach(inst, vc, vd, vc.sew, func(i uint64) uint64 { return i })
+		return
+	}
+	cpu.IllegalInst(uint32(inst))
+}
+
+// vcompress packs elements of vs2 selected by mask vs1
+func vcompress(cpu *Cpu, inst InstWord, vc vconf) {
+	vd := inst.rd()
+	if cpu.csr[VSTART] != 0 || inst.x(25, 1) == 0 || !vAligned(vd, vc.lmul8) ||
+		!vAligned(inst.rs2(), vc.lmul8) || vd == inst.rs2() || vd == inst.rs1() {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	var n uint64
+	for i := uint64(0); i < cpu.csr[VL]; i++ {
+		if cpu.readVMask(inst.rs1(), i) {
+			cpu.writeVElem(vd, n, vc.sew, cpu.readVElem(inst.rs2(), i, vc.sew))
+			n++
+		}
+	}
+	if vc.ta {
+		for i := n; i < max(vc.vlmax, cpu.vcfg.VLEN/vc.sew); i++ {
+			cpu.vAgnostic(vd, i, vc.sew)
+		}
+	}
+}
+
+// Permutation instructions
+
+func (cpu *Cpu) vPermuteCheck(inst InstWord, vc vconf) bool {
+	vd := inst.rd()
+	if !cpu.vCheckRegs(inst, vc, false) || vd == inst.rs2() || (vVectorSrc1(inst) && vd == inst.rs1()) {
+		cpu.IllegalInst(uint32(inst))
+		return false
+	}
+	return true
+}
+
+// vslideup: vd[i+off] = vs2[i], elements below off are unchanged
+func vslideup(cpu *Cpu, inst InstWord, vc vconf) {
+	if !cpu.vPermuteCheck(inst, vc) {
+		return
+	}
+	off := cpu.vSrc1(inst, 0, 64, true)
+	start := max(cpu.csr[VSTART], min(off, cpu.csr[VL]))
+	cpu.vForEachFrom(inst, vc, inst.rd(), vc.sew, start, func(i uint64) uint64 {
+		return cpu.readVElem(inst.rs2(), i-off, vc.sew)
+	})
+}
+
+// vslidedown: vd[i] = vs2[i+off], zero past VLMAX
+func vslidedown(cpu *Cpu, inst InstWord, vc vconf) {
+	if !cpu.vCheckRegs(inst, vc, false) {
+		return
+	}
+	off := cpu.vSrc1(inst, 0, 64, true)
+	vs2 := inst.rs2()
+	cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+		if i+off < i || i+off >= vc.vlmax {
+			return 0
+		}
+		return cpu.readVElem(vs2, i+off, vc.sew)
+	})
+}
+
+// vSlide1 implements vslide1up/down and vfslide1up/down, the scalar
+// fills the vacated element
+func (cpu *Cpu) vSlide1(inst InstWord, vc vconf, up bool, scalar uint64) {
+	if up && !cpu.vPermuteCheck(inst, vc) || !up && !cpu.vCheckRegs(inst, vc, false) {
+		return
+	}
+	vs2, vl := inst.rs2(), cpu.csr[VL]
+	cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+		switch {
+		case up && i == 0, !up && i == vl-1:
+			return scalar
+		case up:
+			return cpu.readVElem(vs2, i-1, vc.sew)
+		}
+		return cpu.readVElem(vs2, i+1, vc.sew)
+	})
+}
+
+func vslide1up(cpu *Cpu, inst InstWord, vc vconf) {
+	cpu.vSlide1(inst, vc, true, cpu.readReg(inst.rs1()))
+}
+
+func vslide1down(cpu *Cpu, inst InstWord, vc vconf) {
+	cpu.vSlide1(inst, vc, false, cpu.readReg(inst.rs1()))
+}
+
+// vrgather: vd[i] = vs2[index], zero for indices not below VLMAX
+func vrgather(cpu *Cpu, inst InstWord, vc vconf) {
+	if !cpu.vPermuteCheck(inst, vc) {
+		return
+	}
+	cpu.vGather(inst, vc, func(i uint64) uint64 {
+		if inst.x(12, 3) == OPIVX {
+			return cpu.readReg(inst.rs1())
+		}
+		return cpu.vSrc1(inst, i, vc.sew, true)
+	})
+}
+
+// vrgatherei16 uses 16-bit indices regardless of SEW
+func vrgatherei16(cpu *Cpu, inst InstWord, vc vconf) {
+	idxMul := 16 * vc.lmul8 / vc.sew
+	vd := inst.rd()
+	if !vAligned(vd, vc.lmul8) || !vAligned(inst.rs2(), vc.lmul8) || !vAligned(inst.rs1(), idxMul) ||
+		vMaskOverlap(inst, vd) || vd == inst.rs2() || vd == inst.rs1() {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	cpu.vGather(inst, vc, func(i uint64) uint64 {
+		return cpu.readVElem(inst.rs1(), i, 16)
+	})
+}
+
+func (cpu *Cpu) vGather(inst InstWord, vc vconf, index func(i uint64) uint64) {
+	vs2 := inst.rs2()
+	cpu.vForEach(inst, vc, inst.rd(), vc.sew, func(i uint64) uint64 {
+		if idx := index(i); idx < vc.vlmax {
+			return cpu.readVElem(vs2, idx, vc.sew)
+		}
+		return 0
+	})
+}
+
+// vmvNr implements vmv1r.v, vmv2r.v, vmv4r.v and vmv8r.v
+func vmvNr(cpu *Cpu, inst InstWord, vc vconf) {
+	nr := inst.x(15, 5) + 1
+	vd, vs2 := inst.rd(), inst.rs2()
+	if nr&(nr-1) != 0 || nr > 8 || vd%nr != 0 || vs2%nr != 0 || inst.x(25, 1) == 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	evl := nr * cpu.vcfg.VLEN / vc.sew
+	for i := cpu.csr[VSTART]; i < evl; i++ {
+		cpu.writeVElem(vd, i, vc.sew, cpu.readVElem(vs2, i, vc.sew))
+	}
+}
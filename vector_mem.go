@@ -0,0 +1,211 @@
+package main
+
+// Addressing modes of vector loads and stores (mop field)
+const (
+	VMOP_UNIT            uint64 = 0
+	VMOP_INDEXED         uint64 = 1
+	VMOP_STRIDED         uint64 = 2
+	VMOP_INDEXED_ORDERED uint64 = 3
+)
+
+// Unit-stride variants (lumop/sumop field)
+const (
+	VUMOP_UNIT  uint64 = 0x00
+	VUMOP_WHOLE uint64 = 0x08
+	VUMOP_MASK  uint64 = 0x0b
+	VUMOP_FF    uint64 = 0x10
+)
+
+// vmemWidth maps the width field of vector loads and stores to EEW,
+// other values encode scalar FP loads and stores
+func vmemWidth(width uint64) uint64 {
+	switch width {
+	case 0:
+		return 8
+	case 5:
+		return 16
+	case 6:
+		return 32
+	case 7:
+		return 64
+	}
+	return 0
+}
+
+func (cpu *Cpu) vload(inst InstWord)  { cpu.vmem(inst, false) }
+func (cpu *Cpu) vstore(inst InstWord) { cpu.vmem(inst, true) }
+
+// vmem decodes a vector load or store, vd is vs3 for stores
+func (cpu *Cpu) vmem(inst InstWord, store bool) {
+	if !cpu.vsCheck(inst) {
+		return
+	}
+	eew := vmemWidth(inst.x(12, 3))
+	if inst.x(28, 1) != 0 || eew > cpu.vcfg.ELEN {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	nf := inst.x(29, 3) + 1
+	ff := false
+	if inst.x(26, 2) == VMOP_UNIT {
+		switch inst.x(20, 5) {
+		case VUMOP_UNIT:
+		case VUMOP_WHOLE:
+			cpu.vmemWhole(inst, eew, nf, store)
+			return
+		case VUMOP_MASK:
+			cpu.vmemMask(inst, eew, nf, store)
+			return
+		case VUMOP_FF:
+			ff = !store
+			if store {
+				cpu.IllegalInst(uint32(inst))
+				return
+			}
+		default:
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+	}
+	if vc, ok := cpu.vtypeCheck(inst); ok {
+		cpu.vmemElements(inst, vc, eew, nf, ff, store)
+	}
+}
+
+// vmemElements performs unit-stride, strided and indexed accesses.
+// Segments of nf fields go to consecutive register groups. On a fault
+// vstart keeps the index of the faulting element so the instruction
+// can be restarted after the trap.
+func (cpu *Cpu) vmemElements(inst InstWord, vc vconf, eew, nf uint64, ff, store bool) {
+	mop := inst.x(26, 2)
+	indexed := mop == VMOP_INDEXED || mop == VMOP_INDEXED_ORDERED
+	// для индексных обращений eew задаёт ширину индексов,
+	// данные имеют ширину SEW
+	dataEew, emul8 := eew, eew*vc.lmul8/vc.sew
+	if indexed {
+		dataEew, emul8 = vc.sew, vc.lmul8
+		if !vAligned(inst.rs2(), eew*vc.lmul8/vc.sew) {
+			cpu.IllegalInst(uint32(inst))
+			return
+		}
+	}
+	vd, regs := inst.rd(), vRegs(emul8)
+	if !vAligned(vd, emul8) || nf*regs > 8 || vd+nf*regs > 32 || (!store && vMaskOverlap(inst, vd)) {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	base := cpu.readReg(inst.rs1())
+	stride := cpu.readReg(inst.rs2())
+	for i := cpu.csr[VSTART]; i < cpu.csr[VL]; i++ {
+		if !cpu.vActive(inst, i) {
+			for f := uint64(0); f < nf && !store && vc.ma; f++ {
+				cpu.vAgnostic(vd+f*regs, i, dataEew)
+			}
+			continue
+		}
+		for f := uint64(0); f < nf; f++ {
+			var addr uint64
+			switch {
+			case indexed:
+				addr = base + cpu.readVElem(inst.rs2(), i, eew) + f*dataEew/8
+			case mop == VMOP_STRIDED:
+				addr = base + i*stride + f*dataEew/8
+			default:
+				addr = base + (i*nf+f)*dataEew/8
+			}
+			reg := vd + f*regs
+			if store {
+				if !cpu.store(addr, cpu.readVElem(reg, i, dataEew), uint8(dataEew)) {
+					cpu.csr[VSTART] = i
+					return
+				}
+				continue
+			}
+			val, ok := cpu.load(addr, uint8(dataEew))
+			if !ok {
+				if ff && i > 0 {
+					// fault-only-first: исключение только для первого
+					// элемента, иначе укорачиваем vl
+					cpu.exception = nil
+					cpu.csr[VL] = i
+					break
+				}
+				cpu.csr[VSTART] = i
+				return
+			}
+			cpu.writeVElem(reg, i, dataEew, val)
+		}
+	}
+	if !store {
+		for f := uint64(0); f < nf; f++ {
+			cpu.vTail(vc, vd+f*regs, dataEew)
+		}
+	}
+	cpu.csr[VSTART] = 0
+	cpu.markVSDirty()
+}
+
+// vmemWhole implements vl<nf>re<eew>.v and vs<nf>r.v, they ignore vtype and vl
+func (cpu *Cpu) vmemWhole(inst InstWord, eew, nf uint64, store bool) {
+	vd := inst.rd()
+	if nf&(nf-1) != 0 || vd%nf != 0 || inst.x(25, 1) == 0 || (store && eew != 8) {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	base := cpu.readReg(inst.rs1())
+	evl := nf * cpu.vcfg.VLEN / eew
+	for i := cpu.csr[VSTART]; i < evl; i++ {
+		addr := base + i*eew/8
+		if store {
+			if !cpu.store(addr, cpu.readVElem(vd, i, eew), uint8(eew)) {
+				cpu.csr[VSTART] = i
+				return
+			}
+			continue
+		}
+		val, ok := cpu.load(addr, uint8(eew))
+		if !ok {
+			cpu.csr[VSTART] = i
+			return
+		}
+		cpu.writeVElem(vd, i, eew, val)
+	}
+	cpu.csr[VSTART] = 0
+	cpu.markVSDirty()
+}
+
+// vmemMask implements vlm.v and vsm.v: ceil(vl/8) bytes, tail agnostic
+func (cpu *Cpu) vmemMask(inst InstWord, eew, nf uint64, store bool) {
+	if eew != 8 || nf != 1 || inst.x(25, 1) == 0 {
+		cpu.IllegalInst(uint32(inst))
+		return
+	}
+	if _, ok := cpu.vtypeCheck(inst); !ok {
+		return
+	}
+	vd := inst.rd()
+	base := cpu.readReg(inst.rs1())
+	evl := (cpu.csr[VL] + 7) / 8
+	for i := cpu.csr[VSTART]; i < evl; i++ {
+		if store {
+			if !cpu.store(base+i, cpu.readVElem(vd, i, 8), BYTE) {
+				cpu.csr[VSTART] = i
+				return
+			}
+			continue
+		}
+		val, ok := cpu.load(base+i, BYTE)
+		if !ok {
+			cpu.csr[VSTART] = i
+			return
+		}
+		cpu.writeVElem(vd, i, 8, val)
+	}
+	if !store {
+		for i := evl; i < cpu.vlenb(); i++ {
+			cpu.vAgnostic(vd, i, 8)
+		}
+	}
+	cpu.csr[VSTART] = 0
+	cpu.markVSDirty()
+}
@@ -0,0 +1,579 @@
+package main
+
+import "testing"
+
+// Кодировки vtype для vsetvli
+const (
+	E8M1   = 0 << 3
+	E16M1  = 1 << 3
+	E32M1  = 2 << 3
+	E64M1  = 3 << 3
+	E8MF8  = 0<<3 | 5
+	E16MF8 = 1<<3 | 5
+	E8M8   = 0<<3 | 3
+	E32M2  = 2<<3 | 1
+)
+
+// vInst encodes an OP-V instruction
+func vInst(funct6, vm, vs2, vs1 uint32, funct3 uint64, vd uint32) uint32 {
+	return funct6<<26 | vm<<25 | vs2<<20 | vs1<<15 | uint32(funct3)<<12 | vd<<7 | 0x57
+}
+
+func vsetvliInst(rd, rs1, vtypei uint32) uint32 {
+	return vtypei<<20 | rs1<<15 | 7<<12 | rd<<7 | 0x57
+}
+
+// vmemInst encodes a vector load or store, lumop goes to the rs2 field
+func vmemInst(nf, mop, vm, rs2, rs1, width, vd uint32, store bool) uint32 {
+	opcode := uint32(0x07)
+	if store {
+		opcode = 0x27
+	}
+	return nf<<29 | mop<<26 | vm<<25 | rs2<<20 | rs1<<15 | width<<12 | vd<<7 | opcode
+}
+
+func newVectorCpu() *Cpu {
+	cpu := NewCPU()
+	cpu.reset()
+	cpu.csr[MSTATUS] = EXT_STATUS_INITIAL<<MSTATUS_VS_SHIFT | EXT_STATUS_INITIAL<<MSTATUS_FS_SHIFT
+	return cpu
+}
+
+// vset configures vl and vtype through vsetvli
+func vset(t *testing.T, cpu *Cpu, vl uint64, vtypei uint32) {
+	cpu.writeReg(10, vl)
+	cpu.ExecuteInst(vsetvliInst(11, 10, vtypei))
+	if cpu.csr[VL] != vl {
+		t.Fatalf("vsetvli: vl=%d, want %d", cpu.csr[VL], vl)
+	}
+}
+
+func setVElems(cpu *Cpu, reg, eew uint64, vals []uint64) {
+	for i, val := range vals {
+		cpu.writeVElem(reg, uint64(i), eew, val)
+	}
+}
+
+func checkVElems(t *testing.T, name string, cpu *Cpu, reg, eew uint64, want []uint64) {
+	t.Helper()
+	for i, w := range want {
+		if got := cpu.readVElem(reg, uint64(i), eew); got != w {
+			t.Fatalf("%s: v%d[%d]=%#x, want %#x", name, reg, i, got, w)
+		}
+	}
+}
+
+func TestVsetvl(t *testing.T) {
+	tests := []struct {
+		name   string
+		vtypei uint32
+		avl    uint64
+		vl     uint64
+		vill   bool
+	}{
+		{"e32m1", E32M1, 10, 4, false},
+		{"e32m1 avl<vlmax", E32M1, 3, 3, false},
+		{"e8m8", E8M8, 1000, 128, false},
+		{"e32m2 tail agnostic", E32M2 | 1<<6, 100, 8, false},
+		{"e8mf8", E8MF8, 5, 2, false},
+		// SEW/LMUL больше ELEN
+		{"e16mf8", E16MF8, 5, 0, true},
+		{"reserved lmul", 4, 5, 0, true},
+		{"reserved sew", 4 << 3, 5, 0, true},
+		{"reserved bit", 1 << 8, 5, 0, true},
+	}
+	for _, test := range tests {
+		cpu := newVectorCpu()
+		cpu.writeReg(10, test.avl)
+		cpu.ExecuteInst(vsetvliInst(11, 10, test.vtypei))
+		if got := cpu.readReg(11); got != test.vl || cpu.csr[VL] != test.vl {
+			t.Fatalf("%s: rd=%d vl=%d, want %d", test.name, got, cpu.csr[VL], test.vl)
+		}
+		if vill := cpu.csr[VTYPE] == VTYPE_VILL; vill != test.vill {
+			t.Fatalf("%s: vtype=%#x, want vill %v", test.name, cpu.csr[VTYPE], test.vill)
+		}
+	}
+
+	cpu := newVectorCpu()
+	// rs1=x0 и rd!=x0 запрашивают VLMAX
+	cpu.ExecuteInst(vsetvliInst(11, 0, E16M1))
+	if cpu.readReg(11) != 8 {
+		t.Fatalf("vsetvli x0: vl=%d, want 8", cpu.readReg(11))
+	}
+	// vsetivli берёт AVL из поля uimm
+	cpu.ExecuteInst(0xc0007057 | E64M1<<20 | 3<<15 | 11<<7)
+	if cpu.readReg(11) != 2 || cpu.csr[VTYPE] != E64M1 {
+		t.Fatalf("vsetivli: vl=%d vtype=%#x", cpu.readReg(11), cpu.csr[VTYPE])
+	}
+	if cpu.csr[MSTATUS]&MSTATUS_VS != EXT_STATUS_DIRTY<<MSTATUS_VS_SHIFT {
+		t.Fatal("vsetivli: mstatus.VS is not dirty")
+	}
+
+	// при выключенном VS векторные инструкции недопустимы
+	cpu.csr[MSTATUS] = 0
+	cpu.pc = DRAM_BASE
+	cpu.ExecuteInst(vsetvliInst(11, 0, E8M1))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("vsetvli with VS off: mcause=%d", cpu.csr[MCAUSE])
+	}
+}
+
+func TestVectorLoadStore(t *testing.T) {
+	const addr = DRAM_BASE + 0x1000
+	cpu := newVectorCpu()
+	for i := uint64(0); i < 16; i++ {
+		cpu.bus.Write(addr+4*i, 0x100+i, WORD)
+	}
+	cpu.writeReg(1, addr)
+
+	vset(t, cpu, 4, E32M1)
+	cpu.ExecuteInst(vmemInst(0, 0, 1, 0, 1, 6, 8, false)) // vle32.v v8, (x1)
+	checkVElems(t, "vle32.v", cpu, 8, 32, []uint64{0x100, 0x101, 0x102, 0x103})
+
+	cpu.writeReg(2, 8)
+	cpu.ExecuteInst(vmemInst(0, 2, 1, 2, 1, 6, 8, false)) // vlse32.v v8, (x1), x2
+	checkVElems(t, "vlse32.v", cpu, 8, 32, []uint64{0x100, 0x102, 0x104, 0x106})
+
+	setVElems(cpu, 4, 32, []uint64{12, 0, 60, 4})
+	cpu.ExecuteInst(vmemInst(0, 1, 1, 4, 1, 6, 8, false)) // vluxei32.v v8, (x1), v4
+	checkVElems(t, "vluxei32.v", cpu, 8, 32, []uint64{0x103, 0x100, 0x10f, 0x101})
+
+	// vlseg2e32.v: поля сегмента попадают в v8 и v9
+	cpu.ExecuteInst(vmemInst(1, 0, 1, 0, 1, 6, 8, false))
+	checkVElems(t, "vlseg2e32.v field 0", cpu, 8, 32, []uint64{0x100, 0x102, 0x104, 0x106})
+	checkVElems(t, "vlseg2e32.v field 1", cpu, 9, 32, []uint64{0x101, 0x103, 0x105, 0x107})
+
+	// vsse32.v v9 с шагом -4 в обратном порядке
+	cpu.writeReg(3, addr+0x100+12)
+	cpu.writeReg(2, ^uint64(3))
+	cpu.ExecuteInst(vmemInst(0, 2, 1, 2, 3, 6, 9, true))
+	for i, want := range []uint64{0x107, 0x105, 0x103, 0x101} {
+		if got := memRead(cpu, addr+0x100+4*uint64(i), WORD); got != want {
+			t.Fatalf("vsse32.v: mem[%d]=%#x, want %#x", i, got, want)
+		}
+	}
+
+	// маскированная загрузка с политикой mask agnostic
+	if err := cpu.ConfigureVector(VectorConfig{VLEN: 128, ELEN: 64, AgnosticOnes: true}); err != nil {
+		t.Fatal(err)
+	}
+	vset(t, cpu, 3, E32M1|1<<6|1<<7)
+	cpu.writeVElem(0, 0, 8, 0b101)
+	cpu.ExecuteInst(vmemInst(0, 0, 0, 0, 1, 6, 8, false)) // vle32.v v8, (x1), v0.t
+	checkVElems(t, "masked vle32.v", cpu, 8, 32, []uint64{0x100, 0xffffffff, 0x102, 0xffffffff})
+
+	// vsm.v сохраняет ceil(vl/8) байт маски
+	cpu.ExecuteInst(vmemInst(0, 0, 1, uint32(VUMOP_MASK), 3, 0, 0, true))
+	if got := memRead(cpu, addr+0x100+12, WORD); got&0xff != 0b101 || got>>8 != 0x1 {
+		t.Fatalf("vsm.v: mem=%#x", got)
+	}
+
+	// vl1re8.v и vs1r.v не зависят от vl
+	cpu.ExecuteInst(vmemInst(0, 0, 1, uint32(VUMOP_WHOLE), 1, 0, 16, false))
+	checkVElems(t, "vl1re8.v", cpu, 16, 32, []uint64{0x100, 0x101, 0x102, 0x103})
+}
+
+func TestVectorLoadFault(t *testing.T) {
+	// данные заканчиваются на втором элементе
+	const addr = DRAM_BASE + MEMORY_SIZE - 8
+	cpu := newVectorCpu()
+	cpu.pc = DRAM_BASE
+	cpu.bus.Write(addr, 0x2222222211111111, DOUBLEWORD)
+	cpu.writeReg(1, addr)
+	vset(t, cpu, 4, E32M1)
+	cpu.ExecuteInst(vmemInst(0, 0, 1, 0, 1, 6, 8, false))
+	if cpu.csr[MCAUSE] != uint64(LOAD_ACCESS_FAULT) || cpu.csr[MTVAL] != addr+8 {
+		t.Fatalf("vle32.v: mcause=%d mtval=%#x", cpu.csr[MCAUSE], cpu.csr[MTVAL])
+	}
+	if cpu.csr[VSTART] != 2 {
+		t.Fatalf("vle32.v: vstart=%d, want 2", cpu.csr[VSTART])
+	}
+	checkVElems(t, "vle32.v", cpu, 8, 32, []uint64{0x11111111, 0x22222222})
+
+	// перезапуск с vstart пропускает уже загруженные элементы
+	cpu.bus.Write(DRAM_BASE+0x100, 0x4444444433333333, DOUBLEWORD)
+	cpu.writeReg(1, DRAM_BASE+0x100-8)
+	cpu.ExecuteInst(vmemInst(0, 0, 1, 0, 1, 6, 8, false))
+	if cpu.csr[VSTART] != 0 {
+		t.Fatalf("restarted vle32.v: vstart=%d", cpu.csr[VSTART])
+	}
+	checkVElems(t, "restarted vle32.v", cpu, 8, 32, []uint64{0x11111111, 0x22222222, 0x33333333, 0x44444444})
+
+	// vle32ff.v укорачивает vl вместо исключения
+	cpu.csr[MCAUSE] = 0
+	cpu.writeReg(1, addr)
+	cpu.ExecuteInst(vmemInst(0, 0, 1, uint32(VUMOP_FF), 1, 6, 8, false))
+	if cpu.csr[MCAUSE] != 0 || cpu.csr[VL] != 2 {
+		t.Fatalf("vle32ff.v: mcause=%d vl=%d", cpu.csr[MCAUSE], cpu.csr[VL])
+	}
+}
+
+func TestVectorArith(t *testing.T) {
+	tests := []struct {
+		name   string
+		vtypei uint32
+		vl     uint64
+		inst   uint32 // vd=v8, vs2=v16, vs1=v24, rs1=x5
+		vs2    []uint64
+		vs1    []uint64
+		x      uint64
+		want   []uint64
+		wantEw uint64 // ширина результата, если отличается от SEW
+		vxsat  bool
+	}{
+		{"vadd.vv", E32M1, 4, vInst(0x00, 1, 16, 24, OPIVV, 8),
+			[]uint64{1, 2, 3, 0xffffffff}, []uint64{10, 20, 30, 1}, 0,
+			[]uint64{11, 22, 33, 0}, 0, false},
+		{"vadd.vx", E16M1, 3, vInst(0x00, 1, 16, 5, OPIVX, 8),
+			[]uint64{1, 2, 3}, nil, 0xfffe,
+			[]uint64{0xffff, 0, 1}, 0, false},
+		{"vrsub.vi", E8M1, 2, vInst(0x03, 1, 16, 0x1f, OPIVI, 8),
+			[]uint64{1, 0xff}, nil, 0,
+			[]uint64{0xfe, 0}, 0, false},
+		{"vsra.vi", E64M1, 2, vInst(0x29, 1, 16, 4, OPIVI, 8),
+			[]uint64{0x8000000000000000, 0x100}, nil, 0,
+			[]uint64{0xf800000000000000, 0x10}, 0, false},
+		{"vmul.vv", E32M1, 2, vInst(0x25, 1, 16, 24, OPMVV, 8),
+			[]uint64{0x10000, 7}, []uint64{0x10001, 6}, 0,
+			[]uint64{0x10000, 42}, 0, false},
+		{"vmulh.vx", E32M1, 2, vInst(0x27, 1, 16, 5, OPMVX, 8),
+			[]uint64{0x80000000, 0x40000000}, nil, 0xfffffffc,
+			[]uint64{2, 0xffffffff}, 0, false},
+		{"vdivu.vv by zero", E8M1, 2, vInst(0x20, 1, 16, 24, OPMVV, 8),
+			[]uint64{100, 100}, []uint64{0, 7}, 0,
+			[]uint64{0xff, 14}, 0, false},
+		{"vdiv.vx overflow", E16M1, 1, vInst(0x21, 1, 16, 5, OPMVX, 8),
+			[]uint64{0x8000}, nil, 0xffff,
+			[]uint64{0x8000}, 0, false},
+		{"vwaddu.vv", E8M1, 2, vInst(0x30, 1, 16, 24, OPMVV, 8),
+			[]uint64{0xff, 2}, []uint64{0xff, 3}, 0,
+			[]uint64{0x1fe, 5}, 16, false},
+		{"vwmul.vx", E16M1, 2, vInst(0x3b, 1, 16, 5, OPMVX, 8),
+			[]uint64{0xffff, 0x100}, nil, 0x100,
+			[]uint64{0xffffff00, 0x10000}, 32, false},
+		{"vsaddu.vv", E8M1, 2, vInst(0x20, 1, 16, 24, OPIVV, 8),
+			[]uint64{200, 10}, []uint64{100, 20}, 0,
+			[]uint64{0xff, 30}, 0, true},
+		{"vssub.vx", E8M1, 2, vInst(0x23, 1, 16, 5, OPIVX, 8),
+			[]uint64{0x80, 5}, nil, 1,
+			[]uint64{0x80, 4}, 0, true},
+		{"vaadd.vv", E8M1, 2, vInst(0x09, 1, 16, 24, OPMVV, 8),
+			[]uint64{5, 0x7f}, []uint64{2, 0x7f}, 0,
+			[]uint64{4, 0x7f}, 0, false},
+		{"vsmul.vv", E16M1, 2, vInst(0x27, 1, 16, 24, OPIVV, 8),
+			[]uint64{0x8000, 0x4000}, []uint64{0x8000, 0x4000}, 0,
+			[]uint64{0x7fff, 0x2000}, 0, true},
+		// сужающий сдвиг: vs2 имеет ширину 2*SEW
+		{"vnclipu.wi", E8M1, 2, vInst(0x2e, 1, 16, 2, OPIVI, 8),
+			[]uint64{0x0406, 0x0016}, nil, 0,
+			[]uint64{0xff, 0x06}, 0, true},
+		{"vredsum.vs", E32M1, 4, vInst(0x00, 1, 16, 24, OPMVV, 8),
+			[]uint64{1, 2, 3, 4}, []uint64{100}, 0,
+			[]uint64{110}, 0, false},
+		{"vredmax.vs", E16M1, 3, vInst(0x07, 1, 16, 24, OPMVV, 8),
+			[]uint64{0xffff, 5, 0x8000}, []uint64{0xfff0}, 0,
+			[]uint64{5}, 0, false},
+		{"vwredsumu.vs", E8M1, 3, vInst(0x30, 1, 16, 24, OPIVV, 8),
+			[]uint64{0xff, 0xff, 0xff}, []uint64{1}, 0,
+			[]uint64{0x2fe}, 16, false},
+		{"vzext.vf2", E32M1, 2, vInst(0x12, 1, 16, 6, OPMVV, 8),
+			[]uint64{0xffff, 0x8000}, nil, 0,
+			[]uint64{0xffff, 0x8000}, 0, false},
+		{"vmv.v.x", E64M1, 2, vInst(0x17, 1, 0, 5, OPIVX, 8),
+			nil, nil, 0x1234,
+			[]uint64{0x1234, 0x1234}, 0, false},
+	}
+	for _, test := range tests {
+		cpu := newVectorCpu()
+		vset(t, cpu, test.vl, test.vtypei)
+		sew := uint64(8) << (test.vtypei >> 3)
+		vs2Ew := sew
+		if test.name[:3] == "vnc" {
+			vs2Ew = 2 * sew
+		}
+		if test.name == "vzext.vf2" {
+			vs2Ew = sew / 2
+		}
+		setVElems(cpu, 16, vs2Ew, test.vs2)
+		setVElems(cpu, 24, sew, test.vs1)
+		cpu.writeReg(5, test.x)
+		cpu.ExecuteInst(test.inst)
+		if cpu.exception != nil {
+			t.Fatalf("%s: unexpected exception %v", test.name, cpu.exception)
+		}
+		eew := sew
+		if test.wantEw != 0 {
+			eew = test.wantEw
+		}
+		checkVElems(t, test.name, cpu, 8, eew, test.want)
+		if vxsat := cpu.readCSR(VXSAT) != 0; vxsat != test.vxsat {
+			t.Fatalf("%s: vxsat=%v, want %v", test.name, vxsat, test.vxsat)
+		}
+	}
+}
+
+func TestVectorFixedPointRounding(t *testing.T) {
+	// vssrl.vi v8, v16, 2 над 0b1010 = 2.5
+	tests := []struct {
+		vxrm uint64
+		want uint64
+	}{
+		{VXRM_RNU, 3},
+		{VXRM_RNE, 2},
+		{VXRM_RDN, 2},
+		{VXRM_ROD, 3},
+	}
+	for _, test := range tests {
+		cpu := newVectorCpu()
+		vset(t, cpu, 1, E8M1)
+		cpu.writeCSR(VXRM, test.vxrm)
+		cpu.writeVElem(16, 0, 8, 0b1010)
+		cpu.ExecuteInst(vInst(0x2a, 1, 16, 2, OPIVI, 8))
+		if got := cpu.readVElem(8, 0, 8); got != test.want {
+			t.Fatalf("vxrm=%d: got %d, want %d", test.vxrm, got, test.want)
+		}
+	}
+}
+
+func TestVectorMaskPermute(t *testing.T) {
+	cpu := newVectorCpu()
+	vset(t, cpu, 8, E8M1)
+	setVElems(cpu, 16, 8, []uint64{1, 5, 3, 5, 5, 0, 5, 2})
+
+	// vmseq.vi v1, v16, 5
+	cpu.ExecuteInst(vInst(0x18, 1, 16, 5, OPIVI, 1))
+	if got := cpu.readVElem(1, 0, 8); got != 0b01011010 {
+		t.Fatalf("vmseq.vi: mask=%#b", got)
+	}
+	// vcpop.m x6, v1 и vfirst.m x7, v1
+	cpu.ExecuteInst(vInst(0x10, 1, 1, 0x10, OPMVV, 6))
+	cpu.ExecuteInst(vInst(0x10, 1, 1, 0x11, OPMVV, 7))
+	if cpu.readReg(6) != 4 || cpu.readReg(7) != 1 {
+		t.Fatalf("vcpop=%d vfirst=%d", cpu.readReg(6), cpu.readReg(7))
+	}
+	// vmsbf.m v2, v1
+	cpu.ExecuteInst(vInst(0x14, 1, 1, 0x01, OPMVV, 2))
+	if got := cpu.readVElem(2, 0, 8); got != 0b1 {
+		t.Fatalf("vmsbf.m: mask=%#b", got)
+	}
+	// vmnand.mm v3, v1, v1
+	cpu.ExecuteInst(vInst(0x1d, 1, 1, 1, OPMVV, 3))
+	if got := cpu.readVElem(3, 0, 8); got != 0b10100101 {
+		t.Fatalf("vmnand.mm: mask=%#b", got)
+	}
+
+	// viota.m v8, v1
+	cpu.ExecuteInst(vInst(0x14, 1, 1, 0x10, OPMVV, 8))
+	checkVElems(t, "viota.m", cpu, 8, 8, []uint64{0, 0, 1, 1, 2, 3, 3, 4})
+
+	// vcompress.vm v8, v16, v3
+	cpu.ExecuteInst(vInst(0x17, 1, 16, 3, OPMVV, 8))
+	checkVElems(t, "vcompress.vm", cpu, 8, 8, []uint64{1, 3, 0, 2})
+
+	// vslideup.vi v8, v16, 3 не трогает первые три элемента
+	setVElems(cpu, 8, 8, []uint64{0xaa, 0xaa, 0xaa})
+	cpu.ExecuteInst(vInst(0x0e, 1, 16, 3, OPIVI, 8))
+	checkVElems(t, "vslideup.vi", cpu, 8, 8, []uint64{0xaa, 0xaa, 0xaa, 1, 5, 3, 5, 5})
+
+	// vslidedown.vi v8, v16, 6 заполняет нулями элементы за VLMAX
+	vset(t, cpu, 4, E8M1)
+	cpu.ExecuteInst(vInst(0x0f, 1, 16, 6, OPIVI, 8))
+	checkVElems(t, "vslidedown.vi", cpu, 8, 8, []uint64{5, 2, 0, 0})
+
+	// vslide1down.vx v8, v16, x5
+	cpu.writeReg(5, 0x77)
+	cpu.ExecuteInst(vInst(0x0f, 1, 16, 5, OPMVX, 8))
+	checkVElems(t, "vslide1down.vx", cpu, 8, 8, []uint64{5, 3, 5, 0x77})
+
+	// vrgather.vv v8, v16, v24 с индексом за VLMAX
+	setVElems(cpu, 24, 8, []uint64{7, 0, 200, 1})
+	cpu.ExecuteInst(vInst(0x0c, 1, 16, 24, OPIVV, 8))
+	checkVElems(t, "vrgather.vv", cpu, 8, 8, []uint64{2, 1, 0, 5})
+
+	// vrgather.vv с перекрытием vd и vs1 недопустим
+	cpu.pc = DRAM_BASE
+	cpu.ExecuteInst(vInst(0x0c, 1, 16, 8, OPIVV, 8))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("overlapping vrgather: mcause=%d", cpu.csr[MCAUSE])
+	}
+}
+
+func TestVectorMaskedArith(t *testing.T) {
+	cpu := newVectorCpu()
+	if err := cpu.ConfigureVector(VectorConfig{VLEN: 128, ELEN: 64, AgnosticOnes: true}); err != nil {
+		t.Fatal(err)
+	}
+	// vl=3 из 4: маскированный элемент и хвост
+	setVElems(cpu, 8, 32, []uint64{0xa, 0xb, 0xc, 0xd})
+	setVElems(cpu, 16, 32, []uint64{1, 2, 3, 4})
+	cpu.writeVElem(0, 0, 8, 0b101)
+
+	// undisturbed: tu, mu
+	vset(t, cpu, 3, E32M1)
+	cpu.ExecuteInst(vInst(0x00, 0, 16, 1, OPIVI, 8))
+	checkVElems(t, "vadd.vi tu mu", cpu, 8, 32, []uint64{2, 0xb, 4, 0xd})
+
+	// agnostic: ta, ma
+	vset(t, cpu, 3, E32M1|1<<6|1<<7)
+	cpu.ExecuteInst(vInst(0x00, 0, 16, 1, OPIVI, 8))
+	checkVElems(t, "vadd.vi ta ma", cpu, 8, 32, []uint64{2, 0xffffffff, 4, 0xffffffff})
+
+	// ненулевой vstart пропускает начальные элементы
+	setVElems(cpu, 8, 32, []uint64{0, 0, 0})
+	cpu.writeCSR(VSTART, 2)
+	cpu.ExecuteInst(vInst(0x00, 1, 16, 1, OPIVI, 8))
+	checkVElems(t, "vadd.vi vstart=2", cpu, 8, 32, []uint64{0, 0, 4})
+	if cpu.csr[VSTART] != 0 {
+		t.Fatalf("vstart=%d after instruction", cpu.csr[VSTART])
+	}
+
+	// vadc.vim v8, v16, 1, v0
+	cpu.ExecuteInst(vInst(0x10, 0, 16, 1, OPIVI, 8))
+	checkVElems(t, "vadc.vim", cpu, 8, 32, []uint64{3, 3, 5})
+
+	// vmadc.vv v1, v16, v24 без переноса на входе
+	setVElems(cpu, 24, 32, []uint64{0xffffffff, 0, 0xfffffffd})
+	cpu.ExecuteInst(vInst(0x11, 1, 16, 24, OPIVV, 1))
+	if got := cpu.readVElem(1, 0, 8) & 0b111; got != 0b101 {
+		t.Fatalf("vmadc.vv: mask=%#b", got)
+	}
+}
+
+func TestVectorFloat(t *testing.T) {
+	const (
+		one32   = 0x3f800000
+		two32   = 0x40000000
+		three32 = 0x40400000
+		half32  = 0x3f000000
+		qnan32  = 0x7fc00000
+		one64   = 0x3ff0000000000000
+		two64   = 0x4000000000000000
+	)
+	tests := []struct {
+		name   string
+		vtypei uint32
+		vl     uint64
+		inst   uint32 // vd=v8, vs2=v16, vs1=v24, rs1=f5
+		vs2    []uint64
+		vs1    []uint64
+		vd     []uint64
+		f      uint64
+		want   []uint64
+		wantEw uint64
+		flags  uint64
+	}{
+		{"vfadd.vv", E32M1, 2, vInst(0x00, 1, 16, 24, OPFVV, 8),
+			[]uint64{one32, two32}, []uint64{two32, one32}, nil, 0,
+			[]uint64{three32, three32}, 0, 0},
+		{"vfsub.vf", E64M1, 2, vInst(0x02, 1, 16, 5, OPFVF, 8),
+			[]uint64{two64, one64}, nil, nil, one64,
+			[]uint64{one64, 0}, 0, 0},
+		{"vfmacc.vf", E32M1, 2, vInst(0x2c, 1, 16, 5, OPFVF, 8),
+			[]uint64{one32, half32}, nil, []uint64{one32, two32}, two32,
+			[]uint64{three32, three32}, 0, 0},
+		{"vfmin.vv nan", E32M1, 1, vInst(0x04, 1, 16, 24, OPFVV, 8),
+			[]uint64{qnan32}, []uint64{two32}, nil, 0,
+			[]uint64{two32}, 0, 0},
+		{"vfsgnjn.vv", E64M1, 1, vInst(0x09, 1, 16, 24, OPFVV, 8),
+			[]uint64{one64}, []uint64{one64}, nil, 0,
+			[]uint64{0xbff0000000000000}, 0, 0},
+		{"vfwadd.vv", E32M1, 2, vInst(0x30, 1, 16, 24, OPFVV, 8),
+			[]uint64{one32, half32}, []uint64{one32, half32}, nil, 0,
+			[]uint64{two64, one64}, 64, 0},
+		{"vfredusum.vs", E32M1, 3, vInst(0x01, 1, 16, 24, OPFVV, 8),
+			[]uint64{one32, one32, half32}, []uint64{half32}, nil, 0,
+			[]uint64{three32}, 0, 0},
+		{"vfcvt.x.f.v", E32M1, 2, vInst(0x12, 1, 16, 0x01, OPFVV, 8),
+			[]uint64{0x40200000, 0xc0200000}, nil, nil, 0,
+			[]uint64{2, 0xfffffffe}, 0, FFLAGS_NX},
+		{"vfcvt.f.xu.v", E64M1, 1, vInst(0x12, 1, 16, 0x02, OPFVV, 8),
+			[]uint64{2}, nil, nil, 0,
+			[]uint64{two64}, 0, 0},
+		{"vfncvt.rod.f.f.w", E32M1, 1, vInst(0x12, 1, 16, 0x15, OPFVV, 8),
+			[]uint64{0x3ff0000000000001}, nil, nil, 0,
+			[]uint64{0x3f800001}, 0, FFLAGS_NX},
+		{"vfrec7.v", E32M1, 2, vInst(0x13, 1, 16, 0x05, OPFVV, 8),
+			[]uint64{two32, 0}, nil, nil, 0,
+			[]uint64{0x3eff0000, 0x7f800000}, 0, FFLAGS_DZ},
+		{"vfrsqrt7.v", E64M1, 1, vInst(0x13, 1, 16, 0x04, OPFVV, 8),
+			[]uint64{0x4010000000000000}, nil, nil, 0,
+			[]uint64{0x3fdfe00000000000}, 0, 0},
+		{"vfclass.v", E32M1, 2, vInst(0x13, 1, 16, 0x10, OPFVV, 8),
+			[]uint64{one32, qnan32}, nil, nil, 0,
+			[]uint64{1 << 6, 1 << 9}, 0, 0},
+	}
+	for _, test := range tests {
+		cpu := newVectorCpu()
+		vset(t, cpu, test.vl, test.vtypei)
+		sew := uint64(8) << (test.vtypei >> 3)
+		vs2Ew := sew
+		if test.name[:5] == "vfncv" {
+			vs2Ew = 2 * sew
+		}
+		setVElems(cpu, 16, vs2Ew, test.vs2)
+		setVElems(cpu, 24, sew, test.vs1)
+		setVElems(cpu, 8, sew, test.vd)
+		cpu.writeFReg(5, vfFmt(sew), test.f)
+		cpu.ExecuteInst(test.inst)
+		if cpu.exception != nil {
+			t.Fatalf("%s: unexpected exception %v", test.name, cpu.exception)
+		}
+		eew := sew
+		if test.wantEw != 0 {
+			eew = test.wantEw
+		}
+		checkVElems(t, test.name, cpu, 8, eew, test.want)
+		if got := cpu.readCSR(FFLAGS); got != test.flags {
+			t.Fatalf("%s: fflags=%#x, want %#x", test.name, got, test.flags)
+		}
+	}
+
+	// vmflt.vf v1, v16, f5
+	cpu := newVectorCpu()
+	vset(t, cpu, 3, E32M1)
+	setVElems(cpu, 16, 32, []uint64{one32, three32, qnan32})
+	cpu.writeFReg(5, FMT_S, two32)
+	cpu.ExecuteInst(vInst(0x1b, 1, 16, 5, OPFVF, 1))
+	if got := cpu.readVElem(1, 0, 8) & 0b111; got != 0b001 {
+		t.Fatalf("vmflt.vf: mask=%#b", got)
+	}
+	// сравнение с тихим NaN выставляет NV
+	if cpu.readCSR(FFLAGS) != FFLAGS_NV {
+		t.Fatalf("vmflt.vf: fflags=%#x", cpu.readCSR(FFLAGS))
+	}
+
+	// при выключенном FS векторные FP инструкции недопустимы
+	cpu.csr[MSTATUS] &^= MSTATUS_FS
+	cpu.pc = DRAM_BASE
+	cpu.ExecuteInst(vInst(0x00, 1, 16, 24, OPFVV, 8))
+	if cpu.csr[MCAUSE] != uint64(ILLEGAL_INSTRUCTION) {
+		t.Fatalf("vfadd.vv with FS off: mcause=%d", cpu.csr[MCAUSE])
+	}
+}
+
+func TestConfigureVector(t *testing.T) {
+	tests := []struct {
+		cfg VectorConfig
+		ok  bool
+	}{
+		{VectorConfig{VLEN: 128, ELEN: 64}, true},
+		{VectorConfig{VLEN: 1024, ELEN: 32}, true},
+		{VectorConfig{VLEN: 32, ELEN: 64}, false},
+		{VectorConfig{VLEN: 96, ELEN: 32}, false},
+		{VectorConfig{VLEN: 128, ELEN: 16}, false},
+	}
+	for _, test := range tests {
+		cpu := NewCPU()
+		if err := cpu.ConfigureVector(test.cfg); (err == nil) != test.ok {
+			t.Fatalf("%+v: err=%v", test.cfg, err)
+		}
+	}
+	cpu := NewCPU()
+	cpu.csr[MSTATUS] = EXT_STATUS_INITIAL << MSTATUS_VS_SHIFT
+	if err := cpu.ConfigureVector(VectorConfig{VLEN: 512, ELEN: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cpu.readCSR(VLENB); got != 64 {
+		t.Fatalf("vlenb=%d, want 64", got)
+	}
+}